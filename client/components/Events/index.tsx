@@ -1,4 +1,3 @@
-import { MemberCache } from "../../repository/MemberRepo";
 import EventRSVPButtonsRow from "./RSVPButtons";
 
 const weekday = {
@@ -24,36 +23,11 @@ export function EventLocation({location}) {
   )
 }
 
-function EventParticipantsRow({ row }) {
-  return <div className="flex -space-x-1 overflow-hidden">
-    {row.map(([id]: [string, any]) => {
-      const member = MemberCache.pick(id);
-      const picture = member?.slack?.profile?.image_512;
-      return (
-        <div
-          key={id}
-          className="inline-block h-4 w-4 rounded-full ring-1 ring-white bg-gray-300"
-          style={picture ? {backgroundImage: `url(${picture})`, backgroundSize: 'cover'} : {}}
-        />
-      );
-    })}
-  </div>;
-}
-
-function EventParticipantsIcons({pats, onClick = () => {}}) {
-  const entries = Object.entries(pats)
-    .filter(([_, p]: [string, any]) => p.type == 'join' || p.type == 'join_late') || [];
-  if (entries.length == 0) return null;
-  const chunk = 25;
-  const rows = entries.reduce((ctx, e, i) => {
-    if (i % chunk == 0) ctx.push(entries.slice(i, i+chunk));
-    return ctx;
-  }, []);
-  return (
-    <div onClick={onClick} >
-      {rows.map((row, i) => <EventParticipantsRow key={i} row={row} />)}
-    </div>
-  )
+// EventJoinCount は一覧に出す参加予定の人数（全員の回答は詳細ページで見る）。
+function EventJoinCount({ event }) {
+  const count = event.joinCount();
+  if (count == 0) return null;
+  return <div className="text-xs text-gray-500">参加予定 {count} 人</div>;
 }
 
 export function EventRow({ event, myself, submit, setModalEvent, navigate }) {
@@ -68,7 +42,7 @@ export function EventRow({ event, myself, submit, setModalEvent, navigate }) {
         <h3 className="text-gray-900 text-sm font-bold">{event.google.title}</h3>
         {event.google.start_time < Date.now() ? null : <>
           <EventLocation location={event.google.location} />
-          <EventParticipantsIcons pats={pats} />
+          <EventJoinCount event={event} />
         </>}
       </div>
       {event.google.start_time < Date.now() ? null : <EventRSVPButtonsRow
@@ -25,20 +25,76 @@ interface SlackTeam {
   }
 }
 
+export type StaffRole = "staff" | "trainer" | "coach";
+
+// MemberPositions はサーバの models.MemberPositions（hub で編集する。スタッフのみ）。
+export interface MemberPositions {
+  primary: string,
+  secondary?: string[],
+  // unit は offence / defence / staff（空なら primary から決まる）。
+  unit: string,
+  staff_roles?: StaffRole[],
+}
+
+export type Role = "admin" | "staff" | "trainer" | "equip_manager" | "captain";
+export const GRANTABLE_ROLES: Role[] = ["staff", "trainer", "equip_manager", "captain"];
+
+// Permission はサーバの models.Permission。/api/1/myself の permissions に入っている。
+export type Permission =
+  | "events:manage" | "event_tags:manage" | "rsvp:proxy" | "attendance:manage" | "members:manage"
+  | "roles:manage" | "equips:manage" | "numbers:manage" | "taping:manage" | "applications:manage" | "audit:view";
+
+// MemberStatusChange はサーバの models.MemberStatusChange（参加状態の変更履歴）。
+// from が空なら入部、to が "deleted" なら退部（Slack のアカウント削除）。
+export interface MemberStatusChange {
+  member_id: string,
+  from: string,
+  to: string,
+  effective_at: string,
+  reason: string,
+  changed_by: string,
+  created_at: string,
+}
+
+export const PLAYER_POSITIONS = ["QB", "RB", "WR", "OL", "TE", "DL", "LB", "DB", "K"];
+export const UNITS = ["offence", "defence", "staff"];
+export const STAFF_ROLES: StaffRole[] = ["staff", "trainer", "coach"];
+
 export default class Member {
   constructor(
       public slack: SlackMember,
       public number: number = null,
       public status: string = "active",
       public team: SlackTeam = null,
+      public positions: MemberPositions = { primary: "", unit: "" },
+      // roles は付与された役割（myself では Slack 管理者・スタッフの役割から決まるものも含む）。
+      public roles: Role[] = [],
+      // permissions はログインユーザの権限（/api/1/myself のみ）。
+      public permissions: Permission[] = [],
+      // joined_at / left_at は入部日・退部日（ISO 8601。不明なら undefined）。
+      public joined_at?: string,
+      public left_at?: string,
   ) { }
 
-  static fromAPIResponse({slack, number, status, team}): Member {
-    return new Member(slack, number, status, team);
+  static fromAPIResponse({slack, number, status, team, positions = { primary: "", unit: "" }, roles = [], permissions = [], joined_at = undefined, left_at = undefined}): Member {
+    return new Member(slack, number, status, team, positions, roles, permissions, joined_at, left_at);
   }
-  static listFromAPIResponse(res: { slack, number, status, team }[]): Member[] {
+  // can はログインユーザが perm の権限を持つか（操作のボタンの表示に使う。実際の判定はサーバ）。
+  can(perm: Permission): boolean {
+    return (this.permissions || []).includes(perm);
+  }
+  static listFromAPIResponse(res: { slack, number, status, team, positions? }[]): Member[] {
     return res.map(Member.fromAPIResponse);
   }
+  // positionLabel は表示用のポジション（例: "OL/DL"、"Trainer"）。サーバの MemberPositions.Label と同じ。
+  positionLabel(): string {
+    const p = this.positions || { primary: "", unit: "" };
+    return [
+      p.primary,
+      ...(p.secondary || []),
+      ...(p.staff_roles || []).map(r => r.charAt(0).toUpperCase() + r.slice(1)),
+    ].filter(Boolean).join("/");
+  }
   static placeholder(): Member {
     return new Member({
       id: "xxx", profile: { name: "", real_name: "", display_name: "", image_512: "", title: "" }, is_admin: false, deleted: false
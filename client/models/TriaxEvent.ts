@@ -6,12 +6,22 @@ interface GoogleEvent {
   location: string;
   start_time: number;
   end_time: number;
+  // source は取り込み元のカレンダー名（"team" / "league" など）。
+  source?: string;
+  // tags は取り込み元カレンダーの既定タグ（タイトルのハッシュタグに加えて扱う）。
+  tags?: EventTag[];
+  visibility?: "" | "staff";
 }
 
 export interface Participation {
   params?: any;
   type: ParticipationType;
   member?: Member;
+  // late は出欠回答の締め切り後の回答であること、late_reason はその理由。
+  late?: boolean;
+  late_reason?: string;
+  // answered_by はスタッフが代理で回答した場合の、回答したスタッフの Slack ID。
+  answered_by?: string;
 }
 
 enum ParticipationType {
@@ -21,11 +31,67 @@ enum ParticipationType {
   ABSENT = "absent",
 }
 
-export type EventTag = "練習" | "試合" | "event" | "meeting" | "sponsor" | "ignore" | "UNKNOWN";
+// 組み込みのタグ。管理者が追加したタグもあるので任意の文字列を許す。
+// Attendance は実際の出席（チェックイン）。出欠回答（Participation）とは別に記録する。
+export interface Attendance {
+  event_id: string;
+  member_id: string;
+  method: "roster" | "code";
+  checked_in_at: number;
+  recorded_by: string;
+}
+
+export interface AttendanceEntry {
+  member_id: string;
+  name: string;
+  rsvp: string;
+  attendance?: Attendance;
+}
+
+// AttendanceReport は出欠回答とチェックインの突き合わせ。
+export interface AttendanceReport {
+  event_id: string;
+  attended: AttendanceEntry[];
+  no_shows: AttendanceEntry[];
+  unannounced: AttendanceEntry[];
+}
+
+export type EventTag = "練習" | "試合" | "event" | "meeting" | "sponsor" | "ignore" | "UNKNOWN" | (string & {});
 
-// TAG_PATTERNS はタグ判定の唯一の定義（判定順序込み）。
-// サーバ events.go の tagDefs と順序・正規表現を一致させること。
-const TAG_PATTERNS: { tag: EventTag; re: RegExp }[] = [
+export type ReminderType = "rsvp" | "final_call" | "condition" | "equipment" | "headcount";
+
+// PositionHeadcount はサーバの models.PositionHeadcount（GET /api/1/events/{id}/headcount）。
+export interface PositionHeadcount {
+  position: string;
+  join: number;
+  join_late: number;
+  leave_early: number;
+  absent: number;
+  unanswered: number;
+  expected: number;
+  // minimum は最低人数（0 なら定めが無い）。short は expected が minimum に満たないこと。
+  minimum: number;
+  short: boolean;
+}
+
+// EventTagRule はサーバの models.EventTagRule（GET /api/1/event-tags）。
+export interface EventTagRule {
+  tag: EventTag;
+  label: string;
+  pattern: string;
+  order: number;
+  reminders: ReminderType[];
+  taping: boolean;
+  rsvp_deadline_hours?: number;
+  // schedule はリマインダを送るタイミング（開始の何時間前か）。書かれていない種別はサーバの既定値。
+  schedule?: { type: ReminderType; hours: number }[];
+  // minimums はポジションごとの最低人数（満たなければ headcount のリマインダで警告する）。
+  minimums?: { position: string; min: number }[];
+}
+
+// TAG_PATTERNS はタグ判定に使う定義（判定順序込み）。
+// 起動時に setTagRules でサーバの定義に置き換える。取得できるまではサーバの既定値と同じものを使う。
+let TAG_PATTERNS: { tag: EventTag; re: RegExp }[] = [
   { tag: "練習", re: /[＃#]練習/ },
   { tag: "試合", re: /[＃#]試合/ },
   { tag: "ignore", re: /[＃#]ignore/ },
@@ -34,22 +100,41 @@ const TAG_PATTERNS: { tag: EventTag; re: RegExp }[] = [
   { tag: "sponsor", re: /[＃#](sponsor|スポンサー)/ },
 ];
 
+// setTagRules はサーバのタグの定義を判定に使うようにする。
+export function setTagRules(rules: EventTagRule[]) {
+  TAG_PATTERNS = [...rules].sort((a, b) => a.order - b.order).map(r => ({ tag: r.tag, re: new RegExp(r.pattern) }));
+}
+
 export default class TeamEvent {
   constructor(
       public google: GoogleEvent,
       public participations: Record<string, Participation>,
+      // rsvpDeadline は出欠回答の締め切り（ミリ秒、締め切りが無ければ 0）。
+      public rsvpDeadline: number = 0,
+      // rsvpCounts は回答の内訳（一覧 API のみ。一覧の participations は本人の回答だけ。join / join_late / leave_early / absent / unanswered の人数）。
+      public rsvpCounts: Record<string, number> = {},
   ) { }
-  static fromAPIResponse({google, participations_json_str}): TeamEvent {
+  static fromAPIResponse({google, participations_json_str, rsvp_deadline = 0, rsvp_counts = {}}): TeamEvent {
     const pats: Record<string, Participation> = JSON.parse(participations_json_str || "{}");
-    return new TeamEvent(google, pats);
+    return new TeamEvent(google, pats, rsvp_deadline, rsvp_counts);
+  }
+  // joinCount は参加予定（遅参を含む）の人数。一覧では rsvpCounts から、回答後の応答のように内訳が無ければ participations から数える。
+  joinCount(): number {
+    if (Object.keys(this.rsvpCounts).length) return (this.rsvpCounts.join || 0) + (this.rsvpCounts.join_late || 0);
+    return Object.values(this.participations).filter(p => p.type == "join" || p.type == "join_late").length;
+  }
+  // isRSVPClosed は出欠回答の締め切りを過ぎているか（過ぎていれば回答に理由が必要）。
+  isRSVPClosed(now = Date.now()): boolean {
+    return this.rsvpDeadline > 0 && now >= this.rsvpDeadline;
   }
   static placeholder(): TeamEvent {
     return new TeamEvent({ id: '', title: 'xx', location: 'xxx', start_time: 0, end_time: 0 }, {});
   }
-  // tags はタイトルに含まれる全てのタグを返す（複数タグ対応）。
+  // tags はタイトルに含まれる全てのタグと、取り込み元カレンダーの既定タグを返す（複数タグ対応）。
   // 該当タグが無ければ "UNKNOWN" ひとつを返す。
   tags(): EventTag[] {
-    const result = TAG_PATTERNS.filter(p => p.re.test(this.google.title)).map(p => p.tag);
+    const defaults = this.google.tags || [];
+    const result = TAG_PATTERNS.filter(p => p.re.test(this.google.title) || defaults.includes(p.tag)).map(p => p.tag);
     return result.length ? result : ["UNKNOWN"];
   }
   // hasTag は指定タグがタイトルに含まれるかを返す（tags() と一貫）。
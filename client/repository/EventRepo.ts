@@ -1,5 +1,5 @@
-import TeamEvent from "../models/TriaxEvent";
-import { fetchJSON } from "./fetch";
+import TeamEvent, { Attendance, AttendanceReport, PositionHeadcount } from "../models/TriaxEvent";
+import { fetchJSON, HTTPError } from "./fetch";
 
 export default class TeamEventRepo {
   constructor(
@@ -13,15 +13,83 @@ export default class TeamEventRepo {
     const endpoint = this.baseURL + `/api/1/events/${id}/delete`;
     return fetchJSON(endpoint, { method: "POST" });
   }
-  list(): Promise<TeamEvent[]> {
-    return fetchJSON<TeamEvent[]>(this.baseURL + "/api/1/events")
-      .then(res => res.map(TeamEvent.fromAPIResponse));
+  // list は条件に合うイベントを nextCursor が空になるまで読んで全て返す。
+  async list(query: EventListQuery = {}): Promise<TeamEvent[]> {
+    const events: TeamEvent[] = [];
+    let cursor = "";
+    do {
+      const res = await this.page({ ...query, cursor });
+      events.push(...res.events);
+      cursor = res.nextCursor;
+    } while (cursor);
+    return events;
   }
-  async rsvp({event, answer, params}): Promise<TeamEvent> {
+  // page は条件に合うイベントを 1 ページ分返す。続きは nextCursor を同じ条件とあわせて渡して読む（無ければ空）。
+  page(query: EventListQuery = {}): Promise<{ events: TeamEvent[], nextCursor: string }> {
+    const params = new URLSearchParams();
+    if (query.from) params.set("from", query.from);
+    if (query.to) params.set("to", query.to);
+    (query.tags || []).forEach(tag => params.append("tag", tag));
+    if (query.order) params.set("order", query.order);
+    if (query.limit) params.set("limit", String(query.limit));
+    if (query.cursor) params.set("cursor", query.cursor);
+    return fetchJSON<{ events: any[], next_cursor: string }>(this.baseURL + "/api/1/events?" + params.toString())
+      .then(res => ({ events: res.events.map(TeamEvent.fromAPIResponse), nextCursor: res.next_cursor }));
+  }
+  // rsvp は出欠を回答する。締め切り後で理由が必要と言われたら、理由を入力してもらって送り直す。
+  async rsvp({event, answer, params, reason = ""}): Promise<TeamEvent> {
     const endpoint = this.baseURL + "/api/1/events/answer";
-    return fetchJSON(endpoint, { method: "POST", body: JSON.stringify({
-      event: { id: event.google.id }, type: answer, params: params ? params : null,
-    })})
+    try {
+      const res = await fetchJSON(endpoint, { method: "POST", body: JSON.stringify({
+        event: { id: event.google.id }, type: answer, params: params ? params : null, reason,
+      })});
+      return TeamEvent.fromAPIResponse(res as any);
+    } catch (err) {
+      if (!reason && err instanceof HTTPError && err.body?.reason_required) {
+        const input = window.prompt("出欠回答の締め切りを過ぎています。変更の理由を入力してください（コーチに連絡されます）");
+        if (input?.trim()) return this.rsvp({event, answer, params, reason: input.trim()});
+      }
+      throw err;
+    }
+  }
+  // rsvpFor はスタッフがメンバーの代わりに出欠を回答する（本人には Slack で通知される）。
+  rsvpFor(id: string, {memberId, answer, params = null, reason = ""}: { memberId: string, answer: string, params?: any, reason?: string }): Promise<TeamEvent> {
+    const endpoint = this.baseURL + `/api/1/events/${id}/proxy-answer`;
+    return fetchJSON(endpoint, { method: "POST", body: JSON.stringify({ member_id: memberId, type: answer, params, reason }) })
+      .then(TeamEvent.fromAPIResponse);
+  }
+  updateRSVPDeadline(id: string, deadline: number): Promise<TeamEvent> {
+    const endpoint = this.baseURL + `/api/1/events/${id}/rsvp-deadline`;
+    return fetchJSON(endpoint, { method: "PUT", body: JSON.stringify({ deadline }) })
       .then(TeamEvent.fromAPIResponse);
   }
+  // headcount はポジションごとの参加予定人数と、最低人数に満たないポジションの警告を返す。
+  headcount(id: string): Promise<{ event_id: string, positions: PositionHeadcount[], warnings: string[] }> {
+    return fetchJSON(this.baseURL + `/api/1/events/${id}/headcount`);
+  }
+  // 以下はチェックイン（実際の出席）。attendance / recordAttendance / issueCheckInCode はスタッフのみ。
+  attendance(id: string): Promise<AttendanceReport> {
+    return fetchJSON(this.baseURL + `/api/1/events/${id}/attendance`);
+  }
+  recordAttendance(id: string, attendance: Record<string, boolean>): Promise<AttendanceReport> {
+    const endpoint = this.baseURL + `/api/1/events/${id}/attendance`;
+    return fetchJSON(endpoint, { method: "POST", body: JSON.stringify({ attendance }) });
+  }
+  issueCheckInCode(id: string, minutes = 0): Promise<{ code: string, expires_at: string }> {
+    const endpoint = this.baseURL + `/api/1/events/${id}/checkin-code`;
+    return fetchJSON(endpoint, { method: "POST", body: JSON.stringify({ minutes }) });
+  }
+  checkIn(id: string, code: string): Promise<Attendance> {
+    const endpoint = this.baseURL + `/api/1/events/${id}/checkin`;
+    return fetchJSON(endpoint, { method: "POST", body: JSON.stringify({ code }) });
+  }
+}
+
+export interface EventListQuery {
+  from?: string;
+  to?: string;
+  tags?: string[];
+  order?: "asc" | "desc";
+  limit?: number;
+  cursor?: string;
 }
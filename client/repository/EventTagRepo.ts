@@ -0,0 +1,19 @@
+import { EventTagRule } from "../models/TriaxEvent";
+import { fetchJSON } from "./fetch";
+
+export default class EventTagRepo {
+  constructor(
+    public baseURL = import.meta.env.VITE_API_BASE_URL || "",
+  ) { }
+  list(): Promise<EventTagRule[]> {
+    return fetchJSON<EventTagRule[]>(this.baseURL + "/api/1/event-tags");
+  }
+  put(rule: EventTagRule): Promise<EventTagRule> {
+    const endpoint = this.baseURL + `/api/1/event-tags/${encodeURIComponent(rule.tag)}`;
+    return fetchJSON(endpoint, { method: "PUT", body: JSON.stringify(rule) });
+  }
+  delete(tag: string): Promise<{ tag: string, ok: boolean }> {
+    const endpoint = this.baseURL + `/api/1/event-tags/${encodeURIComponent(tag)}/delete`;
+    return fetchJSON(endpoint, { method: "POST" });
+  }
+}
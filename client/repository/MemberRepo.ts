@@ -1,4 +1,4 @@
-import Member from "../models/Member";
+import Member, { MemberPositions, MemberStatusChange, Role } from "../models/Member";
 import { fetchJSON } from "./fetch";
 
 export default class MemberRepo {
@@ -9,6 +9,13 @@ export default class MemberRepo {
     const endpoint = this.baseURL + `/api/1/myself` + `?t=${Date.now()}`;
     return fetchJSON(endpoint).then(Member.fromAPIResponse);
   }
+  // calendarFeed は自分用の iCalendar フィードの URL（カレンダーアプリで購読する）。resetCalendarFeed で URL を作り直す。
+  calendarFeed(): Promise<{ url: string }> {
+    return fetchJSON(this.baseURL + `/api/1/myself/calendar-feed`);
+  }
+  resetCalendarFeed(): Promise<{ url: string }> {
+    return fetchJSON(this.baseURL + `/api/1/myself/calendar-feed/reset`, { method: "POST" });
+  }
   get(id: string): Promise<Member> {
     const endpoint = this.baseURL + `/api/1/members/${id}`;
     return fetchJSON(endpoint).then(Member.fromAPIResponse);
@@ -20,13 +27,68 @@ export default class MemberRepo {
     const endpoint = this.baseURL + `/api/1/members` + '?' + query.toString();
     return fetchJSON(endpoint).then(Member.listFromAPIResponse);
   }
-  update(id: string, props: { status?: string, number?: number }): Promise<Member> {
+  // update はメンバーの参加状態・背番号・ポジション・入退部日を更新する（背番号以外はスタッフのみ）。
+  // 参加状態の変更は status_reason / effective_at とともに履歴に残る。
+  update(id: string, props: {
+    status?: string, status_reason?: string, effective_at?: string,
+    number?: number, positions?: MemberPositions, roles?: Role[],
+    joined_at?: string, left_at?: string,
+  }): Promise<Member> {
     const endpoint = this.baseURL + `/api/1/members/${id}/props`;
     return fetchJSON(endpoint, {
       method: "POST",
       body: JSON.stringify(props),
     }).then(Member.fromAPIResponse);
   }
+  // statusHistory は入退部日と参加状態の変更履歴（本人とスタッフのみ）。
+  statusHistory(id: string): Promise<{ member_id: string, status: string, joined_at?: string, left_at?: string, changes: MemberStatusChange[] }> {
+    return fetchJSON(this.baseURL + `/api/1/members/${id}/status-history`);
+  }
+  // attendance は過去のイベントへの出欠の集計（本人とスタッフのみ）。season は年、from / to は "YYYY-MM-DD"。
+  attendance(id: string, query: AttendanceStatsQuery = {}): Promise<{ stats: MemberAttendanceStats }> {
+    return fetchJSON(this.baseURL + `/api/1/members/${id}/attendance?` + attendanceStatsParams(query));
+  }
+  // teamAttendance はチーム全員の出欠の集計（スタッフのみ）。
+  teamAttendance(query: AttendanceStatsQuery = {}): Promise<{ events: number, members: MemberAttendanceStats[] }> {
+    return fetchJSON(this.baseURL + `/api/1/attendance?` + attendanceStatsParams(query));
+  }
+}
+
+export interface AttendanceStatsQuery {
+  season?: number;
+  from?: string;
+  to?: string;
+  tags?: string[];
+}
+
+export interface AttendanceStats {
+  events: number;
+  join: number;
+  join_late: number;
+  leave_early: number;
+  absent: number;
+  unanswered: number;
+  checked_in: number;
+  attendance_rate: number;
+  unanswered_rate: number;
+  current_streak: number;
+  longest_streak: number;
+}
+
+export interface MemberAttendanceStats {
+  member_id: string;
+  name: string;
+  total: AttendanceStats;
+  by_tag: Record<string, AttendanceStats>;
+}
+
+function attendanceStatsParams(query: AttendanceStatsQuery): string {
+  const params = new URLSearchParams();
+  if (query.season) params.set("season", String(query.season));
+  if (query.from) params.set("from", query.from);
+  if (query.to) params.set("to", query.to);
+  (query.tags || []).forEach(tag => params.append("tag", tag));
+  return params.toString();
 }
 
 export class MemberCache extends MemberRepo {
@@ -1,3 +1,12 @@
+/**
+ * fetchJSON が HTTP エラー時に投げる例外。body はレスポンスの JSON（読めなければ null）。
+ */
+export class HTTPError extends Error {
+  constructor(public status: number, statusText: string, public body: any) {
+    super(`HTTP ${status}: ${statusText}`);
+  }
+}
+
 /**
  * fetch のラッパー。HTTPステータスを検証し、エラー時に例外を投げる。
  */
@@ -10,7 +19,7 @@ export async function fetchJSON<T>(endpoint: string, init?: RequestInit): Promis
   }
   const res = await fetch(endpoint, init);
   if (!res.ok) {
-    throw new Error(`HTTP ${res.status}: ${res.statusText}`);
+    throw new HTTPError(res.status, res.statusText, await res.json().catch(() => null));
   }
   return res.json();
 }
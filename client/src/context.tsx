@@ -1,5 +1,7 @@
-import { createContext, useContext, useEffect, useMemo, useState } from "react";
+import { createContext, useContext, useEffect, useMemo, useRef, useState } from "react";
 import MemberRepo from "../repository/MemberRepo";
+import EventTagRepo from "../repository/EventTagRepo";
+import { setTagRules } from "../models/TriaxEvent";
 import Member from "../models/Member";
 import { useRouter } from "@tanstack/react-router";
 
@@ -29,6 +31,16 @@ export function AppProvider({ children }: { children: React.ReactNode }) {
     repo.myself().then(setMyself);
   }, [router.state.location.pathname, repo]);
 
+  // タグの定義はサーバと共有する（ログイン後に一度だけ取得。失敗したら既定の定義のまま）
+  const tagRulesLoaded = useRef(false);
+  useEffect(() => {
+    const path = router.state.location.pathname;
+    if (path === "/login" || path === "/errors" || path === "/applications/onboarding") return;
+    if (tagRulesLoaded.current) return;
+    tagRulesLoaded.current = true;
+    new EventTagRepo().list().then(setTagRules).catch(() => { tagRulesLoaded.current = false; });
+  }, [router.state.location.pathname]);
+
   return (
     <AppContext.Provider value={{
       myself,
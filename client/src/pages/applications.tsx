@@ -31,7 +31,7 @@ export default function ApplicationsPage() {
   const [error, setError] = useState("");
 
   const isWaitingMyself = !myself?.slack?.id || myself.slack.id === "xxx";
-  const isAdmin = myself?.slack?.is_admin;
+  const isAdmin = myself?.can("applications:manage");
 
   useEffect(() => {
     if (isWaitingMyself || !isAdmin) return;
@@ -41,7 +41,7 @@ export default function Item() {
         </div>
       </div>
 
-      {myself.slack.is_admin ? <div className="flex space-x-2">
+      {myself.can("equips:manage") ? <div className="flex space-x-2">
         <div className="flex-1">
           <div
             onClick={() => {
@@ -94,6 +94,11 @@ export default function EventView() {
           </div>
         </div>
 
+        {event.rsvpDeadline ? <div className={event.isRSVPClosed() ? "text-sm text-red-600" : "text-sm text-gray-600"}>
+          回答締め切り: {new Date(event.rsvpDeadline).toLocaleString("ja-JP", { month: "numeric", day: "numeric", weekday: "short", hour: "2-digit", minute: "2-digit" })}
+          {event.isRSVPClosed() ? "（締め切り後の変更には理由が必要です）" : null}
+        </div> : null}
+
         <div className="py-4">
           {event.google.start_time < Date.now() ? null : <EventRSVPButtonsRow
             event={event}
@@ -143,7 +148,7 @@ export default function EventView() {
           </div>
         </div>
 
-        {myself.slack.is_admin ? <div className="py-8">
+        {myself.can("events:manage") ? <div className="py-8">
           <div>
             <button
               className="w-full bg-red-500 text-white p-4 rounded-md font-bold cursor-pointer"
@@ -196,6 +201,7 @@ function ParticipationRow({ entry, title }: { entry: Participation, title?: stri
     <div key={member.slack?.id} className="flex space-x-2 items-center">
       <div className="flex-auto">{name}</div>
       <div className="text-xs">{getTimeLimitation(entry)}</div>
+      {entry.answered_by ? <div className="text-xs text-gray-500">代理</div> : null}
       {title ? <></> : <div className="text-xs">{member.slack?.profile?.title}</div>}
     </div>
   );
@@ -4,7 +4,7 @@ import { ChangeEvent, useEffect, useMemo, useRef, useState } from "react";
 import StatusBadges from "../../components/statusbadges";
 import MemberRepo from "../../repository/MemberRepo";
 import HPProfileRepo, { validatePhotoFile } from "../../repository/HPProfileRepo";
-import Member from "../../models/Member";
+import Member, { GRANTABLE_ROLES, MemberPositions, MemberStatusChange, PLAYER_POSITIONS, Role, STAFF_ROLES, StaffRole, UNITS } from "../../models/Member";
 import HPProfile, { CustomField, emptyHPProfile, HIDDEN_FIELD_KEYS, HiddenFieldKey } from "../../models/HPProfile";
 import { useAppContext } from "../context";
 
@@ -30,8 +30,8 @@ export default function MemberView() {
         <div className="flex-grow">
           <div className="flex flex-col h-full">
             <h1 className="text-3xl font-medium">{member.slack.profile.real_name}</h1>
-            <div className="text-2xl flex-grow text-gray-800">{member.slack.profile.title || "ポジション未設定"}</div>
-            <div className="text-xs text-gray-400 mt-1">名前・アイコン・ポジションは Slack プロフィールから同期されます</div>
+            <div className="text-2xl flex-grow text-gray-800">{member.positionLabel() || "ポジション未設定"}</div>
+            <div className="text-xs text-gray-400 mt-1">名前・アイコンは Slack プロフィールから同期されます。ポジションはスタッフが設定します</div>
           </div>
         </div>
       </div>
@@ -42,7 +42,7 @@ export default function MemberView() {
         <div className="flex space-x-2"><StatusBadges member={member} size="text-lg px-4 py-1" /></div>
       </div>
 
-      {myself.slack.is_admin ? <AdminMenu member={member} repo={repo} /> : null}
+      {myself.can("members:manage") ? <AdminMenu member={member} repo={repo} canGrantRoles={myself.can("roles:manage")} /> : null}
 
       <hr className="my-4" />
 
@@ -62,10 +62,14 @@ export default function MemberView() {
   )
 }
 
-function AdminMenu({ member, repo }: { member: Member, repo: MemberRepo }) {
+function AdminMenu({ member, repo, canGrantRoles }: { member: Member, repo: MemberRepo, canGrantRoles: boolean }) {
   const { status, slack } = member;
+  const [history, setHistory] = useState<MemberStatusChange[]>([]);
+  useEffect(() => { repo.statusHistory(slack.id).then(res => setHistory(res.changes)); }, [slack.id, repo]);
   const onInputChange = async (ev: ChangeEvent<HTMLSelectElement>) => {
-    await repo.update(slack.id, { status: ev.target.value });
+    const reason = window.prompt("変更の理由（履歴に残ります）") || "";
+    await repo.update(slack.id, { status: ev.target.value, status_reason: reason });
+    repo.statusHistory(slack.id).then(res => setHistory(res.changes));
   };
   return <div className="p-2 border rounded-md bg-red-100">
     <h3>管理者メニュー</h3>
@@ -84,6 +88,101 @@ function AdminMenu({ member, repo }: { member: Member, repo: MemberRepo }) {
         >退部済み（Slackで設定）</option>
       </select>
     </div>
+    <StatusHistory member={member} history={history} />
+    <PositionsEditor member={member} repo={repo} />
+    {canGrantRoles ? <RolesEditor member={member} repo={repo} /> : null}
+  </div>;
+}
+
+const statusLabels: Record<string, string> = {
+  "": "入部", active: "通常部員", limited: "練習外部員", inactive: "休眠", deleted: "退部",
+};
+
+function StatusHistory({ member, history }: { member: Member, history: MemberStatusChange[] }) {
+  const date = (s: string) => new Date(s).toLocaleDateString("ja-JP");
+  return <div className="mt-2 text-sm">
+    <div>
+      入部日: {member.joined_at ? date(member.joined_at) : "不明"}
+      {member.left_at ? ` / 退部日: ${date(member.left_at)}` : null}
+    </div>
+    <ul className="text-xs text-gray-600">
+      {history.map(c => (
+        <li key={c.created_at}>
+          {date(c.effective_at)} {c.from ? `${statusLabels[c.from] ?? c.from} → ` : ""}{statusLabels[c.from ? c.to : ""] ?? c.to}
+          {c.reason ? `（${c.reason}）` : null}
+        </li>
+      ))}
+    </ul>
+  </div>;
+}
+
+function RolesEditor({ member, repo }: { member: Member, repo: MemberRepo }) {
+  const [roles, setRoles] = useState<Role[]>(member.roles || []);
+  const onChange = async (role: Role) => {
+    const next = roles.includes(role) ? roles.filter(r => r !== role) : [...roles, role];
+    const updated = await repo.update(member.slack.id, { roles: next });
+    setRoles(updated.roles);
+  };
+  return <div className="mt-2 flex items-center space-x-2 text-sm">
+    <label className="w-20">役割</label>
+    {GRANTABLE_ROLES.map(role => (
+      <label key={role} className="space-x-1">
+        <input type="checkbox" checked={roles.includes(role)} onChange={() => onChange(role)} />
+        <span>{role}</span>
+      </label>
+    ))}
+  </div>;
+}
+
+function PositionsEditor({ member, repo }: { member: Member, repo: MemberRepo }) {
+  const [positions, setPositions] = useState<MemberPositions>(member.positions);
+  const [message, setMessage] = useState("");
+  const toggle = <T,>(list: T[] = [], v: T): T[] => list.includes(v) ? list.filter(x => x !== v) : [...list, v];
+  const onSave = async () => {
+    try {
+      const updated = await repo.update(member.slack.id, { positions });
+      setPositions(updated.positions);
+      setMessage("保存しました");
+    } catch (e) {
+      setMessage("保存に失敗しました: " + (e as Error).message);
+    }
+  };
+  return <div className="mt-2 space-y-1 text-sm">
+    <div className="flex items-center space-x-2">
+      <label className="w-20">ポジション</label>
+      <select className="rounded-sm" value={positions.primary}
+        onChange={ev => setPositions(p => ({ ...p, primary: ev.target.value }))}>
+        <option value="">なし</option>
+        {PLAYER_POSITIONS.map(pos => <option key={pos} value={pos}>{pos}</option>)}
+      </select>
+      <select className="rounded-sm" value={positions.unit}
+        onChange={ev => setPositions(p => ({ ...p, unit: ev.target.value }))}>
+        <option value="">ユニット（自動）</option>
+        {UNITS.map(u => <option key={u} value={u}>{u}</option>)}
+      </select>
+    </div>
+    <div className="flex items-center space-x-2 flex-wrap">
+      <label className="w-20">兼任</label>
+      {PLAYER_POSITIONS.filter(pos => pos !== positions.primary).map(pos => (
+        <label key={pos} className="space-x-1">
+          <input type="checkbox" checked={(positions.secondary || []).includes(pos)}
+            onChange={() => setPositions(p => ({ ...p, secondary: toggle(p.secondary, pos) }))} />
+          <span>{pos}</span>
+        </label>
+      ))}
+    </div>
+    <div className="flex items-center space-x-2">
+      <label className="w-20">スタッフ</label>
+      {STAFF_ROLES.map((role: StaffRole) => (
+        <label key={role} className="space-x-1">
+          <input type="checkbox" checked={(positions.staff_roles || []).includes(role)}
+            onChange={() => setPositions(p => ({ ...p, staff_roles: toggle(p.staff_roles, role) }))} />
+          <span>{role}</span>
+        </label>
+      ))}
+    </div>
+    <button className="bg-red-600 text-white rounded-md px-4 py-1" onClick={onSave}>ポジションを保存</button>
+    {message && <span className="ml-2">{message}</span>}
   </div>;
 }
 
@@ -27,7 +27,7 @@ export default function Members() {
           {members.map(member => <MemberItem
             key={member.slack.id}
             member={member}
-            admin={myself.slack.is_admin}
+            admin={myself.can("members:manage")}
           />)}
         </List>
       </div>
@@ -63,7 +63,7 @@ function MemberItem({ member, admin }) {
           </h3>
           <div className="flex space-x-4 text-gray-400">
             <div className="flex-shrink-0">#{member.number === null ? "未設定" : member.number}</div>
-            <div><PositionCols title={member.slack.profile.title} /></div>
+            <div><PositionCols title={member.positionLabel()} /></div>
           </div>
         </div>
         <div className="flex-shrink">
@@ -0,0 +1,167 @@
+// Command dump は Datastore の全 Kind を JSONL へ export し、JSONL から import する。
+//
+// 使い方:
+//
+//	go run ./cmd/dump export -o hub.jsonl
+//	go run ./cmd/dump export -o members.jsonl -kinds Member,MemberHPProfile
+//	go run ./cmd/dump import -i hub.jsonl --dry-run
+//	go run ./cmd/dump import -i hub.jsonl --project triax-hub-dev
+//
+// key（ancestor を含む）はそのまま保存・復元するので、import は冪等な upsert になる。
+// バックアップ、本番データでの不具合再現（エミュレーターへ import）、dev / prod 間の移送に使う。
+// 環境変数 DATASTORE_EMULATOR_HOST が設定されていればエミュレーターへ接続する。
+// project ID は --project / DATASTORE_PROJECT_ID / GOOGLE_CLOUD_PROJECT の順で解決する。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/dump"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatalf("dump: %v", err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dump export|import [flags]")
+	os.Exit(2)
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var (
+		out         = fs.String("o", "-", "出力ファイル（- なら標準出力）")
+		kindsFlag   = fs.String("kinds", strings.Join(dump.Kinds, ","), "export する Kind（カンマ区切り）")
+		projectFlag = fs.String("project", "", "Datastore project ID（未指定時は env から解決）")
+	)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	client, err := newClient(ctx, *projectFlag)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	counts, err := dump.Export(ctx, client, w, splitCSV(*kindsFlag))
+	if err != nil {
+		return err
+	}
+	printCounts("exported", counts)
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var (
+		in          = fs.String("i", "-", "入力ファイル（- なら標準入力）")
+		dryRun      = fs.Bool("dry-run", false, "Datastore へ書き込まず件数だけ表示する")
+		projectFlag = fs.String("project", "", "Datastore project ID（未指定時は env から解決）")
+	)
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	records, err := dump.Read(r)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, err := newClient(ctx, *projectFlag)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	counts, err := dump.Import(ctx, client, records, *dryRun)
+	if err != nil {
+		return err
+	}
+	printCounts(fmt.Sprintf("imported (dry-run=%v)", *dryRun), counts)
+	return nil
+}
+
+func newClient(ctx context.Context, projectFlag string) (*datastore.Client, error) {
+	projectID := resolveProjectID(projectFlag)
+	if projectID == "" {
+		return nil, fmt.Errorf("project ID unresolved: set --project, DATASTORE_PROJECT_ID, or GOOGLE_CLOUD_PROJECT")
+	}
+	client, err := datastore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("datastore client: %w", err)
+	}
+	return client, nil
+}
+
+// printCounts は件数を標準エラーへ出す（export の標準出力を汚さないため）。
+func printCounts(label string, counts map[string]int) {
+	kinds := make([]string, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		fmt.Fprintf(os.Stderr, "%s %6d %s\n", label, counts[k], k)
+	}
+}
+
+func resolveProjectID(flagVal string) string {
+	if flagVal != "" {
+		return flagVal
+	}
+	if v := os.Getenv("DATASTORE_PROJECT_ID"); v != "" {
+		return v
+	}
+	return os.Getenv("GOOGLE_CLOUD_PROJECT")
+}
+
+func splitCSV(s string) []string {
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if t := strings.TrimSpace(p); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+// Command migrate は Datastore のスキーマ移行（server/migration.Steps）を適用する。
+//
+// 使い方:
+//
+//	go run ./cmd/migrate --status
+//	go run ./cmd/migrate --dry-run
+//	go run ./cmd/migrate --to 2
+//	go run ./cmd/migrate
+//
+// 適用済みバージョンは SchemaVersion Kind に記録され、未適用の Step だけが順に実行される。
+// 途中で中断した場合は、同じコマンドを再実行すれば SchemaMigration Kind のチェックポイントから再開する。
+// project ID は --project / DATASTORE_PROJECT_ID / GOOGLE_CLOUD_PROJECT の順で解決する。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/server/migration"
+)
+
+func main() {
+	var (
+		dryRun      = flag.Bool("dry-run", false, "Datastore へ書き込まず変更件数だけ表示する")
+		status      = flag.Bool("status", false, "適用済みバージョンと各 Step の進捗を表示する")
+		target      = flag.Int("to", 0, "このバージョンまで適用する（0 なら最新まで）")
+		batch       = flag.Int("batch", 100, "1 バッチ（チェックポイント間隔）あたりのエンティティ数")
+		projectFlag = flag.String("project", "", "Datastore project ID（未指定時は env から解決）")
+	)
+	flag.Parse()
+
+	if err := run(*dryRun, *status, *target, *batch, *projectFlag); err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+}
+
+func run(dryRun, status bool, target, batch int, projectFlag string) error {
+	projectID := resolveProjectID(projectFlag)
+	if projectID == "" {
+		return fmt.Errorf("project ID unresolved: set --project, DATASTORE_PROJECT_ID, or GOOGLE_CLOUD_PROJECT")
+	}
+
+	ctx := context.Background()
+	client, err := datastore.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("datastore client: %w", err)
+	}
+	defer client.Close()
+
+	runner := &migration.Runner{
+		Client:    client,
+		DryRun:    dryRun,
+		BatchSize: batch,
+		Logf:      log.Printf,
+	}
+	if status {
+		return printStatus(ctx, runner)
+	}
+	return runner.Run(ctx, migration.Steps, target)
+}
+
+func printStatus(ctx context.Context, runner *migration.Runner) error {
+	current, err := runner.CurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema version: %d\n", current)
+	for _, step := range migration.Steps {
+		cp, err := runner.Checkpoint(ctx, step.Version)
+		if err != nil {
+			return err
+		}
+		state := "pending"
+		switch {
+		case step.Version <= current:
+			state = "applied"
+		case cp != nil:
+			state = fmt.Sprintf("in progress (processed=%d changed=%d)", cp.Processed, cp.Changed)
+		}
+		fmt.Printf("%4d  %-16s %-40s %s\n", step.Version, step.Kind, state, step.Description)
+	}
+	return nil
+}
+
+func resolveProjectID(flagVal string) string {
+	if flagVal != "" {
+		return flagVal
+	}
+	if v := os.Getenv("DATASTORE_PROJECT_ID"); v != "" {
+		return v
+	}
+	return os.Getenv("GOOGLE_CLOUD_PROJECT")
+}
@@ -3,7 +3,12 @@
 // 使い方:
 //
 //	go run ./cmd/seed --scenarios default
+//	go run ./cmd/seed --scenarios default,equips,taping,numbers,applications
 //	go run ./cmd/seed --validate-only --scenarios default
+//	go run ./cmd/seed --dump prod.jsonl --scenarios ""
+//
+// --dump には cmd/dump export の出力を渡す。Slack ID・氏名などを匿名化した
+// scenario（fixtures.FromDump）として、--scenarios の後に合成して投入する。
 //
 // 環境変数 DATASTORE_EMULATOR_HOST が設定されていれば、datastore client は
 // 自動的にエミュレーターへ接続する。project ID は --project / DATASTORE_PROJECT_ID /
@@ -18,10 +23,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/dump"
 	"github.com/triax/hub/fixtures"
 )
 
@@ -30,17 +37,18 @@ func main() {
 		scenariosFlag = flag.String("scenarios", "default", "投入する scenario 名（カンマ区切り）")
 		validateOnly  = flag.Bool("validate-only", false, "Datastore へ投入せず validation のみ実行する")
 		projectFlag   = flag.String("project", "", "Datastore project ID（未指定時は env から解決）")
+		dumpFlag      = flag.String("dump", "", "匿名化して投入する cmd/dump export の出力ファイル")
 	)
 	flag.Parse()
 
-	if err := run(*scenariosFlag, *validateOnly, *projectFlag); err != nil {
+	if err := run(*scenariosFlag, *validateOnly, *projectFlag, *dumpFlag); err != nil {
 		log.Fatalf("seed: %v", err)
 	}
 }
 
-func run(scenariosCSV string, validateOnly bool, projectFlag string) error {
+func run(scenariosCSV string, validateOnly bool, projectFlag, dumpFile string) error {
 	names := splitCSV(scenariosCSV)
-	if len(names) == 0 {
+	if len(names) == 0 && dumpFile == "" {
 		return fmt.Errorf("no scenarios specified")
 	}
 
@@ -50,6 +58,17 @@ func run(scenariosCSV string, validateOnly bool, projectFlag string) error {
 		return err
 	}
 
+	if dumpFile != "" {
+		anonymized, err := loadDump(dumpFile)
+		if err != nil {
+			return err
+		}
+		if scenario, err = fixtures.Compose(scenario, anonymized); err != nil {
+			return err
+		}
+		names = append(names, anonymized.Name)
+	}
+
 	if err := fixtures.Validate(scenario); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
@@ -79,6 +98,20 @@ func run(scenariosCSV string, validateOnly bool, projectFlag string) error {
 	return nil
 }
 
+// loadDump は cmd/dump export の出力を読み、匿名化した scenario を返す。
+func loadDump(path string) (fixtures.Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fixtures.Scenario{}, fmt.Errorf("open dump: %w", err)
+	}
+	defer f.Close()
+	records, err := dump.Read(f)
+	if err != nil {
+		return fixtures.Scenario{}, fmt.Errorf("read dump: %w", err)
+	}
+	return fixtures.FromDump("dump:"+filepath.Base(path), records)
+}
+
 func resolveProjectID(flagVal string) string {
 	if flagVal != "" {
 		return flagVal
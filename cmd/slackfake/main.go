@@ -0,0 +1,57 @@
+// Command slackfake は Slack Web API の代役サーバ（slackfake パッケージ）を起動する。
+//
+// 使い方:
+//
+//	go run ./cmd/slackfake --addr :9090 --scenarios default,equips
+//
+// hub 側は SLACK_API_URL=http://localhost:9090/api/ を設定して起動する（secrets.local.yaml など）。
+// users.list / users.info は --scenarios の Member を返し、投稿されたメッセージは
+// http://localhost:9090/messages（?channel= で絞り込み）で確認できる。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/triax/hub/fixtures"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/slackfake"
+)
+
+func main() {
+	var (
+		addr          = flag.String("addr", ":9090", "listen address")
+		scenariosFlag = flag.String("scenarios", "default", "users.list に使う fixtures の scenario 名（カンマ区切り）")
+	)
+	flag.Parse()
+
+	names := []string{}
+	for _, n := range strings.Split(*scenariosFlag, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	scenario, err := fixtures.Resolve(time.Now(), names...)
+	if err != nil {
+		log.Fatalf("slackfake: %v", err)
+	}
+	members := []models.Member{}
+	for _, e := range scenario.Entities {
+		if m, ok := e.Value.(*models.Member); ok {
+			members = append(members, *m)
+		}
+	}
+	users, err := slackfake.UsersFromMembers(members)
+	if err != nil {
+		log.Fatalf("slackfake: %v", err)
+	}
+
+	fmt.Printf("slackfake: serving %d users on %s (SLACK_API_URL=http://localhost%s/api/)\n", len(users), *addr, *addr)
+	if err := http.ListenAndServe(*addr, slackfake.New(slackfake.DefaultTeam, users)); err != nil {
+		log.Fatalf("slackfake: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package dump
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/server/migration"
+	"github.com/triax/hub/server/models"
+)
+
+// Kinds は export / import の対象 Kind（import 時の依存順: 参照される側・ancestor を先に）。
+// SchemaVersion も含めるので、移行済みのデータを別 project へ持ち込んでも再移行されない。
+var Kinds = []string{
+	models.KindMember,
+	models.KindEvent,
+	models.KindParticipation,
+	models.KindEquip,
+	models.KindCustody,
+	models.KindNumber,
+	models.KindTapeItem,
+	models.KindTapingMenuItem,
+	models.KindTaping,
+	models.KindApplication,
+	models.KindHPProfile,
+	models.KindCalendarSync,
+	models.KindEventTagRule,
+	models.KindReminder,
+	models.KindAttendance,
+	models.KindCheckInCode,
+	models.KindCalendarFeed,
+	models.KindMemberStatusChange,
+	models.KindDormancyProposal,
+	models.KindAuditEntry,
+	migration.KindSchemaVersion,
+}
+
+// putLimit は PutMulti 1 回あたりの上限件数。
+const putLimit = 500
+
+// Export は kinds の全エンティティを w へ JSONL で書き出し、Kind ごとの件数を返す。
+func Export(ctx context.Context, client *datastore.Client, w io.Writer, kinds []string) (map[string]int, error) {
+	enc := json.NewEncoder(w)
+	counts := map[string]int{}
+	for _, kind := range kinds {
+		var entities []datastore.PropertyList
+		keys, err := client.GetAll(ctx, datastore.NewQuery(kind), &entities)
+		if err != nil {
+			return counts, fmt.Errorf("query %s: %w", kind, err)
+		}
+		for i, key := range keys {
+			rec, err := NewRecord(key, entities[i])
+			if err != nil {
+				return counts, fmt.Errorf("%v: %w", key, err)
+			}
+			if err := enc.Encode(rec); err != nil {
+				return counts, err
+			}
+		}
+		counts[kind] = len(keys)
+	}
+	return counts, nil
+}
+
+// Read は JSONL を Record のリストとして読み込む。空行は無視する。
+func Read(r io.Reader) ([]Record, error) {
+	records := []Record{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 16*1024*1024) // 1 エンティティ最大 16MB
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Key == nil || len(rec.Key.Path) == 0 {
+			return nil, fmt.Errorf("line %d: key is empty", line)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// Import は records を同じ key で upsert し、Kind ごとの件数を返す。
+// key をそのまま使うので、同じダンプを何度 import しても件数は増えない。
+// dryRun なら書き込まずに件数だけを数える。
+func Import(ctx context.Context, client *datastore.Client, records []Record, dryRun bool) (map[string]int, error) {
+	counts := map[string]int{}
+	keys := make([]*datastore.Key, 0, putLimit)
+	srcs := make([]interface{}, 0, putLimit)
+	flush := func() error {
+		if len(keys) == 0 || dryRun {
+			keys, srcs = keys[:0], srcs[:0]
+			return nil
+		}
+		if _, err := client.PutMulti(ctx, keys, srcs); err != nil {
+			return err
+		}
+		keys, srcs = keys[:0], srcs[:0]
+		return nil
+	}
+	for _, rec := range ordered(records) {
+		props, err := rec.PropertyList()
+		if err != nil {
+			return counts, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		keys = append(keys, rec.DatastoreKey())
+		srcs = append(srcs, &props)
+		counts[rec.Kind()]++
+		if len(keys) == putLimit {
+			if err := flush(); err != nil {
+				return counts, err
+			}
+		}
+	}
+	return counts, flush()
+}
+
+// ordered は Kinds の順（未知の Kind は最後）に安定ソートした records を返す。
+func ordered(records []Record) []Record {
+	rank := map[string]int{}
+	for i, k := range Kinds {
+		rank[k] = i
+	}
+	buckets := make([][]Record, len(Kinds)+1)
+	for _, rec := range records {
+		r, ok := rank[rec.Kind()]
+		if !ok {
+			r = len(Kinds)
+		}
+		buckets[r] = append(buckets[r], rec)
+	}
+	out := make([]Record, 0, len(records))
+	for _, b := range buckets {
+		out = append(out, b...)
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+// Package dump は Datastore のエンティティを JSONL（1 行 1 エンティティ）へ書き出し、読み戻す。
+//
+// プロパティは型つきで保存するので（int64 / time / key / 埋め込みエンティティ等）、
+// struct に無いプロパティや ancestor を含む key も欠けずに往復できる。
+package dump
+
+import (
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+type (
+	// Record は JSONL の 1 行。
+	Record struct {
+		Key        *Key       `json:"key"`
+		Properties []Property `json:"properties"`
+	}
+
+	// Key は root から順に並べた key のパス。
+	Key struct {
+		Namespace string        `json:"namespace,omitempty"`
+		Path      []PathElement `json:"path"`
+	}
+
+	PathElement struct {
+		Kind string `json:"kind"`
+		ID   int64  `json:"id,omitempty,string"`
+		Name string `json:"name,omitempty"`
+	}
+
+	Property struct {
+		Name    string `json:"name"`
+		NoIndex bool   `json:"noindex,omitempty"`
+		Value   Value  `json:"value"`
+	}
+
+	// Value は型つきのプロパティ値。Type に応じたフィールドだけが埋まる。
+	// Type: null, int, float, bool, string, time, bytes, key, geo, array, entity
+	Value struct {
+		Type   string              `json:"type"`
+		Int    int64               `json:"int,omitempty,string"`
+		Float  float64             `json:"float,omitempty"`
+		Bool   bool                `json:"bool,omitempty"`
+		String string              `json:"string,omitempty"`
+		Time   *time.Time          `json:"time,omitempty"`
+		Bytes  []byte              `json:"bytes,omitempty"`
+		Key    *Key                `json:"key,omitempty"`
+		Geo    *datastore.GeoPoint `json:"geo,omitempty"`
+		Array  []Value             `json:"array,omitempty"`
+		Entity *Record             `json:"entity,omitempty"`
+	}
+)
+
+// Kind は key の末尾の Kind を返す。
+func (r Record) Kind() string {
+	if r.Key == nil || len(r.Key.Path) == 0 {
+		return ""
+	}
+	return r.Key.Path[len(r.Key.Path)-1].Kind
+}
+
+// NewRecord は key と PropertyList から Record を作る。
+func NewRecord(key *datastore.Key, props datastore.PropertyList) (Record, error) {
+	rec := Record{Key: EncodeKey(key), Properties: make([]Property, 0, len(props))}
+	for _, p := range props {
+		v, err := encodeValue(p.Value)
+		if err != nil {
+			return rec, fmt.Errorf("property %s: %w", p.Name, err)
+		}
+		rec.Properties = append(rec.Properties, Property{Name: p.Name, NoIndex: p.NoIndex, Value: v})
+	}
+	return rec, nil
+}
+
+// DatastoreKey は Record の key を datastore.Key に戻す。
+func (r Record) DatastoreKey() *datastore.Key {
+	return r.Key.Decode()
+}
+
+// PropertyList は Record のプロパティを datastore.PropertyList に戻す。
+func (r Record) PropertyList() (datastore.PropertyList, error) {
+	props := make(datastore.PropertyList, 0, len(r.Properties))
+	for _, p := range r.Properties {
+		v, err := decodeValue(p.Value)
+		if err != nil {
+			return nil, fmt.Errorf("property %s: %w", p.Name, err)
+		}
+		props = append(props, datastore.Property{Name: p.Name, NoIndex: p.NoIndex, Value: v})
+	}
+	return props, nil
+}
+
+// Load は Record を models の struct へ読み込む。struct に無いプロパティは無視する。
+func (r Record) Load(dst interface{}) error {
+	props, err := r.PropertyList()
+	if err != nil {
+		return err
+	}
+	if err := datastore.LoadStruct(dst, props); err != nil {
+		if _, ok := err.(*datastore.ErrFieldMismatch); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeKey は datastore.Key をパス表現にする。nil なら nil。
+func EncodeKey(key *datastore.Key) *Key {
+	if key == nil {
+		return nil
+	}
+	k := &Key{Namespace: key.Namespace}
+	for ; key != nil; key = key.Parent {
+		k.Path = append([]PathElement{{Kind: key.Kind, ID: key.ID, Name: key.Name}}, k.Path...)
+	}
+	return k
+}
+
+// Decode はパス表現を datastore.Key に戻す。
+func (k *Key) Decode() *datastore.Key {
+	if k == nil {
+		return nil
+	}
+	var key *datastore.Key
+	for _, e := range k.Path {
+		if e.Name != "" {
+			key = datastore.NameKey(e.Kind, e.Name, key)
+		} else {
+			key = datastore.IDKey(e.Kind, e.ID, key)
+		}
+		key.Namespace = k.Namespace
+	}
+	return key
+}
+
+func encodeValue(v interface{}) (Value, error) {
+	switch x := v.(type) {
+	case nil:
+		return Value{Type: "null"}, nil
+	case int64:
+		return Value{Type: "int", Int: x}, nil
+	case float64:
+		return Value{Type: "float", Float: x}, nil
+	case bool:
+		return Value{Type: "bool", Bool: x}, nil
+	case string:
+		return Value{Type: "string", String: x}, nil
+	case time.Time:
+		return Value{Type: "time", Time: &x}, nil
+	case []byte:
+		return Value{Type: "bytes", Bytes: x}, nil
+	case *datastore.Key:
+		return Value{Type: "key", Key: EncodeKey(x)}, nil
+	case datastore.GeoPoint:
+		return Value{Type: "geo", Geo: &x}, nil
+	case []interface{}:
+		arr := make([]Value, 0, len(x))
+		for _, e := range x {
+			ev, err := encodeValue(e)
+			if err != nil {
+				return Value{}, err
+			}
+			arr = append(arr, ev)
+		}
+		return Value{Type: "array", Array: arr}, nil
+	case *datastore.Entity:
+		rec, err := NewRecord(x.Key, x.Properties)
+		if err != nil {
+			return Value{}, err
+		}
+		return Value{Type: "entity", Entity: &rec}, nil
+	default:
+		return Value{}, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+func decodeValue(v Value) (interface{}, error) {
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "int":
+		return v.Int, nil
+	case "float":
+		return v.Float, nil
+	case "bool":
+		return v.Bool, nil
+	case "string":
+		return v.String, nil
+	case "time":
+		if v.Time == nil {
+			return time.Time{}, nil
+		}
+		return *v.Time, nil
+	case "bytes":
+		if v.Bytes == nil {
+			return []byte{}, nil
+		}
+		return v.Bytes, nil
+	case "key":
+		return v.Key.Decode(), nil
+	case "geo":
+		if v.Geo == nil {
+			return datastore.GeoPoint{}, nil
+		}
+		return *v.Geo, nil
+	case "array":
+		arr := make([]interface{}, 0, len(v.Array))
+		for _, e := range v.Array {
+			ev, err := decodeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, ev)
+		}
+		return arr, nil
+	case "entity":
+		if v.Entity == nil {
+			return nil, fmt.Errorf("entity value is empty")
+		}
+		props, err := v.Entity.PropertyList()
+		if err != nil {
+			return nil, err
+		}
+		return &datastore.Entity{Key: v.Entity.DatastoreKey(), Properties: props}, nil
+	default:
+		return nil, fmt.Errorf("unknown value type %q", v.Type)
+	}
+}
@@ -0,0 +1,88 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/server/models"
+)
+
+// TestRecordRoundtrip は、型つきのプロパティと ancestor つきの key が JSONL を往復して変わらないことを検証する。
+func TestRecordRoundtrip(t *testing.T) {
+	equip := datastore.IDKey(models.KindEquip, 5629499534213120, nil)
+	key := datastore.IDKey(models.KindCustody, 9007199254740993, equip) // 2^53+1: float64 では表せない
+	ts := time.Date(2026, 6, 2, 12, 0, 0, 123456000, time.UTC)
+	props := datastore.PropertyList{
+		{Name: "MemberID", Value: "U00000001"},
+		{Name: "Timestamp", Value: int64(1780000000000)},
+		{Name: "Comment", Value: "部室", NoIndex: true},
+		{Name: "Ratio", Value: 0.5},
+		{Name: "Done", Value: true},
+		{Name: "At", Value: ts},
+		{Name: "Raw", Value: []byte{0, 1, 2}},
+		{Name: "Ref", Value: datastore.NameKey(models.KindMember, "U00000001", nil)},
+		{Name: "Where", Value: datastore.GeoPoint{Lat: 35.6, Lng: 139.7}},
+		{Name: "Nil", Value: nil},
+		{Name: "Tags", Value: []interface{}{"a", int64(1)}},
+		{Name: "Slack", Value: &datastore.Entity{Properties: []datastore.Property{{Name: "ID", Value: "U00000001"}}}},
+	}
+
+	rec, err := NewRecord(key, props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(rec); err != nil {
+		t.Fatal(err)
+	}
+	records, err := Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("len(records) = %d, want 1", len(records))
+	}
+	got := records[0]
+	if !got.DatastoreKey().Equal(key) {
+		t.Errorf("key = %v, want %v", got.DatastoreKey(), key)
+	}
+	if got.Kind() != models.KindCustody {
+		t.Errorf("kind = %s", got.Kind())
+	}
+	gotProps, err := got.PropertyList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotProps, props) {
+		t.Errorf("properties differ:\n got: %#v\nwant: %#v", gotProps, props)
+	}
+
+	custody := models.Custody{}
+	if err := got.Load(&custody); err != nil {
+		t.Fatal(err)
+	}
+	if custody.MemberID != "U00000001" || custody.Timestamp != 1780000000000 {
+		t.Errorf("custody = %+v", custody)
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	records := []Record{
+		{Key: EncodeKey(datastore.IDKey(models.KindCustody, 1, datastore.IDKey(models.KindEquip, 1, nil)))},
+		{Key: EncodeKey(datastore.NameKey("Unknown", "x", nil))},
+		{Key: EncodeKey(datastore.IDKey(models.KindEquip, 1, nil))},
+		{Key: EncodeKey(datastore.NameKey(models.KindMember, "U1", nil))},
+	}
+	kinds := []string{}
+	for _, rec := range ordered(records) {
+		kinds = append(kinds, rec.Kind())
+	}
+	want := []string{models.KindMember, models.KindEquip, models.KindCustody, "Unknown"}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("kinds = %v, want %v", kinds, want)
+	}
+}
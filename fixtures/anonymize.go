@@ -0,0 +1,274 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/dump"
+	"github.com/triax/hub/server/models"
+)
+
+var (
+	fakeFamilyNames = []string{"佐藤", "鈴木", "高橋", "田中", "伊藤", "渡辺", "山本", "中村", "小林", "加藤"}
+	fakeGivenNames  = []string{"太郎", "次郎", "健太", "翔", "大輔", "拓也", "直樹", "亮", "悠斗", "蓮"}
+
+	// participationTimeParam は出欠回答の Params のうち残してよい値（"10:30" などの時刻）。
+	participationTimeParam = regexp.MustCompile(`^\d{1,2}:\d{2}$`)
+)
+
+// anonymizer は Slack ID と氏名の置き換え表を持つ。
+// 同じ Slack ID は、どの Kind に現れても同じ匿名 ID に置き換える。
+type anonymizer struct {
+	ids   map[string]string // 本物の Slack ID -> 匿名 ID
+	index map[string]int    // 匿名 ID -> 通し番号（偽名の生成用）
+}
+
+func (a *anonymizer) id(slackID string) string {
+	return a.ids[slackID]
+}
+
+// name は通し番号 i の偽名（姓, 名）を返す。100 人を超えたら番号を付けて一意にする。
+func (a *anonymizer) name(anonID string) (family, given string) {
+	i := a.index[anonID]
+	family = fakeFamilyNames[i%len(fakeFamilyNames)]
+	given = fakeGivenNames[(i/len(fakeFamilyNames))%len(fakeGivenNames)]
+	if n := i / (len(fakeFamilyNames) * len(fakeGivenNames)); n > 0 {
+		given = fmt.Sprintf("%s%d", given, n+1)
+	}
+	return family, given
+}
+
+// FromDump は cmd/dump export の出力から、個人情報を除いた scenario を作る。
+//
+//   - Member の Slack ID は "UANON00001" 形式に置き換え、Participation / Custody / Taping /
+//     Number / MemberHPProfile の参照も同じ表で置き換える（key 名に含まれる ID も含む）。
+//   - 氏名は偽名に、メールアドレス・電話・画像・自由記述は空にする。
+//   - 存在しない Member / Event を参照するエンティティは捨てるか参照を外し、
+//     結果が Validate を通ることを保証する。
+//   - AuditEntry / SchemaVersion など fixture に不要な Kind は含めない。
+func FromDump(name string, records []dump.Record) (Scenario, error) {
+	byKind := map[string][]dump.Record{}
+	for _, rec := range records {
+		byKind[rec.Kind()] = append(byKind[rec.Kind()], rec)
+	}
+
+	// Member の key 名（Slack ID）の昇順で匿名 ID を振る（同じダンプなら毎回同じ結果）
+	members := byKind[models.KindMember]
+	sort.Slice(members, func(i, j int) bool { return members[i].Key.Path[0].Name < members[j].Key.Path[0].Name })
+	anon := &anonymizer{ids: map[string]string{}, index: map[string]int{}}
+	for i, rec := range members {
+		id := fmt.Sprintf("UANON%05d", i+1)
+		anon.ids[rec.Key.Path[0].Name] = id
+		anon.index[id] = i
+	}
+	events := map[string]bool{}
+	for _, rec := range byKind[models.KindEvent] {
+		events[rec.Key.Path[0].Name] = true
+	}
+	equips := map[int64]bool{}
+	for _, rec := range byKind[models.KindEquip] {
+		equips[rec.Key.Path[0].ID] = true
+	}
+	menuItems := map[int64]bool{}
+	for _, rec := range byKind[models.KindTapingMenuItem] {
+		menuItems[rec.Key.Path[0].ID] = true
+	}
+
+	s := Scenario{Name: name}
+	add := func(key *datastore.Key, v interface{}) {
+		s.Entities = append(s.Entities, NewEntity(key, v))
+	}
+
+	for _, rec := range members {
+		m := &models.Member{}
+		if err := rec.Load(m); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		id := anon.id(rec.Key.Path[0].Name)
+		family, given := anon.name(id)
+		anonymizeSlackUser(&m.Slack, id, family, given)
+		add(MemberKey(id), m)
+	}
+
+	for _, rec := range byKind[models.KindEvent] {
+		ev := &models.Event{}
+		if err := rec.Load(ev); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		ev.Google.Description = ""
+		ev.LegacyParticipationsJSONString = ""
+		add(EventKey(ev.Google.ID), ev)
+	}
+
+	for _, rec := range byKind[models.KindParticipation] {
+		p := &models.Participation{}
+		if err := rec.Load(p); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		memberID := anon.id(p.MemberID)
+		if memberID == "" || !events[p.EventID] || p.Type == "" {
+			continue
+		}
+		p.MemberID = memberID
+		if err := p.UnmarshalParams(); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		p.Params = keepTimeParams(p.Params)
+		p.ParamsJSON = ""
+		for i := range p.History {
+			p.History[i].ParamsJSON = ""
+		}
+		add(ParticipationKey(p.EventID, memberID), p)
+	}
+
+	for _, rec := range byKind[models.KindEquip] {
+		e := &models.Equip{}
+		if err := rec.Load(e); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		if e.StorageType == "" {
+			e.StorageType = models.StorageTypeTakeHome // migration step 2 と同じ既定値
+		}
+		add(EquipKey(rec.Key.Path[0].ID), e)
+	}
+
+	for _, rec := range byKind[models.KindCustody] {
+		if len(rec.Key.Path) != 2 || !equips[rec.Key.Path[0].ID] {
+			continue // Equip を ancestor に持たない Custody は参照されないので捨てる
+		}
+		c := &models.Custody{}
+		if err := rec.Load(c); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		c.MemberID = anon.id(c.MemberID) // 退部などで Member が無ければ空（管理者不明）にする
+		c.Comment = ""
+		add(CustodyKey(rec.Key.Path[1].ID, EquipKey(rec.Key.Path[0].ID)), c)
+	}
+
+	for _, rec := range byKind[models.KindNumber] {
+		n := &models.PlayerNumber{}
+		if err := rec.Load(n); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		n.PlayerID = anon.id(n.PlayerID)
+		add(NumberKey(rec.Key.Path[0].Name), n)
+	}
+
+	for _, rec := range byKind[models.KindTapeItem] {
+		t := &models.TapeItem{}
+		if err := rec.Load(t); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		add(TapeItemKey(rec.Key.Path[0].ID), t)
+	}
+
+	for _, rec := range byKind[models.KindTapingMenuItem] {
+		mi := &models.TapingMenuItem{}
+		if err := rec.Load(mi); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		mi.TapeUsages = unmarshalOrNil(mi.TapeUsagesJSON)
+		add(TapingMenuItemKey(rec.Key.Path[0].ID), mi)
+	}
+
+	for _, rec := range byKind[models.KindTaping] {
+		t := &models.Taping{}
+		if err := rec.Load(t); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		memberID := anon.id(t.MemberID)
+		if memberID == "" || !events[t.EventID] || !menuItems[t.MenuItemID] {
+			continue
+		}
+		t.MemberID = memberID
+		t.TapeUsages = unmarshalOrNil(t.TapeUsagesJSON)
+		add(TapingKey(memberID, t.EventID, t.MenuItemID), t)
+	}
+
+	for i, rec := range byKind[models.KindApplication] {
+		a := &models.Application{}
+		if err := rec.Load(a); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		a.Email = fmt.Sprintf("applicant%03d@example.com", i+1)
+		a.Name = fmt.Sprintf("申請者 %03d", i+1)
+		a.Fields = map[string]string{}
+		a.FieldsJSON = ""
+		add(ApplicationKey(fmt.Sprintf("anon-application-%03d", i+1)), a)
+	}
+
+	for _, rec := range byKind[models.KindHPProfile] {
+		id := anon.id(rec.Key.Path[0].Name)
+		if id == "" {
+			continue
+		}
+		p := &models.MemberHPProfile{}
+		if err := rec.Load(p); err != nil {
+			return s, fmt.Errorf("%v: %w", rec.DatastoreKey(), err)
+		}
+		family, given := anon.name(id)
+		*p = models.MemberHPProfile{
+			DisplayName:  family + " " + given,
+			FirstName:    given,
+			FamilyName:   family,
+			Height:       p.Height,
+			Weight:       p.Weight,
+			Position:     p.Position,
+			HideFromHP:   p.HideFromHP,
+			HiddenFields: p.HiddenFields,
+		}
+		add(HPProfileKey(id), p)
+	}
+
+	if err := Validate(s); err != nil {
+		return s, fmt.Errorf("anonymized scenario is invalid: %w", err)
+	}
+	return s, nil
+}
+
+func anonymizeSlackUser(u *models.SlackUser, id, family, given string) {
+	full := family + " " + given
+	u.ID = id
+	u.Name = fmt.Sprintf("user%s", id[len("UANON"):])
+	u.RealName = full
+	title := u.Profile.Title // ポジション・役職は権限判定に使うので残す
+	team := u.Profile.Team
+	u.Profile = models.SlackProfile{
+		FirstName:             given,
+		LastName:              family,
+		RealName:              full,
+		RealNameNormalized:    full,
+		DisplayName:           u.Name,
+		DisplayNameNormalized: u.Name,
+		Email:                 u.Name + "@example.com",
+		Title:                 title,
+		Team:                  team,
+	}
+}
+
+func keepTimeParams(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+	kept := map[string]interface{}{}
+	for k, v := range params {
+		if s, ok := v.(string); ok && participationTimeParam.MatchString(s) {
+			kept[k] = s
+		}
+	}
+	return kept
+}
+
+func unmarshalOrNil(s string) []models.TapeUsage {
+	if s == "" {
+		return nil
+	}
+	usages := []models.TapeUsage{}
+	if err := json.Unmarshal([]byte(s), &usages); err != nil {
+		return nil
+	}
+	return usages
+}
@@ -0,0 +1,134 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/dump"
+	"github.com/triax/hub/server/models"
+)
+
+func record(t *testing.T, key *datastore.Key, v interface{}) dump.Record {
+	t.Helper()
+	props, err := datastore.SaveStruct(v)
+	if err != nil {
+		t.Fatalf("SaveStruct(%T): %v", v, err)
+	}
+	rec, err := dump.NewRecord(key, props)
+	if err != nil {
+		t.Fatalf("NewRecord(%v): %v", key, err)
+	}
+	return rec
+}
+
+func TestFromDump(t *testing.T) {
+	realMember := func(id, name string) *models.Member {
+		m := &models.Member{Status: models.MSActive}
+		m.Slack.ID = id
+		m.Slack.Name = name
+		m.Slack.RealName = name
+		m.Slack.Profile.RealName = name
+		m.Slack.Profile.Email = name + "@triax.football"
+		m.Slack.Profile.Phone = "090-0000-0000"
+		m.Slack.Profile.Title = "QB"
+		return m
+	}
+	ev := &models.Event{}
+	ev.Google.ID = "ev01"
+	ev.Google.Title = "練習"
+	ev.Google.Description = "集合は山田宅"
+	part := &models.Participation{EventID: "ev01", MemberID: "UREAL0002", Type: models.PTJoinLate,
+		ParamsJSON: `{"time":"10:30","comment":"山田と一緒に行きます"}`}
+	orphan := &models.Participation{EventID: "ev01", MemberID: "ULEFT0001", Type: models.PTJoin}
+	equip := &models.Equip{Name: "ビデオカメラ"}
+	custody := &models.Custody{MemberID: "UREAL0002", Timestamp: 1, Comment: "山田が持ち帰り"}
+	lost := &models.Custody{MemberID: "ULEFT0001", Timestamp: 2}
+	num := &models.PlayerNumber{Number: 12, PlayerID: "UREAL0001"}
+	taping := &models.Taping{MemberID: "UREAL0001", EventID: "ev01", MenuItemID: 3}
+	app := &models.Application{Type: "join", Email: "taro@example.jp", Name: "山田 太郎", FieldsJSON: `{"phone":"090"}`}
+	hp := &models.MemberHPProfile{DisplayName: "ヤマダ", Bio: "山田です", Height: 180}
+	audit := &models.AuditEntry{Actor: "UREAL0001", Action: "Member.Put"}
+
+	equipKey := datastore.IDKey(models.KindEquip, 10, nil)
+	records := []dump.Record{
+		record(t, datastore.NameKey(models.KindMember, "UREAL0002", nil), realMember("UREAL0002", "山田花子")),
+		record(t, datastore.NameKey(models.KindMember, "UREAL0001", nil), realMember("UREAL0001", "山田太郎")),
+		record(t, datastore.NameKey(models.KindEvent, "ev01", nil), ev),
+		record(t, datastore.NameKey(models.KindParticipation, "ev01_UREAL0002", nil), part),
+		record(t, datastore.NameKey(models.KindParticipation, "ev01_ULEFT0001", nil), orphan),
+		record(t, equipKey, equip),
+		record(t, datastore.IDKey(models.KindCustody, 20, equipKey), custody),
+		record(t, datastore.IDKey(models.KindCustody, 21, equipKey), lost),
+		record(t, datastore.NameKey(models.KindNumber, "12", nil), num),
+		record(t, datastore.IDKey(models.KindTapingMenuItem, 3, nil), &models.TapingMenuItem{Name: "足首"}),
+		record(t, datastore.NameKey(models.KindTaping, "UREAL0001_ev01_3", nil), taping),
+		record(t, datastore.NameKey(models.KindApplication, "app-real", nil), app),
+		record(t, datastore.NameKey(models.KindHPProfile, "UREAL0001", nil), hp),
+		record(t, datastore.IncompleteKey(models.KindAuditEntry, nil), audit),
+	}
+
+	s, err := FromDump("anon", records)
+	if err != nil {
+		t.Fatalf("FromDump: %v", err)
+	}
+
+	b, err := json.Marshal(s.Entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for _, e := range s.Entities {
+		keys = append(keys, e.Key.String())
+	}
+	all := string(b) + strings.Join(keys, " ")
+	for _, secret := range []string{"UREAL", "ULEFT", "山田", "triax.football", "090", "app-real"} {
+		if strings.Contains(all, secret) {
+			t.Errorf("anonymized scenario still contains %q", secret)
+		}
+	}
+
+	byKey := map[string]interface{}{}
+	for _, e := range s.Entities {
+		byKey[e.Key.String()] = e.Value
+	}
+	// key 名の昇順で振るので UREAL0001 -> UANON00001, UREAL0002 -> UANON00002
+	m, ok := byKey[MemberKey("UANON00001").String()].(*models.Member)
+	if !ok {
+		t.Fatalf("member UANON00001 not found: %v", keys)
+	}
+	if m.Slack.ID != "UANON00001" || m.Slack.Profile.Title != "QB" || m.Status != models.MSActive {
+		t.Errorf("unexpected member: %+v", m.Slack)
+	}
+	if n := byKey[NumberKey("12").String()].(*models.PlayerNumber); n.PlayerID != "UANON00001" {
+		t.Errorf("Number.PlayerID = %q", n.PlayerID)
+	}
+	if _, ok := byKey[TapingKey("UANON00001", "ev01", 3).String()]; !ok {
+		t.Error("taping is not remapped")
+	}
+	if _, ok := byKey[HPProfileKey("UANON00001").String()]; !ok {
+		t.Error("hp profile is not remapped")
+	}
+	p, ok := byKey[ParticipationKey("ev01", "UANON00002").String()].(*models.Participation)
+	if !ok {
+		t.Fatal("participation is not remapped")
+	}
+	if len(p.Params) != 1 || p.Params["time"] != "10:30" {
+		t.Errorf("participation params = %v", p.Params)
+	}
+	if c := byKey[CustodyKey(20, EquipKey(10)).String()].(*models.Custody); c.MemberID != "UANON00002" || c.Comment != "" {
+		t.Errorf("custody = %+v", c)
+	}
+	if c := byKey[CustodyKey(21, EquipKey(10)).String()].(*models.Custody); c.MemberID != "" {
+		t.Errorf("custody of unknown member = %+v", c)
+	}
+	for _, e := range s.Entities {
+		if e.Key.Kind == models.KindAuditEntry {
+			t.Error("AuditEntry should not be included")
+		}
+	}
+	if err := Validate(s); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
@@ -125,3 +125,56 @@ func TestComposeAllowsDeclaredOverride(t *testing.T) {
 		t.Error("override did not take effect (expected IsAdmin=true)")
 	}
 }
+
+// 追加 scenario を default と合成しても collision せず Validate を通過すること。
+func TestComposeAllScenarios(t *testing.T) {
+	s, err := Resolve(fixedNow, Names()...)
+	if err != nil {
+		t.Fatalf("Resolve(all): %v", err)
+	}
+	if err := Validate(s); err != nil {
+		t.Fatalf("composed scenarios failed validation: %v", err)
+	}
+	kinds := map[string]int{}
+	for _, e := range s.Entities {
+		kinds[e.Key.Kind]++
+	}
+	for _, kind := range kindOrder {
+		if kind == models.KindHPProfile {
+			continue
+		}
+		if kinds[kind] == 0 {
+			t.Errorf("no %s entity in composed scenarios", kind)
+		}
+	}
+}
+
+// Custody は Equip を ancestor に持たなければならないこと。
+func TestValidateDetectsCustodyWithoutEquip(t *testing.T) {
+	m := &models.Member{}
+	m.Slack.ID = "UCUST"
+	s := Scenario{
+		Name: "orphan-custody",
+		Entities: []Entity{
+			NewEntity(MemberKey("UCUST"), m),
+			NewEntity(CustodyKey(1, EquipKey(99)), &models.Custody{MemberID: "UCUST"}),
+		},
+	}
+	if err := Validate(s); err == nil {
+		t.Fatal("expected dangling equip error, got nil")
+	}
+}
+
+// Taping のメニュー参照も dangling 検出の対象であること。
+func TestValidateDetectsTapingWithoutMenuItem(t *testing.T) {
+	s, err := Resolve(fixedNow, "taping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Entities = append(s.Entities, NewEntity(TapingKey(fixturePlayerQB, "fixture_event_game_01", 9999), &models.Taping{
+		MemberID: fixturePlayerQB, EventID: "fixture_event_game_01", MenuItemID: 9999,
+	}))
+	if err := Validate(s); err == nil {
+		t.Fatal("expected dangling menu item error, got nil")
+	}
+}
@@ -12,8 +12,10 @@ import (
 //   - Member       : NameKey（Slack ID）
 //   - Event        : NameKey（Google Calendar ID）
 //   - Number       : NameKey（背番号文字列）
+//   - Participation: NameKey（eventID_memberID）
 //   - Taping       : NameKey（memberID_eventID_menuItemID）
 //   - Application  : NameKey（申請 ID）
+//   - MemberHPProfile: NameKey（Slack ID）
 //   - Equip/TapeItem/TapingMenuItem/Custody : IDKey（数値 ID）
 //     ※ 実コードは IncompleteKey（auto-ID）を使うが、fixture は冪等性のため
 //       明示的な数値 ID を割り当てる。
@@ -26,6 +28,11 @@ func EventKey(googleID string) *datastore.Key {
 	return datastore.NameKey(models.KindEvent, googleID, nil)
 }
 
+// ParticipationKey は実コード（models.ParticipationKeyName）の NameKey 規約に従う。
+func ParticipationKey(eventID, memberID string) *datastore.Key {
+	return datastore.NameKey(models.KindParticipation, models.ParticipationKeyName(eventID, memberID), nil)
+}
+
 func EquipKey(id int64) *datastore.Key {
 	return datastore.IDKey(models.KindEquip, id, nil)
 }
@@ -55,3 +62,7 @@ func TapingKey(memberID, eventID string, menuItemID int64) *datastore.Key {
 func ApplicationKey(id string) *datastore.Key {
 	return datastore.NameKey(models.KindApplication, id, nil)
 }
+
+func HPProfileKey(slackID string) *datastore.Key {
+	return datastore.NameKey(models.KindHPProfile, slackID, nil)
+}
@@ -3,6 +3,7 @@ package fixtures
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/triax/hub/server/models"
@@ -11,7 +12,11 @@ import (
 // registry は名前 → scenario ビルダーのレジストリ。
 // scenario はビルダー関数として登録する（相対日付など実行時計算を含むため）。
 var registry = map[string]func(now time.Time) Scenario{
-	"default": defaultScenario,
+	"default":      defaultScenario,
+	"equips":       equipsScenario,
+	"taping":       tapingScenario,
+	"numbers":      numbersScenario,
+	"applications": applicationsScenario,
 }
 
 // Names は登録済み scenario 名を返す（ソート済み）。
@@ -40,7 +45,8 @@ func Resolve(now time.Time, names ...string) (Scenario, error) {
 
 // defaultScenario は全 env で必要な最小ベースライン。
 //   - local-user.json の SlackID を持つ admin Member 1 件（自動ログインの本人）
-//   - 直近・近未来の Event 3 件（home 画面が空にならないように。相対日付）
+//   - 直近・近未来の Event 数件（home 画面が空にならないように。相対日付）
+//   - 過去の練習への admin の出欠回答（Participation）
 //
 // いずれも他 entity を参照しないため dangling は発生しない。
 func defaultScenario(now time.Time) Scenario {
@@ -62,6 +68,24 @@ func defaultScenario(now time.Time) Scenario {
 		NewEntity(MemberKey(localUserSlackID), admin),
 	}
 
+	entities = append(entities, eventEntities(now)...)
+
+	for _, eventID := range []string{"fixture_event_practice_01", "fixture_event_practice_02"} {
+		p := &models.Participation{
+			EventID:    eventID,
+			MemberID:   localUserSlackID,
+			Type:       models.PTJoin,
+			AnsweredAt: now.AddDate(0, 0, -10).UnixMilli(),
+		}
+		entities = append(entities, NewEntity(ParticipationKey(eventID, localUserSlackID), p))
+	}
+
+	return Scenario{Name: "default", Entities: entities}
+}
+
+// eventEntities は default の Event 群。taping など Event を参照する scenario も同じものを
+// 含める（内容が同一なので Compose で dedup される）ことで、単独でも Validate を通す。
+func eventEntities(now time.Time) []Entity {
 	eventDefs := []struct {
 		id    string
 		title string
@@ -74,6 +98,7 @@ func defaultScenario(now time.Time) Scenario {
 		{"fixture_event_sponsor_02", "#スポンサー 協賛企業交流会", 10},
 		{"fixture_event_practice_sponsor_01", "#練習 #sponsor 合同練習＆協賛社見学", 5},
 	}
+	entities := make([]Entity, 0, len(eventDefs))
 	for _, d := range eventDefs {
 		start := now.AddDate(0, 0, d.days)
 		ev := &models.Event{}
@@ -83,6 +108,273 @@ func defaultScenario(now time.Time) Scenario {
 		ev.Google.EndTime = start.Add(3 * time.Hour).UnixMilli()
 		entities = append(entities, NewEntity(EventKey(d.id), ev))
 	}
+	return entities
+}
 
-	return Scenario{Name: "default", Entities: entities}
+// fixture 用の一般メンバー（Slack ID は実在しない "UFIXTURE" 始まり）。
+const (
+	fixturePlayerQB      = "UFIXTUREQB"
+	fixturePlayerOL      = "UFIXTUREOL"
+	fixturePlayerDB      = "UFIXTUREDB"
+	fixtureStaffTrainer  = "UFIXTURETR"
+	fixturePlayerLimited = "UFIXTURELM"
+)
+
+// memberEntities は equips / taping / numbers が参照する一般メンバー。
+// Title はポジション判定（IsMemberOf）にそのまま使われる。
+// Number は numbers scenario の背番号割り当てと一致させている。
+func memberEntities() []Entity {
+	defs := []struct {
+		id     string
+		name   string
+		title  string
+		status models.MemberStatus
+		number int // 0 は未割り当て（背番号 0 は numbers scenario で空き番号として扱う）
+	}{
+		{fixturePlayerQB, "Fixture Quarterback", "QB", models.MSActive, 7},
+		{fixturePlayerOL, "Fixture Lineman", "OL/主将", models.MSActive, 55},
+		{fixturePlayerDB, "Fixture Defensive Back", "DB", models.MSActive, 24},
+		{fixtureStaffTrainer, "Fixture Trainer", "Trainer/Staff", models.MSActive, 0},
+		{fixturePlayerLimited, "Fixture Limited", "WR", models.MSLimited, 0},
+	}
+	entities := make([]Entity, 0, len(defs))
+	for _, d := range defs {
+		m := &models.Member{Status: d.status}
+		m.Slack.ID = d.id
+		m.Slack.TeamID = "T9LHPRHA6"
+		m.Slack.Name = d.name
+		m.Slack.RealName = d.name
+		m.Slack.Profile.RealName = d.name
+		m.Slack.Profile.DisplayName = d.name
+		m.Slack.Profile.Title = d.title
+		if d.number != 0 {
+			number := d.number
+			m.Number = &number
+		}
+		entities = append(entities, NewEntity(MemberKey(d.id), m))
+	}
+	return entities
+}
+
+// equipsScenario は備品一覧・持ち帰り履歴の画面用。
+//   - 倉庫保管 / 持ち帰りの備品（ビデオは充電が必要な備品として扱われる）
+//   - 持ち帰り備品には直近の Custody（Equip を ancestor に持つ）
+func equipsScenario(now time.Time) Scenario {
+	entities := memberEntities()
+
+	equips := []struct {
+		id          int64
+		name        string
+		forPractice bool
+		forGame     bool
+		storage     models.StorageType
+	}{
+		{1001, "ボールバッグ", true, true, models.StorageTypeTakeHome},
+		{1002, "ビデオカメラ", true, true, models.StorageTypeTakeHome},
+		{1003, "ダミー", true, false, models.StorageTypeWarehouse},
+		{1004, "救急バッグ", true, true, models.StorageTypeTakeHome},
+		{1005, "ヘッドセット", false, true, models.StorageTypeWarehouse},
+	}
+	for _, d := range equips {
+		entities = append(entities, NewEntity(EquipKey(d.id), &models.Equip{
+			Name:        d.name,
+			ForPractice: d.forPractice,
+			ForGame:     d.forGame,
+			StorageType: d.storage,
+		}))
+	}
+
+	custodies := []struct {
+		id       int64
+		equipID  int64
+		memberID string
+		days     int
+		comment  string
+	}{
+		{1101, 1001, fixturePlayerQB, -7, ""},
+		{1102, 1001, fixturePlayerOL, -3, ""},
+		{1103, 1002, fixtureStaffTrainer, -3, "充電済み"},
+		{1104, 1004, fixtureStaffTrainer, -10, "テーピング補充が必要"},
+	}
+	for _, d := range custodies {
+		entities = append(entities, NewEntity(CustodyKey(d.id, EquipKey(d.equipID)), &models.Custody{
+			MemberID:  d.memberID,
+			Timestamp: now.AddDate(0, 0, d.days).UnixMilli(),
+			Comment:   d.comment,
+		}))
+	}
+
+	return Scenario{Name: "equips", Entities: entities}
+}
+
+// tapingScenario はテーピング申請・集計の画面用。
+//   - テープ素材マスタとメニュー（テープ使用量付き）
+//   - 直近の試合（fixture_event_game_01）への申請
+//   - Trainer を Title に持つメンバー（isTapingManager で管理者扱い）
+func tapingScenario(now time.Time) Scenario {
+	entities := append(memberEntities(), eventEntities(now)...)
+
+	tapes := []struct {
+		id    int64
+		name  string
+		stock float64
+	}{
+		{2001, "ホワイト 38mm", 24},
+		{2002, "キネシオ 50mm", 12},
+		{2003, "アンダーラップ", 30},
+	}
+	for i, d := range tapes {
+		entities = append(entities, NewEntity(TapeItemKey(d.id), &models.TapeItem{
+			Name:       d.name,
+			StockCount: d.stock,
+			SortOrder:  i + 1,
+		}))
+	}
+
+	menu := []struct {
+		id     int64
+		name   string
+		price  int
+		usages []models.TapeUsage
+	}{
+		{3001, "足首（両足）", 600, []models.TapeUsage{
+			{TapeItemID: 2003, TapeItemName: "アンダーラップ", Quantity: 0.5},
+			{TapeItemID: 2001, TapeItemName: "ホワイト 38mm", Quantity: 1},
+		}},
+		{3002, "手首", 200, []models.TapeUsage{
+			{TapeItemID: 2001, TapeItemName: "ホワイト 38mm", Quantity: 0.3},
+		}},
+		{3003, "膝（キネシオ）", 400, []models.TapeUsage{
+			{TapeItemID: 2002, TapeItemName: "キネシオ 50mm", Quantity: 0.5},
+		}},
+	}
+	items := map[int64]*models.TapingMenuItem{}
+	for i, d := range menu {
+		items[d.id] = &models.TapingMenuItem{
+			Name:       d.name,
+			Price:      d.price,
+			TapeUsages: d.usages,
+			SortOrder:  i + 1,
+		}
+		entities = append(entities, NewEntity(TapingMenuItemKey(d.id), items[d.id]))
+	}
+
+	const gameID = "fixture_event_game_01"
+	requests := []struct {
+		memberID string
+		menuID   int64
+	}{
+		{fixturePlayerQB, 3002},
+		{fixturePlayerOL, 3001},
+		{fixturePlayerOL, 3002},
+		{fixturePlayerDB, 3003},
+	}
+	for _, d := range requests {
+		item := items[d.menuID]
+		entities = append(entities, NewEntity(TapingKey(d.memberID, gameID, d.menuID), &models.Taping{
+			MemberID:     d.memberID,
+			EventID:      gameID,
+			MenuItemID:   d.menuID,
+			MenuItemName: item.Name,
+			Price:        item.Price,
+			TapeUsages:   item.TapeUsages,
+			RequestedAt:  now.AddDate(0, 0, -1).UnixMilli(),
+		}))
+	}
+
+	return Scenario{Name: "taping", Entities: entities}
+}
+
+// numbersScenario は背番号・ユニフォーム管理の画面用。
+//   - 割り当て済み / 空き番号
+//   - チーム所有と個人所有のユニフォーム、難ありのユニフォーム
+func numbersScenario(now time.Time) Scenario {
+	entities := memberEntities()
+
+	numbers := []struct {
+		number   int
+		playerID string
+		uniforms []models.Uniform
+	}{
+		{0, "", []models.Uniform{
+			{Number: 0, Size: "L", Color: true},
+		}},
+		{7, fixturePlayerQB, []models.Uniform{
+			{Number: 7, Size: "M", Color: true, OwnerID: fixturePlayerQB},
+			{Number: 7, Size: "M", Color: false, OwnerID: fixturePlayerQB},
+		}},
+		{24, fixturePlayerDB, []models.Uniform{
+			{Number: 24, Size: "L", Color: true},
+			{Number: 24, Size: "L", Color: false, Damaged: true},
+		}},
+		{55, fixturePlayerOL, []models.Uniform{
+			{Number: 55, Size: "XXL", Color: true, Decoration: map[models.Decoration]bool{models.Deco_REFINVERSE_PATCH_v2024: true}},
+		}},
+		{88, "", nil},
+	}
+	for _, d := range numbers {
+		entities = append(entities, NewEntity(NumberKey(strconv.Itoa(d.number)), &models.PlayerNumber{
+			Number:   d.number,
+			PlayerID: d.playerID,
+			Uniforms: d.uniforms,
+		}))
+	}
+
+	return Scenario{Name: "numbers", Entities: entities}
+}
+
+// applicationsScenario は入部申請の画面用（default の admin で閲覧できる）。
+//   - 受付直後 / 手続き途中 / 完了済みの onboarding 申請
+func applicationsScenario(now time.Time) Scenario {
+	steps := func(done int) []models.ApplicationStep {
+		s := []models.ApplicationStep{
+			{Key: "slack_invited", Label: "Slack 招待"},
+			{Key: "google_groups_added", Label: "Google Groups 追加"},
+			{Key: "hudl_added", Label: "Hudl 追加"},
+		}
+		for i := 0; i < done; i++ {
+			s[i].Done = true
+		}
+		return s
+	}
+
+	apps := []struct {
+		id     string
+		family string
+		given  string
+		role   string
+		days   int
+		done   int
+	}{
+		{"fixture-application-01", "入部", "一郎", "選手", -1, 0},
+		{"fixture-application-02", "入部", "二郎", "選手", -5, 2},
+		{"fixture-application-03", "入部", "花子", "スタッフ", -20, 3},
+	}
+	entities := make([]Entity, 0, len(apps))
+	for i, d := range apps {
+		created := now.AddDate(0, 0, d.days)
+		fields := map[string]string{
+			"family_name": d.family,
+			"given_name":  d.given,
+			"gmail":       fmt.Sprintf("fixture.applicant%02d@example.com", i+1),
+			"role":        d.role,
+		}
+		if d.role == "選手" {
+			fields["position"] = "RB"
+			fields["height"] = "175"
+			fields["weight"] = "80"
+		}
+		entities = append(entities, NewEntity(ApplicationKey(d.id), &models.Application{
+			Type:            "onboarding",
+			Email:           fields["gmail"],
+			Name:            d.family + d.given,
+			Fields:          fields,
+			ConsentAgreedAt: created,
+			Steps:           steps(d.done),
+			Done:            d.done == 3,
+			CreatedAt:       created,
+		}))
+	}
+
+	return Scenario{Name: "applications", Entities: entities}
 }
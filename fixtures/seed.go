@@ -12,6 +12,7 @@ import (
 var kindOrder = []string{
 	models.KindMember,
 	models.KindEvent,
+	models.KindParticipation,
 	models.KindEquip,
 	models.KindNumber,
 	models.KindTapeItem,
@@ -19,6 +20,7 @@ var kindOrder = []string{
 	models.KindCustody,
 	models.KindTaping,
 	models.KindApplication,
+	models.KindHPProfile,
 }
 
 func kindRank(kind string) int {
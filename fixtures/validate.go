@@ -15,7 +15,8 @@ type kindRule struct {
 	// required は必須フィールドの非空を検証する。
 	required func(v interface{}) error
 	// refs はこの entity が参照する他 entity の key を返す（dangling 検出用）。
-	refs func(v interface{}) []*datastore.Key
+	// ancestor や key 名で参照を表す Kind（Custody / MemberHPProfile）のため key も渡す。
+	refs func(key *datastore.Key, v interface{}) []*datastore.Key
 	// roundtrip は JSON エンコードフィールドの marshal/unmarshal 健全性を検証する。
 	roundtrip func(v interface{}) error
 	// prepare は Put 直前に JSON エンコードフィールド等を埋める（投入用）。
@@ -53,16 +54,42 @@ var kindRules = map[string]kindRule{
 			}
 			return nil
 		},
+	},
+	models.KindParticipation: {
+		required: func(v interface{}) error {
+			p, ok := v.(*models.Participation)
+			if !ok {
+				return typeErr(models.KindParticipation, v)
+			}
+			if p.MemberID == "" || p.EventID == "" {
+				return fmt.Errorf("Participation.MemberID / Participation.EventID must be set")
+			}
+			if p.Type == "" {
+				return fmt.Errorf("Participation.Type is empty")
+			}
+			return nil
+		},
+		refs: func(_ *datastore.Key, v interface{}) []*datastore.Key {
+			p := v.(*models.Participation)
+			return []*datastore.Key{MemberKey(p.MemberID), EventKey(p.EventID)}
+		},
 		roundtrip: func(v interface{}) error {
-			e := v.(*models.Event)
-			if e.ParticipationsJSONString == "" {
-				return nil
+			p := v.(*models.Participation)
+			if _, err := json.Marshal(p.Params); err != nil {
+				return fmt.Errorf("Participation.Params marshal: %w", err)
 			}
-			if _, err := e.Participations(); err != nil {
-				return fmt.Errorf("Event.ParticipationsJSONString invalid: %w", err)
+			if p.ParamsJSON != "" {
+				tmp := map[string]interface{}{}
+				if err := json.Unmarshal([]byte(p.ParamsJSON), &tmp); err != nil {
+					return fmt.Errorf("Participation.ParamsJSON invalid: %w", err)
+				}
 			}
 			return nil
 		},
+		prepare: func(v interface{}) error {
+			p := v.(*models.Participation)
+			return p.MarshalParams()
+		},
 	},
 	models.KindEquip: {
 		required: func(v interface{}) error {
@@ -73,22 +100,37 @@ var kindRules = map[string]kindRule{
 			if e.Name == "" {
 				return fmt.Errorf("Equip.Name is empty")
 			}
+			switch e.StorageType {
+			case models.StorageTypeWarehouse, models.StorageTypeTakeHome:
+			default:
+				return fmt.Errorf("Equip.StorageType %q is invalid", e.StorageType)
+			}
 			return nil
 		},
 	},
 	models.KindNumber: {
 		required: func(v interface{}) error {
-			if _, ok := v.(*models.PlayerNumber); !ok {
+			n, ok := v.(*models.PlayerNumber)
+			if !ok {
 				return typeErr(models.KindNumber, v)
 			}
+			if n.Number < 0 {
+				return fmt.Errorf("Number.Number must not be negative")
+			}
 			return nil
 		},
-		refs: func(v interface{}) []*datastore.Key {
+		refs: func(_ *datastore.Key, v interface{}) []*datastore.Key {
 			n := v.(*models.PlayerNumber)
-			if n.PlayerID == "" {
-				return nil
+			refs := []*datastore.Key{}
+			if n.PlayerID != "" {
+				refs = append(refs, MemberKey(n.PlayerID))
+			}
+			for _, u := range n.Uniforms {
+				if u.OwnerID != "" {
+					refs = append(refs, MemberKey(u.OwnerID))
+				}
 			}
-			return []*datastore.Key{MemberKey(n.PlayerID)}
+			return refs
 		},
 	},
 	models.KindTapeItem: {
@@ -135,12 +177,14 @@ var kindRules = map[string]kindRule{
 			}
 			return nil
 		},
-		refs: func(v interface{}) []*datastore.Key {
+		// Custody は Equip を ancestor に持つ（key.Parent が Equip でなければ dangling 扱い）。
+		refs: func(key *datastore.Key, v interface{}) []*datastore.Key {
 			c := v.(*models.Custody)
-			if c.MemberID == "" {
-				return nil
+			refs := []*datastore.Key{key.Parent}
+			if c.MemberID != "" {
+				refs = append(refs, MemberKey(c.MemberID))
 			}
-			return []*datastore.Key{MemberKey(c.MemberID)}
+			return refs
 		},
 	},
 	models.KindTaping: {
@@ -149,14 +193,14 @@ var kindRules = map[string]kindRule{
 			if !ok {
 				return typeErr(models.KindTaping, v)
 			}
-			if t.MemberID == "" || t.EventID == "" {
-				return fmt.Errorf("Taping.MemberID / Taping.EventID must be set")
+			if t.MemberID == "" || t.EventID == "" || t.MenuItemID == 0 {
+				return fmt.Errorf("Taping.MemberID / Taping.EventID / Taping.MenuItemID must be set")
 			}
 			return nil
 		},
-		refs: func(v interface{}) []*datastore.Key {
+		refs: func(_ *datastore.Key, v interface{}) []*datastore.Key {
 			t := v.(*models.Taping)
-			return []*datastore.Key{MemberKey(t.MemberID), EventKey(t.EventID)}
+			return []*datastore.Key{MemberKey(t.MemberID), EventKey(t.EventID), TapingMenuItemKey(t.MenuItemID)}
 		},
 		roundtrip: func(v interface{}) error {
 			t := v.(*models.Taping)
@@ -178,8 +222,8 @@ var kindRules = map[string]kindRule{
 			if !ok {
 				return typeErr(models.KindApplication, v)
 			}
-			if a.Email == "" {
-				return fmt.Errorf("Application.Email is empty")
+			if a.Type == "" || a.Email == "" || a.Name == "" {
+				return fmt.Errorf("Application.Type / Application.Email / Application.Name must be set")
 			}
 			return nil
 		},
@@ -199,6 +243,18 @@ var kindRules = map[string]kindRule{
 			return a.MarshalFields()
 		},
 	},
+	models.KindHPProfile: {
+		required: func(v interface{}) error {
+			if _, ok := v.(*models.MemberHPProfile); !ok {
+				return typeErr(models.KindHPProfile, v)
+			}
+			return nil
+		},
+		// key 名が Member の Slack ID
+		refs: func(key *datastore.Key, _ interface{}) []*datastore.Key {
+			return []*datastore.Key{MemberKey(key.Name)}
+		},
+	},
 }
 
 // marshalTapeUsages は TapeUsages（struct）が JSON 化可能か検証する。
@@ -267,7 +323,7 @@ func Validate(s Scenario) error {
 		if rule.refs == nil {
 			continue
 		}
-		for _, ref := range rule.refs(e.Value) {
+		for _, ref := range rule.refs(e.Key, e.Value) {
 			rks := keyString(ref)
 			if rks == "" {
 				return fmt.Errorf("%s references an incomplete key", keyString(e.Key))
@@ -6,6 +6,7 @@ require (
 	cloud.google.com/go/datastore v1.24.0
 	cloud.google.com/go/storage v1.62.2
 	github.com/go-chi/chi/v5 v5.3.0
+	github.com/goccy/go-yaml v1.8.9
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/google/uuid v1.6.0
 	github.com/otiai10/appyaml v0.0.0-20210625032121-1fe2f3423963
@@ -56,7 +57,6 @@ require (
 require (
 	cloud.google.com/go v0.123.0 // indirect
 	github.com/fatih/color v1.12.0 // indirect
-	github.com/goccy/go-yaml v1.8.9 // indirect
 	github.com/googleapis/gax-go/v2 v2.22.0 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/mattn/go-colorable v0.1.8 // indirect
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/otiai10/appyaml"
 	"github.com/otiai10/marmoset"
 	"github.com/otiai10/openaigo"
-	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
 	"github.com/triax/hub/server/api"
 	"github.com/triax/hub/server/controllers"
 	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/gcal"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
 	"github.com/triax/hub/server/slackbot"
 	"github.com/triax/hub/server/tasks"
 
@@ -34,6 +39,15 @@ func main() {
 
 	marmoset.UseTemplate(tpl)
 
+	ds, err := repository.NewDatastore(context.Background(), os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	if err != nil {
+		log.Fatalf("failed to initialize datastore: %v", err)
+	}
+	defer ds.Close()
+	// ログインユーザ（Slack ユーザ）による書き込みは AuditEntry に記録する。
+	// メンバーの一覧は API・cron・Slack のボタンで共有のキャッシュから返す（書き込みのたびに捨て、10 分で失効）
+	repo := repository.WithMemberCache(repository.WithAudit(ds), 10*time.Minute)
+
 	r := chi.NewRouter()
 
 	// panic を捕捉して 500 を返しつつ Slack へアラート（プロセスを落とさない）
@@ -42,6 +56,15 @@ func main() {
 	// セキュリティヘッダー
 	r.Use(filters.SecurityHeaders)
 
+	// 全ハンドラへ Datastore リポジトリを注入
+	r.Use(filters.Repository(repo))
+
+	// イベントのタグの定義（Datastore で編集できる）を 5 分ごとに読み直す
+	r.Use(filters.EventTagRules(repo, 5*time.Minute))
+
+	// Google Calendar（GOOGLE_CALENDAR_YAML があればローカル用の YAML カレンダー）
+	r.Use(filters.Calendar(newCalendar()))
+
 	// API
 	v1 := chi.NewRouter()
 	auth := &filters.Auth{API: true, LocalDev: os.Getenv("GAE_APPLICATION") == ""}
@@ -50,6 +73,7 @@ func main() {
 	// 写真アップロードは 10MB まで許容（他の全エンドポイントは下の Group で 1MB）
 	v1.With(filters.MaxBodySize(10<<20)).Post("/members/{id}/hp-profile/photo", api.UploadHPPhoto)
 
+	// 権限が要る操作は filters.Require で宣言する（役割と権限は models.RolePermissions）
 	v1.Group(func(r chi.Router) {
 		r.Use(filters.MaxBodySize(1 << 20)) // 1MB
 		// フロントエンドのエラー報告を受け取り Slack へアラート
@@ -58,44 +82,64 @@ func main() {
 		r.Post("/members/{id}/props", api.UpdateMemberProps)
 		r.Get("/members/{id}/hp-profile", api.GetHPProfile)
 		r.Put("/members/{id}/hp-profile", api.UpdateHPProfile)
+		r.Get("/members/{id}/attendance", api.GetMemberAttendance)
+		r.Get("/members/{id}/status-history", api.GetMemberStatusHistory)
 		r.Get("/members", api.ListMembers)
+		r.With(filters.Require(models.PermManageAttendance)).Get("/attendance", api.GetTeamAttendance)
 		r.Get("/myself", api.GetCurrentUser)
+		r.Get("/myself/calendar-feed", api.GetMyCalendarFeed)
+		r.Post("/myself/calendar-feed/reset", api.ResetMyCalendarFeed)
 		r.Get("/events/{id}", api.GetEvent)
-		r.Post("/events/{id}/delete", api.DeleteEvent)
+		r.With(filters.Require(models.PermManageEvents)).Post("/events/{id}/delete", api.DeleteEvent)
 		r.Post("/events/answer", api.AnswerEvent)
+		r.With(filters.Require(models.PermAnswerForOthers)).Post("/events/{id}/proxy-answer", api.AnswerEventFor)
+		r.With(filters.Require(models.PermManageEvents)).Put("/events/{id}/rsvp-deadline", api.UpdateEventRSVPDeadline)
+		r.Get("/events/{id}/headcount", api.GetEventHeadcount)
+		r.With(filters.Require(models.PermManageAttendance)).Get("/events/{id}/attendance", api.GetEventAttendance)
+		r.With(filters.Require(models.PermManageAttendance)).Post("/events/{id}/attendance", api.RecordEventAttendance)
+		r.With(filters.Require(models.PermManageAttendance)).Post("/events/{id}/checkin-code", api.IssueCheckInCode)
+		r.Post("/events/{id}/checkin", api.CheckInEvent)
 		r.Get("/events", api.ListEvents)
+		// Event tags
+		r.Get("/event-tags", api.ListEventTagRules)
+		r.With(filters.Require(models.PermManageEventTags)).Put("/event-tags/{tag}", api.PutEventTagRule)
+		r.With(filters.Require(models.PermManageEventTags)).Post("/event-tags/{tag}/delete", api.DeleteEventTagRule)
 		// Equips
 		r.Post("/equips/custody", api.EquipCustodyReport)
 		r.Get("/equips/{id}", api.GetEquip)
-		r.Post("/equips/{id}/delete", api.DeleteEquip)
-		r.Post("/equips/{id}/update", api.UpdateEquip)
-		r.Post("/equips", api.CreateEquipItem)
+		r.With(filters.Require(models.PermManageEquips)).Post("/equips/{id}/delete", api.DeleteEquip)
+		r.With(filters.Require(models.PermManageEquips)).Post("/equips/{id}/update", api.UpdateEquip)
+		r.With(filters.Require(models.PermManageEquips)).Post("/equips", api.CreateEquipItem)
 		r.Get("/equips", api.ListEquips)
-		r.Post("/numbers/{num}/assign", api.AssignPlayerNumber)
-		r.Post("/numbers/{num}/deprive", api.DeprivePlayerNumber)
+		r.With(filters.Require(models.PermManageNumbers)).Post("/numbers/{num}/assign", api.AssignPlayerNumber)
+		r.With(filters.Require(models.PermManageNumbers)).Post("/numbers/{num}/deprive", api.DeprivePlayerNumber)
 		r.Get("/numbers", api.GetAllNumbers)
 		// TapeItem
 		r.Get("/tape-items", api.ListTapeItems)
-		r.Post("/tape-items", api.CreateTapeItem)
-		r.Post("/tape-items/{id}/update", api.UpdateTapeItem)
-		r.Post("/tape-items/{id}/delete", api.DeleteTapeItem)
+		r.With(filters.Require(models.PermManageTaping)).Post("/tape-items", api.CreateTapeItem)
+		r.With(filters.Require(models.PermManageTaping)).Post("/tape-items/{id}/update", api.UpdateTapeItem)
+		r.With(filters.Require(models.PermManageTaping)).Post("/tape-items/{id}/delete", api.DeleteTapeItem)
 		// Taping
 		r.Get("/taping/menu", api.ListTapingMenuItems)
-		r.Post("/taping/menu", api.CreateTapingMenuItem)
-		r.Post("/taping/menu/{id}/update", api.UpdateTapingMenuItem)
-		r.Post("/taping/menu/{id}/delete", api.DeleteTapingMenuItem)
+		r.With(filters.Require(models.PermManageTaping)).Post("/taping/menu", api.CreateTapingMenuItem)
+		r.With(filters.Require(models.PermManageTaping)).Post("/taping/menu/{id}/update", api.UpdateTapingMenuItem)
+		r.With(filters.Require(models.PermManageTaping)).Post("/taping/menu/{id}/delete", api.DeleteTapingMenuItem)
 		r.Get("/taping/requests", api.ListTapingRequests)
 		r.Post("/taping/requests", api.SubmitTapingRequest)
 		r.Get("/taping/requests/me", api.GetMyTapingRequest)
 		r.Get("/taping/events", api.ListTapingEvents)
 		// Applications
-		r.Get("/applications", api.GetApplications)
-		r.Patch("/applications/{id}", api.UpdateApplication)
+		r.With(filters.Require(models.PermManageApplications)).Get("/applications", api.GetApplications)
+		r.With(filters.Require(models.PermManageApplications)).Patch("/applications/{id}", api.UpdateApplication)
+		// Audit
+		r.With(filters.Require(models.PermViewAudit)).Get("/audit", api.ListAuditEntries)
 	})
 	r.Mount("/api/1", v1)
 
 	// 認証不要の公開 API（外部 HP サイト向け、および公開フォーム）
 	r.With(filters.MaxBodySize(1<<20)).Get("/api/1/public/members", api.ListPublicMembers)
+	// 個人用の iCalendar フィード（トークンで認証）
+	r.Get("/api/1/public/calendar/{token}.ics", api.GetCalendarFeed)
 
 	// 入部申請フォーム送信（認証不要）
 	// NOTE: chi の Mount は /api/1/* を v1 サブルーターに転送するが、
@@ -121,8 +165,9 @@ func main() {
 	// Bot events
 	bot := slackbot.Bot{
 		VerificationToken: os.Getenv("SLACK_BOT_EVENTS_VERIFICATION_TOKEN"),
-		SlackAPI:          slack.New(os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN")),
+		SlackAPI:          server.NewSlackClient(),
 		ChatGPT:           openaigo.NewClient(os.Getenv("OPENAI_API_KEY")),
+		Repository:        repo,
 	}
 	r.With(smallBody).Post("/slack/events", bot.Webhook)
 	r.With(smallBody).Post("/slack/shortcuts", bot.Shortcuts)
@@ -159,12 +204,14 @@ func main() {
 	}
 	cron.Get("/fetch-slack-members", tasks.CronFetchSlackMembers)
 	cron.Get("/fetch-calendar-events", tasks.CronFetchGoogleEvents)
+	cron.Get("/reminders/tick", tasks.CronReminderTick)
 	cron.Get("/check-rsvp", tasks.CronCheckRSVP)
 	cron.Get("/final-call", tasks.FinalCall)
 	cron.Get("/equips/remind/bring", tasks.EquipsRemindBring)
 	cron.Get("/equips/remind/report", tasks.EquipsRemindReportAfterEvent)
 	cron.Get("/equips/scan-unreported", tasks.EquipsScanUnreported)
 	cron.Get("/condition/form", tasks.ConditionFrom)
+	cron.Get("/members/scan-dormant", tasks.CronScanDormantMembers)
 	r.Mount("/tasks", cron)
 
 	r.NotFound(controllers.NotFound)
@@ -179,3 +226,16 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// newCalendar は Google Calendar のアクセス手段を返す。
+// GOOGLE_CALENDAR_YAML にファイルパスが設定されていれば、Google ではなくその YAML を使う（ローカル開発用）。
+func newCalendar() gcal.Calendar {
+	if path := os.Getenv("GOOGLE_CALENDAR_YAML"); path != "" {
+		cal, err := gcal.LoadYAML(path, time.Now())
+		if err != nil {
+			log.Fatalf("failed to load calendar yaml: %v", err)
+		}
+		return cal
+	}
+	return gcal.Google{CredentialsJSON: os.Getenv("GOOGLE_SERVICE_ACCOUNT_JSON")}
+}
@@ -1,19 +1,17 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/otiai10/marmoset"
 	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
 	"github.com/triax/hub/server/filters"
 	"github.com/triax/hub/server/models"
 )
@@ -32,20 +30,6 @@ func isAllowedOrigin(origin string) bool {
 	return false
 }
 
-func isApplicationAdmin(ctx context.Context, slackID string) (bool, error) {
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		return false, err
-	}
-	defer client.Close()
-	member := models.Member{}
-	key := datastore.NameKey(models.KindMember, slackID, nil)
-	if err := client.Get(ctx, key, &member); err != nil && !models.IsFiledMismatch(err) {
-		return false, err
-	}
-	return member.Slack.IsAdmin, nil
-}
-
 func CreateApplication(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 
@@ -87,14 +71,14 @@ func CreateApplication(w http.ResponseWriter, req *http.Request) {
 	}
 
 	id := uuid.NewString()
-	if err := models.PutApplication(req.Context(), id, app); err != nil {
+	if err := filters.GetRepositoryContext(req).Applications().Put(req.Context(), id, app); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
 
 	if input.Type == "onboarding" && isAllowedOrigin(req.Header.Get("Origin")) {
 		go func() {
-			api := slack.New(os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN"))
+			api := server.NewSlackClient()
 			msg := fmt.Sprintf("<!channel> 新しい入部申請が届きました。\nhttps://hub.triax.football/applications")
 			if _, _, err := api.PostMessage(slackChannelApplications, slack.MsgOptionText(msg, false)); err != nil {
 				log.Printf("[ERROR] 9010 Slack notification for new application: %v", err)
@@ -112,15 +96,9 @@ func CreateApplication(w http.ResponseWriter, req *http.Request) {
 func GetApplications(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 
-	callerID := filters.GetSessionUserContext(req)
-	ok, err := isApplicationAdmin(req.Context(), callerID)
-	if err != nil || !ok {
-		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
-		return
-	}
-
+	repo := filters.GetRepositoryContext(req)
 	appType := req.URL.Query().Get("type")
-	apps, ids, err := models.ListApplications(req.Context(), appType)
+	apps, ids, err := repo.Applications().List(req.Context(), appType)
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
@@ -141,15 +119,9 @@ func UpdateApplication(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	id := chi.URLParam(req, "id")
 
-	callerID := filters.GetSessionUserContext(req)
-	ok, err := isApplicationAdmin(req.Context(), callerID)
-	if err != nil || !ok {
-		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
-		return
-	}
-
-	app, err := models.GetApplication(req.Context(), id)
-	if err != nil || app == nil {
+	repo := filters.GetRepositoryContext(req)
+	app, err := repo.Applications().Get(req.Context(), id)
+	if err != nil {
 		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
 		return
 	}
@@ -170,7 +142,7 @@ func UpdateApplication(w http.ResponseWriter, req *http.Request) {
 		app.Done = *input.Done
 	}
 
-	if err := models.PutApplication(req.Context(), id, app); err != nil {
+	if err := repo.Applications().Put(req.Context(), id, app); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,438 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/otiai10/marmoset"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// GetEventAttendance はイベントの出欠回答とチェックインの突き合わせ（models.AttendanceReport）を返す（PermManageAttendance）。
+// 参加と回答して来なかったメンバー（no_shows）と、回答せずに来たメンバー（unannounced）が分かる。
+func GetEventAttendance(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	repo := filters.GetRepositoryContext(req)
+
+	id := chi.URLParam(req, "id")
+	if _, err := repo.Events().Get(req.Context(), id); err != nil {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+	report, err := attendanceReport(req, repo, id)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	render.JSON(http.StatusOK, report)
+}
+
+// RecordEventAttendance は名簿からメンバーの出席を記録する（PermManageAttendance）。
+// body の attendance は Slack ID → 出席したか。false はチェックインを取り消す。
+func RecordEventAttendance(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	staff := sessionMember(req, repo)
+	body := struct {
+		Attendance map[string]bool `json:"attendance"`
+	}{}
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || len(body.Attendance) == 0 {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": "attendance is required"})
+		return
+	}
+
+	id := chi.URLParam(req, "id")
+	if _, err := repo.Events().Get(ctx, id); err != nil {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+	for memberID := range body.Attendance {
+		if _, err := repo.Members().Get(ctx, memberID); err != nil {
+			render.JSON(http.StatusBadRequest, marmoset.P{"error": "unknown member: " + memberID})
+			return
+		}
+	}
+
+	now := time.Now()
+	for memberID, attended := range body.Attendance {
+		if !attended {
+			if err := repo.Attendances().Delete(ctx, id, memberID); err != nil {
+				render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+				return
+			}
+			continue
+		}
+		// 既にチェックイン済みなら最初の記録を残す
+		if _, err := repo.Attendances().Get(ctx, id, memberID); err == nil {
+			continue
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+			return
+		}
+		att := &models.Attendance{EventID: id, MemberID: memberID, Method: models.AMRoster, CheckedInAt: now.UnixMilli(), RecordedBy: staff.Slack.ID}
+		if err := repo.Attendances().Put(ctx, att); err != nil {
+			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+			return
+		}
+	}
+
+	report, err := attendanceReport(req, repo, id)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	render.JSON(http.StatusOK, report)
+}
+
+// IssueCheckInCode はイベントのセルフチェックイン用のコードを発行する（PermManageAttendance）。
+// minutes で有効期間を指定できる（既定 30 分、最大 6 時間）。発行し直すと前のコードは無効になる。
+func IssueCheckInCode(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	staff := sessionMember(req, repo)
+	body := struct {
+		Minutes int `json:"minutes"`
+	}{}
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+	ttl := models.CheckInCodeDefaultTTL
+	if body.Minutes != 0 {
+		ttl = time.Duration(body.Minutes) * time.Minute
+	}
+
+	id := chi.URLParam(req, "id")
+	event, err := repo.Events().Get(ctx, id)
+	if err != nil {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+	if event.Cancelled {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": "event is cancelled"})
+		return
+	}
+	code, err := models.NewCheckInCode(id, staff.Slack.ID, ttl, time.Now())
+	if err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+	if err := repo.Attendances().PutCode(ctx, code); err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	render.JSON(http.StatusCreated, code)
+}
+
+// CheckInEvent が UpdateCode の中で判定した結果。errCheckInCodeAccepted は書き戻さずに抜けるために使う。
+var (
+	errCheckInLockedOut    = errors.New("too many failed attempts")
+	errCheckInCodeInvalid  = errors.New("invalid or expired code")
+	errCheckInCodeAccepted = errors.New("check-in code accepted")
+)
+
+// CheckInEvent はログインユーザがチェックインコードでイベントにチェックインする。
+// 既にチェックイン済みなら、その記録を返す。閲覧できないイベントは 404。
+// 同じコードを models.CheckInMaxFailures 回間違えたら、発行し直すまで 429 を返す（総当たりを防ぐ）。
+// 期限切れのコードへの送信は間違えた回数に数えない。
+func CheckInEvent(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+	slackID := filters.GetSessionUserContext(req)
+
+	body := struct {
+		Code string `json:"code"`
+	}{}
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+
+	member, err := repo.Members().Get(ctx, slackID)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+	id := chi.URLParam(req, "id")
+	event, err := repo.Events().Get(ctx, id)
+	if err != nil || event.Cancelled || !event.VisibleTo(*member) {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+
+	// ロックアウトの確認・コードの照合・間違えた回数の記録をひとつのトランザクションで行うので、
+	// 並行して送っても CheckInMaxFailures 回を超えて試せない。
+	now := time.Now()
+	accepted := false
+	_, err = repo.Attendances().UpdateCode(ctx, id, func(c *models.CheckInCode) error {
+		accepted = false
+		switch {
+		case c.LockedOut(slackID):
+			return errCheckInLockedOut
+		case !now.Before(c.ExpiresAt):
+			// 期限切れのコードへの送信は数えない（遅れて来たメンバーを締め出さないため）
+			return errCheckInCodeInvalid
+		case c.Verify(body.Code, now):
+			accepted = true
+			return errCheckInCodeAccepted // 書き戻さない
+		}
+		c.FailedBy = append(c.FailedBy, slackID)
+		return nil
+	})
+	switch {
+	case accepted:
+	case errors.Is(err, errCheckInLockedOut):
+		render.JSON(http.StatusTooManyRequests, marmoset.P{"error": "too many failed attempts"})
+		return
+	case err == nil, errors.Is(err, errCheckInCodeInvalid), errors.Is(err, repository.ErrNotFound):
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": "invalid or expired code"})
+		return
+	default:
+		log.Println("[ERROR]", 13001, err.Error())
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+
+	if att, err := repo.Attendances().Get(ctx, id, slackID); err == nil {
+		render.JSON(http.StatusOK, att)
+		return
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	att := &models.Attendance{EventID: id, MemberID: slackID, Method: models.AMCode, CheckedInAt: now.UnixMilli(), RecordedBy: slackID}
+	if err := repo.Attendances().Put(ctx, att); err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	render.JSON(http.StatusCreated, att)
+}
+
+func attendanceReport(req *http.Request, repo repository.Repository, eventID string) (models.AttendanceReport, error) {
+	ctx := req.Context()
+	parts, err := repo.Participations().ListByEvent(ctx, eventID)
+	if err != nil {
+		return models.AttendanceReport{}, err
+	}
+	atts, err := repo.Attendances().ListByEvent(ctx, eventID)
+	if err != nil {
+		return models.AttendanceReport{}, err
+	}
+	members, err := repo.Members().List(ctx, true)
+	if err != nil {
+		return models.AttendanceReport{}, err
+	}
+	return models.NewAttendanceReport(eventID, parts, atts, models.MembersToDict(members)), nil
+}
+
+// attendanceStatsQuery は出欠の集計の条件。
+type attendanceStatsQuery struct {
+	From time.Time         `json:"from"`
+	To   time.Time         `json:"to"`
+	Tags []models.EventTag `json:"tags"`
+}
+
+// parseAttendanceStatsQuery はクエリから集計の条件を読む。
+//
+//   - season: 年（例 2025）。その年の 1/1〜12/31 を対象にする
+//   - from / to: 期間（"2006-01-02" または RFC3339）。season より優先。既定は今年の 1/1〜現在
+//   - tag: タグごとの内訳を出すタグ（複数可）。既定は 練習 と 試合
+//
+// 未来のイベントは集計しないので、to は現在より後にならない。
+func parseAttendanceStatsQuery(req *http.Request, now time.Time) (attendanceStatsQuery, error) {
+	q := req.URL.Query()
+	jst := now.In(server.ServiceLocation)
+	query := attendanceStatsQuery{
+		From: time.Date(jst.Year(), 1, 1, 0, 0, 0, 0, server.ServiceLocation),
+		To:   now,
+		Tags: []models.EventTag{models.ETPractice, models.ETGame},
+	}
+	if s := q.Get("season"); s != "" {
+		year, err := strconv.Atoi(s)
+		if err != nil {
+			return query, fmt.Errorf("invalid season: %s", s)
+		}
+		query.From = time.Date(year, 1, 1, 0, 0, 0, 0, server.ServiceLocation)
+		query.To = query.From.AddDate(1, 0, 0)
+	}
+	if from, err := parseDateParam(q.Get("from"), false); err != nil {
+		return query, err
+	} else if !from.IsZero() {
+		query.From = from
+	}
+	if to, err := parseDateParam(q.Get("to"), true); err != nil {
+		return query, err
+	} else if !to.IsZero() {
+		query.To = to
+	}
+	if query.To.After(now) {
+		query.To = now
+	}
+	if tags := q["tag"]; len(tags) > 0 {
+		query.Tags = []models.EventTag{}
+		for _, t := range tags {
+			query.Tags = append(query.Tags, models.EventTag(t))
+		}
+	}
+	return query, nil
+}
+
+// statsEvents は集計対象のイベント（期間内に始まった、全員向けの、tags のいずれかを持つイベント）を古い順に返す。
+func statsEvents(ctx context.Context, repo repository.Repository, query attendanceStatsQuery) ([]models.Event, error) {
+	events, err := repo.Events().Find(ctx, repository.EventQuery{From: query.From, To: query.To})
+	if err != nil {
+		return nil, err
+	}
+	targets := []models.Event{}
+	for _, ev := range events {
+		if ev.Google.Visibility != models.EVAll || ev.HasTag(models.ETIgnore) {
+			continue
+		}
+		if slices.ContainsFunc(query.Tags, ev.HasTag) {
+			targets = append(targets, ev)
+		}
+	}
+	return targets, nil
+}
+
+// GetMemberAttendance はメンバーの過去のイベントへの出欠の集計（models.MemberAttendanceStats）を返す。
+// 本人と PermManageAttendance を持つメンバーだけが見られる。条件は parseAttendanceStatsQuery。
+func GetMemberAttendance(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	id := chi.URLParam(req, "id")
+	if id != filters.GetSessionUserContext(req) && !sessionMember(req, repo).Can(models.PermManageAttendance) {
+		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
+		return
+	}
+	member, err := repo.Members().Get(ctx, id)
+	if err != nil {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+	query, err := parseAttendanceStatsQuery(req, time.Now())
+	if err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+
+	events, err := statsEvents(ctx, repo, query)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	parts, err := repo.Participations().ListByMember(ctx, id)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	atts, err := repo.Attendances().ListByMember(ctx, id)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	answers := models.AnswersByEventOf(parts)
+	checkedIn := map[string]bool{}
+	for _, att := range atts {
+		checkedIn[att.EventID] = true
+	}
+
+	render.JSON(http.StatusOK, marmoset.P{
+		"query": query,
+		"stats": models.NewMemberAttendanceStats(*member, events, answers, checkedIn, query.Tags),
+	})
+}
+
+// GetTeamAttendance はチーム全員の過去のイベントへの出欠の集計を返す（PermManageAttendance）。
+// 回答が期待されない（IsExpectedToRSVP でない）メンバーは含めない。条件は parseAttendanceStatsQuery。
+func GetTeamAttendance(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	query, err := parseAttendanceStatsQuery(req, time.Now())
+	if err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+
+	events, err := statsEvents(ctx, repo, query)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	ids := make([]string, len(events))
+	for i, ev := range events {
+		ids[i] = ev.Google.ID
+	}
+	partsByEvent, err := repo.Participations().ListByEvents(ctx, ids)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	attsByEvent, err := repo.Attendances().ListByEvents(ctx, ids)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	// Slack ID → Event ID → 回答 / チェックイン
+	answers := map[string]models.AnswersByEvent{}
+	for _, parts := range partsByEvent {
+		for _, p := range parts {
+			if answers[p.MemberID] == nil {
+				answers[p.MemberID] = models.AnswersByEvent{}
+			}
+			answers[p.MemberID][p.EventID] = p
+		}
+	}
+	checkedIn := map[string]map[string]bool{}
+	for _, atts := range attsByEvent {
+		for _, att := range atts {
+			if checkedIn[att.MemberID] == nil {
+				checkedIn[att.MemberID] = map[string]bool{}
+			}
+			checkedIn[att.MemberID][att.EventID] = true
+		}
+	}
+
+	members, err := repo.Members().List(ctx, false)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	stats := []models.MemberAttendanceStats{}
+	for _, m := range members {
+		// 休眠・部分参加など回答が期待されないメンバーは集計しない
+		if !m.IsExpectedToRSVP() {
+			continue
+		}
+		stats = append(stats, models.NewMemberAttendanceStats(m, events, answers[m.Slack.ID], checkedIn[m.Slack.ID], query.Tags))
+	}
+	render.JSON(http.StatusOK, marmoset.P{
+		"query":   query,
+		"events":  len(events),
+		"members": stats,
+	})
+}
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+func newAttendanceRequest(repo repository.Repository, slackID, method, eventID, body string) *http.Request {
+	req := httptest.NewRequest(method, "/api/1/events/"+eventID+"/attendance", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", eventID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	req = filters.SetSessionUserContext(req, slackID)
+	return filters.SetRepositoryContext(req, repo)
+}
+
+// TestEventAttendance は、チェックイン（コード・名簿）と出欠回答を突き合わせたレポートを検証する。
+func TestEventAttendance(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "USTAFF", RealName: "staff"}, Positions: models.MemberPositions{Unit: "staff", StaffRoles: []models.StaffRole{models.SRStaff}}},
+		{Slack: models.SlackUser{ID: "UJOIN", RealName: "join"}},     // 参加と回答してコードでチェックイン
+		{Slack: models.SlackUser{ID: "UNOSHOW", RealName: "noshow"}}, // 参加と回答して来なかった
+		{Slack: models.SlackUser{ID: "UABSENT", RealName: "absent"}}, // 欠席と回答して名簿で出席
+		{Slack: models.SlackUser{ID: "USILENT", RealName: "silent"}}, // 未回答でコードでチェックイン
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ev := models.Event{Google: models.GoogleEvent{ID: "ev001", Title: "#練習", StartTime: time.Now().UnixMilli()}}
+	if err := repo.Events().Put(ctx, &ev); err != nil {
+		t.Fatal(err)
+	}
+	for id, typ := range map[string]models.ParticipationType{"UJOIN": models.PTJoin, "UNOSHOW": models.PTJoinLate, "UABSENT": models.PTAbsent} {
+		if _, err := repo.Participations().Update(ctx, "ev001", id, func(p *models.Participation) error {
+			return p.Answer(typ, nil, time.Now())
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 出席の管理は main.go と同じく filters.Require の後ろで呼ぶ
+	issue := filters.Require(models.PermManageAttendance)(http.HandlerFunc(IssueCheckInCode))
+	report := filters.Require(models.PermManageAttendance)(http.HandlerFunc(GetEventAttendance))
+
+	// スタッフ以外はコードを発行できない
+	rec := httptest.NewRecorder()
+	issue.ServeHTTP(rec, newAttendanceRequest(repo, "UJOIN", http.MethodPost, "ev001", `{}`))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("issue by player: status = %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	issue.ServeHTTP(rec, newAttendanceRequest(repo, "USTAFF", http.MethodPost, "ev001", `{"minutes":10}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("issue: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	code := models.CheckInCode{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &code); err != nil || len(code.Code) != 6 {
+		t.Fatalf("code = %+v, %v", code, err)
+	}
+
+	rec = httptest.NewRecorder()
+	CheckInEvent(rec, newAttendanceRequest(repo, "UJOIN", http.MethodPost, "ev001", `{"code":"wrong"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("wrong code: status = %d", rec.Code)
+	}
+	for _, id := range []string{"UJOIN", "USILENT"} {
+		rec = httptest.NewRecorder()
+		CheckInEvent(rec, newAttendanceRequest(repo, id, http.MethodPost, "ev001", `{"code":"`+code.Code+`"}`))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("check-in %s: status = %d (body=%s)", id, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 期限切れのコードは使えない
+	expired := code
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	if err := repo.Attendances().PutCode(ctx, &expired); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	CheckInEvent(rec, newAttendanceRequest(repo, "UNOSHOW", http.MethodPost, "ev001", `{"code":"`+code.Code+`"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expired code: status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	RecordEventAttendance(rec, newAttendanceRequest(repo, "USTAFF", http.MethodPost, "ev001", `{"attendance":{"UABSENT":true}}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("roster: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	if att, err := repo.Attendances().Get(ctx, "ev001", "UABSENT"); err != nil || att.Method != models.AMRoster || att.RecordedBy != "USTAFF" {
+		t.Errorf("roster attendance = %+v, %v", att, err)
+	}
+
+	rec = httptest.NewRecorder()
+	report.ServeHTTP(rec, newAttendanceRequest(repo, "USTAFF", http.MethodGet, "ev001", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("report: status = %d", rec.Code)
+	}
+	res := models.AttendanceReport{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	ids := func(entries []models.AttendanceEntry) string {
+		s := []string{}
+		for _, e := range entries {
+			s = append(s, e.MemberID)
+		}
+		return strings.Join(s, ",")
+	}
+	if got := ids(res.Attended); got != "UABSENT,UJOIN,USILENT" {
+		t.Errorf("attended = %s", got)
+	}
+	if got := ids(res.NoShows); got != "UNOSHOW" {
+		t.Errorf("no_shows = %s", got)
+	}
+	if got := ids(res.Unannounced); got != "UABSENT,USILENT" {
+		t.Errorf("unannounced = %s", got)
+	}
+
+	// スタッフ以外はレポートを見られない
+	rec = httptest.NewRecorder()
+	report.ServeHTTP(rec, newAttendanceRequest(repo, "UJOIN", http.MethodGet, "ev001", ""))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("report by player: status = %d", rec.Code)
+	}
+}
+
+// TestGetMemberAttendance は、過去のイベントへの回答がタグごとに集計され、連続参加が数えられることと、
+// チーム全体の集計が休眠中のメンバーと入部前のイベントを除くことを検証する。
+func TestGetMemberAttendance(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UPLAYER", RealName: "player"}},
+		{Slack: models.SlackUser{ID: "UOTHER", RealName: "other"}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	// 古い順: 練習(欠席) 試合(参加) 練習(遅参) 練習(未回答) 練習(参加) ... 未来の練習は数えない
+	answers := []struct {
+		id, title string
+		typ       models.ParticipationType
+		days      int
+	}{
+		{"p1", "#練習", models.PTAbsent, -10},
+		{"g1", "#試合", models.PTJoin, -8},
+		{"p2", "#練習", models.PTJoinLate, -6},
+		{"p3", "#練習", "", -4},
+		{"p4", "#練習", models.PTJoin, -2},
+		{"m1", "#meeting", models.PTJoin, -1},
+		{"p5", "#練習", models.PTJoin, 3},
+	}
+	for _, a := range answers {
+		ev := models.Event{Google: models.GoogleEvent{ID: a.id, Title: a.title, StartTime: now.AddDate(0, 0, a.days).UnixMilli()}}
+		if err := repo.Events().Put(ctx, &ev); err != nil {
+			t.Fatal(err)
+		}
+		if a.typ == "" {
+			continue
+		}
+		if _, err := repo.Participations().Update(ctx, a.id, "UPLAYER", func(p *models.Participation) error {
+			return p.Answer(a.typ, nil, now)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Attendances().Put(ctx, &models.Attendance{EventID: "g1", MemberID: "UPLAYER", Method: models.AMRoster}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(slackID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/1/members/UPLAYER/attendance?"+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "UPLAYER")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, slackID), repo)
+		rec := httptest.NewRecorder()
+		GetMemberAttendance(rec, req)
+		return rec
+	}
+
+	if rec := get("UOTHER", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("other member: status = %d", rec.Code)
+	}
+	from := now.AddDate(0, 0, -30).Format(time.RFC3339)
+	rec := get("UPLAYER", "from="+from)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	res := struct {
+		Stats models.MemberAttendanceStats `json:"stats"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	total := res.Stats.Total
+	if total.Events != 5 || total.Join != 2 || total.JoinLate != 1 || total.Absent != 1 || total.Unanswered != 1 || total.CheckedIn != 1 {
+		t.Errorf("total = %+v", total)
+	}
+	if total.AttendanceRate != 0.6 || total.UnansweredRate != 0.2 || total.CurrentStreak != 1 || total.LongestStreak != 2 {
+		t.Errorf("total rates/streaks = %+v", total)
+	}
+	if p := res.Stats.ByTag[models.ETPractice]; p.Events != 4 || p.AttendanceRate != 0.5 {
+		t.Errorf("practice = %+v", p)
+	}
+	if g := res.Stats.ByTag[models.ETGame]; g.Events != 1 || g.Join != 1 {
+		t.Errorf("game = %+v", g)
+	}
+
+	// チーム全体の集計は休眠中のメンバーを除き、入部前のイベントを数えない
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UNEW"}, JoinedAt: now.AddDate(0, 0, -5)},
+		{Slack: models.SlackUser{ID: "UDORMANT"}, Status: models.MSInactive},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/1/attendance?from="+from, nil)
+	req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, "UPLAYER"), repo)
+	rec = httptest.NewRecorder()
+	GetTeamAttendance(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("team: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	team := struct {
+		Members []models.MemberAttendanceStats `json:"members"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &team); err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]models.AttendanceStats{}
+	for _, m := range team.Members {
+		byID[m.MemberID] = m.Total
+	}
+	if _, ok := byID["UDORMANT"]; ok || len(byID) != 3 {
+		t.Errorf("team members = %v", byID)
+	}
+	if p := byID["UPLAYER"]; p.Events != 5 || p.CheckedIn != 1 {
+		t.Errorf("player = %+v", p)
+	}
+	if n := byID["UNEW"]; n.Events != 2 || n.Unanswered != 2 {
+		t.Errorf("new member = %+v", n)
+	}
+}
+
+// TestCheckInEvent_Lockout は、コードを何度も間違えたメンバーが発行し直すまでチェックインできず、
+// 閲覧できないイベントにはチェックインできないことを検証する。
+func TestCheckInEvent_Lockout(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, id := range []string{"UGUESS", "UPLAYER", "ULATE"} {
+		if err := repo.Members().Put(ctx, &models.Member{Slack: models.SlackUser{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ev := range []models.Event{
+		{Google: models.GoogleEvent{ID: "ev001", Title: "#練習", StartTime: time.Now().UnixMilli()}},
+		{Google: models.GoogleEvent{ID: "staff", Title: "#練習 スタッフMTG", Visibility: models.EVStaff, StartTime: time.Now().UnixMilli()}},
+	} {
+		if err := repo.Events().Put(ctx, &ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	issue := func(eventID string) *models.CheckInCode {
+		code, err := models.NewCheckInCode(eventID, "USTAFF", time.Hour, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Attendances().PutCode(ctx, code); err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	checkIn := func(slackID, eventID, code string) int {
+		rec := httptest.NewRecorder()
+		CheckInEvent(rec, newAttendanceRequest(repo, slackID, http.MethodPost, eventID, `{"code":"`+code+`"}`))
+		return rec.Code
+	}
+
+	code := issue("ev001")
+	for i := 0; i < models.CheckInMaxFailures; i++ {
+		if status := checkIn("UGUESS", "ev001", "wrong"); status != http.StatusBadRequest {
+			t.Fatalf("attempt %d: status = %d", i, status)
+		}
+	}
+	if status := checkIn("UGUESS", "ev001", code.Code); status != http.StatusTooManyRequests {
+		t.Errorf("locked out: status = %d", status)
+	}
+	if status := checkIn("UPLAYER", "ev001", code.Code); status != http.StatusCreated {
+		t.Errorf("other member: status = %d", status)
+	}
+	// 発行し直すと間違えた回数は消える
+	code = issue("ev001")
+	if status := checkIn("UGUESS", "ev001", code.Code); status != http.StatusCreated {
+		t.Errorf("after reissue: status = %d", status)
+	}
+
+	staff := issue("staff")
+	if status := checkIn("UPLAYER", "staff", staff.Code); status != http.StatusNotFound {
+		t.Errorf("staff-only event: status = %d", status)
+	}
+
+	// 並行して送っても CheckInMaxFailures 回を超えては数えない
+	code = issue("ev001")
+	var wg sync.WaitGroup
+	for i := 0; i < 4*models.CheckInMaxFailures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkIn("UGUESS", "ev001", "wrong")
+		}()
+	}
+	wg.Wait()
+	if stored, _ := repo.Attendances().GetCode(ctx, "ev001"); len(stored.FailedBy) != models.CheckInMaxFailures {
+		t.Errorf("failures = %d, want %d", len(stored.FailedBy), models.CheckInMaxFailures)
+	}
+
+	// 期限切れのコードへの送信は数えない
+	code = issue("ev001")
+	if _, err := repo.Attendances().UpdateCode(ctx, "ev001", func(c *models.CheckInCode) error {
+		c.ExpiresAt = time.Now().Add(-time.Minute)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < models.CheckInMaxFailures+1; i++ {
+		if status := checkIn("ULATE", "ev001", code.Code); status != http.StatusBadRequest {
+			t.Fatalf("expired code: status = %d", status)
+		}
+	}
+	if stored, _ := repo.Attendances().GetCode(ctx, "ev001"); len(stored.FailedBy) != 0 {
+		t.Errorf("expired attempts counted: %v", stored.FailedBy)
+	}
+}
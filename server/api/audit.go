@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/otiai10/marmoset"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/repository"
+)
+
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 500
+)
+
+// ListAuditEntries は AuditEntry を新しい順に返す（PermViewAudit）。
+//
+// クエリ:
+//   - actor: 操作者の Slack ID
+//   - kind:  対象の Kind（Event, Member, Number, ...）
+//   - from / to: 期間（"2006-01-02" または RFC3339）。to の日付はその日を含む
+//   - limit: 件数（既定 100、最大 500）
+func ListAuditEntries(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	var err error
+	q := req.URL.Query()
+	query := repository.AuditQuery{
+		Actor: q.Get("actor"),
+		Kind:  q.Get("kind"),
+		Limit: auditDefaultLimit,
+	}
+	if query.From, err = parseDateParam(q.Get("from"), false); err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+	if query.To, err = parseDateParam(q.Get("to"), true); err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			render.JSON(http.StatusBadRequest, marmoset.P{"error": "invalid limit"})
+			return
+		}
+		query.Limit = min(limit, auditMaxLimit)
+	}
+
+	entries, err := repo.Audit().Find(ctx, query)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	render.JSON(http.StatusOK, entries)
+}
+
+// parseDateParam は "2006-01-02"（ServiceLocation）または RFC3339 を解釈する。
+// 日付のみで end が true の場合は翌日 0 時（その日を含む上限）を返す。
+func parseDateParam(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, server.ServiceLocation)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %s", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// TestListAuditEntries は、API 経由の書き込みが操作者つきで記録され、
+// 管理者だけが actor / kind で絞り込んで参照できることを検証する。
+func TestListAuditEntries(t *testing.T) {
+	ctx := context.Background()
+	mem := repository.NewMemory()
+	repo := repository.WithAudit(mem)
+	admin := models.Member{Slack: models.SlackUser{ID: "UADMIN001", IsAdmin: true}}
+	player := models.Member{Slack: models.SlackUser{ID: "UPLAYER01"}}
+	for _, m := range []*models.Member{&admin, &player} {
+		if err := mem.Members().Put(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mem.Events().Put(ctx, &models.Event{Google: models.GoogleEvent{ID: "ev001", Title: "#練習"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 管理者がイベントを削除し、選手が出欠回答する
+	req := httptest.NewRequest(http.MethodPost, "/api/1/events/ev001/delete", nil)
+	req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, "UADMIN001"), repo)
+	if err := repo.Events().Delete(req.Context(), "ev001"); err != nil {
+		t.Fatal(err)
+	}
+	req = filters.SetSessionUserContext(httptest.NewRequest(http.MethodPost, "/", nil), "UPLAYER01")
+	if _, err := repo.Participations().Update(req.Context(), "ev002", "UPLAYER01", func(p *models.Participation) error {
+		p.Type = models.PTJoin
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(slackID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/1/audit?"+query, strings.NewReader(""))
+		req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, slackID), repo)
+		rec := httptest.NewRecorder()
+		filters.Require(models.PermViewAudit)(http.HandlerFunc(ListAuditEntries)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := list("UPLAYER01", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	rec := list("UADMIN001", "kind=Event&actor=UADMIN001")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (body=%s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	entries := []models.AuditEntry{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != "Event.Delete" || entries[0].TargetKey != "ev001" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	found := false
+	for _, c := range entries[0].Changes {
+		if c.Field == "google.title" && c.Before == `"#練習"` && c.After == "" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("changes = %+v", entries[0].Changes)
+	}
+
+	rec = list("UADMIN001", "actor=UPLAYER01")
+	entries = []models.AuditEntry{}
+	json.Unmarshal(rec.Body.Bytes(), &entries)
+	if len(entries) != 1 || entries[0].Kind != models.KindParticipation {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	if rec := list("UADMIN001", "from=yesterday"); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
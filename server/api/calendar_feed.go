@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/otiai10/marmoset"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// calendarFeedPast はフィードに含める過去のイベントの範囲。
+const calendarFeedPast = 90 * 24 * time.Hour
+
+// calendarFeedURL はトークンのフィードの URL（カレンダーアプリに登録する）。
+func calendarFeedURL(token string) string {
+	return fmt.Sprintf("%s/api/1/public/calendar/%s.ics", server.HubBaseURL(), token)
+}
+
+// GetMyCalendarFeed はログインユーザの個人用 iCalendar フィードの URL を返す。無ければ発行する。
+func GetMyCalendarFeed(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+	slackID := filters.GetSessionUserContext(req)
+
+	feeds, err := repo.CalendarFeeds().ListByMember(ctx, slackID)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	if len(feeds) > 0 {
+		w.Header().Add("Cache-Control", "no-store, max-age=0")
+		render.JSON(http.StatusOK, marmoset.P{"url": calendarFeedURL(feeds[0].Token)})
+		return
+	}
+	feed, err := models.NewCalendarFeed(slackID, time.Now())
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	if err := repo.CalendarFeeds().Put(ctx, feed); err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	w.Header().Add("Cache-Control", "no-store, max-age=0")
+	render.JSON(http.StatusOK, marmoset.P{"url": calendarFeedURL(feed.Token)})
+}
+
+// ResetMyCalendarFeed はログインユーザのフィードのトークンを作り直す。以前の URL は使えなくなる。
+func ResetMyCalendarFeed(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+	slackID := filters.GetSessionUserContext(req)
+
+	feeds, err := repo.CalendarFeeds().ListByMember(ctx, slackID)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	for _, feed := range feeds {
+		if err := repo.CalendarFeeds().Delete(ctx, feed.Token); err != nil {
+			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+			return
+		}
+	}
+	feed, err := models.NewCalendarFeed(slackID, time.Now())
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	if err := repo.CalendarFeeds().Put(ctx, feed); err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	render.JSON(http.StatusOK, marmoset.P{"url": calendarFeedURL(feed.Token)})
+}
+
+// GetCalendarFeed はトークンのメンバーの iCalendar フィード（text/calendar）を返す（認証不要）。
+// 過去 90 日以降の、メンバーが閲覧できるイベントを、本人の出欠回答をタイトルに付けて並べる。
+// #ignore のイベントは含めず、キャンセルされたイベントは STATUS:CANCELLED にする。
+func GetCalendarFeed(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	feed, err := repo.CalendarFeeds().Get(ctx, chi.URLParam(req, "token"))
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	member, err := repo.Members().Get(ctx, feed.MemberID)
+	if err != nil || member.Slack.Deleted {
+		http.NotFound(w, req)
+		return
+	}
+
+	now := time.Now()
+	events, err := repo.Events().Find(ctx, repository.EventQuery{From: now.Add(-calendarFeedPast), IncludeCancelled: true})
+	if err != nil {
+		log.Println("[ERROR]", 14001, err.Error())
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	parts, err := repo.Participations().ListByMember(ctx, member.Slack.ID)
+	if err != nil {
+		log.Println("[ERROR]", 14002, err.Error())
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	answers := models.AnswersByEventOf(parts)
+
+	targets := []models.Event{}
+	for _, ev := range events {
+		if ev.VisibleTo(*member) && !ev.HasTag(models.ETIgnore) {
+			targets = append(targets, ev)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	w.Write(buildICalendar(targets, answers, now))
+}
+
+// buildICalendar は events を RFC 5545 の VCALENDAR にする。answers は Event ID → 回答。
+func buildICalendar(events []models.Event, answers models.AnswersByEvent, now time.Time) []byte {
+	buf := bytes.NewBuffer(nil)
+	line := func(name, value string) { writeICalLine(buf, name+":"+value) }
+	stamp := now.UTC().Format(icalTimeFormat)
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//TRIAX//Hub//JA")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", "TRIAX Hub")
+	line("X-WR-TIMEZONE", server.ServiceLocation.String())
+	for _, ev := range events {
+		answer := answers[ev.Google.ID]
+		start := ev.Google.Start()
+		end := time.UnixMilli(ev.Google.EndTime)
+		if !end.After(start) {
+			end = start.Add(2 * time.Hour)
+		}
+		description := fmt.Sprintf("出欠: %s\n%s/events/%s", icalAnswerLabel(answer), server.HubBaseURL(), ev.Google.ID)
+		if ev.Google.Description != "" {
+			description += "\n\n" + ev.Google.Description
+		}
+
+		line("BEGIN", "VEVENT")
+		line("UID", ev.Google.ID+"@triax-hub")
+		line("DTSTAMP", stamp)
+		line("DTSTART", start.UTC().Format(icalTimeFormat))
+		line("DTEND", end.UTC().Format(icalTimeFormat))
+		line("SUMMARY", escapeICalText(fmt.Sprintf("[%s] %s", icalAnswerLabel(answer), ev.Google.Title)))
+		if ev.Google.Location != "" {
+			line("LOCATION", escapeICalText(ev.Google.Location))
+		}
+		line("DESCRIPTION", escapeICalText(description))
+		if ev.Cancelled {
+			line("STATUS", "CANCELLED")
+		} else {
+			line("STATUS", "CONFIRMED")
+		}
+		// 欠席と回答したイベントは予定を「空き」として扱わせる
+		if answer.Type == models.PTAbsent {
+			line("TRANSP", "TRANSPARENT")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return buf.Bytes()
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// icalAnswerLabel は回答の表示（遅参・早退は時刻付き）。
+func icalAnswerLabel(p models.Participation) string {
+	if p.Type.Unanswered() {
+		return models.PTUnanswered.String()
+	}
+	if t, ok := p.Params["time"].(string); ok && t != "" && (p.Type == models.PTJoinLate || p.Type == models.PTLeaveEarly) {
+		return p.Type.String() + " " + t
+	}
+	return p.Type.String()
+}
+
+var icalTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICalText(s string) string {
+	return icalTextEscaper.Replace(s)
+}
+
+// writeICalLine は 1 行を CRLF で書く。75 オクテットを超える行は UTF-8 の文字の途中で切らないように折り返す。
+func writeICalLine(buf *bytes.Buffer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		buf.WriteString(s[:cut])
+		buf.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // 折り返した行は先頭の空白の分だけ短くする
+	}
+	buf.WriteString(s)
+	buf.WriteString("\r\n")
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// TestCalendarFeed は、個人用フィードに本人の回答付きでイベントが並び、トークンを作り直すと古い URL が使えなくなることを検証する。
+func TestCalendarFeed(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	if err := repo.Members().Put(ctx, &models.Member{Slack: models.SlackUser{ID: "UPLAYER"}}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(48 * time.Hour)
+	for _, ev := range []models.Event{
+		{Google: models.GoogleEvent{ID: "practice", Title: "#練習 グラウンド, 午前", Location: "河川敷", StartTime: start.UnixMilli(), EndTime: start.Add(3 * time.Hour).UnixMilli()}},
+		{Google: models.GoogleEvent{ID: "ignored", Title: "#ignore 私用", StartTime: start.UnixMilli()}},
+		{Google: models.GoogleEvent{ID: "staffmtg", Title: "#meeting", StartTime: start.UnixMilli(), Visibility: models.EVStaff}},
+		{Google: models.GoogleEvent{ID: "game", Title: "#試合", StartTime: start.Add(72 * time.Hour).UnixMilli()}, Cancelled: true},
+	} {
+		if err := repo.Events().Put(ctx, &ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.Participations().Update(ctx, "practice", "UPLAYER", func(p *models.Participation) error {
+		return p.Answer(models.PTJoinLate, map[string]interface{}{"time": "10:30"}, time.Now())
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(filters.Repository(repo))
+	r.Get("/api/1/myself/calendar-feed", GetMyCalendarFeed)
+	r.Post("/api/1/myself/calendar-feed/reset", ResetMyCalendarFeed)
+	r.Get("/api/1/public/calendar/{token}.ics", GetCalendarFeed)
+	call := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req = filters.SetSessionUserContext(req, "UPLAYER")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	feedPath := func(rec *httptest.ResponseRecorder) string {
+		res := struct {
+			URL string `json:"url"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res.URL[strings.Index(res.URL, "/api/1/public/calendar/"):]
+	}
+
+	path := feedPath(call(http.MethodGet, "/api/1/myself/calendar-feed"))
+	if again := feedPath(call(http.MethodGet, "/api/1/myself/calendar-feed")); again != path {
+		t.Errorf("feed url changed: %s -> %s", path, again)
+	}
+
+	rec := call(http.MethodGet, path)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/calendar") {
+		t.Fatalf("status = %d, content-type = %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	ics := strings.ReplaceAll(rec.Body.String(), "\r\n ", "") // 折り返しを戻す
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:practice@triax-hub\r\n",
+		`SUMMARY:[遅参 10:30] #練習 グラウンド\, 午前` + "\r\n",
+		"LOCATION:河川敷\r\n",
+		"DTSTART:" + time.UnixMilli(start.UnixMilli()).UTC().Format("20060102T150405Z") + "\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("feed does not contain %q:\n%s", want, ics)
+		}
+	}
+	if !strings.Contains(ics, "SUMMARY:[未回答] #試合\r\n") || !strings.Contains(ics, "STATUS:CANCELLED\r\n") {
+		t.Errorf("cancelled game must be listed as cancelled:\n%s", ics)
+	}
+	if strings.Contains(ics, "ignored@") || strings.Contains(ics, "staffmtg@") {
+		t.Errorf("feed must exclude #ignore and staff-only events:\n%s", ics)
+	}
+
+	reset := feedPath(call(http.MethodPost, "/api/1/myself/calendar-feed/reset"))
+	if reset == path {
+		t.Fatal("token must change on reset")
+	}
+	if rec := call(http.MethodGet, path); rec.Code != http.StatusNotFound {
+		t.Errorf("old feed: status = %d", rec.Code)
+	}
+	if rec := call(http.MethodGet, reset); rec.Code != http.StatusOK {
+		t.Errorf("new feed: status = %d", rec.Code)
+	}
+}
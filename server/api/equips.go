@@ -4,39 +4,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/go-chi/chi/v5"
 	"github.com/otiai10/marmoset"
+	"github.com/triax/hub/server/filters"
 	"github.com/triax/hub/server/models"
 )
 
 func ListEquips(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	equips := []models.Equip{}
-	query := datastore.NewQuery(models.KindEquip)
-	if _, err := client.GetAll(ctx, query, &equips); err != nil && !models.IsFiledMismatch(err) {
+	equips, err := repo.Equips().List(ctx)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
 
 	for i, e := range equips {
-		equips[i].ID = e.Key.ID
-
 		// 最新のHistoryだけ収集する
-		query := datastore.NewQuery(models.KindCustody).Ancestor(e.Key).Order("-Timestamp").Limit(1)
-		client.GetAll(ctx, query, &equips[i].History) // エラーは無視してよい
+		equips[i].History, _ = repo.Equips().History(ctx, e.ID, 1) // エラーは無視してよい
 	}
 
 	if req.URL.Query().Get("cached") == "1" {
@@ -50,29 +40,20 @@ func ListEquips(w http.ResponseWriter, req *http.Request) {
 func GetEquip(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	equip := models.Equip{}
 	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
 	if err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	key := datastore.IDKey(models.KindEquip, id, nil)
-	if err := client.Get(ctx, key, &equip); err != nil && !models.IsFiledMismatch(err) {
+	equip, err := repo.Equips().Get(ctx, id)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
-	equip.ID = key.ID
 
-	query := datastore.NewQuery(models.KindCustody).Ancestor(key).Order("-Timestamp")
-
-	if _, err := client.GetAll(ctx, query, &equip.History); err != nil && !models.IsFiledMismatch(err) {
+	if equip.History, err = repo.Equips().History(ctx, id, 0); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -88,12 +69,7 @@ func GetEquip(w http.ResponseWriter, req *http.Request) {
 func CreateEquipItem(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
 	defer req.Body.Close()
 	equip := models.Equip{}
@@ -101,15 +77,10 @@ func CreateEquipItem(w http.ResponseWriter, req *http.Request) {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	key := datastore.IncompleteKey(models.KindEquip, nil)
-	equip.Key = key
 
-	if created, err := client.Put(ctx, key, &equip); err != nil {
+	if err := repo.Equips().Create(ctx, &equip); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
-	} else {
-		equip.Key = created
-		equip.ID = created.ID
 	}
 
 	render.JSON(http.StatusCreated, equip)
@@ -118,12 +89,7 @@ func CreateEquipItem(w http.ResponseWriter, req *http.Request) {
 func UpdateEquip(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 	defer req.Body.Close()
 
 	equip := models.Equip{}
@@ -143,8 +109,7 @@ func UpdateEquip(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	key := datastore.IDKey(models.KindEquip, id, nil)
-	if _, err := client.Put(ctx, key, &equip); err != nil {
+	if err := repo.Equips().Put(ctx, id, &equip); err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": "Name cannot be empty"})
 		return
 	}
@@ -155,39 +120,24 @@ func UpdateEquip(w http.ResponseWriter, req *http.Request) {
 func DeleteEquip(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
 	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
 	if err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	equip := models.Equip{}
-	key := datastore.IDKey(models.KindEquip, id, nil)
-	if err := client.Get(ctx, key, &equip); err != nil && !models.IsFiledMismatch(err) {
+	equip, err := repo.Equips().Get(ctx, id)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
-	equip.Key = key
-	equip.ID = key.ID
-
-	query := datastore.NewQuery(models.KindCustody).Ancestor(key)
-	if keys, err := client.GetAll(ctx, query, &equip.History); err != nil && !models.IsFiledMismatch(err) {
+	if equip.History, err = repo.Equips().History(ctx, id, 0); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
-	} else {
-		if err := client.DeleteMulti(ctx, keys); err != nil {
-			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-			return
-		}
 	}
 
-	if err := client.Delete(ctx, equip.Key); err != nil {
+	if err := repo.Equips().Delete(ctx, id); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -197,12 +147,7 @@ func DeleteEquip(w http.ResponseWriter, req *http.Request) {
 func EquipCustodyReport(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
 	body := struct {
 		IDs      []int64 `json:"ids"`
@@ -215,26 +160,13 @@ func EquipCustodyReport(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	keys := []*datastore.Key{}
-	custodies := []*models.Custody{}
-	for _, id := range body.IDs {
-		keys = append(keys, datastore.IncompleteKey(
-			models.KindCustody,
-			datastore.IDKey(models.KindEquip, id, nil),
-		))
-		custodies = append(custodies, &models.Custody{
-			MemberID:  body.MemberID,
-			Timestamp: time.Now().Unix() * 1000,
-		})
-	}
-
-	if inserted, err := client.PutMulti(ctx, keys, custodies); err != nil {
+	custodies, err := repo.Equips().AddCustody(ctx, body.IDs, models.Custody{
+		MemberID:  body.MemberID,
+		Timestamp: time.Now().Unix() * 1000,
+	})
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
-	} else {
-		for i, key := range inserted {
-			custodies[i].Key = key
-		}
 	}
 
 	render.JSON(http.StatusAccepted, custodies)
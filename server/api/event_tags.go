@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/otiai10/marmoset"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// ListEventTagRules はタグの定義を判定順に返す（クライアントの TriaxEvent.ts もこれを使う）。
+func ListEventTagRules(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	rules, err := repository.LoadEventTagRules(req.Context(), filters.GetRepositoryContext(req).EventTags())
+	if err != nil {
+		log.Println("[ERROR]", 11002, err.Error())
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	render.JSON(http.StatusOK, rules)
+}
+
+// PutEventTagRule はタグの定義を追加・更新する（PermManageEventTags）。
+//
+// まだ Datastore に定義が無い場合は、既定の定義を保存してから更新する
+// （1 件だけ保存されて他の既定のタグが消えることのないように）。
+func PutEventTagRule(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	rule := models.EventTagRule{}
+	if err := json.NewDecoder(req.Body).Decode(&rule); err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+	rule.Tag = models.EventTag(chi.URLParam(req, "tag"))
+	if rule.Reminders == nil {
+		rule.Reminders = []models.ReminderType{}
+	}
+	if err := rule.Validate(); err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+
+	if err := seedEventTagRules(req, repo); err != nil {
+		log.Println("[ERROR]", 11003, err.Error())
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	if err := repo.EventTags().Put(ctx, &rule); err != nil {
+		log.Println("[ERROR]", 11004, err.Error())
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	if err := filters.ReloadEventTagRules(ctx, repo); err != nil {
+		log.Println("[ERROR]", 11001, err.Error())
+	}
+	render.JSON(http.StatusOK, rule)
+}
+
+// DeleteEventTagRule はタグの定義を削除する（PermManageEventTags）。組み込みのタグは削除できない。
+func DeleteEventTagRule(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	tag := models.EventTag(chi.URLParam(req, "tag"))
+	if models.IsBuiltinEventTag(tag) {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": "builtin tag cannot be deleted"})
+		return
+	}
+	if _, err := repo.EventTags().Get(ctx, tag); err != nil {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+	if err := repo.EventTags().Delete(ctx, tag); err != nil {
+		log.Println("[ERROR]", 11005, err.Error())
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	if err := filters.ReloadEventTagRules(ctx, repo); err != nil {
+		log.Println("[ERROR]", 11001, err.Error())
+	}
+	render.JSON(http.StatusOK, marmoset.P{"tag": tag, "ok": true})
+}
+
+// seedEventTagRules は Datastore に定義が無ければ既定の定義を保存する。
+func seedEventTagRules(req *http.Request, repo repository.Repository) error {
+	stored, err := repo.EventTags().List(req.Context())
+	if err != nil || len(stored) > 0 {
+		return err
+	}
+	for _, rule := range models.DefaultEventTagRules() {
+		if err := repo.EventTags().Put(req.Context(), &rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// TestEventTagRules は、管理者が追加・編集したタグの定義が保存され、
+// 一覧 API とサーバ側のタグ判定の両方に反映されることを検証する。
+func TestEventTagRules(t *testing.T) {
+	t.Cleanup(func() { models.SetEventTagRules(nil) })
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UADMIN001", IsAdmin: true}},
+		{Slack: models.SlackUser{ID: "UPLAYER01"}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := chi.NewRouter()
+	r.Use(filters.Repository(repo))
+	r.Get("/event-tags", ListEventTagRules)
+	r.With(filters.Require(models.PermManageEventTags)).Put("/event-tags/{tag}", PutEventTagRule)
+	r.With(filters.Require(models.PermManageEventTags)).Post("/event-tags/{tag}/delete", DeleteEventTagRule)
+	call := func(slackID, method, path, body string) *httptest.ResponseRecorder {
+		req := filters.SetSessionUserContext(httptest.NewRequest(method, path, strings.NewReader(body)), slackID)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// 未保存の間は既定の定義を返す
+	rec := call("UPLAYER01", http.MethodGet, "/event-tags", "")
+	rules := []models.EventTagRule{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != len(models.DefaultEventTagRules()) || rules[0].Tag != models.ETPractice {
+		t.Errorf("default rules = %+v", rules)
+	}
+
+	camp := `{"label":"合宿","pattern":"[＃#]合宿","order":70,"reminders":["rsvp","final_call"],"taping":true}`
+	if rec := call("UPLAYER01", http.MethodPut, "/event-tags/合宿", camp); rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin put: status = %d", rec.Code)
+	}
+	if rec := call("UADMIN001", http.MethodPut, "/event-tags/合宿", `{"pattern":"[#"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid pattern: status = %d", rec.Code)
+	}
+	if rec := call("UADMIN001", http.MethodPut, "/event-tags/合宿", camp); rec.Code != http.StatusOK {
+		t.Fatalf("put: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	// 既定の定義も一緒に保存される
+	stored, _ := repo.EventTags().List(ctx)
+	if len(stored) != len(models.DefaultEventTagRules())+1 {
+		t.Errorf("stored = %+v", stored)
+	}
+
+	ev := models.Event{Google: models.GoogleEvent{Title: "#合宿 夏合宿"}}
+	if !ev.HasTag("合宿") || !ev.OffersTaping() || ev.ShouldSkipReminders(models.RTRSVP) || !ev.ShouldSkipReminders(models.RTCondition) {
+		t.Errorf("tags = %v", ev.Tags())
+	}
+
+	// 組み込みのタグは編集できるが削除はできない
+	if rec := call("UADMIN001", http.MethodPut, "/event-tags/meeting", `{"label":"MTG","pattern":"[＃#](meeting|mtg)","order":40,"reminders":["rsvp"]}`); rec.Code != http.StatusOK {
+		t.Errorf("put builtin: status = %d", rec.Code)
+	}
+	if (models.Event{Google: models.GoogleEvent{Title: "#mtg 総会"}}).ShouldSkipReminders(models.RTRSVP) {
+		t.Error("edited meeting rule should send RSVP")
+	}
+	if rec := call("UADMIN001", http.MethodPost, "/event-tags/meeting/delete", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("delete builtin: status = %d", rec.Code)
+	}
+	if rec := call("UADMIN001", http.MethodPost, "/event-tags/合宿/delete", ""); rec.Code != http.StatusOK {
+		t.Errorf("delete: status = %d", rec.Code)
+	}
+	if ev.HasTag("合宿") {
+		t.Error("deleted tag should not match")
+	}
+}
@@ -1,90 +1,196 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"html/template"
+	"errors"
 	"net/http"
-	"os"
-	"strings"
+	"slices"
+	"strconv"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/go-chi/chi/v5"
 	"github.com/otiai10/marmoset"
-	"github.com/slack-go/slack"
 	"github.com/triax/hub/server/filters"
 	"github.com/triax/hub/server/models"
-)
-
-var (
-	TplLastMinuteRSVPChange = template.Must(template.New("").Parse(`*{{.event.Google.Start.Format "2006/01/02"}}* {{.event.Google.Title}}
-*{{.prev}} ⇒ {{.next}}* {{.member.Slack.Profile.RealName}} ({{if .member.Slack.Profile.Title}}{{.member.Slack.Profile.Title}}{{else}}ポジション未設定{{end}})`))
+	"github.com/triax/hub/server/repository"
+	"github.com/triax/hub/server/rsvp"
 )
 
 func GetEvent(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
 	id := chi.URLParam(req, "id")
-	event := models.Event{}
-	key := datastore.NameKey(models.KindEvent, id, nil)
-	if err := client.Get(ctx, key, &event); err != nil {
+	event, err := repo.Events().Get(ctx, id)
+	if err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
+	if !event.VisibleTo(sessionMember(req, repo)) {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+	if err := populateParticipations(ctx, repo, event); err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
 	render.JSON(http.StatusOK, event)
 }
 
+// populateParticipations は Participation Kind の回答を Event.ParticipationsJSONString に埋める。
+// クライアントは participations_json_str を読むので、API の形は Participation Kind 導入前と変えない。
+// あわせて出欠回答の締め切り（rsvp_deadline）も埋める。回答は ListByEvents でまとめて読む。
+func populateParticipations(ctx context.Context, repo repository.Repository, events ...*models.Event) error {
+	ids := make([]string, len(events))
+	for i, ev := range events {
+		ids[i] = ev.Google.ID
+	}
+	byEvent, err := repo.Participations().ListByEvents(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, ev := range events {
+		ev.SetRSVPDeadline()
+		if err := ev.SetParticipations(byEvent[ev.Google.ID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionMember はログインユーザの Member を返す。取得できなければゼロ値（スタッフではない扱い）。
+func sessionMember(req *http.Request, repo repository.Repository) models.Member {
+	member, err := repo.Members().Get(req.Context(), filters.GetSessionUserContext(req))
+	if err != nil {
+		return models.Member{}
+	}
+	return *member
+}
+
+// visibleEvents は member が閲覧できる Event だけを返す（スタッフ限定のカレンダーのイベントなどを除く）。
+func visibleEvents(events []models.Event, member models.Member) []models.Event {
+	visible := []models.Event{}
+	for _, ev := range events {
+		if ev.VisibleTo(member) {
+			visible = append(visible, ev)
+		}
+	}
+	return visible
+}
+
 func DeleteEvent(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+	repo := filters.GetRepositoryContext(req)
+
+	id := chi.URLParam(req, "id")
+	if err := repo.Participations().DeleteByEvent(ctx, id); err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	defer client.Close()
-	id := chi.URLParam(req, "id")
-	key := datastore.NameKey(models.KindEvent, id, nil)
-	if err := client.Delete(ctx, key); err != nil {
+	if err := repo.Events().Delete(ctx, id); err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
 	render.JSON(http.StatusOK, marmoset.P{"id": id, "ok": true})
 }
 
-func ListEvents(w http.ResponseWriter, req *http.Request) {
+const (
+	eventsDefaultLimit = 50
+	eventsMaxLimit     = 200
+)
 
+// ListEvents はイベントを開始時刻の順に返す（ログインユーザが閲覧できるものだけ）。
+//
+// クエリ:
+//   - from / to: 期間（"2006-01-02" または RFC3339）。どちらも無ければ 24 時間前以降
+//   - tag: タグ（複数指定した場合はいずれかを持つもの）
+//   - order: "desc" なら新しい順
+//   - limit: 件数（既定 50、最大 200）
+//   - cursor: 続きを読むときに、前の応答の next_cursor を他の条件とあわせて渡す
+//
+// 応答は {"events": [...], "next_cursor": "..."}。続きが無ければ next_cursor は空。
+// 各イベントには回答の内訳（rsvp_counts）と、ログインユーザ本人の回答だけの participations_json_str を含む。
+func ListEvents(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	repo := filters.GetRepositoryContext(req)
+
+	q := req.URL.Query()
+	query := repository.EventQuery{Desc: q.Get("order") == "desc", Limit: eventsDefaultLimit}
+	var err error
+	if query.From, err = parseDateParam(q.Get("from"), false); err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+	if query.To, err = parseDateParam(q.Get("to"), true); err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
+	if query.From.IsZero() && query.To.IsZero() {
+		query.From = time.Now().Add(-24 * time.Hour)
+	}
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			render.JSON(http.StatusBadRequest, marmoset.P{"error": "invalid limit"})
+			return
+		}
+		query.Limit = min(limit, eventsMaxLimit)
+	}
+
+	member := sessionMember(req, repo)
+	tags := q["tag"]
+	match := func(ev models.Event) bool {
+		if !ev.VisibleTo(member) {
+			return false
+		}
+		return len(tags) == 0 || slices.ContainsFunc(tags, func(t string) bool { return ev.HasTag(models.EventTag(t)) })
+	}
+	events, next, err := repo.Events().Page(ctx, query, q.Get("cursor"), match)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
-	defer client.Close()
-
-	var offset time.Duration = 24 * time.Hour
-	events := []models.Event{}
-	query := datastore.NewQuery(models.KindEvent).
-		Filter("Google.StartTime >", time.Now().Add(-offset).Unix()*1000).
-		Order("Google.StartTime")
 
-	if _, err := client.GetAll(ctx, query, &events); err != nil {
+	members, err := repo.Members().List(ctx, false)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	ids := make([]string, len(events))
+	for i, ev := range events {
+		ids[i] = ev.Google.ID
+	}
+	byEvent, err := repo.Participations().ListByEvents(ctx, ids)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
+	// 一覧では回答の内訳と本人の回答だけを返す。全員の回答は GetEvent で返す
+	for i := range events {
+		parts := byEvent[events[i].Google.ID]
+		events[i].SetRSVPDeadline()
+		events[i].SetRSVPCounts(parts, members)
+		own := slices.DeleteFunc(slices.Clone(parts), func(p models.Participation) bool { return p.MemberID != member.Slack.ID })
+		if err := events[i].SetParticipations(own); err != nil {
+			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+			return
+		}
+	}
 
-	render.JSON(http.StatusOK, events)
+	render.JSON(http.StatusOK, marmoset.P{"events": events, "next_cursor": next})
 }
 
+// AnswerEvent はログインユーザの出欠を回答する（rsvp.Answer）。
+// キャンセル済みのイベントとログインユーザが閲覧できないイベントは 404 を返す。
+// 出欠回答の締め切りを過ぎた回答に reason が無ければ、reason_required を付けて 400 を返す。
 func AnswerEvent(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	slackID := filters.GetSessionUserContext(req)
@@ -95,93 +201,158 @@ func AnswerEvent(w http.ResponseWriter, req *http.Request) {
 		} `json:"event"`
 		Type   models.ParticipationType `json:"type"`
 		Params map[string]interface{}   `json:"params"`
+		// Reason は締め切り後の回答の理由。
+		Reason string `json:"reason"`
 	}{}
 	defer req.Body.Close()
 	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
+	if !body.Type.Answerable() {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": "invalid type"})
+		return
+	}
 
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	repo := filters.GetRepositoryContext(req)
+
+	member, err := repo.Members().Get(ctx, slackID)
 	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	defer client.Close()
 
-	member := models.Member{}
-	if err := client.Get(ctx,
-		datastore.NameKey(models.KindMember, slackID, nil),
-		&member,
-	); err != nil && !models.IsFiledMismatch(err) {
+	event, err := repo.Events().Get(ctx, body.Event.ID)
+	if err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
 
-	event := models.Event{}
-	key := datastore.NameKey(models.KindEvent, body.Event.ID, nil)
-	if err := client.Get(ctx, key, &event); err != nil {
+	if _, err := rsvp.Answer(ctx, repo, *member, *event, body.Type, body.Params, body.Reason, time.Now()); err != nil {
+		if errors.Is(err, rsvp.ErrEventUnavailable) {
+			render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+			return
+		}
+		if errors.Is(err, rsvp.ErrReasonRequired) {
+			deadline, _ := event.RSVPDeadlineAt()
+			render.JSON(http.StatusBadRequest, marmoset.P{
+				"error":           err.Error(),
+				"reason_required": true,
+				"rsvp_deadline":   deadline.UnixMilli(),
+			})
+			return
+		}
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	if event.ParticipationsJSONString == "" {
-		event.ParticipationsJSONString = "{}"
+
+	if err := populateParticipations(ctx, repo, event); err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
 	}
-	parts := models.Participations{}
-	if err := json.NewDecoder(strings.NewReader(event.ParticipationsJSONString)).Decode(&parts); err != nil {
+	render.JSON(http.StatusAccepted, event)
+}
+
+// AnswerEventFor はスタッフがメンバーの代わりに出欠を回答する（rsvp.AnswerFor、PermAnswerForOthers）。
+// 回答したスタッフは Participation.AnsweredBy に残り、メンバーには記録した内容が DM される。
+// 締め切り後の扱いは AnswerEvent と同じ。キャンセル済みのイベントやメンバーが閲覧できないイベントは 404、
+// 退部・休眠などで回答が期待されないメンバー（IsExpectedToRSVP）は 409 を返す。
+func AnswerEventFor(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	proxy := sessionMember(req, repo)
+	body := struct {
+		MemberID string                   `json:"member_id"`
+		Type     models.ParticipationType `json:"type"`
+		Params   map[string]interface{}   `json:"params"`
+		Reason   string                   `json:"reason"`
+	}{}
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-
-	if p, ok := parts[slackID]; ok && shouldNoticeRSVPChangeToSlack(event, p.Type, body.Type) {
-		token := os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN")
-		channel, msg := buildSlackMessageOfLastMinuteRSVPChange(member, event, p.Type, body.Type)
-		slack.New(token).PostMessageContext(ctx, channel, msg...) // は〜エラー見るのめんどくせ
+	if !body.Type.Answerable() {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": "invalid type"})
+		return
 	}
 
-	parts[slackID] = models.Participation{
-		Type:   body.Type,
-		Params: body.Params,
+	member, err := repo.Members().Get(ctx, body.MemberID)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": "unknown member: " + body.MemberID})
+		return
 	}
-	b, err := json.Marshal(parts)
+	event, err := repo.Events().Get(ctx, chi.URLParam(req, "id"))
 	if err != nil {
-		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
 		return
 	}
-	event.ParticipationsJSONString = string(b)
-	if _, err := client.Put(ctx, key, &event); err != nil {
+	// キャンセル済みのイベントと、対象のメンバー本人が閲覧できないイベントには回答させない
+	if event.Cancelled || !event.VisibleTo(*member) {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+	if member.Slack.Deleted || !member.IsExpectedToRSVP() {
+		render.JSON(http.StatusConflict, marmoset.P{"error": "member is not expected to answer: " + body.MemberID})
+		return
+	}
+
+	if _, err := rsvp.AnswerFor(ctx, repo, proxy, *member, *event, body.Type, body.Params, body.Reason, time.Now()); err != nil {
+		if errors.Is(err, rsvp.ErrReasonRequired) {
+			deadline, _ := event.RSVPDeadlineAt()
+			render.JSON(http.StatusBadRequest, marmoset.P{
+				"error":           err.Error(),
+				"reason_required": true,
+				"rsvp_deadline":   deadline.UnixMilli(),
+			})
+			return
+		}
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
 
+	if err := populateParticipations(ctx, repo, event); err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
 	render.JSON(http.StatusAccepted, event)
 }
 
-func shouldNoticeRSVPChangeToSlack(event models.Event, prev, next models.ParticipationType) bool {
-	// 同じものの場合は除外
-	if prev == next {
-		return false
+// UpdateEventRSVPDeadline はイベントの出欠回答の締め切りを変える（PermManageEvents）。
+// deadline（ミリ秒）が 0 ならタグの既定の締め切りに戻す。
+func UpdateEventRSVPDeadline(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	body := struct {
+		Deadline int64 `json:"deadline"`
+	}{}
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Deadline < 0 {
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": "invalid deadline"})
+		return
 	}
-	// 試合なら「欠席」の場合通知
-	if event.IsGame() {
-		return next == models.PTAbsent
+
+	id := chi.URLParam(req, "id")
+	if _, err := repo.Events().Get(ctx, id); err != nil {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
 	}
-	// 以下、練習など: 水曜正午から、という想定のもと、計算めんどくさいので決め打ちで
-	// 水曜 12 + 木曜 24 + 金曜 24 + 土曜 7 = 67時間前 としてます.
-	if time.Until(event.Google.Start()) <= 67*time.Hour {
-		return true
+	event, err := repo.Events().Update(ctx, id, func(ev *models.Event) error {
+		ev.RSVPDeadlineOverride = body.Deadline
+		return nil
+	})
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
 	}
-	return false
-}
-
-func buildSlackMessageOfLastMinuteRSVPChange(m models.Member, e models.Event, p, n models.ParticipationType) (string, []slack.MsgOption) {
-	loc := time.FixedZone("Asia/Tokyo", 9*60*60)
-	start := e.Google.Start().In(loc)
-	return "practice", []slack.MsgOption{
-		slack.MsgOptionBlocks(
-			slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, start.Format("2006/01/02 ")+e.Google.Title, false, false)),
-			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s ⇒ %s* %s", p, n, m.Name()), false, false), nil, nil),
-		),
+	if err := populateParticipations(ctx, repo, event); err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
 	}
+	render.JSON(http.StatusOK, event)
 }
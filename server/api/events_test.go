@@ -0,0 +1,443 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+	"github.com/triax/hub/slackfake"
+)
+
+func newAnswerEventRequest(repo repository.Repository, slackID, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/1/events/answer", strings.NewReader(body))
+	req = filters.SetSessionUserContext(req, slackID)
+	return filters.SetRepositoryContext(req, repo)
+}
+
+// TestAnswerEvent は、回答が Event の参加情報として保存されることを検証する。
+func TestAnswerEvent(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	member := models.Member{Slack: models.SlackUser{ID: "U12345678"}}
+	if err := repo.Members().Put(ctx, &member); err != nil {
+		t.Fatal(err)
+	}
+	event := models.Event{Google: models.GoogleEvent{
+		ID:        "ev001",
+		Title:     "練習",
+		StartTime: time.Now().Add(7*24*time.Hour).Unix() * 1000,
+	}}
+	if err := repo.Events().Put(ctx, &event); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	AnswerEvent(rec, newAnswerEventRequest(repo, "U12345678", `{"event":{"id":"ev001"},"type":"join_late","params":{"time":"10:00"}}`))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d (body=%s)", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+
+	p, err := repo.Participations().Get(ctx, "ev001", "U12345678")
+	if err != nil {
+		t.Fatalf("participation not saved: %v", err)
+	}
+	if p.Type != models.PTJoinLate || p.Params["time"] != "10:00" || p.AnsweredAt == 0 {
+		t.Errorf("participation = %+v", p)
+	}
+
+	// 応答は participations_json_str 互換の形を保つ
+	res := models.Event{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	parts := models.Participations{}
+	if err := json.Unmarshal([]byte(res.ParticipationsJSONString), &parts); err != nil {
+		t.Fatal(err)
+	}
+	if parts["U12345678"].Type != models.PTJoinLate {
+		t.Errorf("participations_json_str = %s", res.ParticipationsJSONString)
+	}
+
+	// 再回答すると以前の回答は History に残る
+	rec = httptest.NewRecorder()
+	AnswerEvent(rec, newAnswerEventRequest(repo, "U12345678", `{"event":{"id":"ev001"},"type":"join_late","params":{"time":"11:00"}}`))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d (body=%s)", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+	p, _ = repo.Participations().Get(ctx, "ev001", "U12345678")
+	if p.Params["time"] != "11:00" || len(p.History) != 1 || p.History[0].ParamsJSON != `{"time":"10:00"}` {
+		t.Errorf("participation = %+v", p)
+	}
+}
+
+// TestAnswerEvent_UnknownEvent は、存在しない Event への回答で 400 を返すことを検証する。
+func TestAnswerEvent_UnknownEvent(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	member := models.Member{Slack: models.SlackUser{ID: "U12345678"}}
+	if err := repo.Members().Put(ctx, &member); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	AnswerEvent(rec, newAnswerEventRequest(repo, "U12345678", `{"event":{"id":"nope"},"type":"join"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// TestAnswerEvent_Unavailable は、スタッフ限定・キャンセル済みのイベントと不正な種類の回答を受け付けないことを検証する。
+func TestAnswerEvent_Unavailable(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UPLAYER"}},
+		{Slack: models.SlackUser{ID: "USTAFF"}, Positions: models.MemberPositions{StaffRoles: []models.StaffRole{models.SRStaff}}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(7 * 24 * time.Hour).UnixMilli()
+	for _, ev := range []models.Event{
+		{Google: models.GoogleEvent{ID: "staff", Title: "スタッフMTG", Visibility: models.EVStaff, StartTime: start}},
+		{Google: models.GoogleEvent{ID: "cancelled", Title: "#練習", StartTime: start}, Cancelled: true},
+		{Google: models.GoogleEvent{ID: "practice", Title: "#練習", StartTime: start}},
+	} {
+		if err := repo.Events().Put(ctx, &ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	answer := func(slackID, body string) int {
+		rec := httptest.NewRecorder()
+		AnswerEvent(rec, newAnswerEventRequest(repo, slackID, body))
+		return rec.Code
+	}
+
+	if code := answer("UPLAYER", `{"event":{"id":"staff"},"type":"join"}`); code != http.StatusNotFound {
+		t.Errorf("staff event by player: status = %d", code)
+	}
+	if _, err := repo.Participations().Get(ctx, "staff", "UPLAYER"); err != repository.ErrNotFound {
+		t.Errorf("participation saved: %v", err)
+	}
+	if code := answer("USTAFF", `{"event":{"id":"staff"},"type":"join"}`); code != http.StatusAccepted {
+		t.Errorf("staff event by staff: status = %d", code)
+	}
+	if code := answer("UPLAYER", `{"event":{"id":"cancelled"},"type":"join"}`); code != http.StatusNotFound {
+		t.Errorf("cancelled event: status = %d", code)
+	}
+	for _, typ := range []string{"unanswered", "maybe", ""} {
+		if code := answer("UPLAYER", `{"event":{"id":"practice"},"type":"`+typ+`"}`); code != http.StatusBadRequest {
+			t.Errorf("type %q: status = %d", typ, code)
+		}
+	}
+}
+
+// TestListEvents_Visibility は、スタッフ限定のイベントがスタッフ以外に返らないことを検証する。
+func TestListEvents_Visibility(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UPLAYER"}, Positions: models.MemberPositions{Primary: "QB", Unit: "offence"}},
+		{Slack: models.SlackUser{ID: "USTAFF"}, Positions: models.MemberPositions{Unit: "staff", StaffRoles: []models.StaffRole{models.SRStaff}}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(24 * time.Hour).UnixMilli()
+	for _, ev := range []models.Event{
+		{Google: models.GoogleEvent{ID: "practice", Title: "#練習", StartTime: start}},
+		{Google: models.GoogleEvent{ID: "staffmtg", Title: "#meeting", StartTime: start, Source: "staff", Visibility: models.EVStaff}},
+	} {
+		if err := repo.Events().Put(ctx, &ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for slackID, want := range map[string]int{"UPLAYER": 1, "USTAFF": 2} {
+		req := httptest.NewRequest(http.MethodGet, "/api/1/events", nil)
+		req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, slackID), repo)
+		rec := httptest.NewRecorder()
+		ListEvents(rec, req)
+		res := struct {
+			Events []models.Event `json:"events"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Events) != want {
+			t.Errorf("%s: events = %d, want %d", slackID, len(res.Events), want)
+		}
+	}
+}
+
+// TestListEvents_Paging は、期間・タグで絞り込んだ過去のイベントを cursor で順に読めて、回答の内訳が付くことを検証する。
+func TestListEvents_Paging(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, id := range []string{"UPLAYER", "UOTHER", "UQUIET"} {
+		if err := repo.Members().Put(ctx, &models.Member{Slack: models.SlackUser{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		for _, title := range []string{"#練習", "#meeting"} {
+			ev := models.Event{Google: models.GoogleEvent{ID: fmt.Sprintf("%s%d", title[1:], i), Title: title, StartTime: base.AddDate(0, 0, i).UnixMilli()}}
+			if err := repo.Events().Put(ctx, &ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for id, typ := range map[string]models.ParticipationType{"UPLAYER": models.PTJoin, "UOTHER": models.PTAbsent} {
+		if _, err := repo.Participations().Update(ctx, "練習4", id, func(p *models.Participation) error {
+			return p.Answer(typ, nil, base)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(query string) (ids []string, next string, res []models.Event) {
+		req := httptest.NewRequest(http.MethodGet, "/api/1/events?"+query, nil)
+		req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, "UPLAYER"), repo)
+		rec := httptest.NewRecorder()
+		ListEvents(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+		}
+		body := struct {
+			Events     []models.Event `json:"events"`
+			NextCursor string         `json:"next_cursor"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range body.Events {
+			ids = append(ids, ev.Google.ID)
+		}
+		return ids, body.NextCursor, body.Events
+	}
+
+	query := "from=2025-04-01&to=2025-04-30&tag=%E7%B7%B4%E7%BF%92&order=desc&limit=2"
+	ids, next, events := list(query)
+	if strings.Join(ids, ",") != "練習4,練習3" || next == "" {
+		t.Fatalf("page 1 = %v, next = %q", ids, next)
+	}
+	if c := events[0].RSVPCounts; c[models.PTJoin] != 1 || c[models.PTAbsent] != 1 || c[models.PTUnanswered] != 1 {
+		t.Errorf("rsvp_counts = %v", c)
+	}
+	// 一覧には本人の回答だけが入る
+	parts := models.Participations{}
+	if err := json.Unmarshal([]byte(events[0].ParticipationsJSONString), &parts); err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 || parts["UPLAYER"].Type != models.PTJoin {
+		t.Errorf("participations_json_str = %s", events[0].ParticipationsJSONString)
+	}
+	ids, next, _ = list(query + "&cursor=" + next)
+	if strings.Join(ids, ",") != "練習2,練習1" || next == "" {
+		t.Fatalf("page 2 = %v, next = %q", ids, next)
+	}
+	ids, next, _ = list(query + "&cursor=" + next)
+	if strings.Join(ids, ",") != "練習0" || next != "" {
+		t.Fatalf("page 3 = %v, next = %q", ids, next)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/1/events?cursor=broken", nil)
+	req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, "UPLAYER"), repo)
+	rec := httptest.NewRecorder()
+	ListEvents(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("broken cursor: status = %d", rec.Code)
+	}
+}
+
+// TestAnswerEvent_Deadline は、締め切り後の回答に理由が必要で、遅い変更としてポジションのコーチに DM されることを検証する。
+func TestAnswerEvent_Deadline(t *testing.T) {
+	ctx := context.Background()
+	fake := slackfake.New(slackfake.DefaultTeam, nil)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UPLAYER"}, Positions: models.MemberPositions{Primary: "OL", Unit: "offence"}},
+		{Slack: models.SlackUser{ID: "UOLCOACH"}, Positions: models.MemberPositions{Primary: "OL", Unit: "offence", StaffRoles: []models.StaffRole{models.SRCoach}}},
+		{Slack: models.SlackUser{ID: "UDBCOACH"}, Positions: models.MemberPositions{Primary: "DB", Unit: "defence", StaffRoles: []models.StaffRole{models.SRCoach}}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// #練習 の締め切りは 67 時間前なので、2 日後の練習は締め切り後
+	event := models.Event{Google: models.GoogleEvent{ID: "ev001", Title: "#練習", StartTime: time.Now().Add(48 * time.Hour).UnixMilli()}}
+	if err := repo.Events().Put(ctx, &event); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Participations().Update(ctx, "ev001", "UPLAYER", func(p *models.Participation) error {
+		return p.Answer(models.PTJoin, nil, time.Now().Add(-72*time.Hour))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	AnswerEvent(rec, newAnswerEventRequest(repo, "UPLAYER", `{"event":{"id":"ev001"},"type":"absent"}`))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "reason_required") {
+		t.Fatalf("without reason: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	AnswerEvent(rec, newAnswerEventRequest(repo, "UPLAYER", `{"event":{"id":"ev001"},"type":"absent","reason":"発熱のため"}`))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("with reason: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	p, _ := repo.Participations().Get(ctx, "ev001", "UPLAYER")
+	if p.Type != models.PTAbsent || !p.Late || p.LateReason != "発熱のため" || p.History[0].Late {
+		t.Errorf("participation = %+v", p)
+	}
+	msgs := fake.Messages("")
+	if len(msgs) != 1 || !strings.Contains(string(msgs[0].Blocks), "発熱のため") {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	if ch := msgs[0].Channel; !strings.HasPrefix(ch, "D") {
+		t.Errorf("message must be a DM to the OL coach, got channel %s", ch)
+	}
+
+	// 管理者は締め切りを個別に変えられる（ここでは 1 日後 = 締め切り前に戻す）
+	admin := models.Member{Slack: models.SlackUser{ID: "UADMIN", IsAdmin: true}}
+	if err := repo.Members().Put(ctx, &admin); err != nil {
+		t.Fatal(err)
+	}
+	r := chi.NewRouter()
+	r.Use(filters.Repository(repo))
+	r.Put("/events/{id}/rsvp-deadline", func(w http.ResponseWriter, req *http.Request) {
+		UpdateEventRSVPDeadline(w, filters.SetSessionUserContext(req, "UADMIN"))
+	})
+	deadline := time.Now().Add(24 * time.Hour).UnixMilli()
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/events/ev001/rsvp-deadline", strings.NewReader(fmt.Sprintf(`{"deadline":%d}`, deadline))))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), fmt.Sprintf(`"rsvp_deadline": %d`, deadline)) {
+		t.Fatalf("update deadline: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	AnswerEvent(rec, newAnswerEventRequest(repo, "UPLAYER", `{"event":{"id":"ev001"},"type":"join"}`))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("before overridden deadline: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	if p, _ := repo.Participations().Get(ctx, "ev001", "UPLAYER"); p.Late {
+		t.Errorf("answer before the deadline must not be late: %+v", p)
+	}
+}
+
+// TestAnswerEventFor は、スタッフの代理回答が回答者付きで記録され、本人に DM されることを検証する。
+func TestAnswerEventFor(t *testing.T) {
+	ctx := context.Background()
+	fake := slackfake.New(slackfake.DefaultTeam, nil)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UNEWBIE"}, Positions: models.MemberPositions{Primary: "WR", Unit: "offence"}},
+		{Slack: models.SlackUser{ID: "UCOACH", RealName: "コーチ太郎"}, Positions: models.MemberPositions{Primary: "WR", Unit: "offence", StaffRoles: []models.StaffRole{models.SRCoach}}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event := models.Event{Google: models.GoogleEvent{ID: "ev001", Title: "#練習", StartTime: time.Now().Add(7 * 24 * time.Hour).UnixMilli()}}
+	if err := repo.Events().Put(ctx, &event); err != nil {
+		t.Fatal(err)
+	}
+
+	answerFor := func(slackID, body string) *httptest.ResponseRecorder {
+		r := chi.NewRouter()
+		r.Use(filters.Repository(repo))
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				next.ServeHTTP(w, filters.SetSessionUserContext(req, slackID))
+			})
+		})
+		r.With(filters.Require(models.PermAnswerForOthers)).Post("/events/{id}/proxy-answer", AnswerEventFor)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events/ev001/proxy-answer", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := answerFor("UNEWBIE", `{"member_id":"UCOACH","type":"absent"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("non-staff: status = %d", rec.Code)
+	}
+	rec := answerFor("UCOACH", `{"member_id":"UNEWBIE","type":"join_late","params":{"time":"10:30"}}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	p, err := repo.Participations().Get(ctx, "ev001", "UNEWBIE")
+	if err != nil || p.Type != models.PTJoinLate || p.AnsweredBy != "UCOACH" {
+		t.Fatalf("participation = %+v, %v", p, err)
+	}
+	msgs := fake.Messages("")
+	if len(msgs) != 1 || !strings.HasPrefix(msgs[0].Channel, "D") || !strings.Contains(msgs[0].Text, "コーチ太郎 さんが") || !strings.Contains(msgs[0].Text, "*遅参 10:30*") {
+		t.Errorf("messages = %+v", msgs)
+	}
+
+	// 本人が回答し直すと代理の記録は履歴に移る
+	rec = httptest.NewRecorder()
+	AnswerEvent(rec, newAnswerEventRequest(repo, "UNEWBIE", `{"event":{"id":"ev001"},"type":"join"}`))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("self answer: status = %d", rec.Code)
+	}
+	p, _ = repo.Participations().Get(ctx, "ev001", "UNEWBIE")
+	if p.AnsweredBy != "" || len(p.History) != 1 || p.History[0].AnsweredBy != "UCOACH" {
+		t.Errorf("participation after self answer = %+v", p)
+	}
+
+	// 本人が回答できないイベント・メンバーには代理でも回答できない
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UDORMANT"}, Status: models.MSInactive},
+		{Slack: models.SlackUser{ID: "ULEFT", Deleted: true}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rec := answerFor("UCOACH", `{"member_id":"UDORMANT","type":"absent"}`); rec.Code != http.StatusConflict {
+		t.Errorf("dormant member: status = %d", rec.Code)
+	}
+	if rec := answerFor("UCOACH", `{"member_id":"ULEFT","type":"absent"}`); rec.Code != http.StatusConflict {
+		t.Errorf("deleted member: status = %d", rec.Code)
+	}
+	if _, err := repo.Events().Update(ctx, "ev001", func(ev *models.Event) error {
+		ev.Google.Visibility = models.EVStaff
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := answerFor("UCOACH", `{"member_id":"UNEWBIE","type":"absent"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("invisible event: status = %d", rec.Code)
+	}
+	if _, err := repo.Events().Update(ctx, "ev001", func(ev *models.Event) error {
+		ev.Google.Visibility = models.EVAll
+		ev.Cancelled = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := answerFor("UCOACH", `{"member_id":"UNEWBIE","type":"absent"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("cancelled event: status = %d", rec.Code)
+	}
+	if p, _ := repo.Participations().Get(ctx, "ev001", "UNEWBIE"); p.Type != models.PTJoin {
+		t.Errorf("participation changed: %+v", p)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/otiai10/marmoset"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+)
+
+// GetEventHeadcount はイベントのポジションごとの参加予定人数（参加・遅参・早退・欠席・未回答の内訳）を返す。
+// warnings は最低人数（EventTagRule.Minimums）に満たないポジションの警告。
+func GetEventHeadcount(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	id := chi.URLParam(req, "id")
+	ev, err := repo.Events().Get(ctx, id)
+	if err != nil || !ev.VisibleTo(sessionMember(req, repo)) {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+	members, err := repo.Members().List(ctx, false)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	parts, err := repo.Participations().ListByEvent(ctx, id)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+
+	counts := models.NewHeadcounts(*ev, parts, members)
+	warnings := []string{}
+	for _, c := range models.Shortages(counts) {
+		warnings = append(warnings, c.Warning())
+	}
+	render.JSON(http.StatusOK, marmoset.P{"event_id": id, "positions": counts, "warnings": warnings})
+}
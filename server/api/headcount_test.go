@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// TestGetEventHeadcount は、ポジションごとに回答が数えられ、最低人数に満たないポジションが警告されることを検証する。
+func TestGetEventHeadcount(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	members := []models.Member{
+		{Slack: models.SlackUser{ID: "UQB", RealName: "qb"}, Positions: models.MemberPositions{Primary: "QB", Unit: "offence"}},
+		{Slack: models.SlackUser{ID: "UOL1", RealName: "ol1"}, Positions: models.MemberPositions{Primary: "OL", Unit: "offence"}},
+		{Slack: models.SlackUser{ID: "UOL2", RealName: "ol2"}, Positions: models.MemberPositions{Primary: "OL", Unit: "offence"}},
+		{Slack: models.SlackUser{ID: "UOL3", RealName: "ol3"}, Positions: models.MemberPositions{Primary: "OL", Unit: "offence"}},
+		{Slack: models.SlackUser{ID: "UCOACH", RealName: "coach"}, Positions: models.MemberPositions{Primary: "OL", Unit: "offence", StaffRoles: []models.StaffRole{models.SRCoach}}},
+	}
+	for _, m := range members {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ev := models.Event{Google: models.GoogleEvent{ID: "ev001", Title: "#練習", StartTime: time.Now().Add(48 * time.Hour).UnixMilli()}}
+	if err := repo.Events().Put(ctx, &ev); err != nil {
+		t.Fatal(err)
+	}
+	for id, typ := range map[string]models.ParticipationType{"UQB": models.PTJoin, "UOL1": models.PTJoinLate, "UOL2": models.PTAbsent, "UCOACH": models.PTJoin} {
+		if _, err := repo.Participations().Update(ctx, "ev001", id, func(p *models.Participation) error {
+			return p.Answer(typ, nil, time.Now())
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/1/events/ev001/headcount", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "ev001")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, "UQB"), repo)
+	rec := httptest.NewRecorder()
+	GetEventHeadcount(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	res := struct {
+		Positions []models.PositionHeadcount `json:"positions"`
+		Warnings  []string                   `json:"warnings"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]models.PositionHeadcount{}
+	for _, c := range res.Positions {
+		counts[c.Position] = c
+	}
+	if qb := counts["QB"]; qb.Join != 1 || qb.Expected != 1 || qb.Minimum != 1 || qb.Short {
+		t.Errorf("QB = %+v", qb)
+	}
+	if ol := counts["OL"]; ol.JoinLate != 1 || ol.Absent != 1 || ol.Unanswered != 1 || ol.Expected != 1 || ol.Minimum != 5 || !ol.Short {
+		t.Errorf("OL = %+v", ol)
+	}
+	if others := counts[models.PositionOthers]; others.Join != 1 {
+		t.Errorf("OTHERS = %+v", others)
+	}
+	if len(res.Warnings) != 1 || res.Warnings[0] != "OL: 参加予定 1 人（最低 5 人、未回答 1 人）" {
+		t.Errorf("warnings = %v", res.Warnings)
+	}
+}
@@ -34,7 +34,7 @@ func GetHPProfile(w http.ResponseWriter, req *http.Request) {
 		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
 		return
 	}
-	profile, err := models.GetHPProfile(req.Context(), id)
+	profile, err := filters.GetRepositoryContext(req).HPProfiles().Get(req.Context(), id)
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
@@ -59,7 +59,8 @@ func UpdateHPProfile(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// 既存プロフィールを取得して写真 URL を保持する（PUT で消えないように）
-	existing, err := models.GetHPProfile(req.Context(), id)
+	repo := filters.GetRepositoryContext(req)
+	existing, err := repo.HPProfiles().Get(req.Context(), id)
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
@@ -74,7 +75,7 @@ func UpdateHPProfile(w http.ResponseWriter, req *http.Request) {
 	// PUT ボディに含まれる値（nil でも [] でも）は常に無視して既存値を保持する。
 	input.AdditionalPhotoURLs = existing.AdditionalPhotoURLs
 
-	if err := models.PutHPProfile(req.Context(), id, &input); err != nil {
+	if err := repo.HPProfiles().Put(req.Context(), id, &input); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -136,7 +137,8 @@ func UploadHPPhoto(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// プロフィールを更新して URL を保存
-	profile, err := models.GetHPProfile(req.Context(), id)
+	repo := filters.GetRepositoryContext(req)
+	profile, err := repo.HPProfiles().Get(req.Context(), id)
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
@@ -149,7 +151,7 @@ func UploadHPPhoto(w http.ResponseWriter, req *http.Request) {
 	case "additional":
 		profile.AdditionalPhotoURLs = append(profile.AdditionalPhotoURLs, publicURL)
 	}
-	if err := models.PutHPProfile(req.Context(), id, profile); err != nil {
+	if err := repo.HPProfiles().Put(req.Context(), id, profile); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -190,8 +192,9 @@ func uploadToGCS(ctx context.Context, objectName, mimeType string, r io.Reader)
 func ListPublicMembers(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
 
-	members, err := models.GetAllMembers(ctx)
+	members, err := repo.Members().List(ctx, false)
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
@@ -204,7 +207,7 @@ func ListPublicMembers(w http.ResponseWriter, req *http.Request) {
 		HPProfile models.MemberHPProfile `json:"hp_profile"`
 	}
 
-	profiles, err := models.GetMultiHPProfile(ctx, members)
+	profiles, err := repo.HPProfiles().GetMulti(ctx, members)
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
@@ -2,33 +2,27 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"regexp"
+	"slices"
+	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/go-chi/chi/v5"
 	"github.com/otiai10/marmoset"
+	"github.com/triax/hub/server/filters"
 	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
 )
 
 func ListMembers(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	members := []models.Member{}
-	query := datastore.NewQuery(models.KindMember)
-	if req.URL.Query().Get("include_deleted") != "1" {
-		query = query.Filter("Slack.Deleted =", false)
-	}
-	if _, err := client.GetAll(ctx, query, &members); err != nil && !models.IsFiledMismatch(err) {
+	members, err := repo.Members().List(ctx, req.URL.Query().Get("include_deleted") == "1")
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -60,17 +54,11 @@ func ListMembers(w http.ResponseWriter, req *http.Request) {
 func GetMember(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
 	id := chi.URLParam(req, "id")
-	member := models.Member{}
-	key := datastore.NameKey(models.KindMember, id, nil)
-	if err := client.Get(ctx, key, &member); err != nil && !models.IsFiledMismatch(err) {
+	member, err := repo.Members().Get(ctx, id)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -80,51 +68,144 @@ func GetMember(w http.ResponseWriter, req *http.Request) {
 	render.JSON(http.StatusOK, member)
 }
 
+// errForbidden は更新の途中で権限が足りないと分かったことを表す。
+var errForbidden = errors.New("forbidden")
+
+// UpdateMemberProps はメンバーの参加状態・背番号・ポジション・役割・入退部日を更新する。
+// 本人が変えられるのは背番号だけで、それ以外は PermManageMembers、役割と StaffRoles の変更は PermManageRoles が要る。
+// 参加状態の変更は status_reason / effective_at とともに変更履歴に残す。
 func UpdateMemberProps(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 	id := chi.URLParam(req, "id")
 
 	props := struct {
-		Status *models.MemberStatus
-		Number *int
+		Status    *models.MemberStatus
+		Number    *int
+		Positions *models.MemberPositions
+		Roles     *[]models.Role
+		// StatusReason / EffectiveAt は Status の変更履歴に残す理由と有効日
+		StatusReason string     `json:"status_reason"`
+		EffectiveAt  time.Time  `json:"effective_at"`
+		JoinedAt     *time.Time `json:"joined_at"`
+		LeftAt       *time.Time `json:"left_at"`
 	}{}
 	if err := json.NewDecoder(req.Body).Decode(&props); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
 
-	member := &models.Member{}
-	key := datastore.NameKey(models.KindMember, id, nil)
-	if err := client.Get(ctx, key, member); err != nil && !models.IsFiledMismatch(err) {
+	caller := sessionMember(req, repo)
+	self := id == caller.Slack.ID && props.Status == nil && props.Positions == nil && props.JoinedAt == nil && props.LeftAt == nil
+	if (!self && !caller.Can(models.PermManageMembers)) || (props.Roles != nil && !caller.Can(models.PermManageRoles)) {
+		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
+		return
+	}
+	if props.Roles != nil {
+		if err := models.ValidateRoles(*props.Roles); err != nil {
+			render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+			return
+		}
+	}
+	if props.Status != nil {
+		if err := props.Status.Validate(); err != nil {
+			render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+			return
+		}
+	}
+	if props.Positions != nil {
+		props.Positions.Normalize()
+		if err := props.Positions.Validate(); err != nil {
+			render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
+			return
+		}
+	}
+
+	if _, err := repo.Members().Get(ctx, id); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
 
-	if _, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
-		if err := client.Get(ctx, key, member); err != nil && !models.IsFiledMismatch(err) {
-			return err
-		}
+	// 参加状態は他の項目と同じトランザクションで変え、変わった場合だけ後で履歴に残す
+	var from models.MemberStatus
+	statusChanged := false
+	member, err := repo.Members().Update(ctx, id, func(member *models.Member) error {
+		statusChanged = false
 		if props.Status != nil {
-			member.Status = *props.Status
+			var err error
+			from, err = repository.SetMemberStatus(member, *props.Status)
+			if err != nil && !errors.Is(err, repository.ErrStatusUnchanged) {
+				return err
+			}
+			statusChanged = err == nil
 		}
 		if props.Number != nil {
 			member.Number = props.Number
 		}
-		if _, err := client.Put(ctx, key, member); err != nil {
-			return err
+		if props.Positions != nil {
+			// StaffRoles は役割（EffectiveRoles）になるので、変えるには PermManageRoles が要る
+			if !slices.Equal(member.Positions.StaffRoles, props.Positions.StaffRoles) && !caller.Can(models.PermManageRoles) {
+				return errForbidden
+			}
+			member.Positions = *props.Positions
+		}
+		if props.Roles != nil {
+			member.Roles = *props.Roles
+		}
+		if props.JoinedAt != nil {
+			member.JoinedAt = *props.JoinedAt
+		}
+		if props.LeftAt != nil {
+			member.LeftAt = *props.LeftAt
 		}
 		return nil
-	}); err != nil {
+	})
+	if errors.Is(err, errForbidden) {
+		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
+		return
+	}
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
+	if statusChanged {
+		if err := repository.RecordMemberStatusChange(ctx, repo, id, from, *props.Status, props.EffectiveAt, props.StatusReason, time.Now()); err != nil {
+			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+			return
+		}
+	}
 
 	render.JSON(http.StatusOK, member)
 }
+
+// GetMemberStatusHistory はメンバーの入退部日と参加状態の変更履歴（有効日の古い順）を返す。
+// 本人か、PermManageMembers を持つ人だけが見られる。
+func GetMemberStatusHistory(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+
+	id := chi.URLParam(req, "id")
+	if id != filters.GetSessionUserContext(req) && !sessionMember(req, repo).Can(models.PermManageMembers) {
+		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
+		return
+	}
+	member, err := repo.Members().Get(ctx, id)
+	if err != nil {
+		render.JSON(http.StatusNotFound, marmoset.P{"error": "not found"})
+		return
+	}
+	changes, err := repo.MemberStatusChanges().ListByMember(ctx, id)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	render.JSON(http.StatusOK, struct {
+		MemberID string                      `json:"member_id"`
+		Status   models.MemberStatus         `json:"status"`
+		JoinedAt time.Time                   `json:"joined_at,omitzero"`
+		LeftAt   time.Time                   `json:"left_at,omitzero"`
+		Changes  []models.MemberStatusChange `json:"changes"`
+	}{id, member.Status, member.JoinedAt, member.LeftAt, changes})
+}
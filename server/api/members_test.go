@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// TestUpdateMemberProps_Positions は、ポジションと役割が権限のあるメンバーだけに編集でき、
+// ポジションが正規化して保存されることを検証する。
+func TestUpdateMemberProps_Positions(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UPLAYER"}},
+		{Slack: models.SlackUser{ID: "UADMIN", IsAdmin: true}},
+		{Slack: models.SlackUser{ID: "USTAFF"}, Roles: []models.Role{models.RoleStaff}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update := func(slackID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/1/members/UPLAYER/props", strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "UPLAYER")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, slackID), repo)
+		rec := httptest.NewRecorder()
+		UpdateMemberProps(rec, req)
+		return rec
+	}
+
+	// 自分でスタッフの役割を付けることはできない
+	if rec := update("UPLAYER", `{"positions":{"staff_roles":["staff"]}}`); rec.Code != http.StatusForbidden {
+		t.Errorf("by player: status = %d", rec.Code)
+	}
+	if rec := update("UADMIN", `{"positions":{"primary":"XX"}}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown position: status = %d", rec.Code)
+	}
+	if rec := update("UADMIN", `{"positions":{"primary":"ol","secondary":["DL","OL"]}}`); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	member, err := repo.Members().Get(ctx, "UPLAYER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := member.Positions; p.Primary != "OL" || len(p.Secondary) != 1 || p.Secondary[0] != "DL" || p.Unit != "offence" {
+		t.Errorf("positions = %+v", p)
+	}
+	if g, ok := member.PositionGroup(); !ok || g.Channel != "offence" {
+		t.Errorf("group = %+v, %v", g, ok)
+	}
+
+	// PermManageMembers だけでは StaffRoles を変えられない（ポジションは変えられる）
+	if rec := update("USTAFF", `{"positions":{"primary":"OL","staff_roles":["staff"]}}`); rec.Code != http.StatusForbidden {
+		t.Errorf("staff roles by staff: status = %d", rec.Code)
+	}
+	if member, _ := repo.Members().Get(ctx, "UPLAYER"); len(member.Positions.StaffRoles) != 0 {
+		t.Errorf("staff roles = %v", member.Positions.StaffRoles)
+	}
+	if rec := update("USTAFF", `{"positions":{"primary":"DL"}}`); rec.Code != http.StatusOK {
+		t.Errorf("positions by staff: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+
+	// 本人は背番号だけ変えられる
+	if rec := update("UPLAYER", `{"number":7}`); rec.Code != http.StatusOK {
+		t.Errorf("own number: status = %d", rec.Code)
+	}
+	if rec := update("UPLAYER", `{"status":"inactive"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("own status: status = %d", rec.Code)
+	}
+	// 役割は付与できるものだけ
+	if rec := update("UADMIN", `{"roles":["admin"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("grant admin: status = %d", rec.Code)
+	}
+	if rec := update("UADMIN", `{"roles":["captain"]}`); rec.Code != http.StatusOK {
+		t.Fatalf("grant captain: status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	if member, _ := repo.Members().Get(ctx, "UPLAYER"); !member.Can(models.PermAnswerForOthers) || member.Can(models.PermManageEquips) {
+		t.Errorf("captain permissions = %v", member.Permissions())
+	}
+}
+
+// TestMemberStatusHistory は、参加状態の変更が理由・有効日とともに履歴に残り、
+// 本人か権限のあるメンバーだけが履歴を見られることを検証する。
+func TestMemberStatusHistory(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UPLAYER"}},
+		{Slack: models.SlackUser{ID: "UOTHER"}},
+		{Slack: models.SlackUser{ID: "UADMIN", IsAdmin: true}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	request := func(method, slackID, body string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/1/members/UPLAYER", strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "UPLAYER")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		req = filters.SetRepositoryContext(filters.SetSessionUserContext(req, slackID), repo)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	if rec := request(http.MethodPost, "UADMIN", `{"status":"dormant"}`, UpdateMemberProps); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown status: status = %d", rec.Code)
+	}
+	body := `{"status":"limited","status_reason":"就職活動","effective_at":"2026-04-01T00:00:00+09:00","joined_at":"2024-04-01T00:00:00+09:00"}`
+	if rec := request(http.MethodPost, "UADMIN", body, UpdateMemberProps); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	// 同じ状態への変更は履歴に残さない
+	if rec := request(http.MethodPost, "UADMIN", `{"status":"limited"}`, UpdateMemberProps); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+
+	if rec := request(http.MethodGet, "UOTHER", "", GetMemberStatusHistory); rec.Code != http.StatusForbidden {
+		t.Errorf("by other: status = %d", rec.Code)
+	}
+	rec := request(http.MethodGet, "UPLAYER", "", GetMemberStatusHistory)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	res := struct {
+		Status   models.MemberStatus         `json:"status"`
+		JoinedAt time.Time                   `json:"joined_at"`
+		Changes  []models.MemberStatusChange `json:"changes"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != models.MSLimited || res.JoinedAt.Year() != 2024 {
+		t.Errorf("res = %+v", res)
+	}
+	if len(res.Changes) != 1 {
+		t.Fatalf("changes = %+v", res.Changes)
+	}
+	c := res.Changes[0]
+	if c.From != models.MSActive || c.To != models.MSLimited || c.Reason != "就職活動" || c.ChangedBy != "UADMIN" || !c.EffectiveAt.Equal(time.Date(2026, 3, 31, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("change = %+v", c)
+	}
+}
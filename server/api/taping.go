@@ -1,35 +1,18 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/go-chi/chi/v5"
 	"github.com/otiai10/marmoset"
 	"github.com/triax/hub/server/filters"
 	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
 )
 
-// isTapingManager は is_admin または Slack title が /trainer/i にマッチするか判定する。
-func isTapingManager(ctx context.Context, slackID string, client *datastore.Client) (bool, error) {
-	member := models.Member{}
-	key := datastore.NameKey(models.KindMember, slackID, nil)
-	if err := client.Get(ctx, key, &member); err != nil && !models.IsFiledMismatch(err) {
-		return false, err
-	}
-	if member.Slack.IsAdmin {
-		return true, nil
-	}
-	yes, _, err := member.IsMemberOf("trainer", "staff")
-	return yes, err
-}
-
 // marshalTapeUsages は TapeUsages を JSON 文字列にシリアライズする。
 func marshalTapeUsages(usages []models.TapeUsage) string {
 	if len(usages) == 0 {
@@ -54,39 +37,20 @@ func unmarshalTapeUsages(s string) []models.TapeUsage {
 func ListTapeItems(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	items := []models.TapeItem{}
-	if _, err := client.GetAll(ctx, datastore.NewQuery(models.KindTapeItem).Order("SortOrder"), &items); err != nil && !models.IsFiledMismatch(err) {
+	items, err := repo.Taping().ListTapeItems(ctx)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
-	for i, it := range items {
-		items[i].ID = it.Key.ID
-	}
 	render.JSON(http.StatusOK, items)
 }
 
 func CreateTapeItem(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	slackID := filters.GetSessionUserContext(req)
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
-
-	if ok, err := isTapingManager(ctx, slackID, client); err != nil || !ok {
-		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
-		return
-	}
+	repo := filters.GetRepositoryContext(req)
 
 	item := models.TapeItem{}
 	defer req.Body.Close()
@@ -94,30 +58,18 @@ func CreateTapeItem(w http.ResponseWriter, req *http.Request) {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	key, err := client.Put(ctx, datastore.IncompleteKey(models.KindTapeItem, nil), &item)
-	if err != nil {
+	item.ID = 0
+	if err := repo.Taping().PutTapeItem(ctx, &item); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
-	item.ID = key.ID
 	render.JSON(http.StatusCreated, item)
 }
 
 func UpdateTapeItem(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	slackID := filters.GetSessionUserContext(req)
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
-
-	if ok, err := isTapingManager(ctx, slackID, client); err != nil || !ok {
-		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
-		return
-	}
+	repo := filters.GetRepositoryContext(req)
 
 	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
 	if err != nil {
@@ -130,36 +82,25 @@ func UpdateTapeItem(w http.ResponseWriter, req *http.Request) {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	if _, err := client.Put(ctx, datastore.IDKey(models.KindTapeItem, id, nil), &item); err != nil {
+	item.ID = id
+	if err := repo.Taping().PutTapeItem(ctx, &item); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
-	item.ID = id
 	render.JSON(http.StatusOK, item)
 }
 
 func DeleteTapeItem(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	slackID := filters.GetSessionUserContext(req)
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
-
-	if ok, err := isTapingManager(ctx, slackID, client); err != nil || !ok {
-		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
-		return
-	}
+	repo := filters.GetRepositoryContext(req)
 
 	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
 	if err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	if err := client.Delete(ctx, datastore.IDKey(models.KindTapeItem, id, nil)); err != nil {
+	if err := repo.Taping().DeleteTapeItem(ctx, id); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -171,20 +112,14 @@ func DeleteTapeItem(w http.ResponseWriter, req *http.Request) {
 func ListTapingMenuItems(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	items := []models.TapingMenuItem{}
-	if _, err := client.GetAll(ctx, datastore.NewQuery(models.KindTapingMenuItem).Order("SortOrder"), &items); err != nil && !models.IsFiledMismatch(err) {
+	items, err := repo.Taping().ListMenuItems(ctx)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
 	for i, it := range items {
-		items[i].ID = it.Key.ID
 		items[i].TapeUsages = unmarshalTapeUsages(it.TapeUsagesJSON)
 	}
 	render.JSON(http.StatusOK, items)
@@ -193,18 +128,7 @@ func ListTapingMenuItems(w http.ResponseWriter, req *http.Request) {
 func CreateTapingMenuItem(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	slackID := filters.GetSessionUserContext(req)
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
-
-	if ok, err := isTapingManager(ctx, slackID, client); err != nil || !ok {
-		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
-		return
-	}
+	repo := filters.GetRepositoryContext(req)
 
 	item := models.TapingMenuItem{}
 	defer req.Body.Close()
@@ -213,30 +137,18 @@ func CreateTapingMenuItem(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	item.TapeUsagesJSON = marshalTapeUsages(item.TapeUsages)
-	key, err := client.Put(ctx, datastore.IncompleteKey(models.KindTapingMenuItem, nil), &item)
-	if err != nil {
+	item.ID = 0
+	if err := repo.Taping().PutMenuItem(ctx, &item); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
-	item.ID = key.ID
 	render.JSON(http.StatusCreated, item)
 }
 
 func UpdateTapingMenuItem(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	slackID := filters.GetSessionUserContext(req)
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
-
-	if ok, err := isTapingManager(ctx, slackID, client); err != nil || !ok {
-		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
-		return
-	}
+	repo := filters.GetRepositoryContext(req)
 
 	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
 	if err != nil {
@@ -250,36 +162,25 @@ func UpdateTapingMenuItem(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	item.TapeUsagesJSON = marshalTapeUsages(item.TapeUsages)
-	if _, err := client.Put(ctx, datastore.IDKey(models.KindTapingMenuItem, id, nil), &item); err != nil {
+	item.ID = id
+	if err := repo.Taping().PutMenuItem(ctx, &item); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
-	item.ID = id
 	render.JSON(http.StatusOK, item)
 }
 
 func DeleteTapingMenuItem(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	slackID := filters.GetSessionUserContext(req)
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
-
-	if ok, err := isTapingManager(ctx, slackID, client); err != nil || !ok {
-		render.JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
-		return
-	}
+	repo := filters.GetRepositoryContext(req)
 
 	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
 	if err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	if err := client.Delete(ctx, datastore.IDKey(models.KindTapingMenuItem, id, nil)); err != nil {
+	if err := repo.Taping().DeleteMenuItem(ctx, id); err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -292,12 +193,7 @@ func SubmitTapingRequest(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
 	slackID := filters.GetSessionUserContext(req)
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
 	body := struct {
 		EventID     string  `json:"event_id"`
@@ -310,63 +206,19 @@ func SubmitTapingRequest(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// メニューアイテムをまとめて取得してスナップショット用データを準備
-	menuKeys := make([]*datastore.Key, len(body.MenuItemIDs))
-	for i, mid := range body.MenuItemIDs {
-		menuKeys[i] = datastore.IDKey(models.KindTapingMenuItem, mid, nil)
-	}
-	menuItems := make([]models.TapingMenuItem, len(body.MenuItemIDs))
-	if len(menuKeys) > 0 {
-		if err := client.GetMulti(ctx, menuKeys, menuItems); err != nil {
-			if me, ok := err.(datastore.MultiError); ok {
-				for _, e := range me {
-					if e != nil && !models.IsFiledMismatch(e) {
-						render.JSON(http.StatusInternalServerError, marmoset.P{"error": e.Error()})
-						return
-					}
-				}
-			} else {
-				render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-				return
-			}
-		}
-	}
-
-	// 既存の Taping エンティティ（同 memberID + eventID）を取得
-	existing := []models.Taping{}
-	existKeys, err := client.GetAll(ctx,
-		datastore.NewQuery(models.KindTaping).Filter("MemberID =", slackID).Filter("EventID =", body.EventID),
-		&existing,
-	)
-	if err != nil && !models.IsFiledMismatch(err) {
+	menuItems, err := repo.Taping().GetMenuItems(ctx, body.MenuItemIDs)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
 
-	// 新リクエストに含まれない既存エンティティを削除
-	newSet := map[int64]bool{}
-	for _, mid := range body.MenuItemIDs {
-		newSet[mid] = true
-	}
-	toDelete := []*datastore.Key{}
-	for i, t := range existing {
-		if !newSet[t.MenuItemID] {
-			toDelete = append(toDelete, existKeys[i])
-		}
-	}
-	if len(toDelete) > 0 {
-		if err := client.DeleteMulti(ctx, toDelete); err != nil {
-			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-			return
-		}
-	}
-
-	// 新規・更新分を PutMulti（NameKey により upsert）
+	// 既存の Taping（同 memberID + eventID）のうち新リクエストに含まれないものは削除し、
+	// 新規・更新分は NameKey により upsert する
 	now := time.Now().Unix() * 1000
-	putKeys := make([]*datastore.Key, 0, len(body.MenuItemIDs))
 	putValues := make([]*models.Taping, 0, len(body.MenuItemIDs))
 	for i, mid := range body.MenuItemIDs {
 		menuItem := menuItems[i]
-		t := &models.Taping{
+		putValues = append(putValues, &models.Taping{
 			MemberID:       slackID,
 			EventID:        body.EventID,
 			MenuItemID:     mid,
@@ -375,16 +227,11 @@ func SubmitTapingRequest(w http.ResponseWriter, req *http.Request) {
 			TapeUsagesJSON: menuItem.TapeUsagesJSON, // JSON 文字列をそのままコピー（decode→encodeの往復を省略）
 			TapeUsages:     unmarshalTapeUsages(menuItem.TapeUsagesJSON),
 			RequestedAt:    now,
-		}
-		putKeys = append(putKeys, datastore.NameKey(models.KindTaping,
-			fmt.Sprintf("%s_%s_%d", slackID, body.EventID, mid), nil))
-		putValues = append(putValues, t)
-	}
-	if len(putKeys) > 0 {
-		if _, err := client.PutMulti(ctx, putKeys, putValues); err != nil {
-			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-			return
-		}
+		})
+	}
+	if err := repo.Taping().ReplaceRequests(ctx, slackID, body.EventID, putValues); err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
 	}
 
 	result := make([]models.Taping, len(putValues))
@@ -403,18 +250,10 @@ func GetMyTapingRequest(w http.ResponseWriter, req *http.Request) {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": "event_id is required"})
 		return
 	}
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	tapings := []models.Taping{}
-	if _, err := client.GetAll(ctx,
-		datastore.NewQuery(models.KindTaping).Filter("MemberID =", slackID).Filter("EventID =", eventID),
-		&tapings,
-	); err != nil && !models.IsFiledMismatch(err) {
+	tapings, err := repo.Taping().ListRequests(ctx, repository.TapingQuery{MemberID: slackID, EventID: eventID})
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -427,27 +266,18 @@ func GetMyTapingRequest(w http.ResponseWriter, req *http.Request) {
 func ListTapingRequests(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	query := datastore.NewQuery(models.KindTaping)
-	if eventID := req.URL.Query().Get("event_id"); eventID != "" {
-		query = query.Filter("EventID =", eventID)
-	}
+	query := repository.TapingQuery{EventID: req.URL.Query().Get("event_id")}
 	if yearStr := req.URL.Query().Get("year"); yearStr != "" {
 		if y, err := strconv.Atoi(yearStr); err == nil {
 			loc := time.FixedZone("Asia/Tokyo", 9*60*60)
-			from := time.Date(y, 1, 1, 0, 0, 0, 0, loc).UnixMilli()
-			to := time.Date(y+1, 1, 1, 0, 0, 0, 0, loc).UnixMilli()
-			query = query.Filter("RequestedAt >=", from).Filter("RequestedAt <", to)
+			query.RequestedFrom = time.Date(y, 1, 1, 0, 0, 0, 0, loc).UnixMilli()
+			query.RequestedTo = time.Date(y+1, 1, 1, 0, 0, 0, 0, loc).UnixMilli()
 		}
 	}
-	tapings := []models.Taping{}
-	if _, err := client.GetAll(ctx, query, &tapings); err != nil && !models.IsFiledMismatch(err) {
+	tapings, err := repo.Taping().ListRequests(ctx, query)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
@@ -461,26 +291,18 @@ func ListTapingRequests(w http.ResponseWriter, req *http.Request) {
 func ListTapingEvents(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	from := time.Now().Add(-40 * 24 * time.Hour).Unix() * 1000
-	all := []models.Event{}
-	if _, err := client.GetAll(ctx,
-		datastore.NewQuery(models.KindEvent).Filter("Google.StartTime >", from).Order("Google.StartTime"),
-		&all,
-	); err != nil && !models.IsFiledMismatch(err) {
+	from := time.Now().Add(-40 * 24 * time.Hour)
+	all, err := repo.Events().Find(ctx, repository.EventQuery{From: from})
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
-	// テーピングが必要なのは練習・試合のみ
+	// テーピングが必要なのはタグの定義で対象にしているイベント（既定では練習・試合）のみ
 	events := []models.Event{}
-	for _, ev := range all {
-		if ev.IsPractice() || ev.IsGame() {
+	for _, ev := range visibleEvents(all, sessionMember(req, repo)) {
+		if ev.OffersTaping() {
 			events = append(events, ev)
 		}
 	}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// TestSubmitTapingRequest は、再申請で含まれなくなったメニューが削除され、
+// 申請時点のメニュー名・価格がスナップショットされることを検証する。
+func TestSubmitTapingRequest(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	ankle := models.TapingMenuItem{Name: "足首", Price: 300, TapeUsagesJSON: `[{"tape_item_id":1,"tape_item_name":"ホワイト","quantity":1}]`}
+	knee := models.TapingMenuItem{Name: "膝", Price: 500}
+	for _, item := range []*models.TapingMenuItem{&ankle, &knee} {
+		if err := repo.Taping().PutMenuItem(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	submit := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/1/taping/requests", strings.NewReader(body))
+		req = filters.SetSessionUserContext(req, "U12345678")
+		req = filters.SetRepositoryContext(req, repo)
+		rec := httptest.NewRecorder()
+		SubmitTapingRequest(rec, req)
+		return rec
+	}
+
+	if rec := submit(`{"event_id":"ev001","menu_item_ids":[` + strconv.FormatInt(ankle.ID, 10) + `,` + strconv.FormatInt(knee.ID, 10) + `]}`); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (body=%s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	// 価格を変更してから膝だけで再申請
+	knee.Price = 600
+	if err := repo.Taping().PutMenuItem(ctx, &knee); err != nil {
+		t.Fatal(err)
+	}
+	if rec := submit(`{"event_id":"ev001","menu_item_ids":[` + strconv.FormatInt(knee.ID, 10) + `]}`); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (body=%s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	tapings, err := repo.Taping().ListRequests(ctx, repository.TapingQuery{MemberID: "U12345678", EventID: "ev001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tapings) != 1 {
+		t.Fatalf("len(tapings) = %d, want 1", len(tapings))
+	}
+	if tapings[0].MenuItemID != knee.ID || tapings[0].MenuItemName != "膝" || tapings[0].Price != 600 {
+		t.Errorf("taping = %+v", tapings[0])
+	}
+}
+
+// TestSubmitTapingRequest_InvalidJSON は、壊れた JSON で 400 を返すことを検証する。
+func TestSubmitTapingRequest_InvalidJSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/1/taping/requests", strings.NewReader(`{not json`))
+	req = filters.SetSessionUserContext(req, "U12345678")
+	req = filters.SetRepositoryContext(req, repository.NewMemory())
+	rec := httptest.NewRecorder()
+
+	SubmitTapingRequest(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -13,10 +13,11 @@ import (
 	"strings"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/golang-jwt/jwt"
 	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/filters"
 	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
 )
 
 // generateRandomString は暗号学的に安全なランダム文字列を生成する
@@ -180,19 +181,11 @@ func AuthCallback(w http.ResponseWriter, req *http.Request) {
 	}
 
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		http.Redirect(w, req, fmt.Sprintf("/errors?code=%d&error=%s", 4001, err.Error()), http.StatusTemporaryRedirect)
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	member := models.Member{}
-	if err := client.Get(ctx,
-		datastore.NameKey(models.KindMember, info.Sub, nil),
-		&member,
-	); err != nil && !models.IsFiledMismatch(err) {
-		if err == datastore.ErrNoSuchEntity {
+	member, err := repo.Members().Get(ctx, info.Sub)
+	if err != nil {
+		if err == repository.ErrNotFound {
 			http.Redirect(
 				w, req, fmt.Sprintf("/errors?code=%d", server.ErrorMemberNotSyncedYet),
 				http.StatusTemporaryRedirect,
@@ -3,37 +3,26 @@ package controllers
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
-	"cloud.google.com/go/datastore"
 	"github.com/slack-go/slack"
 	"github.com/triax/hub/server"
 	"github.com/triax/hub/server/filters"
-	"github.com/triax/hub/server/models"
 )
 
 func RedirectConditioningForm(w http.ResponseWriter, req *http.Request) {
 
 	id := filters.GetSessionUserContext(req)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		http.Redirect(w, req, fmt.Sprintf("/errors?code=%d&error=%s", 4001, err.Error()), http.StatusTemporaryRedirect)
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	myself := models.Member{}
-	if err := client.Get(
-		ctx, datastore.NameKey(models.KindMember, id, nil), &myself,
-	); err != nil && !models.IsFiledMismatch(err) {
+	myself, err := repo.Members().Get(ctx, id)
+	if err != nil {
 		http.Redirect(w, req, fmt.Sprintf("/errors?code=%d&error=%s", 4002, err.Error()), http.StatusTemporaryRedirect)
 		return
 	}
 
-	// position := req.URL.Query().Get("position")
-	position := strings.Split(myself.Slack.Profile.Title, "/")[0]
+	position := myself.Position()
 
 	label := req.URL.Query().Get("label")
 	switch label {
@@ -54,7 +43,7 @@ func RedirectConditioningForm(w http.ResponseWriter, req *http.Request) {
 
 	ts := req.URL.Query().Get("slack_timestamp")
 	ch := req.URL.Query().Get("response_channel")
-	api := slack.New(os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN"))
+	api := server.NewSlackClient()
 	text := fmt.Sprintf("[%s] %s", position, myself.Name())
 	api.PostMessage(ch, slack.MsgOptionText(text, false), slack.MsgOptionTS(ts))
 }
@@ -0,0 +1,181 @@
+// Package dormancy は出欠の未回答が続くメンバーの検知と、休眠（MSInactive）にする提案の承認・却下を
+// cron タスクと Slack のボタンで共有する。
+package dormancy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// DefaultEvents は未回答が何回続いたら休眠を提案するか。
+const DefaultEvents = 4
+
+// lookback はさかのぼって調べるイベントの期間。
+const lookback = 180 * 24 * time.Hour
+
+// ErrAlreadyDecided は提案がすでに承認・却下済みであることを表す。
+var ErrAlreadyDecided = errors.New("dormancy proposal is already decided")
+
+// ErrAlreadyProposed は保留中の提案がすでにあることを表す。
+var ErrAlreadyProposed = errors.New("dormancy proposal is already pending")
+
+// Candidate は休眠を提案するメンバーと、続けて未回答だったイベント（新しい順）。
+type Candidate struct {
+	Member models.Member
+	Events []models.Event
+}
+
+// Scan は now までに開始した出欠確認の対象のイベント（models.Event.ShouldSkipReminders(RTRSVP) でないもの）を
+// 新しい順にたどり、直近 n 回続けて未回答の MSActive のメンバーを返す。
+//
+// 入部日（JoinedAt）と最後に参加状態が変わった日より前のイベントは数えない。
+// 提案が保留中のメンバーと、承認・却下した後に n 回の未回答がまだ揃っていないメンバーは除く。
+func Scan(ctx context.Context, repo repository.Repository, n int, now time.Time) ([]Candidate, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("events must be positive: %d", n)
+	}
+	found, err := repo.Events().Find(ctx, repository.EventQuery{From: now.Add(-lookback), To: now, Desc: true})
+	if err != nil {
+		return nil, err
+	}
+	events := []models.Event{}
+	ids := []string{}
+	for _, ev := range found {
+		if !ev.ShouldSkipReminders(models.RTRSVP) {
+			events = append(events, ev)
+			ids = append(ids, ev.Google.ID)
+		}
+	}
+	byEvent, err := repo.Participations().ListByEvents(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	answered := map[string]map[string]bool{} // Event ID -> Member ID -> 回答済み
+	for id, parts := range byEvent {
+		answered[id] = map[string]bool{}
+		for _, p := range parts {
+			answered[id][p.MemberID] = !p.Type.Unanswered()
+		}
+	}
+	// lookback より前の変更は、どのイベントよりも前なので数えなくてよい
+	changes, err := repo.MemberStatusChanges().ListSince(ctx, now.Add(-lookback))
+	if err != nil {
+		return nil, err
+	}
+	changed := map[string]time.Time{} // Member ID -> 最後に参加状態が変わった日
+	for _, c := range changes {
+		if c.EffectiveAt.After(changed[c.MemberID]) {
+			changed[c.MemberID] = c.EffectiveAt
+		}
+	}
+
+	members, err := repo.Members().List(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []Candidate{}
+	for _, m := range members {
+		if m.Status != models.MSActive && m.Status != "" {
+			continue
+		}
+		since := m.JoinedAt
+		if at := changed[m.Slack.ID]; at.After(since) {
+			since = at
+		}
+
+		streak := []models.Event{}
+		for _, ev := range events {
+			if len(streak) == n || ev.Google.Start().Before(since) || answered[ev.Google.ID][m.Slack.ID] {
+				break
+			}
+			streak = append(streak, ev)
+		}
+		if len(streak) < n {
+			continue
+		}
+
+		proposal, err := repo.DormancyProposals().Get(ctx, m.Slack.ID)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+		case err != nil:
+			return nil, err
+		case proposal.Status == models.DPPending:
+			continue
+		case !streak[n-1].Google.Start().After(proposal.DecidedAt):
+			continue
+		}
+		candidates = append(candidates, Candidate{Member: m, Events: streak})
+	}
+	return candidates, nil
+}
+
+// Propose は c の休眠の提案を保留中として記録し、上書きした以前の提案（無ければ nil）とあわせて返す。
+// 保留中の提案がすでにあれば ErrAlreadyProposed。
+func Propose(ctx context.Context, repo repository.Repository, c Candidate, now time.Time) (proposal, prev *models.DormancyProposal, err error) {
+	prev, err = repo.DormancyProposals().Get(ctx, c.Member.Slack.ID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		prev = nil
+	case err != nil:
+		return nil, nil, err
+	case prev.Status == models.DPPending:
+		return nil, prev, ErrAlreadyProposed
+	}
+	ids := make([]string, len(c.Events))
+	for i, ev := range c.Events {
+		ids[i] = ev.Google.ID
+	}
+	proposal = &models.DormancyProposal{
+		MemberID:   c.Member.Slack.ID,
+		EventIDs:   ids,
+		Status:     models.DPPending,
+		ProposedAt: now,
+	}
+	if err := repo.DormancyProposals().Put(ctx, proposal); err != nil {
+		return nil, prev, err
+	}
+	return proposal, prev, nil
+}
+
+// Withdraw は Propose で記録した memberID への提案を取り消し、以前の提案 prev（無ければ nil）に戻す。
+// 提案を Slack に投稿できなかったときに、誰も決められない保留中の提案を残さないために使う。
+func Withdraw(ctx context.Context, repo repository.Repository, memberID string, prev *models.DormancyProposal) error {
+	if prev == nil {
+		return repo.DormancyProposals().Delete(ctx, memberID)
+	}
+	return repo.DormancyProposals().Put(ctx, prev)
+}
+
+// Decide は memberID への保留中の提案を承認（approve）または却下する。決めた人は ctx の操作者（repository.WithActor）。
+// 承認した場合はメンバーを MSInactive にし、参加状態の変更履歴に残す。
+// 提案が無ければ repository.ErrNotFound、すでに決まっていれば ErrAlreadyDecided。
+func Decide(ctx context.Context, repo repository.Repository, memberID string, approve bool, now time.Time) (*models.DormancyProposal, error) {
+	proposal, err := repo.DormancyProposals().Update(ctx, memberID, func(p *models.DormancyProposal) error {
+		if p.Status != models.DPPending {
+			return ErrAlreadyDecided
+		}
+		p.Status = models.DPRejected
+		if approve {
+			p.Status = models.DPApproved
+		}
+		p.DecidedBy = repository.ActorFrom(ctx)
+		p.DecidedAt = now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !approve {
+		return proposal, nil
+	}
+	reason := fmt.Sprintf("出欠の未回答が %d 回続いたため（休眠の提案を承認）", len(proposal.EventIDs))
+	if _, err := repository.ChangeMemberStatus(ctx, repo, memberID, models.MSInactive, now, reason, now); err != nil {
+		return proposal, err
+	}
+	return proposal, nil
+}
@@ -0,0 +1,76 @@
+package dormancy
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/models"
+)
+
+// ActionPrefix は休眠の提案の承認・却下ボタンの action_id の接頭辞（Bot.Shortcuts の振り分けに使う）。
+const ActionPrefix = "dormancy/"
+
+// ActionID は memberID への提案を承認（approve）・却下するボタンの action_id。
+func ActionID(memberID string, approve bool) string {
+	decision := "reject"
+	if approve {
+		decision = "approve"
+	}
+	return ActionPrefix + "?" + url.Values{"member": {memberID}, "decision": {decision}}.Encode()
+}
+
+// ParseActionID は ActionID の逆。休眠の提案のボタンでなければ false。
+func ParseActionID(actionID string) (memberID string, approve bool, ok bool) {
+	if !strings.HasPrefix(actionID, ActionPrefix) {
+		return "", false, false
+	}
+	u, err := url.Parse(actionID)
+	if err != nil {
+		return "", false, false
+	}
+	q := u.Query()
+	switch q.Get("decision") {
+	case "approve":
+		approve = true
+	case "reject":
+	default:
+		return "", false, false
+	}
+	return q.Get("member"), approve, q.Get("member") != ""
+}
+
+// ProposalBlocks は休眠の提案のメッセージを返す。承認・却下のボタンを押すと DecidedBlocks に書き換える。
+func ProposalBlocks(c Candidate) []slack.Block {
+	lines := []string{}
+	for _, ev := range c.Events {
+		lines = append(lines, fmt.Sprintf("• <%s/events/%s|%s>", server.HubBaseURL(), ev.Google.ID, ev.Google.Title))
+	}
+	text := fmt.Sprintf(":zzz: <@%s> さんは直近 %d 回のイベントの出欠が未回答です。休眠（inactive）にしますか？\n%s",
+		c.Member.Slack.ID, len(c.Events), strings.Join(lines, "\n"))
+
+	approve := slack.NewButtonBlockElement(ActionID(c.Member.Slack.ID, true), "approve",
+		slack.NewTextBlockObject(slack.PlainTextType, "休眠にする", false, false))
+	approve.Style = slack.StylePrimary
+	reject := slack.NewButtonBlockElement(ActionID(c.Member.Slack.ID, false), "reject",
+		slack.NewTextBlockObject(slack.PlainTextType, "そのままにする", false, false))
+
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("dormancy_buttons", approve, reject),
+	}
+}
+
+// DecidedBlocks は承認・却下した後の提案のメッセージ（ボタンを外し、決めた人を添える）を返す。
+func DecidedBlocks(p models.DormancyProposal) []slack.Block {
+	result := ":white_check_mark: 休眠にしました"
+	if p.Status == models.DPRejected {
+		result = ":leftwards_arrow_with_hook: そのままにしました"
+	}
+	text := fmt.Sprintf("<@%s> さんの休眠の提案（未回答 %d 回）\n%s（<@%s>）", p.MemberID, len(p.EventIDs), result, p.DecidedBy)
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/golang-jwt/jwt"
 	"github.com/triax/hub/server"
 	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
 )
 
 type (
@@ -22,8 +23,11 @@ const (
 	SessionContextKey ContextKey = "session_user"
 )
 
+// SetSessionUserContext はログイン中のユーザを ctx に載せる。
+// 同じユーザを書き込みの操作者（repository.WithActor）としても記録する。
 func SetSessionUserContext(req *http.Request, slackID string) *http.Request {
 	ctx := context.WithValue(req.Context(), SessionContextKey, slackID)
+	ctx = repository.WithActor(ctx, slackID)
 	return req.WithContext(ctx)
 }
 
@@ -0,0 +1,31 @@
+package filters
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/triax/hub/server/gcal"
+)
+
+const (
+	CalendarContextKey ContextKey = "calendar"
+)
+
+func SetCalendarContext(req *http.Request, cal gcal.Calendar) *http.Request {
+	ctx := context.WithValue(req.Context(), CalendarContextKey, cal)
+	return req.WithContext(ctx)
+}
+
+func GetCalendarContext(req *http.Request) gcal.Calendar {
+	return req.Context().Value(CalendarContextKey).(gcal.Calendar)
+}
+
+// Calendar はハンドラへ Google Calendar のアクセス手段を注入するミドルウェア。
+// ローカル開発・テストでは gcal.Memory を渡す。
+func Calendar(cal gcal.Calendar) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, SetCalendarContext(req, cal))
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package filters
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// EventTagRules は Datastore のタグの定義を models.SetEventTagRules へ反映するミドルウェア。
+//
+// 他のインスタンスで編集された定義も拾えるよう、最後の読み込みから ttl 以上経ったリクエストで読み直す。
+// 読み込みに失敗した場合はログだけ残し、それまでの定義のまま処理を続ける。
+func EventTagRules(repo repository.Repository, ttl time.Duration) func(http.Handler) http.Handler {
+	var (
+		mu       sync.Mutex
+		loadedAt time.Time
+	)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			if time.Since(loadedAt) >= ttl {
+				if err := ReloadEventTagRules(req.Context(), repo); err != nil {
+					log.Println("[ERROR]", 11001, err.Error())
+				}
+				loadedAt = time.Now()
+			}
+			mu.Unlock()
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// ReloadEventTagRules は Datastore からタグの定義を読み直して models へ反映する。
+func ReloadEventTagRules(ctx context.Context, repo repository.Repository) error {
+	rules, err := repository.LoadEventTagRules(ctx, repo.EventTags())
+	if err != nil {
+		return err
+	}
+	return models.SetEventTagRules(rules)
+}
@@ -0,0 +1,23 @@
+package filters
+
+import (
+	"net/http"
+
+	"github.com/otiai10/marmoset"
+	"github.com/triax/hub/server/models"
+)
+
+// Require はログインユーザが perm の権限を持つときだけ next を呼ぶミドルウェア。持たなければ 403 を返す。
+// Auth と Repository の後ろで使う（main.go のルーティングで r.With(filters.Require(...)) と宣言する）。
+func Require(perm models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			member, err := GetRepositoryContext(req).Members().Get(req.Context(), GetSessionUserContext(req))
+			if err != nil || member.Slack.Deleted || !member.Can(perm) {
+				marmoset.Render(w).JSON(http.StatusForbidden, marmoset.P{"error": "forbidden"})
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
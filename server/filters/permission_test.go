@@ -0,0 +1,48 @@
+package filters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// TestRequire は、役割から決まる権限を持つメンバーだけがハンドラまで届くことを検証する。
+func TestRequire(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UADMIN", IsAdmin: true}},
+		{Slack: models.SlackUser{ID: "UTRAINER"}, Positions: models.MemberPositions{StaffRoles: []models.StaffRole{models.SRTrainer}}},
+		{Slack: models.SlackUser{ID: "UEQUIP"}, Roles: []models.Role{models.RoleEquipManager}},
+		{Slack: models.SlackUser{ID: "UPLAYER"}},
+		{Slack: models.SlackUser{ID: "ULEFT", IsAdmin: true, Deleted: true}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := Require(models.PermManageEquips)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for slackID, want := range map[string]int{
+		"UADMIN":   http.StatusOK,
+		"UEQUIP":   http.StatusOK,
+		"UTRAINER": http.StatusForbidden,
+		"UPLAYER":  http.StatusForbidden,
+		"ULEFT":    http.StatusForbidden,
+		"UNKNOWN":  http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/1/equips", nil)
+		req = SetRepositoryContext(SetSessionUserContext(req, slackID), repo)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", slackID, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package filters
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/triax/hub/server/repository"
+)
+
+const (
+	RepositoryContextKey ContextKey = "repository"
+)
+
+func SetRepositoryContext(req *http.Request, repo repository.Repository) *http.Request {
+	ctx := context.WithValue(req.Context(), RepositoryContextKey, repo)
+	return req.WithContext(ctx)
+}
+
+func GetRepositoryContext(req *http.Request) repository.Repository {
+	return req.Context().Value(RepositoryContextKey).(repository.Repository)
+}
+
+// Repository はハンドラへ repo を注入するミドルウェア。
+// ハンドラは GetRepositoryContext で取り出して使う（テストでは SetRepositoryContext で差し替える）。
+func Repository(repo repository.Repository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, SetRepositoryContext(req, repo))
+		})
+	}
+}
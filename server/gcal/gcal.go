@@ -0,0 +1,113 @@
+// Package gcal は Google Calendar へのアクセスを抽象化する。
+//
+// 本番は Google（Calendar API）を、ローカル開発・テストでは Memory（YAML で定義した
+// イベント）を使う。どちらも Calendar API の型（*calendar.Event）を返すので、
+// models.CreateEventFromCalendarAPI など下流の処理は実装の違いを意識しない。
+package gcal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// Query は Calendar.List の条件。
+// Calendar API の events.list を SingleEvents(true) / ShowDeleted(false) で呼んだときと同じ意味を持つ:
+// 繰り返しイベントは個々の instance に展開され、キャンセルされたイベントは含まれない。
+type Query struct {
+	CalendarID string
+	// From / To は期間（終了が From より後、かつ開始が To より前のイベントが対象）。
+	From time.Time
+	To   time.Time
+}
+
+// SyncQuery は Calendar.Sync の条件。
+type SyncQuery struct {
+	CalendarID string
+	// SyncToken は前回の Sync が返した NextSyncToken。空なら全件同期。
+	SyncToken string
+}
+
+// Changes は Sync の結果。
+//
+// 全件同期ではキャンセルされていない全イベント（過去も含む）を、増分同期では前回以降に
+// 追加・変更・キャンセルされたイベントを返す。繰り返しイベントは instance に展開される。
+// キャンセルされたイベントは Status が "cancelled" で、Id 以外が空のことがある。
+type Changes struct {
+	Items         []*calendar.Event
+	NextSyncToken string
+	// Full は全件同期の結果であることを示す（Items に無いイベントは存在しない）。
+	Full bool
+}
+
+// ErrSyncTokenExpired は SyncToken が無効になったことを示す。全件同期からやり直す。
+var ErrSyncTokenExpired = errors.New("gcal: sync token expired")
+
+// Calendar はカレンダーのイベント取得。
+type Calendar interface {
+	// List は期間内のイベントを開始時刻の昇順で返す。
+	List(ctx context.Context, q Query) ([]*calendar.Event, error)
+	// Sync は SyncToken 以降の変更を返す。
+	Sync(ctx context.Context, q SyncQuery) (*Changes, error)
+}
+
+// Google は Calendar API を使う実装。
+type Google struct {
+	// CredentialsJSON は service account の JSON（GOOGLE_SERVICE_ACCOUNT_JSON）。
+	CredentialsJSON string
+}
+
+func (g Google) List(ctx context.Context, q Query) ([]*calendar.Event, error) {
+	service, err := g.service(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := []*calendar.Event{}
+	err = service.Events.List(q.CalendarID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(q.From.Format(time.RFC3339)).
+		TimeMax(q.To.Format(time.RFC3339)).
+		OrderBy("startTime").
+		Pages(ctx, func(page *calendar.Events) error {
+			items = append(items, page.Items...)
+			return nil
+		})
+	return items, err
+}
+
+// Sync は events.list の syncToken による増分同期を行う。
+// 全件同期で NextSyncToken を受け取るため timeMin / timeMax は指定できない（API の制約）。
+func (g Google) Sync(ctx context.Context, q SyncQuery) (*Changes, error) {
+	service, err := g.service(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := &Changes{Items: []*calendar.Event{}, Full: q.SyncToken == ""}
+	call := service.Events.List(q.CalendarID).SingleEvents(true)
+	if changes.Full {
+		call = call.ShowDeleted(false)
+	} else {
+		call = call.SyncToken(q.SyncToken)
+	}
+	err = call.Pages(ctx, func(page *calendar.Events) error {
+		changes.Items = append(changes.Items, page.Items...)
+		if page.NextSyncToken != "" {
+			changes.NextSyncToken = page.NextSyncToken
+		}
+		return nil
+	})
+	if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusGone {
+		return nil, ErrSyncTokenExpired
+	}
+	return changes, err
+}
+
+func (g Google) service(ctx context.Context) (*calendar.Service, error) {
+	return calendar.NewService(ctx, option.WithCredentialsJSON([]byte(g.CredentialsJSON)))
+}
@@ -0,0 +1,315 @@
+package gcal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/triax/hub/server"
+	"google.golang.org/api/calendar/v3"
+)
+
+// Memory はメモリ上のカレンダー。ローカル開発（LoadYAML）とテストで使う。
+//
+// Put したイベントは Calendar API 上の「マスター」として扱い、List / Sync 時に次を再現する:
+//   - Recurrence（RRULE の FREQ=DAILY/WEEKLY, INTERVAL, COUNT, UNTIL のみ）を instance に展開する。
+//     instance の ID は Google と同じく "{マスターID}_{開始時刻UTC}"（終日は "_{日付}"）。
+//   - RecurringEventId と OriginalStartTime を持つイベントは、該当 instance の上書き（例外）とみなす。
+//     上書き後も ID は instance の ID のまま。
+//   - Status が "cancelled" のイベント・instance は List では返さない。
+//   - SyncToken は Put / Cancel ごとに増える版番号。増分同期では版番号が新しいマスター・例外の
+//     instance を（キャンセルされたものも含めて）返す。
+type Memory struct {
+	mu       sync.Mutex
+	events   map[string][]*calendar.Event // calendarID -> マスター / 例外
+	version  int64
+	modified map[string]int64 // calendarID + "/" + イベントID -> 最後に変更した版
+}
+
+// syncHorizon は Sync で終わりの無い繰り返しイベントを展開する範囲（現在から）。
+const syncHorizon = 2 * 365 * 24 * time.Hour
+
+func NewMemory() *Memory {
+	return &Memory{events: map[string][]*calendar.Event{}, modified: map[string]int64{}}
+}
+
+// Put はイベントを追加する（同じ ID があれば置き換える）。
+func (m *Memory) Put(calendarID string, events ...*calendar.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ev := range events {
+		m.put(calendarID, ev)
+	}
+}
+
+// Cancel はイベント（マスター / 例外）をキャンセルする。繰り返しイベントなら全 instance がキャンセルされる。
+func (m *Memory) Cancel(calendarID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.events[calendarID] {
+		if e.Id == id {
+			cancelled := *e
+			cancelled.Status = "cancelled"
+			m.put(calendarID, &cancelled)
+			return nil
+		}
+	}
+	return fmt.Errorf("event %s not found in %s", id, calendarID)
+}
+
+func (m *Memory) put(calendarID string, ev *calendar.Event) {
+	m.version++
+	m.modified[calendarID+"/"+ev.Id] = m.version
+	for i, e := range m.events[calendarID] {
+		if e.Id == ev.Id {
+			m.events[calendarID][i] = ev
+			return
+		}
+	}
+	m.events[calendarID] = append(m.events[calendarID], ev)
+}
+
+func (m *Memory) List(ctx context.Context, q Query) ([]*calendar.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	all, err := m.instances(q.CalendarID, q.To)
+	if err != nil {
+		return nil, err
+	}
+	items := []*calendar.Event{}
+	for _, inst := range all {
+		if inst.Status == "cancelled" {
+			continue
+		}
+		start, end, err := span(inst.Event)
+		if err != nil {
+			return nil, fmt.Errorf("event %s: %w", inst.Id, err)
+		}
+		if end.After(q.From) && start.Before(q.To) {
+			items = append(items, inst.Event)
+		}
+	}
+	sortByStart(items)
+	return items, nil
+}
+
+func (m *Memory) Sync(ctx context.Context, q SyncQuery) (*Changes, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since := int64(0)
+	if q.SyncToken != "" {
+		v, err := strconv.ParseInt(q.SyncToken, 10, 64)
+		if err != nil || v > m.version {
+			return nil, ErrSyncTokenExpired
+		}
+		since = v
+	}
+	all, err := m.instances(q.CalendarID, time.Now().Add(syncHorizon))
+	if err != nil {
+		return nil, err
+	}
+	changes := &Changes{Items: []*calendar.Event{}, NextSyncToken: strconv.FormatInt(m.version, 10), Full: q.SyncToken == ""}
+	for _, inst := range all {
+		if changes.Full && inst.Status == "cancelled" {
+			continue
+		}
+		if inst.version > since {
+			changes.Items = append(changes.Items, inst.Event)
+		}
+	}
+	sortByStart(changes.Items)
+	return changes, nil
+}
+
+// instance は展開後のイベントと、その元になったマスター / 例外の最新の版。
+type instance struct {
+	*calendar.Event
+	version int64
+}
+
+// instances はマスターを limit まで展開し、例外で上書きした全 instance を返す（キャンセルされたものも含む）。
+func (m *Memory) instances(calendarID string, limit time.Time) ([]instance, error) {
+	exceptions := map[string]*calendar.Event{} // instance ID -> 例外
+	for _, ev := range m.events[calendarID] {
+		if ev.RecurringEventId != "" && ev.OriginalStartTime != nil {
+			id, err := instanceID(ev.RecurringEventId, ev.OriginalStartTime)
+			if err != nil {
+				return nil, fmt.Errorf("event %s: %w", ev.Id, err)
+			}
+			exceptions[id] = ev
+		}
+	}
+
+	all := []instance{}
+	for _, ev := range m.events[calendarID] {
+		if ev.RecurringEventId != "" {
+			continue // 例外は instance 展開時に差し替える
+		}
+		version := m.modified[calendarID+"/"+ev.Id]
+		if len(ev.Recurrence) == 0 {
+			all = append(all, instance{ev, version})
+			continue
+		}
+		expanded, err := expand(ev, limit)
+		if err != nil {
+			return nil, fmt.Errorf("event %s: %w", ev.Id, err)
+		}
+		for _, inst := range expanded {
+			v := version
+			if exc, ok := exceptions[inst.Id]; ok {
+				override := *exc
+				override.Id = inst.Id
+				if ev.Status == "cancelled" {
+					override.Status = "cancelled"
+				}
+				inst = &override
+				if ve := m.modified[calendarID+"/"+exc.Id]; ve > v {
+					v = ve
+				}
+			}
+			all = append(all, instance{inst, v})
+		}
+	}
+	return all, nil
+}
+
+// sortByStart は開始時刻の昇順に並べる。時刻の無いもの（キャンセル済み instance）は先頭に来る。
+func sortByStart(items []*calendar.Event) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, _, _ := span(items[i])
+		b, _, _ := span(items[j])
+		return a.Before(b)
+	})
+}
+
+// span はイベントの開始・終了時刻を返す。終日イベントはチームのタイムゾーンの 0 時で解釈する。
+func span(ev *calendar.Event) (start, end time.Time, err error) {
+	if ev.Start == nil || ev.End == nil {
+		return start, end, fmt.Errorf("start / end is empty")
+	}
+	if start, err = eventTime(ev.Start); err != nil {
+		return
+	}
+	end, err = eventTime(ev.End)
+	return
+}
+
+func eventTime(edt *calendar.EventDateTime) (time.Time, error) {
+	if edt.DateTime != "" {
+		return time.Parse(time.RFC3339, edt.DateTime)
+	}
+	if edt.Date != "" {
+		return time.ParseInLocation("2006-01-02", edt.Date, server.ServiceLocation)
+	}
+	return time.Time{}, fmt.Errorf("both DateTime and Date are empty")
+}
+
+func instanceID(masterID string, original *calendar.EventDateTime) (string, error) {
+	t, err := eventTime(original)
+	if err != nil {
+		return "", err
+	}
+	if original.DateTime == "" {
+		return masterID + "_" + t.Format("20060102"), nil
+	}
+	return masterID + "_" + t.UTC().Format("20060102T150405Z"), nil
+}
+
+// rrule は RRULE のうち hub のカレンダーで使われる範囲だけを表す。
+type rrule struct {
+	days     int // 1 回の繰り返しで進む日数（DAILY=1, WEEKLY=7 に INTERVAL を掛けたもの）
+	count    int
+	until    time.Time
+	hasUntil bool
+}
+
+func parseRRule(lines []string) (rrule, error) {
+	r := rrule{}
+	interval := 1
+	freq := ""
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "RRULE:") {
+			return r, fmt.Errorf("unsupported recurrence %q", line)
+		}
+		for _, part := range strings.Split(strings.TrimPrefix(line, "RRULE:"), ";") {
+			kv := strings.SplitN(part, "=", 2)
+			if len(kv) != 2 {
+				return r, fmt.Errorf("invalid RRULE part %q", part)
+			}
+			var err error
+			switch kv[0] {
+			case "FREQ":
+				freq = kv[1]
+			case "INTERVAL":
+				interval, err = strconv.Atoi(kv[1])
+			case "COUNT":
+				r.count, err = strconv.Atoi(kv[1])
+			case "UNTIL":
+				r.hasUntil = true
+				if r.until, err = time.Parse("20060102T150405Z", kv[1]); err != nil {
+					r.until, err = time.ParseInLocation("20060102", kv[1], server.ServiceLocation)
+					r.until = r.until.Add(24*time.Hour - time.Second) // 日付指定はその日の終わりまで
+				}
+			default:
+				return r, fmt.Errorf("unsupported RRULE part %q", part)
+			}
+			if err != nil {
+				return r, fmt.Errorf("invalid RRULE part %q: %w", part, err)
+			}
+		}
+	}
+	switch freq {
+	case "DAILY":
+		r.days = interval
+	case "WEEKLY":
+		r.days = 7 * interval
+	default:
+		return r, fmt.Errorf("unsupported FREQ %q", freq)
+	}
+	return r, nil
+}
+
+// expand は繰り返しイベントを instance に展開する。COUNT / UNTIL が無い場合は limit までで止める。
+func expand(master *calendar.Event, limit time.Time) ([]*calendar.Event, error) {
+	rule, err := parseRRule(master.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := span(master)
+	if err != nil {
+		return nil, err
+	}
+	allDay := master.Start.DateTime == ""
+	instances := []*calendar.Event{}
+	for i := 0; ; i++ {
+		if rule.count > 0 && i >= rule.count {
+			break
+		}
+		s, e := start.AddDate(0, 0, i*rule.days), end.AddDate(0, 0, i*rule.days)
+		if (rule.hasUntil && s.After(rule.until)) || !s.Before(limit) {
+			break
+		}
+		inst := *master
+		inst.Recurrence = nil
+		inst.RecurringEventId = master.Id
+		if allDay {
+			inst.Start = &calendar.EventDateTime{Date: s.Format("2006-01-02")}
+			inst.End = &calendar.EventDateTime{Date: e.Format("2006-01-02")}
+		} else {
+			inst.Start = &calendar.EventDateTime{DateTime: s.Format(time.RFC3339), TimeZone: master.Start.TimeZone}
+			inst.End = &calendar.EventDateTime{DateTime: e.Format(time.RFC3339), TimeZone: master.End.TimeZone}
+		}
+		inst.OriginalStartTime = inst.Start
+		if inst.Id, err = instanceID(master.Id, inst.Start); err != nil {
+			return nil, err
+		}
+		instances = append(instances, &inst)
+	}
+	return instances, nil
+}
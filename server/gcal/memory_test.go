@@ -0,0 +1,197 @@
+package gcal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/triax/hub/server"
+)
+
+const testCalendarYAML = `
+team@example.com:
+  - id: weekly
+    summary: "#練習 定期練習"
+    start: "2026-06-07T09:00:00+09:00"
+    end: "2026-06-07T12:00:00+09:00"
+    recurrence: ["RRULE:FREQ=WEEKLY;COUNT=4"]
+  - id: weekly-moved
+    recurring_event_id: weekly
+    original_start: "2026-06-14T09:00:00+09:00"
+    summary: "#練習 定期練習（午後）"
+    start: "2026-06-14T13:00:00+09:00"
+    end: "2026-06-14T16:00:00+09:00"
+  - id: weekly-cancelled
+    recurring_event_id: weekly
+    original_start: "2026-06-21T09:00:00+09:00"
+    status: cancelled
+  - id: camp
+    summary: 夏合宿
+    date: "2026-06-10"
+    end_date: "2026-06-12"
+  - id: cancelled-game
+    summary: "#試合 中止"
+    start: "2026-06-08T10:00:00+09:00"
+    end: "2026-06-08T13:00:00+09:00"
+    status: cancelled
+  - id: relative
+    summary: "#練習 相対指定"
+    start: "+3d 19:00"
+    end: "+3d 21:00"
+other@example.com:
+  - id: other
+    summary: 別カレンダー
+    date: "2026-06-09"
+`
+
+func TestMemory_List(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, server.ServiceLocation)
+	cal, err := ParseYAML([]byte(testCalendarYAML), now)
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	items, err := cal.List(context.Background(), Query{
+		CalendarID: "team@example.com",
+		From:       now,
+		To:         now.AddDate(0, 1, 0),
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	got := []string{}
+	for _, item := range items {
+		got = append(got, item.Id)
+	}
+	want := []string{
+		"relative",                // 6/4 19:00
+		"weekly_20260607T000000Z", // 6/7
+		"camp",                    // 6/10（終日）
+		"weekly_20260614T000000Z", // 6/14 は例外（weekly-moved）で上書き
+		"weekly_20260628T000000Z", // 6/21 はキャンセル
+	}
+	if len(got) != len(want) {
+		t.Fatalf("items = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("items[%d] = %s, want %s (all=%v)", i, got[i], want[i], got)
+		}
+	}
+	if items[0].Start.DateTime != "2026-06-04T19:00:00+09:00" {
+		t.Errorf("relative start = %s", items[0].Start.DateTime)
+	}
+	if items[1].RecurringEventId != "weekly" || items[1].Summary != "#練習 定期練習" {
+		t.Errorf("instance = %+v", items[1])
+	}
+	if items[2].Start.Date != "2026-06-10" || items[2].End.Date != "2026-06-12" {
+		t.Errorf("all-day = %+v %+v", items[2].Start, items[2].End)
+	}
+}
+
+func TestMemory_ListRange(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, server.ServiceLocation)
+	cal, err := ParseYAML([]byte(testCalendarYAML), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 6/11 は合宿の途中: 開始済みでも終了前のイベントは含まれる
+	from := time.Date(2026, 6, 11, 0, 0, 0, 0, server.ServiceLocation)
+	items, err := cal.List(context.Background(), Query{CalendarID: "team@example.com", From: from, To: from.Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Id != "camp" {
+		t.Errorf("items = %+v", items)
+	}
+}
+
+func TestParseYAML_Invalid(t *testing.T) {
+	for name, src := range map[string]string{
+		"no id":        "cal:\n  - summary: x\n    date: \"2026-06-01\"\n",
+		"no start":     "cal:\n  - id: x\n    summary: x\n",
+		"bad datetime": "cal:\n  - id: x\n    start: \"2026/06/01 09:00\"\n",
+		"bad end_date": "cal:\n  - id: x\n    date: \"2026-06-01\"\n    end_date: tomorrow\n",
+	} {
+		if _, err := ParseYAML([]byte(src), time.Now()); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	cal, err := ParseYAML([]byte("cal:\n  - id: x\n    start: \"2026-06-01T09:00:00+09:00\"\n    recurrence: [\"RRULE:FREQ=MONTHLY\"]\n"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cal.List(context.Background(), Query{CalendarID: "cal", To: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)}); err == nil {
+		t.Error("unsupported FREQ should be an error")
+	}
+}
+
+// リポジトリに置いているローカル開発用の YAML が読めること。
+func TestLoadYAML_LocalFixture(t *testing.T) {
+	cal, err := LoadYAML("../../fixtures/calendar.yaml", time.Now())
+	if err != nil {
+		t.Fatalf("LoadYAML: %v", err)
+	}
+	items, err := cal.List(context.Background(), Query{
+		CalendarID: "local@group.calendar.google.com",
+		From:       time.Now(),
+		To:         time.Now().AddDate(0, 6, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) == 0 {
+		t.Error("no events in fixtures/calendar.yaml")
+	}
+}
+
+func TestMemory_Sync(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, server.ServiceLocation)
+	cal, err := ParseYAML([]byte(testCalendarYAML), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 全件同期: キャンセルされたものを除く全 instance
+	full, err := cal.Sync(ctx, SyncQuery{CalendarID: "team@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !full.Full || len(full.Items) != 5 || full.NextSyncToken == "" {
+		t.Fatalf("full = %+v", full)
+	}
+
+	// 変更が無ければ空
+	changes, err := cal.Sync(ctx, SyncQuery{CalendarID: "team@example.com", SyncToken: full.NextSyncToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Full || len(changes.Items) != 0 {
+		t.Errorf("no changes = %+v", changes)
+	}
+
+	// マスターのキャンセルは全 instance（キャンセル済みのものも含む）が返る
+	if err := cal.Cancel("team@example.com", "weekly"); err != nil {
+		t.Fatal(err)
+	}
+	changes, err = cal.Sync(ctx, SyncQuery{CalendarID: "team@example.com", SyncToken: changes.NextSyncToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Items) != 4 {
+		t.Fatalf("cancelled = %+v", changes.Items)
+	}
+	for _, item := range changes.Items {
+		if item.Status != "cancelled" || item.RecurringEventId != "weekly" {
+			t.Errorf("item = %+v", item)
+		}
+	}
+
+	if _, err := cal.Sync(ctx, SyncQuery{CalendarID: "team@example.com", SyncToken: "999"}); err != ErrSyncTokenExpired {
+		t.Errorf("unknown token: err = %v", err)
+	}
+	if err := cal.Cancel("team@example.com", "nothing"); err == nil {
+		t.Error("cancel unknown event should be an error")
+	}
+}
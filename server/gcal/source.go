@@ -0,0 +1,87 @@
+package gcal
+
+import (
+	"fmt"
+	"os"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/triax/hub/server/models"
+)
+
+// Source は Hub に取り込むカレンダー 1 つの設定。
+//
+// GOOGLE_CALENDARS に YAML のリストで書く:
+//
+//	GOOGLE_CALENDARS: |
+//	  - name: team                          # 最初のものが主カレンダー
+//	    calendar_id: xxxxxxxxxx@group.calendar.google.com
+//	  - name: staff
+//	    calendar_id: yyyyyyyyyy@group.calendar.google.com
+//	    visibility: staff                   # スタッフにだけ見せる
+//	  - name: league
+//	    calendar_id: zzzzzzzzzz@group.calendar.google.com
+//	    tags: ["試合"]                       # タイトルに #試合 が無くても試合として扱う
+//
+// GOOGLE_CALENDARS が無ければ GOOGLE_CALENDAR_ID を "team" として 1 つだけ使う。
+type Source struct {
+	// Name は取り込み元の名前。Event の Google.Source に記録する。
+	Name       string `yaml:"name" json:"name"`
+	CalendarID string `yaml:"calendar_id" json:"calendar_id"`
+	// Tags はこのカレンダーのイベントに既定で付けるタグ。
+	Tags []models.EventTag `yaml:"tags" json:"tags,omitempty"`
+	// Visibility はこのカレンダーのイベントを閲覧できる範囲（空なら全員、"staff" ならスタッフのみ）。
+	Visibility models.EventVisibility `yaml:"visibility" json:"visibility,omitempty"`
+}
+
+// DefaultSourceName は GOOGLE_CALENDAR_ID だけが設定されているときの Source.Name。
+const DefaultSourceName = "team"
+
+// Apply は取り込み元の設定を ev に記録する。
+func (s Source) Apply(ev *models.GoogleEvent) {
+	ev.Source = s.Name
+	ev.Tags = s.Tags
+	ev.Visibility = s.Visibility
+}
+
+// LoadSources は環境変数から取り込むカレンダーの一覧を返す。
+func LoadSources() ([]Source, error) {
+	if v := os.Getenv("GOOGLE_CALENDARS"); v != "" {
+		return ParseSources([]byte(v))
+	}
+	id := os.Getenv("GOOGLE_CALENDAR_ID")
+	if id == "" {
+		return nil, fmt.Errorf("neither GOOGLE_CALENDARS nor GOOGLE_CALENDAR_ID is set")
+	}
+	return []Source{{Name: DefaultSourceName, CalendarID: id}}, nil
+}
+
+func ParseSources(b []byte) ([]Source, error) {
+	sources := []Source{}
+	if err := yaml.Unmarshal(b, &sources); err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no calendar is defined")
+	}
+	names := map[string]bool{}
+	for i, s := range sources {
+		if s.Name == "" || s.CalendarID == "" {
+			return nil, fmt.Errorf("calendars[%d]: name and calendar_id are required", i)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("calendars[%d]: duplicated name %q", i, s.Name)
+		}
+		names[s.Name] = true
+		for _, t := range s.Tags {
+			if !models.IsEventTag(t) {
+				return nil, fmt.Errorf("calendars[%d]: unknown tag %q", i, t)
+			}
+		}
+		switch s.Visibility {
+		case models.EVAll, models.EVStaff:
+		default:
+			return nil, fmt.Errorf("calendars[%d]: unknown visibility %q", i, s.Visibility)
+		}
+	}
+	return sources, nil
+}
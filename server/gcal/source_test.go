@@ -0,0 +1,49 @@
+package gcal
+
+import (
+	"testing"
+
+	"github.com/triax/hub/server/models"
+)
+
+func TestLoadSources(t *testing.T) {
+	t.Setenv("GOOGLE_CALENDARS", "")
+	t.Setenv("GOOGLE_CALENDAR_ID", "team@example.com")
+	sources, err := LoadSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0].Name != DefaultSourceName || sources[0].CalendarID != "team@example.com" {
+		t.Errorf("sources = %+v", sources)
+	}
+
+	t.Setenv("GOOGLE_CALENDARS", `
+- name: team
+  calendar_id: team@example.com
+- name: league
+  calendar_id: league@example.com
+  tags: ["試合"]
+  visibility: staff
+`)
+	sources, err = LoadSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 || sources[1].Tags[0] != models.ETGame || sources[1].Visibility != models.EVStaff {
+		t.Errorf("sources = %+v", sources)
+	}
+}
+
+func TestParseSources_Invalid(t *testing.T) {
+	for name, src := range map[string]string{
+		"empty":          "[]",
+		"no calendar_id": "- name: team\n",
+		"duplicated":     "- name: a\n  calendar_id: x\n- name: a\n  calendar_id: y\n",
+		"unknown tag":    "- name: a\n  calendar_id: x\n  tags: [\"合宿\"]\n",
+		"unknown vis":    "- name: a\n  calendar_id: x\n  visibility: private\n",
+	} {
+		if _, err := ParseSources([]byte(src)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
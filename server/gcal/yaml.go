@@ -0,0 +1,162 @@
+package gcal
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/triax/hub/server"
+	"google.golang.org/api/calendar/v3"
+)
+
+// yamlEvent は YAML に書くイベント。
+//
+//	# calendar ID ごとのイベント一覧
+//	xxxxxxxxxx@group.calendar.google.com:
+//	  - id: practice01
+//	    summary: "#練習 定期練習"
+//	    location: 大井ふ頭中央海浜公園
+//	    start: "2026-06-07T09:00:00+09:00"  # "+3d 09:00" のように実行日からの相対指定も可
+//	    end: "2026-06-07T12:00:00+09:00"
+//	    recurrence: ["RRULE:FREQ=WEEKLY;COUNT=8"]
+//	  - id: practice01-exception          # 繰り返しの 1 回だけを変更・キャンセルする
+//	    recurring_event_id: practice01
+//	    original_start: "2026-06-14T09:00:00+09:00"
+//	    status: cancelled
+//	  - id: camp
+//	    summary: 夏合宿
+//	    date: "2026-08-10"                  # 終日イベント（end_date は翌日が既定。排他的）
+//	    end_date: "2026-08-13"
+type yamlEvent struct {
+	ID               string   `yaml:"id"`
+	Summary          string   `yaml:"summary"`
+	Description      string   `yaml:"description"`
+	Location         string   `yaml:"location"`
+	Status           string   `yaml:"status"`
+	Start            string   `yaml:"start"`
+	End              string   `yaml:"end"`
+	Date             string   `yaml:"date"`
+	EndDate          string   `yaml:"end_date"`
+	Recurrence       []string `yaml:"recurrence"`
+	RecurringEventID string   `yaml:"recurring_event_id"`
+	OriginalStart    string   `yaml:"original_start"`
+}
+
+// relativeTime は "+3d 09:00" / "-1d" 形式の相対指定。
+var relativeTime = regexp.MustCompile(`^([+-]\d+)d(?:\s+(\d{1,2}):(\d{2}))?$`)
+
+// LoadYAML は YAML ファイルからカレンダーを作る。相対指定は now を基準に解決する。
+func LoadYAML(path string, now time.Time) (*Memory, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseYAML(b, now)
+}
+
+func ParseYAML(b []byte, now time.Time) (*Memory, error) {
+	calendars := map[string][]yamlEvent{}
+	if err := yaml.Unmarshal(b, &calendars); err != nil {
+		return nil, err
+	}
+	m := NewMemory()
+	for calendarID, defs := range calendars {
+		for i, def := range defs {
+			ev, err := def.toEvent(now)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d] (%s): %w", calendarID, i, def.ID, err)
+			}
+			m.Put(calendarID, ev)
+		}
+	}
+	return m, nil
+}
+
+func (def yamlEvent) toEvent(now time.Time) (*calendar.Event, error) {
+	if def.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	ev := &calendar.Event{
+		Id:               def.ID,
+		Summary:          def.Summary,
+		Description:      def.Description,
+		Location:         def.Location,
+		Status:           def.Status,
+		Recurrence:       def.Recurrence,
+		RecurringEventId: def.RecurringEventID,
+	}
+	if ev.Status == "" {
+		ev.Status = "confirmed"
+	}
+	if def.OriginalStart != "" {
+		original, err := parseDateTime(def.OriginalStart, now)
+		if err != nil {
+			return nil, fmt.Errorf("original_start: %w", err)
+		}
+		ev.OriginalStartTime = original
+	}
+
+	switch {
+	case def.Date != "":
+		start, err := parseDate(def.Date, now)
+		if err != nil {
+			return nil, fmt.Errorf("date: %w", err)
+		}
+		end := start.AddDate(0, 0, 1)
+		if def.EndDate != "" {
+			if end, err = parseDate(def.EndDate, now); err != nil {
+				return nil, fmt.Errorf("end_date: %w", err)
+			}
+		}
+		ev.Start = &calendar.EventDateTime{Date: start.Format("2006-01-02")}
+		ev.End = &calendar.EventDateTime{Date: end.Format("2006-01-02")}
+	case def.Start != "":
+		start, err := parseDateTime(def.Start, now)
+		if err != nil {
+			return nil, fmt.Errorf("start: %w", err)
+		}
+		end := start
+		if def.End != "" {
+			if end, err = parseDateTime(def.End, now); err != nil {
+				return nil, fmt.Errorf("end: %w", err)
+			}
+		}
+		ev.Start, ev.End = start, end
+	case def.Status == "cancelled" && def.RecurringEventID != "":
+		// キャンセルされた instance は時刻を持たなくてよい
+	default:
+		return nil, fmt.Errorf("either start or date is required")
+	}
+	return ev, nil
+}
+
+func parseDateTime(s string, now time.Time) (*calendar.EventDateTime, error) {
+	if m := relativeTime.FindStringSubmatch(s); m != nil {
+		days, _ := strconv.Atoi(m[1])
+		local := now.In(server.ServiceLocation)
+		hour, minute := 0, 0
+		if m[2] != "" {
+			hour, _ = strconv.Atoi(m[2])
+			minute, _ = strconv.Atoi(m[3])
+		}
+		t := time.Date(local.Year(), local.Month(), local.Day()+days, hour, minute, 0, 0, server.ServiceLocation)
+		return &calendar.EventDateTime{DateTime: t.Format(time.RFC3339), TimeZone: server.ServiceLocation.String()}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &calendar.EventDateTime{DateTime: t.Format(time.RFC3339)}, nil
+}
+
+func parseDate(s string, now time.Time) (time.Time, error) {
+	if m := relativeTime.FindStringSubmatch(s); m != nil && m[2] == "" {
+		days, _ := strconv.Atoi(m[1])
+		local := now.In(server.ServiceLocation)
+		return time.Date(local.Year(), local.Month(), local.Day()+days, 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Parse("2006-01-02", s)
+}
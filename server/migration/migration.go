@@ -0,0 +1,353 @@
+// Package migration は Datastore エンティティのスキーマ移行を行う。
+//
+// 移行は Version の昇順に並んだ Step の列で表す。Step は 1 つの Kind の全エンティティを
+// datastore.PropertyList として読み込み（struct に無いプロパティも失わない）、書き換える。
+// 実行済みのバージョンは SchemaVersion Kind に記録され、Step の途中経過（cursor）は
+// SchemaMigration Kind にチェックポイントとして保存されるので、中断しても続きから再開できる。
+// Step は何度適用しても同じ結果になる（冪等）ように書くこと。
+package migration
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	KindSchemaVersion   = "SchemaVersion"
+	KindSchemaMigration = "SchemaMigration"
+
+	// schemaVersionName は SchemaVersion の NameKey（アプリ全体で 1 件）。
+	schemaVersionName = "hub"
+
+	// putLimit は PutMulti 1 回あたりの上限件数。
+	putLimit = 500
+)
+
+type (
+	// Step は 1 つの Kind に対する移行。
+	Step struct {
+		Version     int
+		Kind        string
+		Description string
+		// Apply はエンティティ 1 件を移行する。書き換える場合は Entity のメソッドを使う。
+		Apply func(ctx context.Context, e *Entity) error
+	}
+
+	// SchemaVersion は適用済みの最新バージョン。
+	SchemaVersion struct {
+		Version   int       `json:"version"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// Checkpoint は Step の進捗。IDKey は Step.Version。
+	Checkpoint struct {
+		Version     int       `json:"version"`
+		Kind        string    `json:"kind"`
+		Cursor      string    `json:"cursor" datastore:",noindex"`
+		Processed   int       `json:"processed"`
+		Changed     int       `json:"changed"`
+		StartedAt   time.Time `json:"started_at"`
+		CompletedAt time.Time `json:"completed_at"`
+	}
+)
+
+// Entity は移行中のエンティティ 1 件。
+type Entity struct {
+	Key   *datastore.Key
+	Props datastore.PropertyList
+
+	changed bool
+	extra   []put
+	exists  func(ctx context.Context, key *datastore.Key) (bool, error)
+}
+
+type put struct {
+	key *datastore.Key
+	src interface{}
+}
+
+// Get は name のプロパティの値を返す。
+func (e *Entity) Get(name string) (interface{}, bool) {
+	for _, p := range e.Props {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set は name のプロパティを value にする。値が変わらなければ何もしない。
+func (e *Entity) Set(name string, value interface{}, noIndex bool) {
+	for i, p := range e.Props {
+		if p.Name != name {
+			continue
+		}
+		if reflect.DeepEqual(p.Value, value) && p.NoIndex == noIndex {
+			return
+		}
+		e.Props[i].Value, e.Props[i].NoIndex = value, noIndex
+		e.changed = true
+		return
+	}
+	e.Props = append(e.Props, datastore.Property{Name: name, Value: value, NoIndex: noIndex})
+	e.changed = true
+}
+
+// Delete は name のプロパティを削除する。
+func (e *Entity) Delete(name string) {
+	props := e.Props[:0]
+	for _, p := range e.Props {
+		if p.Name == name {
+			e.changed = true
+			continue
+		}
+		props = append(props, p)
+	}
+	e.Props = props
+}
+
+// Replace はプロパティ全体を props で置き換える。内容が同じなら何もしない。
+func (e *Entity) Replace(props datastore.PropertyList) {
+	if sameProperties(e.Props, props) {
+		return
+	}
+	e.Props = props
+	e.changed = true
+}
+
+// Changed は書き戻しが必要かを返す。
+func (e *Entity) Changed() bool {
+	return e.changed
+}
+
+// PutAlso は移行に伴って別のエンティティを書き込む（例: blob を別 Kind へ展開する）。
+// 書き込みはこの Entity と同じバッチで行われ、dry-run では書き込まれない。
+func (e *Entity) PutAlso(key *datastore.Key, src interface{}) {
+	e.extra = append(e.extra, put{key, src})
+}
+
+// Exists は key のエンティティが既に存在するかを返す。
+func (e *Entity) Exists(ctx context.Context, key *datastore.Key) (bool, error) {
+	return e.exists(ctx, key)
+}
+
+func sameProperties(a, b datastore.PropertyList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sa := append(datastore.PropertyList(nil), a...)
+	sb := append(datastore.PropertyList(nil), b...)
+	sort.SliceStable(sa, func(i, j int) bool { return sa[i].Name < sa[j].Name })
+	sort.SliceStable(sb, func(i, j int) bool { return sb[i].Name < sb[j].Name })
+	return reflect.DeepEqual(sa, sb)
+}
+
+// Runner は Step を順に適用する。
+type Runner struct {
+	Client *datastore.Client
+	// DryRun なら何も書き込まず、変更される件数だけを数える。
+	DryRun bool
+	// BatchSize は 1 回のクエリで読む件数（チェックポイントの間隔）。
+	BatchSize int
+	Logf      func(format string, args ...interface{})
+}
+
+func (r *Runner) logf(format string, args ...interface{}) {
+	if r.Logf != nil {
+		r.Logf(format, args...)
+	}
+}
+
+func schemaVersionKey() *datastore.Key {
+	return datastore.NameKey(KindSchemaVersion, schemaVersionName, nil)
+}
+
+func checkpointKey(version int) *datastore.Key {
+	return datastore.IDKey(KindSchemaMigration, int64(version), nil)
+}
+
+// CurrentVersion は適用済みのバージョンを返す。未記録なら 0。
+func (r *Runner) CurrentVersion(ctx context.Context) (int, error) {
+	v := SchemaVersion{}
+	if err := r.Client.Get(ctx, schemaVersionKey(), &v); err != nil && err != datastore.ErrNoSuchEntity {
+		return 0, err
+	}
+	return v.Version, nil
+}
+
+// Checkpoint は Step の進捗を返す。未着手なら nil。
+func (r *Runner) Checkpoint(ctx context.Context, version int) (*Checkpoint, error) {
+	cp := &Checkpoint{}
+	if err := r.Client.Get(ctx, checkpointKey(version), cp); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cp, nil
+}
+
+// Run は現在のバージョンより新しく target 以下の Step を順に適用する。
+// target が 0 なら最新まで適用する。
+func (r *Runner) Run(ctx context.Context, steps []Step, target int) error {
+	if err := Validate(steps); err != nil {
+		return err
+	}
+	current, err := r.CurrentVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+	r.logf("current schema version: %d", current)
+	for _, step := range steps {
+		if step.Version <= current {
+			continue
+		}
+		if target > 0 && step.Version > target {
+			break
+		}
+		cp, err := r.runStep(ctx, step)
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %w", step.Version, step.Description, err)
+		}
+		r.logf("step %d (%s): processed=%d changed=%d dry-run=%v", step.Version, step.Description, cp.Processed, cp.Changed, r.DryRun)
+		if r.DryRun {
+			continue
+		}
+		if _, err := r.Client.Put(ctx, schemaVersionKey(), &SchemaVersion{Version: step.Version, UpdatedAt: time.Now()}); err != nil {
+			return fmt.Errorf("failed to record schema version %d: %w", step.Version, err)
+		}
+	}
+	return nil
+}
+
+// Validate は Step が Version の昇順で重複なく並んでいることを確認する。
+func Validate(steps []Step) error {
+	prev := 0
+	for _, step := range steps {
+		if step.Version <= prev {
+			return fmt.Errorf("step versions must be strictly increasing: %d after %d", step.Version, prev)
+		}
+		if step.Kind == "" || step.Apply == nil {
+			return fmt.Errorf("step %d: Kind and Apply are required", step.Version)
+		}
+		prev = step.Version
+	}
+	return nil
+}
+
+func (r *Runner) runStep(ctx context.Context, step Step) (*Checkpoint, error) {
+	cp := &Checkpoint{Version: step.Version, Kind: step.Kind, StartedAt: time.Now()}
+	if !r.DryRun {
+		saved, err := r.Checkpoint(ctx, step.Version)
+		if err != nil {
+			return nil, err
+		}
+		if saved != nil {
+			if !saved.CompletedAt.IsZero() {
+				return saved, nil
+			}
+			cp = saved
+			r.logf("step %d: resuming from checkpoint (processed=%d)", step.Version, cp.Processed)
+		}
+	}
+	batch := r.BatchSize
+	if batch <= 0 {
+		batch = 100
+	}
+
+	for {
+		query := datastore.NewQuery(step.Kind).Limit(batch)
+		if cp.Cursor != "" {
+			cursor, err := datastore.DecodeCursor(cp.Cursor)
+			if err != nil {
+				return nil, err
+			}
+			query = query.Start(cursor)
+		}
+		it := r.Client.Run(ctx, query)
+		puts := []put{}
+		n := 0
+		for {
+			var props datastore.PropertyList
+			key, err := it.Next(&props)
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			n++
+			e := &Entity{Key: key, Props: props, exists: r.exists}
+			if err := step.Apply(ctx, e); err != nil {
+				return nil, fmt.Errorf("%v: %w", key, err)
+			}
+			if e.changed {
+				cp.Changed++
+				puts = append(puts, put{key, &e.Props})
+			}
+			puts = append(puts, e.extra...)
+		}
+		if n == 0 {
+			break
+		}
+		cp.Processed += n
+		next, err := it.Cursor()
+		if err != nil {
+			return nil, err
+		}
+		cp.Cursor = next.String()
+		if !r.DryRun {
+			if err := r.putMulti(ctx, puts); err != nil {
+				return nil, err
+			}
+			if _, err := r.Client.Put(ctx, checkpointKey(step.Version), cp); err != nil {
+				return nil, err
+			}
+		}
+		if n < batch {
+			break
+		}
+	}
+
+	cp.CompletedAt = time.Now()
+	if !r.DryRun {
+		if _, err := r.Client.Put(ctx, checkpointKey(step.Version), cp); err != nil {
+			return nil, err
+		}
+	}
+	return cp, nil
+}
+
+func (r *Runner) putMulti(ctx context.Context, puts []put) error {
+	for start := 0; start < len(puts); start += putLimit {
+		end := min(start+putLimit, len(puts))
+		keys := make([]*datastore.Key, 0, end-start)
+		srcs := make([]interface{}, 0, end-start)
+		for _, p := range puts[start:end] {
+			keys = append(keys, p.key)
+			srcs = append(srcs, p.src)
+		}
+		if _, err := r.Client.PutMulti(ctx, keys, srcs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) exists(ctx context.Context, key *datastore.Key) (bool, error) {
+	var props datastore.PropertyList
+	switch err := r.Client.Get(ctx, key, &props); err {
+	case nil:
+		return true, nil
+	case datastore.ErrNoSuchEntity:
+		return false, nil
+	default:
+		return false, err
+	}
+}
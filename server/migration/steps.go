@@ -0,0 +1,120 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/server/models"
+)
+
+// Steps はこのアプリのスキーマ移行の一覧。新しい移行は末尾に Version を増やして追加する。
+// 適用済みの Step を書き換えてはいけない（本番では再実行されない）。
+var Steps = []Step{
+	{
+		Version:     1,
+		Kind:        models.KindEvent,
+		Description: "Event.ParticipationsJSONString を Participation Kind へ展開する",
+		Apply:       explodeParticipations,
+	},
+	{
+		Version:     2,
+		Kind:        models.KindEquip,
+		Description: "Equip.StorageType を backfill する（未設定は持ち帰り）",
+		Apply:       backfillEquipStorageType,
+	},
+	{
+		Version:     3,
+		Kind:        models.KindHPProfile,
+		Description: "MemberHPProfile を現在の struct で保存し直す（不足フィールドの追加・廃止フィールドの削除）",
+		Apply: func(ctx context.Context, e *Entity) error {
+			return resave(e, &models.MemberHPProfile{})
+		},
+	},
+	{
+		Version:     4,
+		Kind:        models.KindMember,
+		Description: "Member.Positions を Slack の役職（Title）から取り込む（設定済みのメンバーはそのまま）",
+		Apply:       importMemberPositions,
+	},
+}
+
+// resave は e を dst の struct へ読み込んで保存し直す。
+// struct に無いプロパティ（ErrFieldMismatch の原因）は削除され、
+// Datastore に無いフィールドはゼロ値で追加される。
+func resave(e *Entity, dst interface{}) error {
+	if err := datastore.LoadStruct(dst, e.Props); err != nil && !models.IsFiledMismatch(err) {
+		return err
+	}
+	props, err := datastore.SaveStruct(dst)
+	if err != nil {
+		return err
+	}
+	e.Replace(props)
+	return nil
+}
+
+func explodeParticipations(ctx context.Context, e *Entity) error {
+	const prop = "ParticipationsJSONString"
+	v, ok := e.Get(prop)
+	if !ok {
+		return nil
+	}
+	blob, _ := v.(string)
+	legacy, err := models.Event{LegacyParticipationsJSONString: blob}.LegacyParticipations()
+	if err != nil {
+		return fmt.Errorf("%s: %w", prop, err)
+	}
+	for memberID, p := range legacy {
+		key := datastore.NameKey(models.KindParticipation, models.ParticipationKeyName(e.Key.Name, memberID), nil)
+		// 既に Participation があれば、それは blob より新しい回答なので上書きしない
+		exists, err := e.Exists(ctx, key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		part := &models.Participation{
+			EventID:  e.Key.Name,
+			MemberID: memberID,
+			Type:     p.Type,
+			Params:   p.Params,
+			// blob には回答日時が無いので AnsweredAt はゼロ（不明）のままにする
+		}
+		if err := part.MarshalParams(); err != nil {
+			return err
+		}
+		e.PutAlso(key, part)
+	}
+	e.Delete(prop)
+	return nil
+}
+
+func backfillEquipStorageType(ctx context.Context, e *Entity) error {
+	equip := &models.Equip{}
+	if err := resave(e, equip); err != nil {
+		return err
+	}
+	if equip.StorageType == "" {
+		e.Set("StorageType", string(models.StorageTypeTakeHome), false)
+	}
+	return nil
+}
+
+func importMemberPositions(ctx context.Context, e *Entity) error {
+	member := &models.Member{}
+	if err := resave(e, member); err != nil {
+		return err
+	}
+	if member.Positions.Label() != "" || member.Positions.Unit != "" {
+		return nil
+	}
+	member.Positions = models.ImportPositions(*member)
+	props, err := datastore.SaveStruct(member)
+	if err != nil {
+		return err
+	}
+	e.Replace(props)
+	return nil
+}
@@ -0,0 +1,142 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/server/models"
+)
+
+func TestStepsValidate(t *testing.T) {
+	if err := Validate(Steps); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate([]Step{Steps[1], Steps[0]}); err == nil {
+		t.Error("expected error for out-of-order steps")
+	}
+}
+
+func newEntity(key *datastore.Key, props datastore.PropertyList, existing ...*datastore.Key) *Entity {
+	return &Entity{Key: key, Props: props, exists: func(_ context.Context, k *datastore.Key) (bool, error) {
+		for _, e := range existing {
+			if e.Equal(k) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}}
+}
+
+// TestExplodeParticipations は、blob が Participation へ展開され、既存の回答は上書きされないことを検証する。
+func TestExplodeParticipations(t *testing.T) {
+	ctx := context.Background()
+	existing := datastore.NameKey(models.KindParticipation, models.ParticipationKeyName("ev001", "U00000002"), nil)
+	e := newEntity(datastore.NameKey(models.KindEvent, "ev001", nil), datastore.PropertyList{
+		{Name: "Google.ID", Value: "ev001"},
+		{Name: "ParticipationsJSONString", Value: `{"U00000001":{"type":"join_late","params":{"time":"10:00"}},"U00000002":{"type":"absent"}}`, NoIndex: true},
+	}, existing)
+
+	if err := explodeParticipations(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if !e.Changed() {
+		t.Error("entity should be changed")
+	}
+	if _, ok := e.Get("ParticipationsJSONString"); ok {
+		t.Error("blob should be removed")
+	}
+	if len(e.extra) != 1 {
+		t.Fatalf("len(extra) = %d, want 1", len(e.extra))
+	}
+	part := e.extra[0].src.(*models.Participation)
+	if part.MemberID != "U00000001" || part.Type != models.PTJoinLate || part.ParamsJSON != `{"time":"10:00"}` {
+		t.Errorf("participation = %+v", part)
+	}
+
+	// 2 回目は何もしない（冪等）
+	again := newEntity(e.Key, e.Props)
+	if err := explodeParticipations(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+	if again.Changed() || len(again.extra) != 0 {
+		t.Error("second run should be no-op")
+	}
+}
+
+func TestBackfillEquipStorageType(t *testing.T) {
+	ctx := context.Background()
+	e := newEntity(datastore.IDKey(models.KindEquip, 1, nil), datastore.PropertyList{
+		{Name: "Name", Value: "ビデオカメラ"},
+		{Name: "ForPractice", Value: true},
+		{Name: "Obsolete", Value: "x"},
+	})
+	if err := backfillEquipStorageType(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := e.Get("StorageType"); v != string(models.StorageTypeTakeHome) {
+		t.Errorf("StorageType = %v", v)
+	}
+	if _, ok := e.Get("Obsolete"); ok {
+		t.Error("unknown property should be removed")
+	}
+
+	again := newEntity(e.Key, e.Props)
+	if err := backfillEquipStorageType(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+	if again.Changed() {
+		t.Error("second run should be no-op")
+	}
+}
+
+// TestImportMemberPositions は、Slack の役職からポジションが取り込まれ、設定済みのポジションは上書きされないことを検証する。
+func TestImportMemberPositions(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		title string
+		want  string // Label()
+		unit  string
+	}{
+		{"OL/DL", "OL/DL", "offence"},
+		{"老害/Staff", "Staff", "staff"},
+		{"db", "DB", "defence"},
+		{"OL コーチ", "OL/Coach", "offence"},
+		{"Trainer", "Trainer", "staff"},
+		{"マネージャー", "", ""},
+	}
+	for _, c := range cases {
+		e := newEntity(datastore.NameKey(models.KindMember, "U001", nil), datastore.PropertyList{
+			{Name: "Slack.ID", Value: "U001"},
+			{Name: "Slack.Profile.Title", Value: c.title},
+		})
+		if err := importMemberPositions(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+		member := models.Member{}
+		if err := datastore.LoadStruct(&member, e.Props); err != nil {
+			t.Fatal(err)
+		}
+		if got := member.Positions; got.Label() != c.want || got.Unit != c.unit {
+			t.Errorf("%q: positions = %+v, want %q (%s)", c.title, got, c.want, c.unit)
+		}
+	}
+
+	// hub で設定済みなら役職とずれていても上書きしない
+	e := newEntity(datastore.NameKey(models.KindMember, "U002", nil), datastore.PropertyList{
+		{Name: "Slack.ID", Value: "U002"},
+		{Name: "Slack.Profile.Title", Value: "OL"},
+		{Name: "Positions.Primary", Value: "TE"},
+		{Name: "Positions.Unit", Value: "offence"},
+	})
+	if err := importMemberPositions(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	member := models.Member{}
+	if err := datastore.LoadStruct(&member, e.Props); err != nil {
+		t.Fatal(err)
+	}
+	if member.Positions.Primary != "TE" {
+		t.Errorf("positions = %+v, want TE", member.Positions)
+	}
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+const (
+	KindAttendance  = "Attendance"
+	KindCheckInCode = "CheckInCode"
+)
+
+// AttendanceMethod はチェックインの記録のしかた。
+type AttendanceMethod string
+
+const (
+	// AMRoster はスタッフが名簿から記録した出席。
+	AMRoster AttendanceMethod = "roster"
+	// AMCode はメンバー本人がチェックインコードで記録した出席。
+	AMCode AttendanceMethod = "code"
+)
+
+type (
+	// Attendance は Event に実際に来たメンバーの記録（チェックイン）。
+	// 出欠回答（Participation）は予定を表すだけなので、実績はこちらに分けて持つ。
+	// NameKey: ParticipationKeyName(eventID, memberID)
+	Attendance struct {
+		EventID     string           `json:"event_id"`
+		MemberID    string           `json:"member_id"`
+		Method      AttendanceMethod `json:"method"`
+		CheckedInAt int64            `json:"checked_in_at"` // ミリ秒
+		// RecordedBy は記録した人の Slack ID（セルフチェックインなら本人）。
+		RecordedBy string `json:"recorded_by"`
+	}
+
+	// CheckInCode はセルフチェックイン用の期限付きのコード（NameKey: eventID）。
+	// 発行し直すと前のコードは使えなくなる。
+	CheckInCode struct {
+		EventID   string    `json:"event_id"`
+		Code      string    `json:"code" datastore:",noindex"`
+		ExpiresAt time.Time `json:"expires_at"`
+		IssuedBy  string    `json:"issued_by"`
+		// FailedBy は間違ったコードを送ったメンバーの Slack ID（1 回ごとに 1 件）。発行し直すと空になる。
+		FailedBy []string `json:"-" datastore:",noindex"`
+	}
+)
+
+const (
+	// CheckInCodeDefaultTTL はチェックインコードの既定の有効期間。
+	CheckInCodeDefaultTTL = 30 * time.Minute
+	// CheckInCodeMaxTTL はチェックインコードの有効期間の上限。
+	CheckInCodeMaxTTL = 6 * time.Hour
+
+	checkInCodeDigits = 6
+
+	// CheckInMaxFailures はひとりが同じコードに対して間違えられる回数。超えたらコードを発行し直すまでチェックインできない。
+	CheckInMaxFailures = 5
+)
+
+// NewCheckInCode は eventID のチェックインコード（数字 6 桁）を発行する。
+func NewCheckInCode(eventID, issuedBy string, ttl time.Duration, now time.Time) (*CheckInCode, error) {
+	if ttl <= 0 || ttl > CheckInCodeMaxTTL {
+		return nil, fmt.Errorf("ttl must be between 1m and %s", CheckInCodeMaxTTL)
+	}
+	max := big.NewInt(1)
+	for i := 0; i < checkInCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return nil, err
+	}
+	return &CheckInCode{
+		EventID:   eventID,
+		Code:      fmt.Sprintf("%0*d", checkInCodeDigits, n.Int64()),
+		ExpiresAt: now.Add(ttl),
+		IssuedBy:  issuedBy,
+	}, nil
+}
+
+// Verify は code が一致し、かつ期限内かを返す。
+func (c CheckInCode) Verify(code string, now time.Time) bool {
+	if c.Code == "" || !now.Before(c.ExpiresAt) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Code), []byte(code)) == 1
+}
+
+// LockedOut は memberID が CheckInMaxFailures 回間違えて、このコードでチェックインできなくなったかを返す。
+func (c CheckInCode) LockedOut(memberID string) bool {
+	failures := 0
+	for _, id := range c.FailedBy {
+		if id == memberID {
+			failures++
+		}
+	}
+	return failures >= CheckInMaxFailures
+}
+
+// AttendanceEntry は出席状況のレポートの 1 行。
+type AttendanceEntry struct {
+	MemberID string `json:"member_id"`
+	Name     string `json:"name"`
+	// RSVP は出欠回答（未回答なら "unanswered"）。
+	RSVP       ParticipationType `json:"rsvp"`
+	Attendance *Attendance       `json:"attendance,omitempty"`
+}
+
+// AttendanceReport は Event の出欠回答とチェックインの突き合わせ。
+type AttendanceReport struct {
+	EventID string `json:"event_id"`
+	// Attended はチェックインしたメンバー全員。
+	Attended []AttendanceEntry `json:"attended"`
+	// NoShows は参加（遅参・早退を含む）と回答したのにチェックインしていないメンバー。
+	NoShows []AttendanceEntry `json:"no_shows"`
+	// Unannounced は参加と回答せずに（不参加・未回答のまま）チェックインしたメンバー。
+	Unannounced []AttendanceEntry `json:"unannounced"`
+}
+
+// NewAttendanceReport は出欠回答とチェックインから AttendanceReport を作る。
+// members は名前の解決に使い、見つからないメンバーは Slack ID を名前とする。
+func NewAttendanceReport(eventID string, parts []Participation, atts []Attendance, members map[string]Member) AttendanceReport {
+	report := AttendanceReport{EventID: eventID, Attended: []AttendanceEntry{}, NoShows: []AttendanceEntry{}, Unannounced: []AttendanceEntry{}}
+	participations := ParticipationsOf(parts)
+	entry := func(memberID string) AttendanceEntry {
+		e := AttendanceEntry{MemberID: memberID, Name: memberID, RSVP: PTUnanswered}
+		if m, ok := members[memberID]; ok && m.Name() != "" {
+			e.Name = m.Name()
+		}
+		if p, ok := participations[memberID]; ok && !p.Type.Unanswered() {
+			e.RSVP = p.Type
+		}
+		return e
+	}
+
+	attended := map[string]bool{}
+	for _, att := range atts {
+		att := att
+		attended[att.MemberID] = true
+		e := entry(att.MemberID)
+		e.Attendance = &att
+		report.Attended = append(report.Attended, e)
+		if !e.RSVP.JoinAnyhow() {
+			report.Unannounced = append(report.Unannounced, e)
+		}
+	}
+	for _, p := range parts {
+		if p.Type.JoinAnyhow() && !attended[p.MemberID] {
+			report.NoShows = append(report.NoShows, entry(p.MemberID))
+		}
+	}
+
+	for _, entries := range [][]AttendanceEntry{report.Attended, report.NoShows, report.Unannounced} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	}
+	return report
+}
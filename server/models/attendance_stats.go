@@ -0,0 +1,80 @@
+package models
+
+type (
+	// AttendanceStats は過去のイベントに対する出欠回答の集計。
+	AttendanceStats struct {
+		Events     int `json:"events"` // 集計対象のイベント数
+		Join       int `json:"join"`
+		JoinLate   int `json:"join_late"`
+		LeaveEarly int `json:"leave_early"`
+		Absent     int `json:"absent"`
+		Unanswered int `json:"unanswered"`
+		// CheckedIn は実際に出席（チェックイン）したイベント数。
+		CheckedIn int `json:"checked_in"`
+		// AttendanceRate は参加（遅参・早退を含む）と回答した割合、UnansweredRate は未回答の割合（0〜1）。
+		AttendanceRate float64 `json:"attendance_rate"`
+		UnansweredRate float64 `json:"unanswered_rate"`
+		// CurrentStreak は直近のイベントから続けて参加している回数、LongestStreak は期間中の最長。
+		CurrentStreak int `json:"current_streak"`
+		LongestStreak int `json:"longest_streak"`
+	}
+
+	// MemberAttendanceStats はメンバー 1 人の集計。ByTag はタグ（練習・試合など）ごとの内訳。
+	MemberAttendanceStats struct {
+		MemberID string                       `json:"member_id"`
+		Name     string                       `json:"name"`
+		Total    AttendanceStats              `json:"total"`
+		ByTag    map[EventTag]AttendanceStats `json:"by_tag"`
+	}
+)
+
+func (s *AttendanceStats) add(typ ParticipationType, checkedIn bool) {
+	s.Events++
+	switch typ {
+	case PTJoin:
+		s.Join++
+	case PTJoinLate:
+		s.JoinLate++
+	case PTLeaveEarly:
+		s.LeaveEarly++
+	case PTAbsent:
+		s.Absent++
+	default:
+		s.Unanswered++
+	}
+	if checkedIn {
+		s.CheckedIn++
+	}
+	if typ.JoinAnyhow() {
+		s.CurrentStreak++
+		s.LongestStreak = max(s.LongestStreak, s.CurrentStreak)
+	} else {
+		s.CurrentStreak = 0
+	}
+	s.AttendanceRate = float64(s.Join+s.JoinLate+s.LeaveEarly) / float64(s.Events)
+	s.UnansweredRate = float64(s.Unanswered) / float64(s.Events)
+}
+
+// NewMemberAttendanceStats は events（開始時刻の昇順）に対する member の出欠回答を集計する。
+// answers と checkedIn は Event ID をキーとする member の回答とチェックイン。
+// 入部日（JoinedAt）より前のイベントは数えない。
+// tags に含まれるタグを持つイベントは、タグごとにも集計する。
+func NewMemberAttendanceStats(member Member, events []Event, answers AnswersByEvent, checkedIn map[string]bool, tags []EventTag) MemberAttendanceStats {
+	stats := MemberAttendanceStats{MemberID: member.Slack.ID, Name: member.Name(), ByTag: map[EventTag]AttendanceStats{}}
+	for _, ev := range events {
+		if ev.Google.Start().Before(member.JoinedAt) {
+			continue
+		}
+		typ := answers[ev.Google.ID].Type
+		attended := checkedIn[ev.Google.ID]
+		stats.Total.add(typ, attended)
+		for _, tag := range tags {
+			if ev.HasTag(tag) {
+				s := stats.ByTag[tag]
+				s.add(typ, attended)
+				stats.ByTag[tag] = s
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type (
+	// AuditEntry は API 経由の書き込み 1 件の記録（IDKey: auto-ID）。
+	AuditEntry struct {
+		Actor     string        `json:"actor"`      // 操作者の Slack ID
+		Action    string        `json:"action"`     // 例: "Event.Delete", "Number.Assign"
+		Kind      string        `json:"kind"`       // 対象の Kind
+		TargetKey string        `json:"target_key"` // 対象の key（Kind 内で一意な名前 / ID）
+		Changes   []AuditChange `json:"changes" datastore:",noindex"`
+		Timestamp time.Time     `json:"timestamp"`
+	}
+
+	// AuditChange はフィールド単位の変更。値は JSON 表現。
+	// Field は JSON のパス（例: "slack.profile.title"）、存在しなかった側は空文字。
+	AuditChange struct {
+		Field  string `json:"field"`
+		Before string `json:"before"`
+		After  string `json:"after"`
+	}
+)
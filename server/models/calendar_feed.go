@@ -0,0 +1,27 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+const KindCalendarFeed = "CalendarFeed"
+
+// CalendarFeed は個人用の iCalendar フィードの購読トークン（NameKey: Token）。
+// フィードの URL はカレンダーアプリに登録するだけで認証できないので、トークンそのものが鍵になる。
+// 漏れた場合は作り直す（古いトークンは削除する）。
+type CalendarFeed struct {
+	Token     string    `json:"token"`
+	MemberID  string    `json:"member_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewCalendarFeed は memberID のフィードのトークンを発行する。
+func NewCalendarFeed(memberID string, now time.Time) (*CalendarFeed, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &CalendarFeed{Token: hex.EncodeToString(b), MemberID: memberID, CreatedAt: now}, nil
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+const KindCalendarSync = "CalendarSync"
+
+// CalendarSync は Google Calendar の増分同期の状態。
+// Datastore のキーは Google Calendar ID。
+type CalendarSync struct {
+	CalendarID string `json:"calendar_id"`
+	// SyncToken は次回の増分同期に使う events.list の nextSyncToken。
+	SyncToken string    `json:"sync_token" datastore:",noindex"`
+	SyncedAt  time.Time `json:"synced_at"`
+}
@@ -14,6 +14,8 @@ const (
 	KindTapingMenuItem = "TapingMenuItem"
 	KindTaping         = "Taping"
 	KindApplication    = "Application"
+	KindParticipation  = "Participation"
+	KindAuditEntry     = "AuditEntry"
 )
 
 // IsFieldMismatch ...
@@ -21,6 +23,7 @@ const (
 // datastore側で存在するフィールドを、struct側が持っていない場合、
 // ErrFieldMismatchが起きるが、これはdataのマイグレーション上めんどくさいので、
 // このエラーだけは無視したいことが多々ある。
+// 廃止したフィールドは cmd/migrate（server/migration.Steps）で実データから取り除くこと。
 // @See
 //   - https://github.com/googleapis/google-cloud-go/issues/913
 //   - https://pkg.go.dev/cloud.google.com/go/datastore#ErrFieldMismatch
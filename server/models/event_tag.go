@@ -0,0 +1,173 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const KindEventTagRule = "EventTagRule"
+
+// ReminderTypes はリマインダ種別の一覧（EventTagRule.Reminders に書ける値）。
+var ReminderTypes = []ReminderType{RTRSVP, RTFinalCall, RTCondition, RTEquipment, RTHeadcount}
+
+// EventTagRule はイベントのタグ 1 つの定義。Datastore のキーは Tag。
+//
+// Datastore に 1 件も無い間は DefaultEventTagRules を使う。管理者が API から編集すると、
+// サーバ（Event.Tags / ShouldSkipReminders など）とクライアント（TriaxEvent.ts）の両方がこれに従う。
+type EventTagRule struct {
+	Tag   EventTag `json:"tag"`
+	Label string   `json:"label"`
+	// Pattern はタイトルにこのタグが含まれるかを判定する正規表現。
+	// クライアントでは JavaScript の RegExp として使うので、両方で同じ意味になる書き方にすること。
+	Pattern string `json:"pattern" datastore:",noindex"`
+	// Order は判定順（Event.Tags の並び順）。
+	Order int `json:"order"`
+	// Reminders はこのタグのイベントで送るリマインダ。タグが複数あればいずれかが望むものを送る。
+	Reminders []ReminderType `json:"reminders" datastore:",noindex"`
+	// Taping はテーピングリクエストの対象にするか。
+	Taping bool `json:"taping"`
+	// RSVPDeadlineHours は出欠回答の締め切り（開始の何時間前か）。0 なら締め切りは無い。
+	// 締め切り後の回答には理由が必要で、遅い変更としてポジションのコーチに連絡する。
+	RSVPDeadlineHours int `json:"rsvp_deadline_hours,omitempty"`
+	// Schedule は Reminders を送るタイミング。書かれていない種別は DefaultReminderSchedule に従う。
+	Schedule []ReminderOffset `json:"schedule,omitempty" datastore:",noindex"`
+	// Minimums はポジションごとの最低人数。参加予定が満たないポジションは人数不足のリマインダ（RTHeadcount）で警告する。
+	Minimums []PositionMinimum `json:"minimums,omitempty" datastore:",noindex"`
+
+	re *regexp.Regexp
+}
+
+// builtinEventTags はコードが意味を持たせているタグ（削除はできず、パターン等の編集だけできる）。
+var builtinEventTags = []EventTag{ETPractice, ETGame, ETIgnore, ETMeeting, ETEvent, ETSponsor}
+
+// IsBuiltinEventTag は t がコードから参照される組み込みのタグかを返す。
+func IsBuiltinEventTag(t EventTag) bool {
+	return slices.Contains(builtinEventTags, t)
+}
+
+// defaultPositionMinimums は練習・試合の既定の最低人数。
+var defaultPositionMinimums = []PositionMinimum{{Position: "QB", Min: 1}, {Position: "OL", Min: 5}}
+
+// DefaultEventTagRules は Datastore にタグの定義が無いときの既定値。
+func DefaultEventTagRules() []EventTagRule {
+	return []EventTagRule{
+		{Tag: ETPractice, Label: "練習", Pattern: EventExpressionPractice.String(), Order: 10, Reminders: ReminderTypes, Taping: true, RSVPDeadlineHours: 67, Minimums: defaultPositionMinimums},
+		{Tag: ETGame, Label: "試合", Pattern: EventExpressionGame.String(), Order: 20, Reminders: ReminderTypes, Taping: true, RSVPDeadlineHours: 72, Minimums: defaultPositionMinimums},
+		{Tag: ETIgnore, Label: "対象外", Pattern: EventExpressionIgnore.String(), Order: 30, Reminders: []ReminderType{}},
+		{Tag: ETMeeting, Label: "ミーティング", Pattern: EventExpressionMeeting.String(), Order: 40, Reminders: []ReminderType{}},
+		{Tag: ETEvent, Label: "イベント", Pattern: EventExpressionEvent.String(), Order: 50, Reminders: []ReminderType{RTRSVP}},
+		{Tag: ETSponsor, Label: "スポンサー", Pattern: EventExpressionSponsor.String(), Order: 60, Reminders: []ReminderType{RTRSVP}},
+	}
+}
+
+// Validate は定義を検査し、Pattern をコンパイルする。
+func (r *EventTagRule) Validate() error {
+	if r.Tag == "" || r.Tag == ETUnkonwn || strings.ContainsAny(string(r.Tag), " \t#＃") {
+		return fmt.Errorf("invalid tag %q", r.Tag)
+	}
+	if r.Pattern == "" {
+		return fmt.Errorf("%s: pattern is required", r.Tag)
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("%s: invalid pattern: %w", r.Tag, err)
+	}
+	for _, rt := range r.Reminders {
+		if !slices.Contains(ReminderTypes, rt) {
+			return fmt.Errorf("%s: unknown reminder type %q", r.Tag, rt)
+		}
+	}
+	if r.RSVPDeadlineHours < 0 || r.RSVPDeadlineHours > MaxReminderHours {
+		return fmt.Errorf("%s: rsvp_deadline_hours must be between 0 and %d", r.Tag, MaxReminderHours)
+	}
+	for _, o := range r.Schedule {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("%s: schedule: %w", r.Tag, err)
+		}
+	}
+	for _, m := range r.Minimums {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("%s: minimums: %w", r.Tag, err)
+		}
+	}
+	r.re = re
+	return nil
+}
+
+// Sends はこのタグが rt のリマインダを望むかを返す。
+func (r EventTagRule) Sends(rt ReminderType) bool {
+	return slices.Contains(r.Reminders, rt)
+}
+
+// activeEventTagRules は Event.Tags などが使うタグの定義（Order 順）。
+var activeEventTagRules = struct {
+	sync.RWMutex
+	rules []EventTagRule
+}{rules: mustCompileEventTagRules(DefaultEventTagRules())}
+
+func mustCompileEventTagRules(rules []EventTagRule) []EventTagRule {
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			panic(err)
+		}
+	}
+	return rules
+}
+
+// SetEventTagRules はタグの定義を入れ替える。空なら DefaultEventTagRules に戻す。
+// 1 つでも不正な定義があれば入れ替えずにエラーを返す。
+func SetEventTagRules(rules []EventTagRule) error {
+	if len(rules) == 0 {
+		rules = DefaultEventTagRules()
+	}
+	compiled := make([]EventTagRule, len(rules))
+	copy(compiled, rules)
+	seen := map[EventTag]bool{}
+	for i := range compiled {
+		if err := compiled[i].Validate(); err != nil {
+			return err
+		}
+		if seen[compiled[i].Tag] {
+			return fmt.Errorf("duplicated tag %q", compiled[i].Tag)
+		}
+		seen[compiled[i].Tag] = true
+	}
+	sort.SliceStable(compiled, func(i, j int) bool { return compiled[i].Order < compiled[j].Order })
+	activeEventTagRules.Lock()
+	defer activeEventTagRules.Unlock()
+	activeEventTagRules.rules = compiled
+	return nil
+}
+
+// EventTagRules は現在のタグの定義を Order 順に返す。
+func EventTagRules() []EventTagRule {
+	activeEventTagRules.RLock()
+	defer activeEventTagRules.RUnlock()
+	return slices.Clone(activeEventTagRules.rules)
+}
+
+// eventTagRule は t の定義を返す。
+func eventTagRule(t EventTag) (EventTagRule, bool) {
+	for _, r := range EventTagRules() {
+		if r.Tag == t {
+			return r, true
+		}
+	}
+	return EventTagRule{}, false
+}
+
+// IsEventTag は t がタグとして定義されているかを返す（ETUnkonwn は含まない）。
+func IsEventTag(t EventTag) bool {
+	_, ok := eventTagRule(t)
+	return ok
+}
+
+// TitleHasTag はタイトルに t のタグが含まれるかを返す（カレンダーの既定タグは見ない）。
+func TitleHasTag(title string, t EventTag) bool {
+	r, ok := eventTagRule(t)
+	return ok && r.re.MatchString(title)
+}
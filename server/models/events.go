@@ -1,30 +1,35 @@
 package models
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
-	"os"
 	"regexp"
 	"slices"
 	"strings"
 	"time"
-
-	"cloud.google.com/go/datastore"
 )
 
 type (
 	Event struct {
 		Google GoogleEvent `json:"google"`
 
-		ParticipationsJSONString string `json:"participations_json_str" datastore:",noindex"`
-	}
+		// ParticipationsJSONString は API 互換のためのフィールド。
+		// 出欠回答は Participation Kind に保存され、API 応答時に SetParticipations で埋める。
+		ParticipationsJSONString string `json:"participations_json_str" datastore:"-"`
 
-	Participations map[string]Participation
+		// LegacyParticipationsJSONString は Participation Kind 導入前に Event へ直接保存していた回答の JSON。
+		// cmd/migrate で Participation Kind へ移行した後に空にされる。新規に書き込んではいけない。
+		LegacyParticipationsJSONString string `json:"-" datastore:"ParticipationsJSONString,noindex,omitempty"`
 
-	Participation struct {
-		Type   ParticipationType      `json:"type"`
-		Params map[string]interface{} `json:"params"`
+		// Cancelled は Google Calendar 側でキャンセル（削除）されたことを示す。
+		// 回答の履歴を残すため Event は消さず、一覧やリマインダの対象から外す。
+		Cancelled bool `json:"cancelled"`
+
+		// RSVPDeadlineOverride はこのイベントだけの出欠回答の締め切り（ミリ秒）。0 ならタグの RSVPDeadlineHours に従う。
+		RSVPDeadlineOverride int64 `json:"rsvp_deadline_override,omitempty"`
+		// RSVPDeadline は API 応答用の実際の締め切り（ミリ秒、締め切りが無ければ 0）。SetRSVPDeadline で埋める。
+		RSVPDeadline int64 `json:"rsvp_deadline,omitempty" datastore:"-"`
+		// RSVPCounts は API 応答用の回答の内訳（回答の種類 → 人数）。SetRSVPCounts で埋める。
+		RSVPCounts map[ParticipationType]int `json:"rsvp_counts,omitempty" datastore:"-"`
 	}
 
 	ParticipationType string
@@ -47,6 +52,8 @@ const (
 	RTFinalCall ReminderType = "final_call"
 	RTCondition ReminderType = "condition"
 	RTEquipment ReminderType = "equipment"
+	// RTHeadcount はポジションの人数不足の警告（EventTagRule.Minimums に満たないときだけポジションのチャンネルへ送る）。
+	RTHeadcount ReminderType = "headcount"
 )
 
 type (
@@ -63,6 +70,18 @@ const (
 	ETUnkonwn  EventTag = "UNKNOWN"
 )
 
+// EventVisibility は Event を閲覧できるメンバーの範囲。
+type EventVisibility string
+
+const (
+	// EVAll は全メンバーに見える（既定）。
+	EVAll EventVisibility = ""
+	// EVStaff はスタッフ（Member.IsStaff）にだけ見える。
+	EVStaff EventVisibility = "staff"
+)
+
+// EventExpression* は組み込みのタグの既定のパターン（DefaultEventTagRules）。
+// 実際の判定は管理者が編集できる EventTagRules に従う。
 var (
 	EventExpressionPractice = regexp.MustCompile("[＃#]練習")
 	EventExpressionGame     = regexp.MustCompile("[＃#]試合")
@@ -89,12 +108,79 @@ func (pt ParticipationType) String() string {
 	}
 }
 
-func (e Event) Participations() (Participations, error) {
+// SetParticipations は Participation Kind の回答から ParticipationsJSONString を埋める。
+func (e *Event) SetParticipations(parts []Participation) error {
+	b, err := json.Marshal(ParticipationsOf(parts))
+	if err != nil {
+		return err
+	}
+	e.ParticipationsJSONString = string(b)
+	return nil
+}
+
+// SetRSVPCounts は回答の種類ごとの人数を RSVPCounts に埋める。
+// 未回答（PTUnanswered）は members のうち回答が期待される（IsExpectedToRSVP）のに回答していない人数。
+func (e *Event) SetRSVPCounts(parts []Participation, members []Member) {
+	counts := map[ParticipationType]int{PTJoin: 0, PTJoinLate: 0, PTLeaveEarly: 0, PTAbsent: 0, PTUnanswered: 0}
+	answered := map[string]bool{}
+	for _, p := range parts {
+		if p.Type.Unanswered() {
+			continue
+		}
+		counts[p.Type]++
+		answered[p.MemberID] = true
+	}
+	for _, m := range members {
+		if m.IsExpectedToRSVP() && !answered[m.Slack.ID] {
+			counts[PTUnanswered]++
+		}
+	}
+	e.RSVPCounts = counts
+}
+
+// LegacyParticipations は LegacyParticipationsJSONString をデコードする（移行用）。
+func (e Event) LegacyParticipations() (Participations, error) {
 	p := Participations{}
-	err := json.NewDecoder(strings.NewReader(e.ParticipationsJSONString)).Decode(&p)
+	if e.LegacyParticipationsJSONString == "" {
+		return p, nil
+	}
+	err := json.NewDecoder(strings.NewReader(e.LegacyParticipationsJSONString)).Decode(&p)
 	return p, err
 }
 
+// RSVPDeadlineAt は出欠回答の締め切りを返す。締め切りが無ければ false。
+//
+// RSVPDeadlineOverride があればそれを、無ければタグの RSVPDeadlineHours のうち最も早い締め切りを使う。
+func (e Event) RSVPDeadlineAt() (time.Time, bool) {
+	if e.RSVPDeadlineOverride != 0 {
+		return time.UnixMilli(e.RSVPDeadlineOverride), true
+	}
+	hours := 0
+	for _, t := range e.Tags() {
+		if rule, ok := eventTagRule(t); ok && rule.RSVPDeadlineHours > hours {
+			hours = rule.RSVPDeadlineHours
+		}
+	}
+	if hours == 0 {
+		return time.Time{}, false
+	}
+	return e.Google.Start().Add(-time.Duration(hours) * time.Hour), true
+}
+
+// IsRSVPClosed は now が出欠回答の締め切りを過ぎているかを返す。
+func (e Event) IsRSVPClosed(now time.Time) bool {
+	deadline, ok := e.RSVPDeadlineAt()
+	return ok && !now.Before(deadline)
+}
+
+// SetRSVPDeadline は API 応答用に RSVPDeadline を埋める。
+func (e *Event) SetRSVPDeadline() {
+	e.RSVPDeadline = 0
+	if deadline, ok := e.RSVPDeadlineAt(); ok {
+		e.RSVPDeadline = deadline.UnixMilli()
+	}
+}
+
 func (e Event) IsPractice() bool {
 	return e.HasTag(ETPractice)
 }
@@ -104,6 +190,10 @@ func (e Event) IsGame() bool {
 }
 
 func (e Event) ShouldSkipReminders(rt ReminderType) bool {
+	// キャンセル済み・スタッフ限定のイベントはチーム全体へのリマインダを送らない
+	if e.Cancelled || e.Google.Visibility != EVAll {
+		return true
+	}
 	tags := e.Tags()
 	// #ignore は最優先: 含まれていれば全リマインダを skip する（明示オプトアウト）
 	for _, t := range tags {
@@ -122,38 +212,32 @@ func (e Event) ShouldSkipReminders(rt ReminderType) bool {
 }
 
 // tagSkipsReminder は単一タグが当該リマインダ種別を skip すべきかを返す。
+// 定義の無いタグ（UNKNOWN・削除されたタグ）は skip しない。
 func tagSkipsReminder(t EventTag, rt ReminderType) bool {
-	switch t {
-	case ETIgnore, ETMeeting:
-		return true // ignore / meeting は全リマインダを skip
-	case ETEvent, ETSponsor:
-		return rt != RTRSVP // event / sponsor は RSVP 以外を skip
-	default:
-		return false // 練習 / 試合 / UNKNOWN は skip しない
+	rule, ok := eventTagRule(t)
+	if !ok {
+		return false
 	}
+	return !rule.Sends(rt)
 }
 
-// tagDefs はタグ判定の唯一の定義（判定順序込み）。Tags() / HasTag() はこれを回す。
-// クライアント TriaxEvent.ts の TAG_PATTERNS と順序・正規表現を一致させること。
-var tagDefs = []struct {
-	tag EventTag
-	re  *regexp.Regexp
-}{
-	{ETPractice, EventExpressionPractice},
-	{ETGame, EventExpressionGame},
-	{ETIgnore, EventExpressionIgnore},
-	{ETMeeting, EventExpressionMeeting},
-	{ETEvent, EventExpressionEvent},
-	{ETSponsor, EventExpressionSponsor},
+// OffersTaping はテーピングリクエストの対象のイベントかを返す（EventTagRule.Taping のタグを含む）。
+func (e Event) OffersTaping() bool {
+	for _, t := range e.Tags() {
+		if rule, ok := eventTagRule(t); ok && rule.Taping {
+			return true
+		}
+	}
+	return false
 }
 
-// Tags はタイトルに含まれる全てのタグを返す（複数タグ対応）。
+// Tags はタイトルに含まれる全てのタグと、取り込み元カレンダーの既定タグ（Google.Tags）を返す（複数タグ対応）。
 // 該当タグが無ければ ETUnkonwn ひとつを返す。
 func (e Event) Tags() []EventTag {
 	tags := []EventTag{}
-	for _, d := range tagDefs {
-		if d.re.MatchString(e.Google.Title) {
-			tags = append(tags, d.tag)
+	for _, r := range EventTagRules() {
+		if r.re.MatchString(e.Google.Title) || slices.Contains(e.Google.Tags, r.Tag) {
+			tags = append(tags, r.Tag)
 		}
 	}
 	if len(tags) == 0 {
@@ -162,7 +246,17 @@ func (e Event) Tags() []EventTag {
 	return tags
 }
 
-// HasTag は指定タグがタイトルに含まれるかを返す。
+// VisibleTo は member がこの Event を閲覧できるかを返す。
+func (e Event) VisibleTo(member Member) bool {
+	switch e.Google.Visibility {
+	case EVStaff:
+		return member.IsStaff()
+	default:
+		return true
+	}
+}
+
+// HasTag は指定タグがタイトル（または既定タグ）に含まれるかを返す。
 // Tags() と一貫させるため、タグ無し時の ETUnkonwn も正しく判定できる。
 func (e Event) HasTag(t EventTag) bool {
 	return slices.Contains(e.Tags(), t)
@@ -172,44 +266,11 @@ func (t ParticipationType) JoinAnyhow() bool {
 	return t == PTJoin || t == PTJoinLate || t == PTLeaveEarly
 }
 
-func (t ParticipationType) Unanswered() bool {
-	return t == "" || t == PTUnanswered
+// Answerable は t がメンバーの回答として受け付ける種類（参加・遅参・早退・欠席）かを返す。
+func (t ParticipationType) Answerable() bool {
+	return t.JoinAnyhow() || t == PTAbsent
 }
 
-// timeboud[0] == いつから
-// timeboud[1] == いつまで
-func FindEventsBetween(ctx context.Context, timebound ...time.Time) (events []Event, err error) {
-
-	if len(timebound) == 0 {
-		timebound = []time.Time{time.Now()}
-	}
-	if len(timebound) == 1 {
-		timebound = append(timebound, timebound[0].Add(24*time.Hour))
-	}
-	from := timebound[0]
-	to := timebound[1]
-	if !from.Before(to) {
-		return nil, fmt.Errorf("invalid time-bound")
-	}
-
-	query := datastore.NewQuery(KindEvent)
-	if !from.IsZero() {
-		query = query.Filter("Google.StartTime >=", from.Unix()*1000)
-	}
-	if !to.IsZero() {
-		query = query.Filter("Google.StartTime <", to.Unix()*1000)
-	}
-	query = query.Order("-Google.StartTime")
-	query = query.Limit(10)
-
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		return nil, fmt.Errorf("datastore client initiation error: %v", err)
-	}
-	defer client.Close()
-
-	if _, err = client.GetAll(ctx, query, &events); err != nil {
-		return nil, fmt.Errorf("datastore query error: %v", err)
-	}
-	return
+func (t ParticipationType) Unanswered() bool {
+	return t == "" || t == PTUnanswered
 }
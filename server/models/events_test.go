@@ -1,6 +1,10 @@
 package models
 
-import "testing"
+import (
+	"slices"
+	"testing"
+	"time"
+)
 
 // TestTagsSingle は単一タグのタイトルで Tags() が該当タグを含むことを確認する。
 func TestTagsSingle(t *testing.T) {
@@ -103,3 +107,127 @@ func TestShouldSkipRemindersMultiTag(t *testing.T) {
 		}
 	}
 }
+
+// キャンセルされたイベントはタグに関わらず全リマインダを skip する。
+func TestShouldSkipRemindersCancelled(t *testing.T) {
+	ev := Event{Cancelled: true}
+	ev.Google.Title = "#練習 定期練習"
+	for _, rt := range []ReminderType{RTRSVP, RTFinalCall, RTCondition, RTEquipment} {
+		if !ev.ShouldSkipReminders(rt) {
+			t.Errorf("cancelled ShouldSkipReminders(%q) = false, want true", rt)
+		}
+	}
+}
+
+// 取り込み元カレンダーの既定タグはタイトルのタグと合わせて判定する。
+func TestTagsWithDefaultTags(t *testing.T) {
+	ev := Event{Google: GoogleEvent{Title: "秋季リーグ 第1節", Tags: []EventTag{ETGame}}}
+	if tags := ev.Tags(); len(tags) != 1 || tags[0] != ETGame {
+		t.Errorf("Tags() = %v", tags)
+	}
+	ev.Google.Title = "#試合 #sponsor 秋季リーグ"
+	if tags := ev.Tags(); len(tags) != 2 || tags[0] != ETGame || tags[1] != ETSponsor {
+		t.Errorf("Tags() = %v", tags)
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	player := Member{Positions: MemberPositions{Primary: "QB", Unit: "offence"}}
+	trainer := Member{Positions: MemberPositions{Unit: "staff", StaffRoles: []StaffRole{SRTrainer, SRStaff}}}
+	ev := Event{Google: GoogleEvent{Visibility: EVStaff}}
+	if ev.VisibleTo(player) || !ev.VisibleTo(trainer) {
+		t.Error("staff-only event")
+	}
+	ev.Google.Visibility = EVAll
+	if !ev.VisibleTo(player) {
+		t.Error("public event")
+	}
+}
+
+func TestSetEventTagRules(t *testing.T) {
+	t.Cleanup(func() { SetEventTagRules(nil) })
+	if err := SetEventTagRules([]EventTagRule{{Tag: "x", Pattern: "("}}); err == nil {
+		t.Error("invalid pattern should be an error")
+	}
+	if err := SetEventTagRules([]EventTagRule{{Tag: "x", Pattern: "x", Reminders: []ReminderType{"daily"}}}); err == nil {
+		t.Error("unknown reminder type should be an error")
+	}
+	// 失敗したときは定義を入れ替えない
+	if !IsEventTag(ETPractice) {
+		t.Error("rules should not be replaced on error")
+	}
+
+	if err := SetEventTagRules([]EventTagRule{
+		{Tag: ETGame, Pattern: "[＃#]試合", Order: 2, Reminders: ReminderTypes},
+		{Tag: ETPractice, Pattern: "[＃#](練習|practice)", Order: 1, Reminders: []ReminderType{RTRSVP}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ev := Event{Google: GoogleEvent{Title: "#試合 #practice"}}
+	if tags := ev.Tags(); len(tags) != 2 || tags[0] != ETPractice {
+		t.Errorf("Tags() = %v", tags)
+	}
+	if IsEventTag(ETSponsor) {
+		t.Error("sponsor is no longer defined")
+	}
+}
+
+// TestReminderSchedule はタグの Schedule と DefaultReminderSchedule から送信タイミングが決まることを確認する。
+func TestReminderSchedule(t *testing.T) {
+	t.Cleanup(func() { SetEventTagRules(nil) })
+
+	ev := Event{}
+	ev.Google.Title = "#event 総会"
+	if got := ev.ReminderSchedule(); len(got) != 2 || got[0] != (ReminderOffset{RTRSVP, 72}) || got[1] != (ReminderOffset{RTRSVP, 24}) {
+		t.Errorf("#event schedule = %v", got)
+	}
+
+	rules := DefaultEventTagRules()
+	rules[1].Schedule = []ReminderOffset{{Type: RTRSVP, Hours: 168}, {Type: RTFinalCall, Hours: 36}} // #試合
+	if err := SetEventTagRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	ev.Google.Title = "#試合 vs X"
+	want := []ReminderOffset{{RTRSVP, 168}, {RTHeadcount, 48}, {RTFinalCall, 36}, {RTEquipment, 24}}
+	if got := ev.ReminderSchedule(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] {
+		t.Errorf("#試合 schedule = %v, want %v", got, want)
+	}
+
+	// 出欠確認は締め切り（#練習 は 67 時間前）より前にだけ送る
+	SetEventTagRules(nil)
+	ev.Google.Title = "#練習"
+	if got := ev.ReminderSchedule(); slices.Contains(got, ReminderOffset{RTRSVP, 24}) || !slices.Contains(got, ReminderOffset{RTRSVP, 72}) {
+		t.Errorf("#練習 schedule = %v", got)
+	}
+	// 締め切りより前に送るものが無ければ、締め切りの RSVPReminderLeadHours 時間前に送る（#試合 は 72 時間前）
+	ev.Google.Title = "#試合"
+	if got := ev.ReminderSchedule(); got[0] != (ReminderOffset{RTRSVP, 72 + RSVPReminderLeadHours}) || slices.ContainsFunc(got, func(o ReminderOffset) bool { return o.Type == RTRSVP && o.Hours <= 72 }) {
+		t.Errorf("#試合 schedule = %v", got)
+	}
+	ev.RSVPDeadlineOverride = ev.Google.Start().Add(-24 * time.Hour).UnixMilli()
+	if got := ev.ReminderSchedule(); !slices.Contains(got, ReminderOffset{RTRSVP, 72}) || !slices.Contains(got, ReminderOffset{RTRSVP, 36}) {
+		t.Errorf("overridden deadline schedule = %v", got)
+	}
+	ev.RSVPDeadlineOverride = 0
+
+	ev.Cancelled = true
+	if got := ev.ReminderSchedule(); len(got) != 0 {
+		t.Errorf("cancelled schedule = %v, want empty", got)
+	}
+
+	rules[1].Schedule = []ReminderOffset{{Type: RTRSVP, Hours: 0}}
+	if err := SetEventTagRules(rules); err == nil {
+		t.Error("schedule with 0 hours must be rejected")
+	}
+}
+
+func TestMemberPositions_Label(t *testing.T) {
+	p := MemberPositions{Primary: "OL", Secondary: []string{"DL"}, StaffRoles: []StaffRole{"", "trainer"}}
+	if got := p.Label(); got != "OL/DL/Trainer" {
+		t.Errorf("Label() = %q, want %q", got, "OL/DL/Trainer")
+	}
+	p = MemberPositions{StaffRoles: []StaffRole{"", "ébéniste"}}
+	if got := p.Label(); got != "Ébéniste" {
+		t.Errorf("Label() = %q, want %q", got, "Ébéniste")
+	}
+}
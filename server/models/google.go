@@ -15,6 +15,14 @@ type (
 		StartTime   int64  `json:"start_time"` // ミリ秒
 		EndTime     int64  `json:"end_time"`
 		Location    string `json:"location"`
+
+		// Source は取り込み元のカレンダーの名前（gcal.Source.Name）。
+		// 複数カレンダー対応以前に取り込んだ Event は空で、最初に登録したカレンダーのものとして扱う。
+		Source string `json:"source,omitempty"`
+		// Tags は取り込み元のカレンダーで既定のタグ。タイトルのハッシュタグに加えて Event.Tags() に含まれる。
+		Tags []EventTag `json:"tags,omitempty"`
+		// Visibility は Event を閲覧できるメンバーの範囲。
+		Visibility EventVisibility `json:"visibility,omitempty"`
 	}
 )
 
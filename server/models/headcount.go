@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Positions はヘッドカウントを数えるポジション（選手のポジションと、スタッフ・トレーナー）。
+var Positions = append(slices.Clone(PlayerPositions), "STAFF", "TRAINER")
+
+// PositionOthers は Positions のどれにも当てはまらないメンバーのポジション。
+const PositionOthers = "OTHERS"
+
+// Position はヘッドカウント上のポジションを返す。コーチやポジションが未設定のメンバーは PositionOthers。
+func (m Member) Position() string {
+	switch {
+	case m.IsCoach():
+		return PositionOthers
+	case m.Positions.Primary != "":
+		return m.Positions.Primary
+	case m.Positions.HasStaffRole(SRTrainer):
+		return "TRAINER"
+	case m.Positions.HasStaffRole(SRStaff):
+		return "STAFF"
+	}
+	return PositionOthers
+}
+
+// PositionGroupOf はポジションの属するグループ（連絡先のチャンネル）を返す。
+func PositionGroupOf(position string) (PositionGroup, bool) {
+	for _, g := range PositionGroups {
+		if slices.Contains(g.Roles, strings.ToLower(position)) {
+			return g, true
+		}
+	}
+	return PositionGroup{}, false
+}
+
+// PositionMinimum はイベントに最低限必要なポジションの人数。
+type PositionMinimum struct {
+	Position string `json:"position"`
+	Min      int    `json:"min"`
+}
+
+func (p PositionMinimum) Validate() error {
+	if !slices.Contains(Positions, p.Position) {
+		return fmt.Errorf("unknown position %q", p.Position)
+	}
+	if p.Min <= 0 || p.Min > 99 {
+		return fmt.Errorf("%s: min must be between 1 and 99", p.Position)
+	}
+	return nil
+}
+
+// PositionMinimums はこの Event のポジションごとの最低人数（タグの EventTagRule.Minimums の大きい方）。
+func (e Event) PositionMinimums() map[string]int {
+	minimums := map[string]int{}
+	for _, t := range e.Tags() {
+		rule, ok := eventTagRule(t)
+		if !ok {
+			continue
+		}
+		for _, m := range rule.Minimums {
+			minimums[m.Position] = max(minimums[m.Position], m.Min)
+		}
+	}
+	return minimums
+}
+
+// PositionHeadcount はイベントの 1 ポジションの出欠の内訳。
+type PositionHeadcount struct {
+	Position   string `json:"position"`
+	Join       int    `json:"join"`
+	JoinLate   int    `json:"join_late"`
+	LeaveEarly int    `json:"leave_early"`
+	Absent     int    `json:"absent"`
+	Unanswered int    `json:"unanswered"`
+	// Expected は参加予定の人数（遅参・早退を含む）。
+	Expected int `json:"expected"`
+	// Minimum は最低人数（0 なら定めが無い）。Short は Expected が Minimum に満たないこと。
+	Minimum int  `json:"minimum"`
+	Short   bool `json:"short"`
+}
+
+// NewHeadcounts は ev のポジションごとの出欠の内訳を Positions の順（最後に PositionOthers）に返す。
+// 未回答は members のうち回答が期待される（IsExpectedToRSVP）のに回答していない人数。
+func NewHeadcounts(ev Event, parts []Participation, members []Member) []PositionHeadcount {
+	positions := append(slices.Clone(Positions), PositionOthers)
+	index := map[string]int{}
+	counts := make([]PositionHeadcount, len(positions))
+	minimums := ev.PositionMinimums()
+	for i, p := range positions {
+		index[p] = i
+		counts[i] = PositionHeadcount{Position: p, Minimum: minimums[p]}
+	}
+
+	participations := ParticipationsOf(parts)
+	for _, m := range members {
+		if m.Slack.Deleted || m.Status == MSDeleted {
+			continue
+		}
+		c := &counts[index[m.Position()]]
+		p, ok := participations[m.Slack.ID]
+		if !ok || p.Type.Unanswered() {
+			if m.IsExpectedToRSVP() {
+				c.Unanswered++
+			}
+			continue
+		}
+		switch p.Type {
+		case PTJoin:
+			c.Join++
+		case PTJoinLate:
+			c.JoinLate++
+		case PTLeaveEarly:
+			c.LeaveEarly++
+		case PTAbsent:
+			c.Absent++
+		}
+	}
+	for i := range counts {
+		c := &counts[i]
+		c.Expected = c.Join + c.JoinLate + c.LeaveEarly
+		c.Short = c.Minimum > 0 && c.Expected < c.Minimum
+	}
+	return counts
+}
+
+// Shortages は最低人数に満たないポジションだけを返す。
+func Shortages(counts []PositionHeadcount) []PositionHeadcount {
+	short := []PositionHeadcount{}
+	for _, c := range counts {
+		if c.Short {
+			short = append(short, c)
+		}
+	}
+	return short
+}
+
+// Warning は不足の警告文（例: "OL: 参加予定 3 人（最低 5 人、未回答 2 人）"）。
+func (c PositionHeadcount) Warning() string {
+	return fmt.Sprintf("%s: 参加予定 %d 人（最低 %d 人、未回答 %d 人）", c.Position, c.Expected, c.Minimum, c.Unanswered)
+}
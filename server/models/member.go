@@ -1,12 +1,9 @@
 package models
 
 import (
-	"context"
-	"fmt"
-	"os"
 	"regexp"
-
-	"cloud.google.com/go/datastore"
+	"sync"
+	"time"
 )
 
 type (
@@ -21,14 +18,21 @@ type (
 		// 背番号ゼロに対応するためにポインタを使わざるを得ない.
 		// おおおか、許すまじ
 		Number *int `json:"number"`
+
+		// Positions ポジションとスタッフの役割
+		Positions MemberPositions `json:"positions"`
+
+		// Roles hub で付与した役割（備品係・主将など）。権限は EffectiveRoles で決まる
+		Roles []Role `json:"roles,omitempty"`
+
+		// JoinedAt 入部日. Slack から初めて取り込んだ日時（管理者が修正できる）
+		JoinedAt time.Time `json:"joined_at,omitzero"`
+		// LeftAt 退部日. Slack のアカウントが削除されたのを検知した日時
+		LeftAt time.Time `json:"left_at,omitzero"`
 	}
 	MemberStatus string
 )
 
-var (
-	memberCache = map[string]Member{}
-)
-
 const (
 	// MSActive 通常のメンバー. 出欠回答必須
 	MSActive MemberStatus = "active"
@@ -51,18 +55,20 @@ func (m Member) Name() string {
 	return m.Slack.Profile.DisplayName
 }
 
-// regexp.Compileのコストを減らしたい
-var onMemRoleExpCache = map[string]*regexp.Regexp{}
+// regexp.Compileのコストを減らしたい（role → *regexp.Regexp。並行して呼ばれるので sync.Map）
+var onMemRoleExpCache sync.Map
 
 func (m Member) IsMemberOf(roles ...string) (yes bool, role string, err error) {
 	for _, r := range roles {
-		exp, ok := onMemRoleExpCache[r]
-		if !ok {
+		var exp *regexp.Regexp
+		if v, ok := onMemRoleExpCache.Load(r); ok {
+			exp = v.(*regexp.Regexp)
+		} else {
 			exp, err = regexp.Compile("(?i)" + r)
 			if err != nil {
 				return false, "", err
 			}
-			onMemRoleExpCache[r] = exp
+			onMemRoleExpCache.Store(r, exp)
 		}
 		if exp.MatchString(m.Slack.Profile.Title) {
 			return true, r, nil
@@ -71,6 +77,11 @@ func (m Member) IsMemberOf(roles ...string) (yes bool, role string, err error) {
 	return false, "", nil
 }
 
+// IsStaff は Slack 管理者、またはスタッフ・トレーナーの役割を持つかを返す（スタッフ限定のイベントを見られる）。
+func (m Member) IsStaff() bool {
+	return m.HasRole(RoleAdmin) || m.HasRole(RoleStaff) || m.HasRole(RoleTrainer)
+}
+
 func (m Member) IsExpectedToRSVP() bool {
 	if m.Status == MSDeleted || m.Status == MSLimited || m.Status == MSInactive {
 		return false
@@ -78,29 +89,6 @@ func (m Member) IsExpectedToRSVP() bool {
 	return true
 }
 
-func GetAllMembers(ctx context.Context) ([]Member, error) {
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialized datastore client: %v", err)
-	}
-	defer client.Close()
-	members := []Member{}
-	query := datastore.NewQuery(KindMember)
-	query = query.Filter("Slack.Deleted =", false)
-	if _, err := client.GetAll(ctx, query, &members); err != nil && !IsFiledMismatch(err) {
-		return nil, fmt.Errorf("failed to execute datastore query: %v", err)
-	}
-	return members, nil
-}
-
-func GetAllMembersAsDict(ctx context.Context) (map[string]Member, error) {
-	members, err := GetAllMembers(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to GetAllMembers: %v", err)
-	}
-	return MembersToDict(members), nil
-}
-
 func MembersToDict(members []Member) map[string]Member {
 	dict := map[string]Member{}
 	for _, m := range members {
@@ -108,18 +96,3 @@ func MembersToDict(members []Member) map[string]Member {
 	}
 	return dict
 }
-
-func GetMemberInfoByCache(ctx context.Context, id string) (m Member, err error) {
-	if m, ok := memberCache[id]; ok {
-		return m, nil
-	}
-	memberCache, err = GetAllMembersAsDict(ctx)
-	if err != nil {
-		return m, err
-	}
-	if m, ok := memberCache[id]; !ok {
-		return m, fmt.Errorf("not found for id:%v", id)
-	} else {
-		return m, nil
-	}
-}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	KindMemberStatusChange = "MemberStatusChange"
+	KindDormancyProposal   = "DormancyProposal"
+)
+
+// MemberStatuses は Member.Status に設定できる値（MSDeleted は Slack.Deleted で管理するので含まない）。
+var MemberStatuses = []MemberStatus{MSActive, MSLimited, MSInactive}
+
+func (s MemberStatus) Validate() error {
+	if !slices.Contains(MemberStatuses, s) {
+		return fmt.Errorf("unknown member status %q", s)
+	}
+	return nil
+}
+
+// MemberStatusChange はメンバーの参加状態の変更の履歴（NameKey: KeyName）。
+// From が空の変更は入部、To が MSDeleted の変更は退部（Slack のアカウント削除）を表す。
+type MemberStatusChange struct {
+	MemberID string       `json:"member_id"`
+	From     MemberStatus `json:"from"`
+	To       MemberStatus `json:"to"`
+	// EffectiveAt は変更が有効になった日時（記録した日時と違ってもよい）
+	EffectiveAt time.Time `json:"effective_at"`
+	Reason      string    `json:"reason" datastore:",noindex"`
+	// ChangedBy は変更した人の Slack ID。cron タスクによる記録は空
+	ChangedBy string    `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (c MemberStatusChange) KeyName() string {
+	return fmt.Sprintf("%s_%d", c.MemberID, c.CreatedAt.UnixMilli())
+}
+
+type DormancyProposalStatus string
+
+const (
+	DPPending  DormancyProposalStatus = "pending"
+	DPApproved DormancyProposalStatus = "approved"
+	DPRejected DormancyProposalStatus = "rejected"
+)
+
+// DormancyProposal は出欠の未回答が続くメンバーを休眠（MSInactive）にする提案（NameKey: MemberID）。
+// メンバーごとに最新の提案だけを残す。
+type DormancyProposal struct {
+	MemberID string `json:"member_id"`
+	// EventIDs は続けて未回答だったイベント（新しい順）
+	EventIDs   []string               `json:"event_ids" datastore:",noindex"`
+	Status     DormancyProposalStatus `json:"status"`
+	ProposedAt time.Time              `json:"proposed_at"`
+	DecidedBy  string                 `json:"decided_by,omitempty"`
+	DecidedAt  time.Time              `json:"decided_at,omitzero"`
+}
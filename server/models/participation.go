@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type (
+	// Participation は Event に対する 1 メンバーの出欠回答。
+	// NameKey: eventID + "_" + memberID
+	// → 回答ごとにエンティティが分かれるので、同時に回答しても互いを上書きしない。
+	Participation struct {
+		EventID    string                 `json:"event_id"`
+		MemberID   string                 `json:"member_id"`
+		Type       ParticipationType      `json:"type"`
+		Params     map[string]interface{} `json:"params" datastore:"-"`
+		ParamsJSON string                 `json:"-" datastore:",noindex"`
+		AnsweredAt int64                  `json:"answered_at"` // ミリ秒
+		// Late は出欠回答の締め切り後の回答であることを示す。LateReason はその理由。
+		Late       bool   `json:"late,omitempty"`
+		LateReason string `json:"late_reason,omitempty" datastore:",noindex"`
+		// AnsweredBy はスタッフが代理で回答した場合の、回答したスタッフの Slack ID（本人の回答なら空）。
+		AnsweredBy string `json:"answered_by,omitempty"`
+
+		// History は以前の回答（古い順）。
+		History []ParticipationChange `json:"history,omitempty" datastore:",noindex"`
+	}
+
+	// ParticipationChange は上書きされた過去の回答。
+	ParticipationChange struct {
+		Type       ParticipationType `json:"type"`
+		ParamsJSON string            `json:"params_json"`
+		AnsweredAt int64             `json:"answered_at"`
+		Late       bool              `json:"late,omitempty"`
+		LateReason string            `json:"late_reason,omitempty"`
+		AnsweredBy string            `json:"answered_by,omitempty"`
+	}
+
+	// Participations は Slack ID → 回答 の辞書。
+	// Event.ParticipationsJSONString（API 応答）の形式でもある。
+	Participations map[string]Participation
+
+	// AnswersByEvent は Event ID → 回答 の辞書（1 人のメンバーの回答）。
+	AnswersByEvent map[string]Participation
+)
+
+// ParticipationKeyName は Participation の NameKey を返す。
+func ParticipationKeyName(eventID, memberID string) string {
+	return fmt.Sprintf("%s_%s", eventID, memberID)
+}
+
+// Answer は回答を更新する。以前の回答があれば History に積む。
+func (p *Participation) Answer(typ ParticipationType, params map[string]interface{}, at time.Time) error {
+	if p.Type != "" {
+		p.History = append(p.History, ParticipationChange{
+			Type:       p.Type,
+			ParamsJSON: p.ParamsJSON,
+			AnsweredAt: p.AnsweredAt,
+			Late:       p.Late,
+			LateReason: p.LateReason,
+			AnsweredBy: p.AnsweredBy,
+		})
+	}
+	p.Type = typ
+	p.Params = params
+	p.AnsweredAt = at.UnixMilli()
+	p.Late, p.LateReason = false, ""
+	p.AnsweredBy = ""
+	return p.MarshalParams()
+}
+
+// AnswerLate は締め切り後の回答として、理由とともに回答を更新する。
+func (p *Participation) AnswerLate(typ ParticipationType, params map[string]interface{}, at time.Time, reason string) error {
+	if err := p.Answer(typ, params, at); err != nil {
+		return err
+	}
+	p.Late, p.LateReason = true, reason
+	return nil
+}
+
+func (p *Participation) MarshalParams() error {
+	if p.Params == nil {
+		p.ParamsJSON = ""
+		return nil
+	}
+	b, err := json.Marshal(p.Params)
+	if err != nil {
+		return err
+	}
+	p.ParamsJSON = string(b)
+	return nil
+}
+
+func (p *Participation) UnmarshalParams() error {
+	p.Params = nil
+	if p.ParamsJSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(p.ParamsJSON), &p.Params)
+}
+
+// AnswersByEventOf は 1 人のメンバーの回答のリストを Event ID の辞書にする。
+func AnswersByEventOf(parts []Participation) AnswersByEvent {
+	dict := AnswersByEvent{}
+	for _, p := range parts {
+		dict[p.EventID] = p
+	}
+	return dict
+}
+
+// ParticipationsOf は回答のリストを Slack ID の辞書にする。
+func ParticipationsOf(parts []Participation) Participations {
+	dict := Participations{}
+	for _, p := range parts {
+		dict[p.MemberID] = p
+	}
+	return dict
+}
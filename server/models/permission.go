@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+)
+
+type (
+	// Role はメンバーの役割。Permission の束として権限を与える。
+	Role string
+	// Permission は API の操作の権限。ルーティング（filters.Require）とフロントエンドの表示の切り替えに使う。
+	Permission string
+)
+
+const (
+	// RoleAdmin は Slack の管理者（Slack.IsAdmin から決まる）。全ての権限を持つ。
+	RoleAdmin Role = "admin"
+	// RoleStaff と RoleTrainer は Positions.StaffRoles から決まる（コーチは RoleStaff）。
+	RoleStaff   Role = "staff"
+	RoleTrainer Role = "trainer"
+	// RoleEquipManager と RoleCaptain は Member.Roles で付与する。
+	RoleEquipManager Role = "equip_manager"
+	RoleCaptain      Role = "captain"
+)
+
+const (
+	PermManageEvents       Permission = "events:manage"       // イベントの削除・出欠回答の締め切りの変更
+	PermManageEventTags    Permission = "event_tags:manage"   // タグの定義の編集
+	PermAnswerForOthers    Permission = "rsvp:proxy"          // 代理の出欠回答
+	PermManageAttendance   Permission = "attendance:manage"   // 出席の記録・チェックインコード・チームの出欠集計
+	PermManageMembers      Permission = "members:manage"      // 他のメンバーの参加状態・背番号・ポジションの変更
+	PermManageRoles        Permission = "roles:manage"        // 役割の付与
+	PermManageEquips       Permission = "equips:manage"       // 備品の登録・編集・削除
+	PermManageNumbers      Permission = "numbers:manage"      // 背番号の割り当て
+	PermManageTaping       Permission = "taping:manage"       // テーピングのマスタの編集・全体の集計
+	PermManageApplications Permission = "applications:manage" // 入部申請の閲覧・更新
+	PermViewAudit          Permission = "audit:view"          // 監査ログの閲覧
+)
+
+// Roles は付与できる役割の一覧。
+var Roles = []Role{RoleAdmin, RoleStaff, RoleTrainer, RoleEquipManager, RoleCaptain}
+
+// RolePermissions は役割ごとの権限。RoleAdmin は全ての権限を持つのでここには書かない。
+var RolePermissions = map[Role][]Permission{
+	RoleStaff: {
+		PermAnswerForOthers, PermManageAttendance, PermManageMembers,
+		PermManageEquips, PermManageNumbers, PermManageTaping,
+	},
+	RoleTrainer:      {PermAnswerForOthers, PermManageAttendance, PermManageTaping},
+	RoleEquipManager: {PermManageEquips, PermManageNumbers},
+	RoleCaptain:      {PermAnswerForOthers, PermManageAttendance},
+}
+
+// AllPermissions は全ての権限（RoleAdmin の権限）。
+var AllPermissions = []Permission{
+	PermManageEvents, PermManageEventTags, PermAnswerForOthers, PermManageAttendance, PermManageMembers,
+	PermManageRoles, PermManageEquips, PermManageNumbers, PermManageTaping, PermManageApplications, PermViewAudit,
+}
+
+// EffectiveRoles はメンバーの役割を返す。Slack の管理者とスタッフの役割（Positions.StaffRoles）から決まるものと、
+// Member.Roles で付与されたものを合わせる。
+func (m Member) EffectiveRoles() []Role {
+	roles := []Role{}
+	add := func(r Role) {
+		if !slices.Contains(roles, r) {
+			roles = append(roles, r)
+		}
+	}
+	if m.Slack.IsAdmin {
+		add(RoleAdmin)
+	}
+	for _, r := range m.Positions.StaffRoles {
+		switch r {
+		case SRStaff, SRCoach:
+			add(RoleStaff)
+		case SRTrainer:
+			add(RoleTrainer)
+		}
+	}
+	for _, r := range m.Roles {
+		add(r)
+	}
+	return roles
+}
+
+// HasRole は role の役割を持つかを返す。
+func (m Member) HasRole(role Role) bool {
+	return slices.Contains(m.EffectiveRoles(), role)
+}
+
+// Permissions はメンバーの権限を AllPermissions の順で返す。
+func (m Member) Permissions() []Permission {
+	roles := m.EffectiveRoles()
+	if slices.Contains(roles, RoleAdmin) {
+		return slices.Clone(AllPermissions)
+	}
+	perms := []Permission{}
+	for _, p := range AllPermissions {
+		for _, r := range roles {
+			if slices.Contains(RolePermissions[r], p) {
+				perms = append(perms, p)
+				break
+			}
+		}
+	}
+	return perms
+}
+
+// Can は perm の権限を持つかを返す。
+func (m Member) Can(perm Permission) bool {
+	return slices.Contains(m.Permissions(), perm)
+}
+
+// ValidateRoles は Member.Roles に付与できる役割かを検証する。RoleAdmin は Slack で管理するので付与できない。
+func ValidateRoles(roles []Role) error {
+	for _, r := range roles {
+		if r == RoleAdmin || !slices.Contains(Roles, r) {
+			return fmt.Errorf("role %q cannot be granted", r)
+		}
+	}
+	return nil
+}
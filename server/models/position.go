@@ -0,0 +1,185 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PositionGroup はポジション（ユニット）のグループと、その連絡先のチャンネル。
+// 直前確認の投稿先や、締め切り後の出欠変更の連絡先に使う。
+type PositionGroup struct {
+	Name string
+	// Roles はグループに属するポジションとスタッフの役割（小文字）。
+	Roles   []string
+	Channel string
+}
+
+type (
+	// MemberPositions はメンバーのポジション。hub で編集する（Slack の役職からは ImportPositions で一度だけ取り込む）。
+	MemberPositions struct {
+		// Primary は主ポジション（PlayerPositions のいずれか）。Secondary は兼任しているポジション。
+		Primary   string   `json:"primary"`
+		Secondary []string `json:"secondary,omitempty"`
+		// Unit は所属するユニット（PositionGroups の Name）。
+		Unit string `json:"unit"`
+		// StaffRoles はスタッフとしての役割。1 つでもあればスタッフとして扱う。
+		StaffRoles []StaffRole `json:"staff_roles,omitempty"`
+	}
+	StaffRole string
+)
+
+const (
+	SRStaff   StaffRole = "staff"
+	SRTrainer StaffRole = "trainer"
+	SRCoach   StaffRole = "coach"
+)
+
+// StaffRoles はスタッフの役割の一覧。
+var StaffRoles = []StaffRole{SRStaff, SRTrainer, SRCoach}
+
+// PlayerPositions は選手のポジション。
+var PlayerPositions = []string{"QB", "RB", "WR", "OL", "TE", "DL", "LB", "DB", "K"}
+
+// PositionGroups はポジションのグループの一覧。
+var PositionGroups = []PositionGroup{
+	{Name: "staff", Roles: []string{"staff", "trainer"}, Channel: "staff"},
+	{Name: "offence", Roles: []string{"rb", "wr", "qb", "ol", "te"}, Channel: "offence"},
+	{Name: "defence", Roles: []string{"dl", "lb", "db"}, Channel: "defence"},
+}
+
+// PositionGroup はメンバーの Unit のグループを返す。
+func (m Member) PositionGroup() (PositionGroup, bool) {
+	for _, g := range PositionGroups {
+		if g.Name == m.Positions.Unit {
+			return g, true
+		}
+	}
+	return PositionGroup{}, false
+}
+
+// IsCoach はスタッフの役割にコーチがあるかを返す。
+func (m Member) IsCoach() bool {
+	return m.Positions.HasStaffRole(SRCoach)
+}
+
+// HasStaffRole は role の役割を持つかを返す。
+func (p MemberPositions) HasStaffRole(role StaffRole) bool {
+	return slices.Contains(p.StaffRoles, role)
+}
+
+// Plays は roles（ポジションまたはスタッフの役割。大文字小文字は区別しない）のうち、
+// メンバーが担うものを返す。主ポジション、兼任のポジション、スタッフの役割の順に探す。
+func (m Member) Plays(roles ...string) (string, bool) {
+	p := m.Positions
+	candidates := append([]string{p.Primary}, p.Secondary...)
+	for _, r := range p.StaffRoles {
+		candidates = append(candidates, string(r))
+	}
+	for _, c := range candidates {
+		for _, r := range roles {
+			if c != "" && strings.EqualFold(c, r) {
+				return r, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Label は表示用のポジション（例: "OL/DL"、"Trainer"）。何も無ければ空。
+func (p MemberPositions) Label() string {
+	labels := []string{}
+	if p.Primary != "" {
+		labels = append(labels, p.Primary)
+	}
+	labels = append(labels, p.Secondary...)
+	for _, r := range p.StaffRoles {
+		if r == "" {
+			continue
+		}
+		head, size := utf8.DecodeRuneInString(string(r))
+		labels = append(labels, string(unicode.ToUpper(head))+string(r[size:]))
+	}
+	return strings.Join(labels, "/")
+}
+
+// Normalize はポジションを大文字にして重複を除き、Unit が空なら主ポジション（無ければスタッフの役割）から決める。
+func (p *MemberPositions) Normalize() {
+	p.Primary = strings.ToUpper(strings.TrimSpace(p.Primary))
+	secondary := []string{}
+	for _, s := range p.Secondary {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s != "" && s != p.Primary && !slices.Contains(secondary, s) {
+			secondary = append(secondary, s)
+		}
+	}
+	p.Secondary = secondary
+	roles := []StaffRole{}
+	for _, r := range p.StaffRoles {
+		r = StaffRole(strings.ToLower(strings.TrimSpace(string(r))))
+		if r != "" && !slices.Contains(roles, r) {
+			roles = append(roles, r)
+		}
+	}
+	p.StaffRoles = roles
+	if p.Unit == "" {
+		if g, ok := PositionGroupOf(p.Primary); ok {
+			p.Unit = g.Name
+		} else if p.HasStaffRole(SRStaff) || p.HasStaffRole(SRTrainer) {
+			p.Unit = "staff"
+		}
+	}
+}
+
+func (p MemberPositions) Validate() error {
+	for _, pos := range append([]string{p.Primary}, p.Secondary...) {
+		if pos != "" && !slices.Contains(PlayerPositions, pos) {
+			return fmt.Errorf("unknown position %q", pos)
+		}
+	}
+	if p.Unit != "" && !slices.ContainsFunc(PositionGroups, func(g PositionGroup) bool { return g.Name == p.Unit }) {
+		return fmt.Errorf("unknown unit %q", p.Unit)
+	}
+	for _, r := range p.StaffRoles {
+		if !slices.Contains(StaffRoles, r) {
+			return fmt.Errorf("unknown staff role %q", r)
+		}
+	}
+	return nil
+}
+
+// staffRolePatterns はスタッフの役割とみなす Slack の役職（IsMemberOf の正規表現）。ImportPositions だけが使う。
+var staffRolePatterns = map[StaffRole][]string{
+	SRStaff:   {"staff", "スタッフ"},
+	SRTrainer: {"trainer", "トレーナー"},
+	SRCoach:   {"coach", "コーチ"},
+}
+
+var titleSeparator = regexp.MustCompile(`[/／,、・\s]+`)
+
+// ImportPositions は Slack の役職（例: "OL/DL"、"老害/Staff"、"OL コーチ"）からポジションを推定する。
+// 構造化したポジションへ移行するときに一度だけ使う。
+func ImportPositions(m Member) MemberPositions {
+	p := MemberPositions{}
+	for _, word := range titleSeparator.Split(m.Slack.Profile.Title, -1) {
+		pos := strings.ToUpper(word)
+		if !slices.Contains(PlayerPositions, pos) {
+			continue
+		}
+		if p.Primary == "" {
+			p.Primary = pos
+		} else {
+			p.Secondary = append(p.Secondary, pos)
+		}
+	}
+	for _, role := range StaffRoles {
+		if yes, _, _ := m.IsMemberOf(staffRolePatterns[role]...); yes {
+			p.StaffRoles = append(p.StaffRoles, role)
+		}
+	}
+	p.Normalize()
+	return p
+}
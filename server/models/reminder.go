@@ -0,0 +1,139 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+const KindReminder = "Reminder"
+
+// ReminderOffset はイベント開始の何時間前に Type のリマインダを送るか。
+type ReminderOffset struct {
+	Type  ReminderType `json:"type"`
+	Hours int          `json:"hours"`
+}
+
+// MaxReminderHours は ReminderOffset.Hours の上限（リマインダの計画はこの範囲のイベントに対して行う）。
+const MaxReminderHours = 7 * 24
+
+// RSVPReminderLeadHours は出欠回答の締め切りの何時間前に最後の出欠確認のリマインダを送るか（ReminderSchedule）。
+const RSVPReminderLeadHours = 12
+
+// DefaultReminderSchedule はタグの定義に Schedule が無い種別の送信タイミング。
+// コンディショニングフォームは既定では送らない（タグの Schedule に書いたときだけ送る）。
+var DefaultReminderSchedule = []ReminderOffset{
+	{Type: RTRSVP, Hours: 72},
+	{Type: RTRSVP, Hours: 24},
+	{Type: RTEquipment, Hours: 24},
+	{Type: RTFinalCall, Hours: 18},
+	{Type: RTHeadcount, Hours: 48},
+}
+
+func (o ReminderOffset) Validate() error {
+	if !slices.Contains(ReminderTypes, o.Type) {
+		return fmt.Errorf("unknown reminder type %q", o.Type)
+	}
+	if o.Hours <= 0 || o.Hours > MaxReminderHours {
+		return fmt.Errorf("%s: hours must be between 1 and %d", o.Type, MaxReminderHours)
+	}
+	return nil
+}
+
+// ReminderSchedule はこの Event で送るリマインダとそのタイミングを、送信の早い順に返す。
+//
+// ShouldSkipReminders が true の種別は含まない。送る種別のタイミングは、その種別を送るタグの
+// EventTagRule.Schedule を合わせたもので、どのタグにも書かれていなければ DefaultReminderSchedule。
+// 出欠確認（RTRSVP）は締め切りより前に送る（rsvpBeforeDeadline）。
+func (e Event) ReminderSchedule() []ReminderOffset {
+	tags := e.Tags()
+	schedule := []ReminderOffset{}
+	for _, rt := range ReminderTypes {
+		if e.ShouldSkipReminders(rt) {
+			continue
+		}
+		offsets := []ReminderOffset{}
+		for _, t := range tags {
+			rule, ok := eventTagRule(t)
+			if !ok || !rule.Sends(rt) {
+				continue
+			}
+			for _, o := range rule.Schedule {
+				if o.Type == rt && !slices.Contains(offsets, o) {
+					offsets = append(offsets, o)
+				}
+			}
+		}
+		if len(offsets) == 0 {
+			for _, o := range DefaultReminderSchedule {
+				if o.Type == rt {
+					offsets = append(offsets, o)
+				}
+			}
+		}
+		if rt == RTRSVP {
+			offsets = e.rsvpBeforeDeadline(offsets)
+		}
+		schedule = append(schedule, offsets...)
+	}
+	sort.SliceStable(schedule, func(i, j int) bool { return schedule[i].Hours > schedule[j].Hours })
+	return schedule
+}
+
+// rsvpBeforeDeadline は出欠回答の締め切り以降に送ることになる出欠確認のリマインダを外し、
+// 代わりに締め切りの RSVPReminderLeadHours 時間前に送る（それまでに送るものがすでにあれば足さない）。
+// 締め切り後の回答には理由が要るので、締め切り後に回答を促しても意味が無い。
+func (e Event) rsvpBeforeDeadline(offsets []ReminderOffset) []ReminderOffset {
+	deadline, ok := e.RSVPDeadlineAt()
+	if !ok {
+		return offsets
+	}
+	hours := int(math.Ceil(e.Google.Start().Sub(deadline).Hours()))
+	final := min(hours+RSVPReminderLeadHours, MaxReminderHours)
+	kept := []ReminderOffset{}
+	for _, o := range offsets {
+		if o.Hours > hours {
+			kept = append(kept, o)
+		}
+	}
+	if len(kept) < len(offsets) && !slices.ContainsFunc(kept, func(o ReminderOffset) bool { return o.Hours <= final }) {
+		kept = append(kept, ReminderOffset{Type: RTRSVP, Hours: final})
+	}
+	return kept
+}
+
+type ReminderStatus string
+
+const (
+	// RSPlanned は送信待ち。
+	RSPlanned ReminderStatus = "planned"
+	// RSSent は送信済み（送信を始めた時点で記録するので、二重には送らない）。
+	RSSent ReminderStatus = "sent"
+	// RSSkipped は送らないことにしたもの（予定から外れた・予定時刻を過ぎていた・イベントがキャンセルされた）。
+	RSSkipped ReminderStatus = "skipped"
+	// RSFailed は送信に失敗したもの（再送はしない）。
+	RSFailed ReminderStatus = "failed"
+)
+
+// Reminder はイベント 1 つ・リマインダ 1 回分の計画と送信状態。
+// Datastore のキーは ReminderKeyName(EventID, Type, Hours)。
+type Reminder struct {
+	EventID string         `json:"event_id"`
+	Type    ReminderType   `json:"type"`
+	Hours   int            `json:"hours"`
+	FireAt  time.Time      `json:"fire_at"`
+	Status  ReminderStatus `json:"status"`
+	SentAt  time.Time      `json:"sent_at,omitempty"`
+	// Reason は skipped / failed になった理由。
+	Reason string `json:"reason,omitempty" datastore:",noindex"`
+}
+
+func ReminderKeyName(eventID string, rt ReminderType, hours int) string {
+	return fmt.Sprintf("%s_%s_%dh", eventID, rt, hours)
+}
+
+func (r Reminder) KeyName() string {
+	return ReminderKeyName(r.EventID, r.Type, r.Hours)
+}
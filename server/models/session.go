@@ -13,5 +13,9 @@ type (
 		OpenID SlackOpenIDUserInfo `json:"openid"`
 		Slack  SlackUser           `json:"slack"`
 		Team   SlackTeam           `json:"team"`
+		// Positions と Roles、Permissions（役割から決まる権限）はフロントエンドで操作の表示を切り替えるのに使う
+		Positions   MemberPositions `json:"positions"`
+		Roles       []Role          `json:"roles"`
+		Permissions []Permission    `json:"permissions"`
 	}
 )
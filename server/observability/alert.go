@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
 )
 
 // alertChannelEnv は通知先チャンネル ID を解決する環境変数名。
@@ -69,7 +70,7 @@ func Notify(r Report) {
 				log.Printf("[observability] アラート送信中に panic: %v", rec)
 			}
 		}()
-		api := slack.New(os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN"))
+		api := server.NewSlackClient()
 		if _, _, err := api.PostMessage(channel, slack.MsgOptionBlocks(r.blocks()...)); err != nil {
 			log.Printf("[observability] アラート投稿に失敗: %v", err)
 		}
@@ -0,0 +1,523 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/triax/hub/server/models"
+)
+
+type actorContextKey struct{}
+
+// WithActor は書き込みの操作者（Slack ID）を ctx に載せる。
+// WithAudit でラップした Repository は、これを AuditEntry.Actor として記録する。
+func WithActor(ctx context.Context, slackID string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, slackID)
+}
+
+// ActorFrom は WithActor で載せた操作者を返す。無ければ空文字。
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
+
+// WithAudit は repo への書き込みを repo.Audit() へ AuditEntry として記録する Repository を返す。
+//
+// 変更前の状態は書き込み前に読み込み、変更後との差分をフィールド単位で記録する。
+// 操作者の無い書き込み（cron タスクによる同期など）は記録しない。
+// 記録に失敗しても書き込み自体は成功として扱う（ログにだけ残す）。
+func WithAudit(repo Repository) Repository {
+	return audited{repo}
+}
+
+type audited struct{ Repository }
+
+func (a audited) Members() Members {
+	return auditedMembers{a.Repository.Members(), a.Repository.Audit()}
+}
+func (a audited) Events() Events { return auditedEvents{a.Repository.Events(), a.Repository.Audit()} }
+func (a audited) Participations() Participations {
+	return auditedParticipations{a.Repository.Participations(), a.Repository.Audit()}
+}
+func (a audited) Equips() Equips { return auditedEquips{a.Repository.Equips(), a.Repository.Audit()} }
+func (a audited) Numbers() Numbers {
+	return auditedNumbers{a.Repository.Numbers(), a.Repository.Audit()}
+}
+func (a audited) Taping() Taping { return auditedTaping{a.Repository.Taping(), a.Repository.Audit()} }
+func (a audited) Applications() Applications {
+	return auditedApplications{a.Repository.Applications(), a.Repository.Audit()}
+}
+func (a audited) HPProfiles() HPProfiles {
+	return auditedHPProfiles{a.Repository.HPProfiles(), a.Repository.Audit()}
+}
+func (a audited) EventTags() EventTags {
+	return auditedEventTags{a.Repository.EventTags(), a.Repository.Audit()}
+}
+func (a audited) Attendances() Attendances {
+	return auditedAttendances{a.Repository.Attendances(), a.Repository.Audit()}
+}
+
+// record は before / after の差分を AuditEntry として書き込む。
+// before / after の nil は「存在しない」を表す。
+func record(ctx context.Context, audit AuditLog, action, kind, target string, before, after interface{}) {
+	actor := ActorFrom(ctx)
+	if actor == "" {
+		return
+	}
+	changes, err := Diff(before, after)
+	if err != nil {
+		log.Println("[ERROR]", 10001, err.Error())
+	}
+	entry := &models.AuditEntry{
+		Actor:     actor,
+		Action:    action,
+		Kind:      kind,
+		TargetKey: target,
+		Changes:   changes,
+		Timestamp: time.Now(),
+	}
+	if err := audit.Record(ctx, entry); err != nil {
+		log.Println("[ERROR]", 10002, err.Error())
+	}
+}
+
+// Diff は before / after を JSON として比較し、変わったフィールドを Field の昇順で返す。
+// オブジェクトは "a.b.c" のパスに展開し、配列は 1 つの値として比較する。
+func Diff(before, after interface{}) ([]models.AuditChange, error) {
+	b, err := flatten(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := flatten(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := []models.AuditChange{}
+	for field, bv := range b {
+		if av, ok := a[field]; !ok || av != bv {
+			changes = append(changes, models.AuditChange{Field: field, Before: bv, After: av})
+		}
+	}
+	for field, av := range a {
+		if _, ok := b[field]; !ok {
+			changes = append(changes, models.AuditChange{Field: field, After: av})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func flatten(v interface{}) (map[string]string, error) {
+	fields := map[string]string{}
+	if v == nil {
+		return fields, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return nil, err
+	}
+	var walk func(prefix string, node interface{})
+	walk = func(prefix string, node interface{}) {
+		if obj, ok := node.(map[string]interface{}); ok && len(obj) > 0 {
+			for k, child := range obj {
+				if prefix != "" {
+					k = prefix + "." + k
+				}
+				walk(k, child)
+			}
+			return
+		}
+		leaf, _ := json.Marshal(node)
+		fields[prefix] = string(leaf)
+	}
+	walk("", tree)
+	return fields, nil
+}
+
+// orNil は Get の結果を record 用に変換する（ErrNotFound なら nil）。
+func orNil[T any](v *T, err error) interface{} {
+	if err != nil || v == nil {
+		return nil
+	}
+	return v
+}
+
+// --- Members ---
+
+type auditedMembers struct {
+	Members
+	audit AuditLog
+}
+
+func (r auditedMembers) Put(ctx context.Context, member *models.Member) error {
+	before := orNil(r.Members.Get(ctx, member.Slack.ID))
+	if err := r.Members.Put(ctx, member); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "Member.Put", models.KindMember, member.Slack.ID, before, member)
+	return nil
+}
+
+func (r auditedMembers) Update(ctx context.Context, slackID string, fn func(*models.Member) error) (*models.Member, error) {
+	var before models.Member
+	member, err := r.Members.Update(ctx, slackID, func(m *models.Member) error {
+		before = *m
+		return fn(m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	record(ctx, r.audit, "Member.Update", models.KindMember, slackID, before, member)
+	return member, nil
+}
+
+// --- Events ---
+
+type auditedEvents struct {
+	Events
+	audit AuditLog
+}
+
+func (r auditedEvents) Put(ctx context.Context, event *models.Event) error {
+	before := orNil(r.Events.Get(ctx, event.Google.ID))
+	if err := r.Events.Put(ctx, event); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "Event.Put", models.KindEvent, event.Google.ID, before, event)
+	return nil
+}
+
+func (r auditedEvents) Update(ctx context.Context, id string, fn func(*models.Event) error) (*models.Event, error) {
+	var before models.Event
+	event, err := r.Events.Update(ctx, id, func(ev *models.Event) error {
+		before = *ev
+		return fn(ev)
+	})
+	if err != nil {
+		return nil, err
+	}
+	record(ctx, r.audit, "Event.Update", models.KindEvent, id, before, event)
+	return event, nil
+}
+
+func (r auditedEvents) Delete(ctx context.Context, id string) error {
+	before := orNil(r.Events.Get(ctx, id))
+	if err := r.Events.Delete(ctx, id); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "Event.Delete", models.KindEvent, id, before, nil)
+	return nil
+}
+
+// --- Participations ---
+
+type auditedParticipations struct {
+	Participations
+	audit AuditLog
+}
+
+func (r auditedParticipations) Update(ctx context.Context, eventID, memberID string, fn func(*models.Participation) error) (*models.Participation, error) {
+	var before *models.Participation
+	part, err := r.Participations.Update(ctx, eventID, memberID, func(p *models.Participation) error {
+		before = nil
+		if p.Type != "" {
+			prev := *p
+			prev.History = nil // History は毎回伸びるだけなので差分に含めない
+			before = &prev
+		}
+		return fn(p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	after := *part
+	after.History = nil
+	record(ctx, r.audit, "Participation.Update", models.KindParticipation, models.ParticipationKeyName(eventID, memberID), orNil(before, nil), after)
+	return part, nil
+}
+
+func (r auditedParticipations) DeleteByEvent(ctx context.Context, eventID string) error {
+	before, err := r.Participations.ListByEvent(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if err := r.Participations.DeleteByEvent(ctx, eventID); err != nil {
+		return err
+	}
+	for _, p := range before {
+		p.History = nil
+		record(ctx, r.audit, "Participation.Delete", models.KindParticipation, models.ParticipationKeyName(p.EventID, p.MemberID), p, nil)
+	}
+	return nil
+}
+
+// --- Equips ---
+
+type auditedEquips struct {
+	Equips
+	audit AuditLog
+}
+
+func equipTarget(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func (r auditedEquips) Create(ctx context.Context, equip *models.Equip) error {
+	if err := r.Equips.Create(ctx, equip); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "Equip.Create", models.KindEquip, equipTarget(equip.ID), nil, equip)
+	return nil
+}
+
+func (r auditedEquips) Put(ctx context.Context, id int64, equip *models.Equip) error {
+	before := orNil(r.Equips.Get(ctx, id))
+	if err := r.Equips.Put(ctx, id, equip); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "Equip.Put", models.KindEquip, equipTarget(id), before, equip)
+	return nil
+}
+
+func (r auditedEquips) Delete(ctx context.Context, id int64) error {
+	before := orNil(r.Equips.Get(ctx, id))
+	if err := r.Equips.Delete(ctx, id); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "Equip.Delete", models.KindEquip, equipTarget(id), before, nil)
+	return nil
+}
+
+func (r auditedEquips) AddCustody(ctx context.Context, ids []int64, custody models.Custody) ([]*models.Custody, error) {
+	custodies, err := r.Equips.AddCustody(ctx, ids, custody)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range custodies {
+		target := ""
+		if c.Key != nil && c.Key.Parent != nil {
+			target = fmt.Sprintf("%s/%d", equipTarget(c.Key.Parent.ID), c.Key.ID)
+		}
+		record(ctx, r.audit, "Custody.Add", models.KindCustody, target, nil, c)
+	}
+	return custodies, nil
+}
+
+// --- Numbers ---
+
+type auditedNumbers struct {
+	Numbers
+	audit AuditLog
+}
+
+func (r auditedNumbers) Assign(ctx context.Context, number int, playerID string) (*models.PlayerNumber, error) {
+	before := orNil(r.Numbers.Get(ctx, number))
+	n, err := r.Numbers.Assign(ctx, number, playerID)
+	if err != nil {
+		return nil, err
+	}
+	record(ctx, r.audit, "Number.Assign", models.KindNumber, strconv.Itoa(number), before, n)
+	return n, nil
+}
+
+func (r auditedNumbers) Deprive(ctx context.Context, number int) (*models.PlayerNumber, error) {
+	before := orNil(r.Numbers.Get(ctx, number))
+	n, err := r.Numbers.Deprive(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	record(ctx, r.audit, "Number.Deprive", models.KindNumber, strconv.Itoa(number), before, n)
+	return n, nil
+}
+
+// --- Taping ---
+
+type auditedTaping struct {
+	Taping
+	audit AuditLog
+}
+
+func (r auditedTaping) findTapeItem(ctx context.Context, id int64) interface{} {
+	items, err := r.Taping.ListTapeItems(ctx)
+	if err != nil {
+		return nil
+	}
+	for _, item := range items {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+func (r auditedTaping) findMenuItem(ctx context.Context, id int64) interface{} {
+	items, err := r.Taping.GetMenuItems(ctx, []int64{id})
+	if err != nil || len(items) == 0 || items[0].Key == nil {
+		return nil
+	}
+	return items[0]
+}
+
+func (r auditedTaping) PutTapeItem(ctx context.Context, item *models.TapeItem) error {
+	var before interface{}
+	if item.ID != 0 {
+		before = r.findTapeItem(ctx, item.ID)
+	}
+	if err := r.Taping.PutTapeItem(ctx, item); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "TapeItem.Put", models.KindTapeItem, strconv.FormatInt(item.ID, 10), before, item)
+	return nil
+}
+
+func (r auditedTaping) DeleteTapeItem(ctx context.Context, id int64) error {
+	before := r.findTapeItem(ctx, id)
+	if err := r.Taping.DeleteTapeItem(ctx, id); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "TapeItem.Delete", models.KindTapeItem, strconv.FormatInt(id, 10), before, nil)
+	return nil
+}
+
+func (r auditedTaping) PutMenuItem(ctx context.Context, item *models.TapingMenuItem) error {
+	var before interface{}
+	if item.ID != 0 {
+		before = r.findMenuItem(ctx, item.ID)
+	}
+	if err := r.Taping.PutMenuItem(ctx, item); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "TapingMenuItem.Put", models.KindTapingMenuItem, strconv.FormatInt(item.ID, 10), before, item)
+	return nil
+}
+
+func (r auditedTaping) DeleteMenuItem(ctx context.Context, id int64) error {
+	before := r.findMenuItem(ctx, id)
+	if err := r.Taping.DeleteMenuItem(ctx, id); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "TapingMenuItem.Delete", models.KindTapingMenuItem, strconv.FormatInt(id, 10), before, nil)
+	return nil
+}
+
+func (r auditedTaping) ReplaceRequests(ctx context.Context, memberID, eventID string, tapings []*models.Taping) error {
+	before, err := r.Taping.ListRequests(ctx, TapingQuery{MemberID: memberID, EventID: eventID})
+	if err != nil {
+		return err
+	}
+	if err := r.Taping.ReplaceRequests(ctx, memberID, eventID, tapings); err != nil {
+		return err
+	}
+	// メニュー ID の集合として差分を取る（RequestedAt は毎回変わるので含めない）
+	menus := func(ids []int64) map[string]string {
+		m := map[string]string{}
+		for _, id := range ids {
+			m[strconv.FormatInt(id, 10)] = "requested"
+		}
+		return m
+	}
+	prev := []int64{}
+	for _, t := range before {
+		prev = append(prev, t.MenuItemID)
+	}
+	next := []int64{}
+	for _, t := range tapings {
+		next = append(next, t.MenuItemID)
+	}
+	record(ctx, r.audit, "Taping.Replace", models.KindTaping, fmt.Sprintf("%s_%s", memberID, eventID), menus(prev), menus(next))
+	return nil
+}
+
+// --- Applications ---
+
+type auditedApplications struct {
+	Applications
+	audit AuditLog
+}
+
+func (r auditedApplications) Put(ctx context.Context, id string, app *models.Application) error {
+	before := orNil(r.Applications.Get(ctx, id))
+	if err := r.Applications.Put(ctx, id, app); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "Application.Put", models.KindApplication, id, before, app)
+	return nil
+}
+
+// --- HPProfiles ---
+
+type auditedHPProfiles struct {
+	HPProfiles
+	audit AuditLog
+}
+
+func (r auditedHPProfiles) Put(ctx context.Context, slackID string, profile *models.MemberHPProfile) error {
+	before := orNil(r.HPProfiles.Get(ctx, slackID))
+	if err := r.HPProfiles.Put(ctx, slackID, profile); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "MemberHPProfile.Put", models.KindHPProfile, slackID, before, profile)
+	return nil
+}
+
+// --- EventTags ---
+
+type auditedEventTags struct {
+	EventTags
+	audit AuditLog
+}
+
+func (r auditedEventTags) Put(ctx context.Context, rule *models.EventTagRule) error {
+	before := orNil(r.EventTags.Get(ctx, rule.Tag))
+	if err := r.EventTags.Put(ctx, rule); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "EventTagRule.Put", models.KindEventTagRule, string(rule.Tag), before, rule)
+	return nil
+}
+
+func (r auditedEventTags) Delete(ctx context.Context, tag models.EventTag) error {
+	before := orNil(r.EventTags.Get(ctx, tag))
+	if err := r.EventTags.Delete(ctx, tag); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "EventTagRule.Delete", models.KindEventTagRule, string(tag), before, nil)
+	return nil
+}
+
+// --- Attendances ---
+
+// auditedAttendances はチェックインの記録・取り消しだけを記録する（CheckInCode はコードを残さないため対象外）。
+type auditedAttendances struct {
+	Attendances
+	audit AuditLog
+}
+
+func (r auditedAttendances) Put(ctx context.Context, att *models.Attendance) error {
+	before := orNil(r.Attendances.Get(ctx, att.EventID, att.MemberID))
+	if err := r.Attendances.Put(ctx, att); err != nil {
+		return err
+	}
+	record(ctx, r.audit, "Attendance.Put", models.KindAttendance, models.ParticipationKeyName(att.EventID, att.MemberID), before, att)
+	return nil
+}
+
+func (r auditedAttendances) Delete(ctx context.Context, eventID, memberID string) error {
+	before := orNil(r.Attendances.Get(ctx, eventID, memberID))
+	if err := r.Attendances.Delete(ctx, eventID, memberID); err != nil {
+		return err
+	}
+	if before != nil {
+		record(ctx, r.audit, "Attendance.Delete", models.KindAttendance, models.ParticipationKeyName(eventID, memberID), before, nil)
+	}
+	return nil
+}
@@ -0,0 +1,1149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/server/models"
+	"google.golang.org/api/iterator"
+)
+
+// Datastore は Cloud Datastore をバックエンドとする Repository。
+// client はプロセス全体で共有する（リクエストごとに NewClient しない）。
+type Datastore struct {
+	client *datastore.Client
+}
+
+// NewDatastore は projectID の Datastore に接続する Repository を作る。
+// DATASTORE_EMULATOR_HOST が設定されていればエミュレーターへ接続する。
+func NewDatastore(ctx context.Context, projectID string) (*Datastore, error) {
+	client, err := datastore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("datastore client: %w", err)
+	}
+	return &Datastore{client: client}, nil
+}
+
+// Client は内部の datastore.Client を返す（コマンドラインツール等で直接使う場合向け）。
+func (ds *Datastore) Client() *datastore.Client {
+	return ds.client
+}
+
+func (ds *Datastore) Close() error {
+	return ds.client.Close()
+}
+
+func (ds *Datastore) Members() Members               { return dsMembers{ds.client} }
+func (ds *Datastore) Events() Events                 { return dsEvents{ds.client} }
+func (ds *Datastore) Participations() Participations { return dsParticipations{ds.client} }
+func (ds *Datastore) Equips() Equips                 { return dsEquips{ds.client} }
+func (ds *Datastore) Numbers() Numbers               { return dsNumbers{ds.client} }
+func (ds *Datastore) Taping() Taping                 { return dsTaping{ds.client} }
+func (ds *Datastore) Applications() Applications     { return dsApplications{ds.client} }
+func (ds *Datastore) HPProfiles() HPProfiles         { return dsHPProfiles{ds.client} }
+func (ds *Datastore) CalendarSyncs() CalendarSyncs   { return dsCalendarSyncs{ds.client} }
+func (ds *Datastore) EventTags() EventTags           { return dsEventTags{ds.client} }
+func (ds *Datastore) Reminders() Reminders           { return dsReminders{ds.client} }
+func (ds *Datastore) Attendances() Attendances       { return dsAttendances{ds.client} }
+func (ds *Datastore) CalendarFeeds() CalendarFeeds   { return dsCalendarFeeds{ds.client} }
+func (ds *Datastore) MemberStatusChanges() MemberStatusChanges {
+	return dsMemberStatusChanges{ds.client}
+}
+func (ds *Datastore) DormancyProposals() DormancyProposals { return dsDormancyProposals{ds.client} }
+func (ds *Datastore) Audit() AuditLog                      { return dsAuditLog{ds.client} }
+
+// ignoreMismatch は models.IsFiledMismatch を nil に、ErrNoSuchEntity を ErrNotFound に読み替える。
+func ignoreMismatch(err error) error {
+	if err == nil || models.IsFiledMismatch(err) {
+		return nil
+	}
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// --- Members ---
+
+type dsMembers struct{ client *datastore.Client }
+
+func memberKey(slackID string) *datastore.Key {
+	return datastore.NameKey(models.KindMember, slackID, nil)
+}
+
+func (r dsMembers) Get(ctx context.Context, slackID string) (*models.Member, error) {
+	member := &models.Member{}
+	if err := ignoreMismatch(r.client.Get(ctx, memberKey(slackID), member)); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (r dsMembers) List(ctx context.Context, includeDeleted bool) ([]models.Member, error) {
+	members := []models.Member{}
+	query := datastore.NewQuery(models.KindMember)
+	if !includeDeleted {
+		query = query.Filter("Slack.Deleted =", false)
+	}
+	if _, err := r.client.GetAll(ctx, query, &members); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r dsMembers) FindByNumber(ctx context.Context, number int) ([]models.Member, error) {
+	members := []models.Member{}
+	query := datastore.NewQuery(models.KindMember).FilterField("Number", "=", number)
+	if _, err := r.client.GetAll(ctx, query, &members); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r dsMembers) Put(ctx context.Context, member *models.Member) error {
+	_, err := r.client.Put(ctx, memberKey(member.Slack.ID), member)
+	return err
+}
+
+func (r dsMembers) Update(ctx context.Context, slackID string, fn func(*models.Member) error) (*models.Member, error) {
+	key := memberKey(slackID)
+	var member *models.Member
+	if _, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		member = &models.Member{}
+		if err := ignoreMismatch(tx.Get(key, member)); err != nil && err != ErrNotFound {
+			return err
+		}
+		if err := fn(member); err != nil {
+			return err
+		}
+		_, err := tx.Put(key, member)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// --- Events ---
+
+type dsEvents struct{ client *datastore.Client }
+
+func eventKey(id string) *datastore.Key {
+	return datastore.NameKey(models.KindEvent, id, nil)
+}
+
+func (r dsEvents) Get(ctx context.Context, id string) (*models.Event, error) {
+	event := &models.Event{}
+	if err := ignoreMismatch(r.client.Get(ctx, eventKey(id), event)); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (r dsEvents) Find(ctx context.Context, q EventQuery) ([]models.Event, error) {
+	query := datastore.NewQuery(models.KindEvent)
+	if !q.From.IsZero() {
+		query = query.Filter("Google.StartTime >=", q.From.Unix()*1000)
+	}
+	if !q.To.IsZero() {
+		query = query.Filter("Google.StartTime <", q.To.Unix()*1000)
+	}
+	if q.Desc {
+		query = query.Order("-Google.StartTime")
+	} else {
+		query = query.Order("Google.StartTime")
+	}
+	if q.IncludeCancelled {
+		if q.Limit > 0 {
+			query = query.Limit(q.Limit)
+		}
+		events := []models.Event{}
+		if _, err := r.client.GetAll(ctx, query, &events); ignoreMismatch(err) != nil {
+			return nil, fmt.Errorf("datastore query error: %v", err)
+		}
+		return events, nil
+	}
+	// Cancelled を持たない既存のエンティティは等価フィルタに掛からないので、読みながら除く
+	events := []models.Event{}
+	for it := r.client.Run(ctx, query); q.Limit == 0 || len(events) < q.Limit; {
+		ev := models.Event{}
+		_, err := it.Next(&ev)
+		if err == iterator.Done {
+			break
+		}
+		if ignoreMismatch(err) != nil {
+			return nil, fmt.Errorf("datastore query error: %v", err)
+		}
+		if !ev.Cancelled {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+func (r dsEvents) Page(ctx context.Context, q EventQuery, cursor string, match func(models.Event) bool) ([]models.Event, string, error) {
+	query := datastore.NewQuery(models.KindEvent)
+	if !q.From.IsZero() {
+		query = query.Filter("Google.StartTime >=", q.From.Unix()*1000)
+	}
+	if !q.To.IsZero() {
+		query = query.Filter("Google.StartTime <", q.To.Unix()*1000)
+	}
+	if q.Desc {
+		query = query.Order("-Google.StartTime")
+	} else {
+		query = query.Order("Google.StartTime")
+	}
+	if cursor != "" {
+		c, err := datastore.DecodeCursor(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		query = query.Start(c)
+	}
+	// キャンセル済みと match を満たさないものは読みながら除くので、cursor は最後に読んだ位置を指す
+	events := []models.Event{}
+	for it := r.client.Run(ctx, query); ; {
+		ev := models.Event{}
+		_, err := it.Next(&ev)
+		if err == iterator.Done {
+			return events, "", nil
+		}
+		if ignoreMismatch(err) != nil {
+			return nil, "", fmt.Errorf("datastore query error: %v", err)
+		}
+		if (ev.Cancelled && !q.IncludeCancelled) || (match != nil && !match(ev)) {
+			continue
+		}
+		events = append(events, ev)
+		if q.Limit > 0 && len(events) >= q.Limit {
+			next, err := it.Cursor()
+			if err != nil {
+				return nil, "", fmt.Errorf("datastore cursor error: %v", err)
+			}
+			return events, next.String(), nil
+		}
+	}
+}
+
+func (r dsEvents) Put(ctx context.Context, event *models.Event) error {
+	_, err := r.client.Put(ctx, eventKey(event.Google.ID), event)
+	return err
+}
+
+func (r dsEvents) Update(ctx context.Context, id string, fn func(*models.Event) error) (*models.Event, error) {
+	key := eventKey(id)
+	var event *models.Event
+	if _, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		event = &models.Event{}
+		if err := ignoreMismatch(tx.Get(key, event)); err != nil && err != ErrNotFound {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+		_, err := tx.Put(key, event)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (r dsEvents) Delete(ctx context.Context, id string) error {
+	return r.client.Delete(ctx, eventKey(id))
+}
+
+// --- Participations ---
+
+type dsParticipations struct{ client *datastore.Client }
+
+func participationKey(eventID, memberID string) *datastore.Key {
+	return datastore.NameKey(models.KindParticipation, models.ParticipationKeyName(eventID, memberID), nil)
+}
+
+func (r dsParticipations) Get(ctx context.Context, eventID, memberID string) (*models.Participation, error) {
+	part := &models.Participation{}
+	if err := ignoreMismatch(r.client.Get(ctx, participationKey(eventID, memberID), part)); err != nil {
+		return nil, err
+	}
+	return part, part.UnmarshalParams()
+}
+
+func (r dsParticipations) list(ctx context.Context, query *datastore.Query) ([]models.Participation, error) {
+	parts := []models.Participation{}
+	if _, err := r.client.GetAll(ctx, query, &parts); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	for i := range parts {
+		if err := parts[i].UnmarshalParams(); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+func (r dsParticipations) ListByEvent(ctx context.Context, eventID string) ([]models.Participation, error) {
+	return r.list(ctx, datastore.NewQuery(models.KindParticipation).Filter("EventID =", eventID))
+}
+
+// inFilterLimit は IN フィルタ 1 回あたりの値の上限。
+const inFilterLimit = 30
+
+func (r dsParticipations) ListByEvents(ctx context.Context, eventIDs []string) (map[string][]models.Participation, error) {
+	byEvent := map[string][]models.Participation{}
+	for chunk := range slices.Chunk(eventIDs, inFilterLimit) {
+		ids := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			ids[i] = id
+		}
+		parts, err := r.list(ctx, datastore.NewQuery(models.KindParticipation).FilterField("EventID", "in", ids))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parts {
+			byEvent[p.EventID] = append(byEvent[p.EventID], p)
+		}
+	}
+	return byEvent, nil
+}
+
+func (r dsParticipations) ListByMember(ctx context.Context, memberID string) ([]models.Participation, error) {
+	return r.list(ctx, datastore.NewQuery(models.KindParticipation).Filter("MemberID =", memberID))
+}
+
+func (r dsParticipations) Update(ctx context.Context, eventID, memberID string, fn func(*models.Participation) error) (*models.Participation, error) {
+	key := participationKey(eventID, memberID)
+	var part *models.Participation
+	if _, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		part = &models.Participation{}
+		if err := ignoreMismatch(tx.Get(key, part)); err != nil && err != ErrNotFound {
+			return err
+		}
+		if err := part.UnmarshalParams(); err != nil {
+			return err
+		}
+		part.EventID, part.MemberID = eventID, memberID
+		if err := fn(part); err != nil {
+			return err
+		}
+		if err := part.MarshalParams(); err != nil {
+			return err
+		}
+		_, err := tx.Put(key, part)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return part, nil
+}
+
+func (r dsParticipations) PutMulti(ctx context.Context, parts []*models.Participation) error {
+	keys := make([]*datastore.Key, len(parts))
+	for i, p := range parts {
+		if err := p.MarshalParams(); err != nil {
+			return err
+		}
+		keys[i] = participationKey(p.EventID, p.MemberID)
+	}
+	// PutMulti は 1 回 500 件まで
+	for start := 0; start < len(parts); start += 500 {
+		end := min(start+500, len(parts))
+		if _, err := r.client.PutMulti(ctx, keys[start:end], parts[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r dsParticipations) DeleteByEvent(ctx context.Context, eventID string) error {
+	keys, err := r.client.GetAll(ctx, datastore.NewQuery(models.KindParticipation).Filter("EventID =", eventID).KeysOnly(), nil)
+	if err != nil {
+		return err
+	}
+	return r.client.DeleteMulti(ctx, keys)
+}
+
+// --- Equips ---
+
+type dsEquips struct{ client *datastore.Client }
+
+func equipKey(id int64) *datastore.Key {
+	return datastore.IDKey(models.KindEquip, id, nil)
+}
+
+func (r dsEquips) List(ctx context.Context) ([]models.Equip, error) {
+	equips := []models.Equip{}
+	if _, err := r.client.GetAll(ctx, datastore.NewQuery(models.KindEquip), &equips); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	for i, e := range equips {
+		equips[i].ID = e.Key.ID
+	}
+	return equips, nil
+}
+
+func (r dsEquips) Get(ctx context.Context, id int64) (*models.Equip, error) {
+	equip := &models.Equip{}
+	key := equipKey(id)
+	if err := ignoreMismatch(r.client.Get(ctx, key, equip)); err != nil {
+		return nil, err
+	}
+	equip.Key = key
+	equip.ID = id
+	return equip, nil
+}
+
+func (r dsEquips) Create(ctx context.Context, equip *models.Equip) error {
+	created, err := r.client.Put(ctx, datastore.IncompleteKey(models.KindEquip, nil), equip)
+	if err != nil {
+		return err
+	}
+	equip.Key = created
+	equip.ID = created.ID
+	return nil
+}
+
+func (r dsEquips) Put(ctx context.Context, id int64, equip *models.Equip) error {
+	_, err := r.client.Put(ctx, equipKey(id), equip)
+	return err
+}
+
+func (r dsEquips) Delete(ctx context.Context, id int64) error {
+	key := equipKey(id)
+	keys, err := r.client.GetAll(ctx, datastore.NewQuery(models.KindCustody).Ancestor(key).KeysOnly(), nil)
+	if err != nil {
+		return err
+	}
+	if err := r.client.DeleteMulti(ctx, keys); err != nil {
+		return err
+	}
+	return r.client.Delete(ctx, key)
+}
+
+func (r dsEquips) History(ctx context.Context, id int64, limit int) ([]models.Custody, error) {
+	query := datastore.NewQuery(models.KindCustody).Ancestor(equipKey(id)).Order("-Timestamp")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	history := []models.Custody{}
+	if _, err := r.client.GetAll(ctx, query, &history); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r dsEquips) AddCustody(ctx context.Context, ids []int64, custody models.Custody) ([]*models.Custody, error) {
+	keys := make([]*datastore.Key, 0, len(ids))
+	custodies := make([]*models.Custody, 0, len(ids))
+	for _, id := range ids {
+		c := custody
+		keys = append(keys, datastore.IncompleteKey(models.KindCustody, equipKey(id)))
+		custodies = append(custodies, &c)
+	}
+	inserted, err := r.client.PutMulti(ctx, keys, custodies)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range inserted {
+		custodies[i].Key = key
+	}
+	return custodies, nil
+}
+
+// --- Numbers ---
+
+type dsNumbers struct{ client *datastore.Client }
+
+func numberKey(number int) *datastore.Key {
+	return datastore.NameKey(models.KindNumber, strconv.Itoa(number), nil)
+}
+
+func (r dsNumbers) List(ctx context.Context) ([]models.PlayerNumber, error) {
+	numbers := []models.PlayerNumber{}
+	if _, err := r.client.GetAll(ctx, datastore.NewQuery(models.KindNumber), &numbers); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	return numbers, nil
+}
+
+func (r dsNumbers) Get(ctx context.Context, number int) (*models.PlayerNumber, error) {
+	n := &models.PlayerNumber{}
+	if err := ignoreMismatch(r.client.Get(ctx, numberKey(number), n)); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (r dsNumbers) Assign(ctx context.Context, number int, playerID string) (*models.PlayerNumber, error) {
+	// あってもなくてもいいので、エラーはとりあえず無視
+	playernumber := &models.PlayerNumber{}
+	r.client.Get(ctx, numberKey(number), playernumber)
+	playernumber.Number = number
+
+	player, err := dsMembers{r.client}.Get(ctx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	prevs, err := dsMembers{r.client}.FindByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	player.Number = &playernumber.Number
+	playernumber.PlayerID = player.Slack.ID
+	if _, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		// Deprive previous players of the number...!
+		for _, prev := range prevs {
+			if prev.Slack.ID == playerID {
+				continue
+			}
+			prev.Number = nil
+			if _, err := tx.Put(memberKey(prev.Slack.ID), &prev); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Put(memberKey(player.Slack.ID), player); err != nil {
+			return err
+		}
+		_, err := tx.Put(numberKey(number), playernumber)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return playernumber, nil
+}
+
+func (r dsNumbers) Deprive(ctx context.Context, number int) (*models.PlayerNumber, error) {
+	playernumber, err := r.Get(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	player, err := dsMembers{r.client}.Get(ctx, playernumber.PlayerID)
+	if err != nil {
+		return nil, err
+	}
+	player.Number = nil
+	playernumber.PlayerID = ""
+	if _, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		if _, err := tx.Put(memberKey(player.Slack.ID), player); err != nil {
+			return err
+		}
+		_, err := tx.Put(numberKey(number), playernumber)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return playernumber, nil
+}
+
+// --- Taping ---
+
+type dsTaping struct{ client *datastore.Client }
+
+func (r dsTaping) ListTapeItems(ctx context.Context) ([]models.TapeItem, error) {
+	items := []models.TapeItem{}
+	if _, err := r.client.GetAll(ctx, datastore.NewQuery(models.KindTapeItem).Order("SortOrder"), &items); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	for i, it := range items {
+		items[i].ID = it.Key.ID
+	}
+	return items, nil
+}
+
+func (r dsTaping) PutTapeItem(ctx context.Context, item *models.TapeItem) error {
+	key := datastore.IncompleteKey(models.KindTapeItem, nil)
+	if item.ID != 0 {
+		key = datastore.IDKey(models.KindTapeItem, item.ID, nil)
+	}
+	key, err := r.client.Put(ctx, key, item)
+	if err != nil {
+		return err
+	}
+	item.ID = key.ID
+	return nil
+}
+
+func (r dsTaping) DeleteTapeItem(ctx context.Context, id int64) error {
+	return r.client.Delete(ctx, datastore.IDKey(models.KindTapeItem, id, nil))
+}
+
+func (r dsTaping) ListMenuItems(ctx context.Context) ([]models.TapingMenuItem, error) {
+	items := []models.TapingMenuItem{}
+	if _, err := r.client.GetAll(ctx, datastore.NewQuery(models.KindTapingMenuItem).Order("SortOrder"), &items); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	for i, it := range items {
+		items[i].ID = it.Key.ID
+	}
+	return items, nil
+}
+
+func (r dsTaping) GetMenuItems(ctx context.Context, ids []int64) ([]models.TapingMenuItem, error) {
+	items := make([]models.TapingMenuItem, len(ids))
+	if len(ids) == 0 {
+		return items, nil
+	}
+	keys := make([]*datastore.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = datastore.IDKey(models.KindTapingMenuItem, id, nil)
+	}
+	if err := r.client.GetMulti(ctx, keys, items); err != nil {
+		me, ok := err.(datastore.MultiError)
+		if !ok {
+			return nil, err
+		}
+		for _, e := range me {
+			if e != nil && !models.IsFiledMismatch(e) {
+				return nil, e
+			}
+		}
+	}
+	for i, id := range ids {
+		items[i].ID = id
+	}
+	return items, nil
+}
+
+func (r dsTaping) PutMenuItem(ctx context.Context, item *models.TapingMenuItem) error {
+	key := datastore.IncompleteKey(models.KindTapingMenuItem, nil)
+	if item.ID != 0 {
+		key = datastore.IDKey(models.KindTapingMenuItem, item.ID, nil)
+	}
+	key, err := r.client.Put(ctx, key, item)
+	if err != nil {
+		return err
+	}
+	item.ID = key.ID
+	return nil
+}
+
+func (r dsTaping) DeleteMenuItem(ctx context.Context, id int64) error {
+	return r.client.Delete(ctx, datastore.IDKey(models.KindTapingMenuItem, id, nil))
+}
+
+func (r dsTaping) ListRequests(ctx context.Context, q TapingQuery) ([]models.Taping, error) {
+	query := datastore.NewQuery(models.KindTaping)
+	if q.MemberID != "" {
+		query = query.Filter("MemberID =", q.MemberID)
+	}
+	if q.EventID != "" {
+		query = query.Filter("EventID =", q.EventID)
+	}
+	if q.RequestedFrom != 0 {
+		query = query.Filter("RequestedAt >=", q.RequestedFrom)
+	}
+	if q.RequestedTo != 0 {
+		query = query.Filter("RequestedAt <", q.RequestedTo)
+	}
+	tapings := []models.Taping{}
+	if _, err := r.client.GetAll(ctx, query, &tapings); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	return tapings, nil
+}
+
+// TapingKey は Taping の NameKey（memberID_eventID_menuItemID）を返す。
+func TapingKey(memberID, eventID string, menuItemID int64) *datastore.Key {
+	return datastore.NameKey(models.KindTaping, fmt.Sprintf("%s_%s_%d", memberID, eventID, menuItemID), nil)
+}
+
+func (r dsTaping) ReplaceRequests(ctx context.Context, memberID, eventID string, tapings []*models.Taping) error {
+	existing, err := r.ListRequests(ctx, TapingQuery{MemberID: memberID, EventID: eventID})
+	if err != nil {
+		return err
+	}
+
+	// 新リクエストに含まれない既存エンティティを削除
+	newSet := map[int64]bool{}
+	for _, t := range tapings {
+		newSet[t.MenuItemID] = true
+	}
+	toDelete := []*datastore.Key{}
+	for _, t := range existing {
+		if !newSet[t.MenuItemID] {
+			toDelete = append(toDelete, t.Key)
+		}
+	}
+	if len(toDelete) > 0 {
+		if err := r.client.DeleteMulti(ctx, toDelete); err != nil {
+			return err
+		}
+	}
+
+	// 新規・更新分を PutMulti（NameKey により upsert）
+	if len(tapings) == 0 {
+		return nil
+	}
+	keys := make([]*datastore.Key, len(tapings))
+	for i, t := range tapings {
+		keys[i] = TapingKey(memberID, eventID, t.MenuItemID)
+	}
+	if _, err := r.client.PutMulti(ctx, keys, tapings); err != nil {
+		return err
+	}
+	for i, t := range tapings {
+		t.Key = keys[i]
+	}
+	return nil
+}
+
+// --- Applications ---
+
+type dsApplications struct{ client *datastore.Client }
+
+func (r dsApplications) Get(ctx context.Context, id string) (*models.Application, error) {
+	app := &models.Application{}
+	key := datastore.NameKey(models.KindApplication, id, nil)
+	if err := ignoreMismatch(r.client.Get(ctx, key, app)); err != nil {
+		return nil, err
+	}
+	_ = app.UnmarshalFields()
+	return app, nil
+}
+
+func (r dsApplications) Put(ctx context.Context, id string, app *models.Application) error {
+	if err := app.MarshalFields(); err != nil {
+		return fmt.Errorf("marshal fields: %w", err)
+	}
+	key := datastore.NameKey(models.KindApplication, id, nil)
+	if _, err := r.client.Put(ctx, key, app); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+func (r dsApplications) List(ctx context.Context, appType string) ([]*models.Application, []string, error) {
+	q := datastore.NewQuery(models.KindApplication).Order("-CreatedAt")
+	if appType != "" {
+		q = q.FilterField("Type", "=", appType)
+	}
+
+	var apps []*models.Application
+	keys, err := r.client.GetAll(ctx, q, &apps)
+	if ignoreMismatch(err) != nil {
+		return nil, nil, fmt.Errorf("datastore GetAll: %w", err)
+	}
+	ids := make([]string, len(keys))
+	for i, k := range keys {
+		ids[i] = k.Name
+		_ = apps[i].UnmarshalFields()
+	}
+	return apps, ids, nil
+}
+
+// --- HPProfiles ---
+
+type dsHPProfiles struct{ client *datastore.Client }
+
+func hpProfileKey(slackID string) *datastore.Key {
+	return datastore.NameKey(models.KindHPProfile, slackID, nil)
+}
+
+func (r dsHPProfiles) Get(ctx context.Context, slackID string) (*models.MemberHPProfile, error) {
+	profile := &models.MemberHPProfile{}
+	if err := ignoreMismatch(r.client.Get(ctx, hpProfileKey(slackID), profile)); err != nil {
+		if err == ErrNotFound {
+			return profile, nil
+		}
+		return nil, fmt.Errorf("datastore Get: %w", err)
+	}
+	return profile, nil
+}
+
+func (r dsHPProfiles) GetMulti(ctx context.Context, members []models.Member) ([]*models.MemberHPProfile, error) {
+	keys := make([]*datastore.Key, len(members))
+	for i, m := range members {
+		keys[i] = hpProfileKey(m.Slack.ID)
+	}
+
+	profiles := make([]*models.MemberHPProfile, len(members))
+	for i := range profiles {
+		profiles[i] = &models.MemberHPProfile{}
+	}
+
+	errs := r.client.GetMulti(ctx, keys, profiles)
+	if errs != nil {
+		if merr, ok := errs.(datastore.MultiError); ok {
+			for i, e := range merr {
+				if e == datastore.ErrNoSuchEntity || models.IsFiledMismatch(e) {
+					profiles[i] = &models.MemberHPProfile{}
+				} else if e != nil {
+					profiles[i] = nil
+				}
+			}
+		} else {
+			return nil, fmt.Errorf("datastore GetMulti: %w", errs)
+		}
+	}
+	return profiles, nil
+}
+
+func (r dsHPProfiles) Put(ctx context.Context, slackID string, profile *models.MemberHPProfile) error {
+	if _, err := r.client.Put(ctx, hpProfileKey(slackID), profile); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+// --- CalendarSyncs ---
+
+type dsCalendarSyncs struct{ client *datastore.Client }
+
+func calendarSyncKey(calendarID string) *datastore.Key {
+	return datastore.NameKey(models.KindCalendarSync, calendarID, nil)
+}
+
+func (r dsCalendarSyncs) Get(ctx context.Context, calendarID string) (*models.CalendarSync, error) {
+	state := &models.CalendarSync{}
+	if err := ignoreMismatch(r.client.Get(ctx, calendarSyncKey(calendarID), state)); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (r dsCalendarSyncs) Put(ctx context.Context, state *models.CalendarSync) error {
+	if _, err := r.client.Put(ctx, calendarSyncKey(state.CalendarID), state); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+// --- EventTags ---
+
+type dsEventTags struct{ client *datastore.Client }
+
+func eventTagKey(tag models.EventTag) *datastore.Key {
+	return datastore.NameKey(models.KindEventTagRule, string(tag), nil)
+}
+
+func (r dsEventTags) List(ctx context.Context) ([]models.EventTagRule, error) {
+	rules := []models.EventTagRule{}
+	if _, err := r.client.GetAll(ctx, datastore.NewQuery(models.KindEventTagRule).Order("Order"), &rules); ignoreMismatch(err) != nil {
+		return nil, fmt.Errorf("datastore GetAll: %w", err)
+	}
+	return rules, nil
+}
+
+func (r dsEventTags) Get(ctx context.Context, tag models.EventTag) (*models.EventTagRule, error) {
+	rule := &models.EventTagRule{}
+	if err := ignoreMismatch(r.client.Get(ctx, eventTagKey(tag), rule)); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r dsEventTags) Put(ctx context.Context, rule *models.EventTagRule) error {
+	if _, err := r.client.Put(ctx, eventTagKey(rule.Tag), rule); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+func (r dsEventTags) Delete(ctx context.Context, tag models.EventTag) error {
+	return r.client.Delete(ctx, eventTagKey(tag))
+}
+
+// --- Reminders ---
+
+type dsReminders struct{ client *datastore.Client }
+
+func reminderKey(keyName string) *datastore.Key {
+	return datastore.NameKey(models.KindReminder, keyName, nil)
+}
+
+func (r dsReminders) ListByEvent(ctx context.Context, eventID string) ([]models.Reminder, error) {
+	reminders := []models.Reminder{}
+	query := datastore.NewQuery(models.KindReminder).Filter("EventID =", eventID)
+	if _, err := r.client.GetAll(ctx, query, &reminders); ignoreMismatch(err) != nil {
+		return nil, fmt.Errorf("datastore GetAll: %w", err)
+	}
+	return reminders, nil
+}
+
+func (r dsReminders) ListDue(ctx context.Context, now time.Time) ([]models.Reminder, error) {
+	reminders := []models.Reminder{}
+	query := datastore.NewQuery(models.KindReminder).
+		Filter("Status =", string(models.RSPlanned)).
+		Filter("FireAt <=", now).
+		Order("FireAt")
+	if _, err := r.client.GetAll(ctx, query, &reminders); ignoreMismatch(err) != nil {
+		return nil, fmt.Errorf("datastore GetAll: %w", err)
+	}
+	return reminders, nil
+}
+
+func (r dsReminders) Put(ctx context.Context, reminder *models.Reminder) error {
+	if _, err := r.client.Put(ctx, reminderKey(reminder.KeyName()), reminder); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+func (r dsReminders) Update(ctx context.Context, keyName string, fn func(*models.Reminder) error) (*models.Reminder, error) {
+	key := reminderKey(keyName)
+	var reminder *models.Reminder
+	if _, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		reminder = &models.Reminder{}
+		if err := ignoreMismatch(tx.Get(key, reminder)); err != nil {
+			return err
+		}
+		if err := fn(reminder); err != nil {
+			return err
+		}
+		_, err := tx.Put(key, reminder)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return reminder, nil
+}
+
+// --- Attendances ---
+
+type dsAttendances struct{ client *datastore.Client }
+
+func attendanceKey(eventID, memberID string) *datastore.Key {
+	return datastore.NameKey(models.KindAttendance, models.ParticipationKeyName(eventID, memberID), nil)
+}
+
+func checkInCodeKey(eventID string) *datastore.Key {
+	return datastore.NameKey(models.KindCheckInCode, eventID, nil)
+}
+
+func (r dsAttendances) Get(ctx context.Context, eventID, memberID string) (*models.Attendance, error) {
+	att := &models.Attendance{}
+	if err := ignoreMismatch(r.client.Get(ctx, attendanceKey(eventID, memberID), att)); err != nil {
+		return nil, err
+	}
+	return att, nil
+}
+
+func (r dsAttendances) list(ctx context.Context, query *datastore.Query) ([]models.Attendance, error) {
+	atts := []models.Attendance{}
+	if _, err := r.client.GetAll(ctx, query, &atts); ignoreMismatch(err) != nil {
+		return nil, fmt.Errorf("datastore GetAll: %w", err)
+	}
+	return atts, nil
+}
+
+func (r dsAttendances) ListByEvent(ctx context.Context, eventID string) ([]models.Attendance, error) {
+	return r.list(ctx, datastore.NewQuery(models.KindAttendance).Filter("EventID =", eventID))
+}
+
+func (r dsAttendances) ListByEvents(ctx context.Context, eventIDs []string) (map[string][]models.Attendance, error) {
+	byEvent := map[string][]models.Attendance{}
+	for chunk := range slices.Chunk(eventIDs, inFilterLimit) {
+		ids := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			ids[i] = id
+		}
+		atts, err := r.list(ctx, datastore.NewQuery(models.KindAttendance).FilterField("EventID", "in", ids))
+		if err != nil {
+			return nil, err
+		}
+		for _, att := range atts {
+			byEvent[att.EventID] = append(byEvent[att.EventID], att)
+		}
+	}
+	return byEvent, nil
+}
+
+func (r dsAttendances) ListByMember(ctx context.Context, memberID string) ([]models.Attendance, error) {
+	return r.list(ctx, datastore.NewQuery(models.KindAttendance).Filter("MemberID =", memberID))
+}
+
+func (r dsAttendances) Put(ctx context.Context, att *models.Attendance) error {
+	if _, err := r.client.Put(ctx, attendanceKey(att.EventID, att.MemberID), att); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+func (r dsAttendances) Delete(ctx context.Context, eventID, memberID string) error {
+	return r.client.Delete(ctx, attendanceKey(eventID, memberID))
+}
+
+func (r dsAttendances) GetCode(ctx context.Context, eventID string) (*models.CheckInCode, error) {
+	code := &models.CheckInCode{}
+	if err := ignoreMismatch(r.client.Get(ctx, checkInCodeKey(eventID), code)); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+func (r dsAttendances) PutCode(ctx context.Context, code *models.CheckInCode) error {
+	if _, err := r.client.Put(ctx, checkInCodeKey(code.EventID), code); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+func (r dsAttendances) UpdateCode(ctx context.Context, eventID string, fn func(*models.CheckInCode) error) (*models.CheckInCode, error) {
+	key := checkInCodeKey(eventID)
+	var code *models.CheckInCode
+	if _, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		code = &models.CheckInCode{}
+		if err := ignoreMismatch(tx.Get(key, code)); err != nil {
+			return err
+		}
+		if err := fn(code); err != nil {
+			return err
+		}
+		_, err := tx.Put(key, code)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// --- CalendarFeeds ---
+
+type dsCalendarFeeds struct{ client *datastore.Client }
+
+func calendarFeedKey(token string) *datastore.Key {
+	return datastore.NameKey(models.KindCalendarFeed, token, nil)
+}
+
+func (r dsCalendarFeeds) Get(ctx context.Context, token string) (*models.CalendarFeed, error) {
+	feed := &models.CalendarFeed{}
+	if err := ignoreMismatch(r.client.Get(ctx, calendarFeedKey(token), feed)); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+func (r dsCalendarFeeds) ListByMember(ctx context.Context, memberID string) ([]models.CalendarFeed, error) {
+	feeds := []models.CalendarFeed{}
+	query := datastore.NewQuery(models.KindCalendarFeed).Filter("MemberID =", memberID)
+	if _, err := r.client.GetAll(ctx, query, &feeds); ignoreMismatch(err) != nil {
+		return nil, fmt.Errorf("datastore GetAll: %w", err)
+	}
+	return feeds, nil
+}
+
+func (r dsCalendarFeeds) Put(ctx context.Context, feed *models.CalendarFeed) error {
+	if _, err := r.client.Put(ctx, calendarFeedKey(feed.Token), feed); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+func (r dsCalendarFeeds) Delete(ctx context.Context, token string) error {
+	return r.client.Delete(ctx, calendarFeedKey(token))
+}
+
+// --- MemberStatusChanges ---
+
+type dsMemberStatusChanges struct{ client *datastore.Client }
+
+func (r dsMemberStatusChanges) list(ctx context.Context, query *datastore.Query) ([]models.MemberStatusChange, error) {
+	changes := []models.MemberStatusChange{}
+	if _, err := r.client.GetAll(ctx, query, &changes); ignoreMismatch(err) != nil {
+		return nil, fmt.Errorf("datastore GetAll: %w", err)
+	}
+	return changes, nil
+}
+
+func (r dsMemberStatusChanges) ListByMember(ctx context.Context, memberID string) ([]models.MemberStatusChange, error) {
+	return r.list(ctx, datastore.NewQuery(models.KindMemberStatusChange).Filter("MemberID =", memberID).Order("EffectiveAt"))
+}
+
+func (r dsMemberStatusChanges) ListSince(ctx context.Context, since time.Time) ([]models.MemberStatusChange, error) {
+	return r.list(ctx, datastore.NewQuery(models.KindMemberStatusChange).Filter("EffectiveAt >=", since).Order("EffectiveAt"))
+}
+
+func (r dsMemberStatusChanges) Add(ctx context.Context, change *models.MemberStatusChange) error {
+	key := datastore.NameKey(models.KindMemberStatusChange, change.KeyName(), nil)
+	if _, err := r.client.Put(ctx, key, change); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+// --- DormancyProposals ---
+
+type dsDormancyProposals struct{ client *datastore.Client }
+
+func dormancyProposalKey(memberID string) *datastore.Key {
+	return datastore.NameKey(models.KindDormancyProposal, memberID, nil)
+}
+
+func (r dsDormancyProposals) Get(ctx context.Context, memberID string) (*models.DormancyProposal, error) {
+	proposal := &models.DormancyProposal{}
+	if err := ignoreMismatch(r.client.Get(ctx, dormancyProposalKey(memberID), proposal)); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+func (r dsDormancyProposals) Put(ctx context.Context, proposal *models.DormancyProposal) error {
+	if _, err := r.client.Put(ctx, dormancyProposalKey(proposal.MemberID), proposal); err != nil {
+		return fmt.Errorf("datastore Put: %w", err)
+	}
+	return nil
+}
+
+func (r dsDormancyProposals) Update(ctx context.Context, memberID string, fn func(*models.DormancyProposal) error) (*models.DormancyProposal, error) {
+	key := dormancyProposalKey(memberID)
+	var proposal *models.DormancyProposal
+	if _, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		proposal = &models.DormancyProposal{}
+		if err := ignoreMismatch(tx.Get(key, proposal)); err != nil {
+			return err
+		}
+		if err := fn(proposal); err != nil {
+			return err
+		}
+		_, err := tx.Put(key, proposal)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+func (r dsDormancyProposals) Delete(ctx context.Context, memberID string) error {
+	return r.client.Delete(ctx, dormancyProposalKey(memberID))
+}
+
+// --- Audit ---
+
+type dsAuditLog struct{ client *datastore.Client }
+
+func (r dsAuditLog) Record(ctx context.Context, entry *models.AuditEntry) error {
+	_, err := r.client.Put(ctx, datastore.IncompleteKey(models.KindAuditEntry, nil), entry)
+	return err
+}
+
+func (r dsAuditLog) Find(ctx context.Context, q AuditQuery) ([]models.AuditEntry, error) {
+	query := datastore.NewQuery(models.KindAuditEntry)
+	if q.Actor != "" {
+		query = query.Filter("Actor =", q.Actor)
+	}
+	if q.Kind != "" {
+		query = query.Filter("Kind =", q.Kind)
+	}
+	if !q.From.IsZero() {
+		query = query.Filter("Timestamp >=", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Filter("Timestamp <", q.To)
+	}
+	query = query.Order("-Timestamp")
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+	entries := []models.AuditEntry{}
+	if _, err := r.client.GetAll(ctx, query, &entries); ignoreMismatch(err) != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/triax/hub/server/models"
+)
+
+// WithMemberCache は Members().Get / List を全メンバーのキャッシュから返す Repository を返す。
+// 返すメンバーはキャッシュのコピーなので、書き換えてもキャッシュには影響しない。
+//
+// API・cron タスク・Slack のボタンは同じ Repository を共有するので、キャッシュもひとつになる。
+// キャッシュは最後の読み込みから ttl で失効し、この Repository を通した Member の書き込み
+// （CronFetchSlackMembers の同期、UpdateMemberProps、休眠の承認、背番号の割り当てなど）のたびに捨てる。
+// 他のインスタンスでの書き込みは ttl が過ぎるまで反映されない。
+func WithMemberCache(repo Repository, ttl time.Duration) Repository {
+	return cached{Repository: repo, members: &memberCache{ttl: ttl, now: time.Now}}
+}
+
+type cached struct {
+	Repository
+	members *memberCache
+}
+
+func (c cached) Members() Members {
+	return cachedMembers{c.Repository.Members(), c.members}
+}
+
+// Numbers は背番号の割り当て・剥奪でメンバーの Number が変わるので、そのたびにキャッシュを捨てる。
+func (c cached) Numbers() Numbers {
+	return cachedNumbers{c.Repository.Numbers(), c.members}
+}
+
+// memberCache は退部済みを含む全メンバー。読み込みの途中で invalidate された結果は保存しない。
+type memberCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.RWMutex
+	all      []models.Member
+	byID     map[string]models.Member
+	loadedAt time.Time
+	gen      uint64 // invalidate のたびに増やす
+}
+
+func (c *memberCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.all, c.byID = nil, nil
+	c.gen++
+}
+
+// load はキャッシュが有効ならそれを、失効していれば list で読み直して返す。
+func (c *memberCache) load(ctx context.Context, list func(context.Context) ([]models.Member, error)) ([]models.Member, map[string]models.Member, error) {
+	c.mu.RLock()
+	all, byID, fresh := c.all, c.byID, c.all != nil && c.now().Sub(c.loadedAt) < c.ttl
+	gen := c.gen
+	c.mu.RUnlock()
+	if fresh {
+		return all, byID, nil
+	}
+
+	all, err := list(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID = models.MembersToDict(all)
+	c.mu.Lock()
+	if c.gen == gen {
+		c.all, c.byID, c.loadedAt = all, byID, c.now()
+	}
+	c.mu.Unlock()
+	return all, byID, nil
+}
+
+type cachedMembers struct {
+	Members
+	cache *memberCache
+}
+
+func (r cachedMembers) listAll(ctx context.Context) ([]models.Member, error) {
+	return r.Members.List(ctx, true)
+}
+
+// Get はキャッシュに無ければ（他のインスタンスで追加された場合など）読み込み元から引く。
+func (r cachedMembers) Get(ctx context.Context, slackID string) (*models.Member, error) {
+	_, byID, err := r.cache.load(ctx, r.listAll)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := byID[slackID]; ok {
+		m = cloneMember(m)
+		return &m, nil
+	}
+	return r.Members.Get(ctx, slackID)
+}
+
+func (r cachedMembers) List(ctx context.Context, includeDeleted bool) ([]models.Member, error) {
+	all, _, err := r.cache.load(ctx, r.listAll)
+	if err != nil {
+		return nil, err
+	}
+	members := []models.Member{}
+	for _, m := range all {
+		if includeDeleted || !m.Slack.Deleted {
+			members = append(members, cloneMember(m))
+		}
+	}
+	return members, nil
+}
+
+func (r cachedMembers) Put(ctx context.Context, member *models.Member) error {
+	defer r.cache.invalidate()
+	return r.Members.Put(ctx, member)
+}
+
+func (r cachedMembers) Update(ctx context.Context, slackID string, fn func(*models.Member) error) (*models.Member, error) {
+	defer r.cache.invalidate()
+	return r.Members.Update(ctx, slackID, fn)
+}
+
+type cachedNumbers struct {
+	Numbers
+	cache *memberCache
+}
+
+func (r cachedNumbers) Assign(ctx context.Context, number int, playerID string) (*models.PlayerNumber, error) {
+	defer r.cache.invalidate()
+	return r.Numbers.Assign(ctx, number, playerID)
+}
+
+func (r cachedNumbers) Deprive(ctx context.Context, number int) (*models.PlayerNumber, error) {
+	defer r.cache.invalidate()
+	return r.Numbers.Deprive(ctx, number)
+}
+
+// cloneMember は m の参照型のフィールド（背番号・ポジション・役割）を複製したコピーを返す。
+func cloneMember(m models.Member) models.Member {
+	if m.Number != nil {
+		n := *m.Number
+		m.Number = &n
+	}
+	m.Positions.Secondary = slices.Clone(m.Positions.Secondary)
+	m.Positions.StaffRoles = slices.Clone(m.Positions.StaffRoles)
+	m.Roles = slices.Clone(m.Roles)
+	return m
+}
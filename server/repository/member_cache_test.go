@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/triax/hub/server/models"
+)
+
+// TestWithMemberCache は、メンバーの読み込みがキャッシュから返り、
+// ラップした Repository を通した書き込みと ttl の経過で読み直されることを検証する。
+func TestWithMemberCache(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "U00000001"}, Status: models.MSActive},
+		{Slack: models.SlackUser{ID: "U00000002", Deleted: true}},
+	} {
+		if err := mem.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	repo := WithMemberCache(mem, time.Minute).(cached)
+	repo.members.now = func() time.Time { return now }
+
+	if members, _ := repo.Members().List(ctx, false); len(members) != 1 {
+		t.Errorf("members = %+v", members)
+	}
+	if members, _ := repo.Members().List(ctx, true); len(members) != 2 {
+		t.Errorf("members with deleted = %+v", members)
+	}
+
+	// 元の Repository への直接の書き込みは ttl が過ぎるまで見えない
+	if _, err := mem.Members().Update(ctx, "U00000001", func(m *models.Member) error {
+		m.Status = models.MSLimited
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := repo.Members().Get(ctx, "U00000001"); m.Status != models.MSActive {
+		t.Errorf("status = %s, want cached %s", m.Status, models.MSActive)
+	}
+	now = now.Add(time.Minute)
+	if m, _ := repo.Members().Get(ctx, "U00000001"); m.Status != models.MSLimited {
+		t.Errorf("status = %s after ttl", m.Status)
+	}
+
+	// キャッシュを通した書き込みはすぐに見える
+	if _, err := repo.Members().Update(ctx, "U00000001", func(m *models.Member) error {
+		m.Status = models.MSInactive
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := repo.Members().Get(ctx, "U00000001"); m.Status != models.MSInactive {
+		t.Errorf("status = %s after update", m.Status)
+	}
+	if err := repo.Members().Put(ctx, &models.Member{Slack: models.SlackUser{ID: "U00000003"}}); err != nil {
+		t.Fatal(err)
+	}
+	if members, _ := repo.Members().List(ctx, false); len(members) != 2 {
+		t.Errorf("members after put = %+v", members)
+	}
+
+	// キャッシュに無いメンバーは元の Repository から引く
+	if err := mem.Members().Put(ctx, &models.Member{Slack: models.SlackUser{ID: "U00000004"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Members().Get(ctx, "U00000004"); err != nil {
+		t.Errorf("uncached member: %v", err)
+	}
+	if _, err := repo.Members().Get(ctx, "U99999999"); err != ErrNotFound {
+		t.Errorf("unknown member: %v", err)
+	}
+
+	// 背番号の割り当て・剥奪はすぐに見える
+	if _, err := repo.Numbers().Assign(ctx, 12, "U00000001"); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := repo.Members().Get(ctx, "U00000001"); m.Number == nil || *m.Number != 12 {
+		t.Errorf("number = %v after assign", m.Number)
+	}
+	if _, err := repo.Numbers().Deprive(ctx, 12); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := repo.Members().Get(ctx, "U00000001"); m.Number != nil {
+		t.Errorf("number = %d after deprive", *m.Number)
+	}
+}
+
+// TestWithMemberCache_Copy は、返したメンバーを書き換えてもキャッシュが変わらないことを検証する。
+func TestWithMemberCache_Copy(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	number := 7
+	if err := mem.Members().Put(ctx, &models.Member{
+		Slack:     models.SlackUser{ID: "U00000001"},
+		Number:    &number,
+		Positions: models.MemberPositions{Primary: "OL", Secondary: []string{"DL"}, StaffRoles: []models.StaffRole{models.SRStaff}},
+		Roles:     []models.Role{models.RoleCaptain},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	repo := WithMemberCache(mem, time.Minute)
+
+	m, _ := repo.Members().Get(ctx, "U00000001")
+	*m.Number = 99
+	m.Positions.Secondary[0] = "QB"
+	m.Positions.StaffRoles[0] = models.SRTrainer
+	m.Roles[0] = models.RoleAdmin
+	members, _ := repo.Members().List(ctx, true)
+	members[0].Roles[0] = models.RoleAdmin
+
+	got, _ := repo.Members().Get(ctx, "U00000001")
+	listed, _ := repo.Members().List(ctx, false)
+	for _, m := range []models.Member{*got, listed[0]} {
+		if *m.Number != 7 || m.Positions.Secondary[0] != "DL" || m.Positions.StaffRoles[0] != models.SRStaff || m.Roles[0] != models.RoleCaptain {
+			t.Errorf("cached member changed: %+v", m)
+		}
+	}
+}
+
+// TestWithMemberCache_Concurrent は読み込みと書き込みが並行しても壊れないことを検証する（go test -race 向け）。
+func TestWithMemberCache_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	repo := WithMemberCache(NewMemory(), time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("U%08d", i)
+			for j := 0; j < 50; j++ {
+				if _, err := repo.Members().Update(ctx, id, func(m *models.Member) error {
+					m.Slack.ID = id
+					return nil
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := repo.Members().Get(ctx, id); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := repo.Members().List(ctx, false); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if members, _ := repo.Members().List(ctx, false); len(members) != 8 {
+		t.Errorf("members = %d", len(members))
+	}
+}
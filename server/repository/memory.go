@@ -0,0 +1,1051 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/triax/hub/server/models"
+)
+
+// Memory はプロセス内のマップをバックエンドとする Repository。
+// ハンドラのユニットテストや、エミュレーター無しでのローカル検証に使う。
+//
+// Update 系メソッドの fn はロックを保持したまま呼ばれるので、fn の中から
+// 同じ Memory を呼び出してはいけない（デッドロックする）。
+type Memory struct {
+	mu sync.Mutex
+
+	members        map[string]models.Member
+	events         map[string]models.Event
+	participations map[string]models.Participation // NameKey -> Participation
+	equips         map[int64]models.Equip
+	custodies      map[int64][]models.Custody // Equip ID -> Custody
+	numbers        map[int]models.PlayerNumber
+	tapeItems      map[int64]models.TapeItem
+	menuItems      map[int64]models.TapingMenuItem
+	tapings        map[string]models.Taping
+	applications   map[string]models.Application
+	hpProfiles     map[string]models.MemberHPProfile
+	calendarSyncs  map[string]models.CalendarSync
+	eventTags      map[models.EventTag]models.EventTagRule
+	reminders      map[string]models.Reminder
+	attendances    map[string]models.Attendance // NameKey -> Attendance
+	checkInCodes   map[string]models.CheckInCode
+	calendarFeeds  map[string]models.CalendarFeed
+	statusChanges  map[string]models.MemberStatusChange
+	dormancy       map[string]models.DormancyProposal
+	audit          []models.AuditEntry
+
+	lastID int64 // auto-ID の払い出し用
+}
+
+// NewMemory は空の Memory を作る。
+func NewMemory() *Memory {
+	return &Memory{
+		members:        map[string]models.Member{},
+		events:         map[string]models.Event{},
+		participations: map[string]models.Participation{},
+		equips:         map[int64]models.Equip{},
+		custodies:      map[int64][]models.Custody{},
+		numbers:        map[int]models.PlayerNumber{},
+		tapeItems:      map[int64]models.TapeItem{},
+		menuItems:      map[int64]models.TapingMenuItem{},
+		tapings:        map[string]models.Taping{},
+		applications:   map[string]models.Application{},
+		hpProfiles:     map[string]models.MemberHPProfile{},
+		calendarSyncs:  map[string]models.CalendarSync{},
+		eventTags:      map[models.EventTag]models.EventTagRule{},
+		reminders:      map[string]models.Reminder{},
+		attendances:    map[string]models.Attendance{},
+		checkInCodes:   map[string]models.CheckInCode{},
+		calendarFeeds:  map[string]models.CalendarFeed{},
+		statusChanges:  map[string]models.MemberStatusChange{},
+		dormancy:       map[string]models.DormancyProposal{},
+	}
+}
+
+func (m *Memory) Members() Members               { return memMembers{m} }
+func (m *Memory) Events() Events                 { return memEvents{m} }
+func (m *Memory) Participations() Participations { return memParticipations{m} }
+func (m *Memory) Equips() Equips                 { return memEquips{m} }
+func (m *Memory) Numbers() Numbers               { return memNumbers{m} }
+func (m *Memory) Taping() Taping                 { return memTaping{m} }
+func (m *Memory) Applications() Applications     { return memApplications{m} }
+func (m *Memory) HPProfiles() HPProfiles         { return memHPProfiles{m} }
+func (m *Memory) CalendarSyncs() CalendarSyncs   { return memCalendarSyncs{m} }
+func (m *Memory) EventTags() EventTags           { return memEventTags{m} }
+func (m *Memory) Reminders() Reminders           { return memReminders{m} }
+func (m *Memory) Attendances() Attendances       { return memAttendances{m} }
+func (m *Memory) CalendarFeeds() CalendarFeeds   { return memCalendarFeeds{m} }
+func (m *Memory) MemberStatusChanges() MemberStatusChanges {
+	return memMemberStatusChanges{m}
+}
+func (m *Memory) DormancyProposals() DormancyProposals { return memDormancyProposals{m} }
+func (m *Memory) Audit() AuditLog                      { return memAuditLog{m} }
+
+// allocateID は auto-ID を払い出す。呼び出し側で m.mu を保持していること。
+func (m *Memory) allocateID() int64 {
+	m.lastID++
+	return m.lastID
+}
+
+// --- Members ---
+
+type memMembers struct{ m *Memory }
+
+func (r memMembers) Get(_ context.Context, slackID string) (*models.Member, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	member, ok := r.m.members[slackID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &member, nil
+}
+
+func (r memMembers) List(_ context.Context, includeDeleted bool) ([]models.Member, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	members := []models.Member{}
+	for _, member := range r.m.members {
+		if includeDeleted || !member.Slack.Deleted {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Slack.ID < members[j].Slack.ID })
+	return members, nil
+}
+
+func (r memMembers) FindByNumber(_ context.Context, number int) ([]models.Member, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	members := []models.Member{}
+	for _, member := range r.m.members {
+		if member.Number != nil && *member.Number == number {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (r memMembers) Put(_ context.Context, member *models.Member) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.members[member.Slack.ID] = *member
+	return nil
+}
+
+func (r memMembers) Update(_ context.Context, slackID string, fn func(*models.Member) error) (*models.Member, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	member := r.m.members[slackID]
+	if err := fn(&member); err != nil {
+		return nil, err
+	}
+	r.m.members[slackID] = member
+	return &member, nil
+}
+
+// --- Events ---
+
+type memEvents struct{ m *Memory }
+
+func (r memEvents) Get(_ context.Context, id string) (*models.Event, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	event, ok := r.m.events[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &event, nil
+}
+
+func (r memEvents) Find(_ context.Context, q EventQuery) ([]models.Event, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	events := []models.Event{}
+	for _, ev := range r.m.events {
+		if !q.From.IsZero() && ev.Google.StartTime < q.From.Unix()*1000 {
+			continue
+		}
+		if !q.To.IsZero() && ev.Google.StartTime >= q.To.Unix()*1000 {
+			continue
+		}
+		if ev.Cancelled && !q.IncludeCancelled {
+			continue
+		}
+		events = append(events, ev)
+	}
+	// 同じ開始時刻のイベントは ID 順にして、Page の cursor（位置）がずれないようにする
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Google.StartTime == events[j].Google.StartTime {
+			return events[i].Google.ID < events[j].Google.ID
+		}
+		if q.Desc {
+			return events[i].Google.StartTime > events[j].Google.StartTime
+		}
+		return events[i].Google.StartTime < events[j].Google.StartTime
+	})
+	if q.Limit > 0 && len(events) > q.Limit {
+		events = events[:q.Limit]
+	}
+	return events, nil
+}
+
+// Page の cursor は Find（Limit 無し）の結果での次の位置。
+func (r memEvents) Page(ctx context.Context, q EventQuery, cursor string, match func(models.Event) bool) ([]models.Event, string, error) {
+	start := 0
+	if cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 0 {
+			return nil, "", ErrInvalidCursor
+		}
+		start = n
+	}
+	limit := q.Limit
+	q.Limit = 0
+	all, err := r.Find(ctx, q)
+	if err != nil {
+		return nil, "", err
+	}
+	events := []models.Event{}
+	for i := start; i < len(all); i++ {
+		if match != nil && !match(all[i]) {
+			continue
+		}
+		events = append(events, all[i])
+		if limit > 0 && len(events) >= limit {
+			return events, strconv.Itoa(i + 1), nil
+		}
+	}
+	return events, "", nil
+}
+
+func (r memEvents) Put(_ context.Context, event *models.Event) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.events[event.Google.ID] = *event
+	return nil
+}
+
+func (r memEvents) Update(_ context.Context, id string, fn func(*models.Event) error) (*models.Event, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	event := r.m.events[id]
+	if err := fn(&event); err != nil {
+		return nil, err
+	}
+	r.m.events[id] = event
+	return &event, nil
+}
+
+func (r memEvents) Delete(_ context.Context, id string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	delete(r.m.events, id)
+	return nil
+}
+
+// --- Participations ---
+
+type memParticipations struct{ m *Memory }
+
+func (r memParticipations) Get(_ context.Context, eventID, memberID string) (*models.Participation, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	part, ok := r.m.participations[models.ParticipationKeyName(eventID, memberID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &part, part.UnmarshalParams()
+}
+
+func (r memParticipations) filter(match func(models.Participation) bool) ([]models.Participation, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	parts := []models.Participation{}
+	for _, p := range r.m.participations {
+		if !match(p) {
+			continue
+		}
+		if err := p.UnmarshalParams(); err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return models.ParticipationKeyName(parts[i].EventID, parts[i].MemberID) < models.ParticipationKeyName(parts[j].EventID, parts[j].MemberID)
+	})
+	return parts, nil
+}
+
+func (r memParticipations) ListByEvent(_ context.Context, eventID string) ([]models.Participation, error) {
+	return r.filter(func(p models.Participation) bool { return p.EventID == eventID })
+}
+
+func (r memParticipations) ListByEvents(_ context.Context, eventIDs []string) (map[string][]models.Participation, error) {
+	parts, err := r.filter(func(p models.Participation) bool { return slices.Contains(eventIDs, p.EventID) })
+	if err != nil {
+		return nil, err
+	}
+	byEvent := map[string][]models.Participation{}
+	for _, p := range parts {
+		byEvent[p.EventID] = append(byEvent[p.EventID], p)
+	}
+	return byEvent, nil
+}
+
+func (r memParticipations) ListByMember(_ context.Context, memberID string) ([]models.Participation, error) {
+	return r.filter(func(p models.Participation) bool { return p.MemberID == memberID })
+}
+
+func (r memParticipations) Update(_ context.Context, eventID, memberID string, fn func(*models.Participation) error) (*models.Participation, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	name := models.ParticipationKeyName(eventID, memberID)
+	part := r.m.participations[name]
+	if err := part.UnmarshalParams(); err != nil {
+		return nil, err
+	}
+	part.EventID, part.MemberID = eventID, memberID
+	if err := fn(&part); err != nil {
+		return nil, err
+	}
+	if err := part.MarshalParams(); err != nil {
+		return nil, err
+	}
+	part.History = append([]models.ParticipationChange(nil), part.History...)
+	r.m.participations[name] = part
+	return &part, nil
+}
+
+func (r memParticipations) PutMulti(_ context.Context, parts []*models.Participation) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, p := range parts {
+		if err := p.MarshalParams(); err != nil {
+			return err
+		}
+		r.m.participations[models.ParticipationKeyName(p.EventID, p.MemberID)] = *p
+	}
+	return nil
+}
+
+func (r memParticipations) DeleteByEvent(_ context.Context, eventID string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for name, p := range r.m.participations {
+		if p.EventID == eventID {
+			delete(r.m.participations, name)
+		}
+	}
+	return nil
+}
+
+// --- Equips ---
+
+type memEquips struct{ m *Memory }
+
+func (r memEquips) List(_ context.Context) ([]models.Equip, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	equips := []models.Equip{}
+	for _, e := range r.m.equips {
+		equips = append(equips, e)
+	}
+	sort.Slice(equips, func(i, j int) bool { return equips[i].ID < equips[j].ID })
+	return equips, nil
+}
+
+func (r memEquips) Get(_ context.Context, id int64) (*models.Equip, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	equip, ok := r.m.equips[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &equip, nil
+}
+
+func (r memEquips) Create(_ context.Context, equip *models.Equip) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	id := r.m.allocateID()
+	equip.ID = id
+	equip.Key = equipKey(id)
+	r.m.equips[id] = *equip
+	return nil
+}
+
+func (r memEquips) Put(_ context.Context, id int64, equip *models.Equip) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	stored := *equip
+	stored.ID = id
+	stored.Key = equipKey(id)
+	stored.History = nil
+	r.m.equips[id] = stored
+	return nil
+}
+
+func (r memEquips) Delete(_ context.Context, id int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	delete(r.m.custodies, id)
+	delete(r.m.equips, id)
+	return nil
+}
+
+func (r memEquips) History(_ context.Context, id int64, limit int) ([]models.Custody, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	history := append([]models.Custody{}, r.m.custodies[id]...)
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp > history[j].Timestamp })
+	if limit > 0 && len(history) > limit {
+		history = history[:limit]
+	}
+	return history, nil
+}
+
+func (r memEquips) AddCustody(_ context.Context, ids []int64, custody models.Custody) ([]*models.Custody, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	custodies := make([]*models.Custody, 0, len(ids))
+	for _, id := range ids {
+		c := custody
+		c.Key = datastore.IDKey(models.KindCustody, r.m.allocateID(), equipKey(id))
+		r.m.custodies[id] = append(r.m.custodies[id], c)
+		custodies = append(custodies, &c)
+	}
+	return custodies, nil
+}
+
+// --- Numbers ---
+
+type memNumbers struct{ m *Memory }
+
+func (r memNumbers) List(_ context.Context) ([]models.PlayerNumber, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	numbers := []models.PlayerNumber{}
+	for _, n := range r.m.numbers {
+		numbers = append(numbers, n)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i].Number < numbers[j].Number })
+	return numbers, nil
+}
+
+func (r memNumbers) Get(_ context.Context, number int) (*models.PlayerNumber, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	n, ok := r.m.numbers[number]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &n, nil
+}
+
+func (r memNumbers) Assign(_ context.Context, number int, playerID string) (*models.PlayerNumber, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	player, ok := r.m.members[playerID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	for id, prev := range r.m.members {
+		if id != playerID && prev.Number != nil && *prev.Number == number {
+			prev.Number = nil
+			r.m.members[id] = prev
+		}
+	}
+	n := r.m.numbers[number]
+	n.Number = number
+	n.PlayerID = playerID
+	player.Number = &n.Number
+	r.m.members[playerID] = player
+	r.m.numbers[number] = n
+	return &n, nil
+}
+
+func (r memNumbers) Deprive(_ context.Context, number int) (*models.PlayerNumber, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	n, ok := r.m.numbers[number]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	player, ok := r.m.members[n.PlayerID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	player.Number = nil
+	r.m.members[n.PlayerID] = player
+	n.PlayerID = ""
+	r.m.numbers[number] = n
+	return &n, nil
+}
+
+// --- Taping ---
+
+type memTaping struct{ m *Memory }
+
+func (r memTaping) ListTapeItems(_ context.Context) ([]models.TapeItem, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	items := []models.TapeItem{}
+	for _, it := range r.m.tapeItems {
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].SortOrder != items[j].SortOrder {
+			return items[i].SortOrder < items[j].SortOrder
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+func (r memTaping) PutTapeItem(_ context.Context, item *models.TapeItem) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if item.ID == 0 {
+		item.ID = r.m.allocateID()
+	}
+	item.Key = datastore.IDKey(models.KindTapeItem, item.ID, nil)
+	r.m.tapeItems[item.ID] = *item
+	return nil
+}
+
+func (r memTaping) DeleteTapeItem(_ context.Context, id int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	delete(r.m.tapeItems, id)
+	return nil
+}
+
+func (r memTaping) ListMenuItems(_ context.Context) ([]models.TapingMenuItem, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	items := []models.TapingMenuItem{}
+	for _, it := range r.m.menuItems {
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].SortOrder != items[j].SortOrder {
+			return items[i].SortOrder < items[j].SortOrder
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+func (r memTaping) GetMenuItems(_ context.Context, ids []int64) ([]models.TapingMenuItem, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	items := make([]models.TapingMenuItem, len(ids))
+	for i, id := range ids {
+		items[i] = r.m.menuItems[id]
+		items[i].ID = id
+	}
+	return items, nil
+}
+
+func (r memTaping) PutMenuItem(_ context.Context, item *models.TapingMenuItem) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if item.ID == 0 {
+		item.ID = r.m.allocateID()
+	}
+	item.Key = datastore.IDKey(models.KindTapingMenuItem, item.ID, nil)
+	r.m.menuItems[item.ID] = *item
+	return nil
+}
+
+func (r memTaping) DeleteMenuItem(_ context.Context, id int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	delete(r.m.menuItems, id)
+	return nil
+}
+
+func (r memTaping) ListRequests(_ context.Context, q TapingQuery) ([]models.Taping, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	tapings := []models.Taping{}
+	for _, t := range r.m.tapings {
+		if q.MemberID != "" && t.MemberID != q.MemberID {
+			continue
+		}
+		if q.EventID != "" && t.EventID != q.EventID {
+			continue
+		}
+		if q.RequestedFrom != 0 && t.RequestedAt < q.RequestedFrom {
+			continue
+		}
+		if q.RequestedTo != 0 && t.RequestedAt >= q.RequestedTo {
+			continue
+		}
+		tapings = append(tapings, t)
+	}
+	sort.Slice(tapings, func(i, j int) bool { return tapings[i].Key.Name < tapings[j].Key.Name })
+	return tapings, nil
+}
+
+func (r memTaping) ReplaceRequests(_ context.Context, memberID, eventID string, tapings []*models.Taping) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for name, t := range r.m.tapings {
+		if t.MemberID == memberID && t.EventID == eventID {
+			delete(r.m.tapings, name)
+		}
+	}
+	for _, t := range tapings {
+		t.Key = TapingKey(memberID, eventID, t.MenuItemID)
+		r.m.tapings[t.Key.Name] = *t
+	}
+	return nil
+}
+
+// --- Applications ---
+
+type memApplications struct{ m *Memory }
+
+func (r memApplications) Get(_ context.Context, id string) (*models.Application, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	app, ok := r.m.applications[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	_ = app.UnmarshalFields()
+	return &app, nil
+}
+
+func (r memApplications) Put(_ context.Context, id string, app *models.Application) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if err := app.MarshalFields(); err != nil {
+		return err
+	}
+	r.m.applications[id] = *app
+	return nil
+}
+
+func (r memApplications) List(_ context.Context, appType string) ([]*models.Application, []string, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	ids := []string{}
+	for id, app := range r.m.applications {
+		if appType == "" || app.Type == appType {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return r.m.applications[ids[i]].CreatedAt.After(r.m.applications[ids[j]].CreatedAt)
+	})
+	apps := make([]*models.Application, len(ids))
+	for i, id := range ids {
+		app := r.m.applications[id]
+		_ = app.UnmarshalFields()
+		apps[i] = &app
+	}
+	return apps, ids, nil
+}
+
+// --- HPProfiles ---
+
+type memHPProfiles struct{ m *Memory }
+
+func (r memHPProfiles) Get(_ context.Context, slackID string) (*models.MemberHPProfile, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	profile := r.m.hpProfiles[slackID]
+	return &profile, nil
+}
+
+func (r memHPProfiles) GetMulti(_ context.Context, members []models.Member) ([]*models.MemberHPProfile, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	profiles := make([]*models.MemberHPProfile, len(members))
+	for i, member := range members {
+		profile := r.m.hpProfiles[member.Slack.ID]
+		profiles[i] = &profile
+	}
+	return profiles, nil
+}
+
+func (r memHPProfiles) Put(_ context.Context, slackID string, profile *models.MemberHPProfile) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.hpProfiles[slackID] = *profile
+	return nil
+}
+
+// --- CalendarSyncs ---
+
+type memCalendarSyncs struct{ m *Memory }
+
+func (r memCalendarSyncs) Get(_ context.Context, calendarID string) (*models.CalendarSync, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	state, ok := r.m.calendarSyncs[calendarID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &state, nil
+}
+
+func (r memCalendarSyncs) Put(_ context.Context, state *models.CalendarSync) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.calendarSyncs[state.CalendarID] = *state
+	return nil
+}
+
+// --- EventTags ---
+
+type memEventTags struct{ m *Memory }
+
+func (r memEventTags) List(_ context.Context) ([]models.EventTagRule, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	rules := []models.EventTagRule{}
+	for _, rule := range r.m.eventTags {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Order < rules[j].Order })
+	return rules, nil
+}
+
+func (r memEventTags) Get(_ context.Context, tag models.EventTag) (*models.EventTagRule, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	rule, ok := r.m.eventTags[tag]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &rule, nil
+}
+
+func (r memEventTags) Put(_ context.Context, rule *models.EventTagRule) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.eventTags[rule.Tag] = *rule
+	return nil
+}
+
+func (r memEventTags) Delete(_ context.Context, tag models.EventTag) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	delete(r.m.eventTags, tag)
+	return nil
+}
+
+// --- Reminders ---
+
+type memReminders struct{ m *Memory }
+
+func (r memReminders) ListByEvent(_ context.Context, eventID string) ([]models.Reminder, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	reminders := []models.Reminder{}
+	for _, reminder := range r.m.reminders {
+		if reminder.EventID == eventID {
+			reminders = append(reminders, reminder)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].KeyName() < reminders[j].KeyName() })
+	return reminders, nil
+}
+
+func (r memReminders) ListDue(_ context.Context, now time.Time) ([]models.Reminder, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	reminders := []models.Reminder{}
+	for _, reminder := range r.m.reminders {
+		if reminder.Status == models.RSPlanned && !reminder.FireAt.After(now) {
+			reminders = append(reminders, reminder)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].FireAt.Equal(reminders[j].FireAt) {
+			return reminders[i].FireAt.Before(reminders[j].FireAt)
+		}
+		return reminders[i].KeyName() < reminders[j].KeyName()
+	})
+	return reminders, nil
+}
+
+func (r memReminders) Put(_ context.Context, reminder *models.Reminder) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.reminders[reminder.KeyName()] = *reminder
+	return nil
+}
+
+func (r memReminders) Update(_ context.Context, keyName string, fn func(*models.Reminder) error) (*models.Reminder, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	reminder, ok := r.m.reminders[keyName]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := fn(&reminder); err != nil {
+		return nil, err
+	}
+	r.m.reminders[keyName] = reminder
+	return &reminder, nil
+}
+
+// --- Attendances ---
+
+type memAttendances struct{ m *Memory }
+
+func (r memAttendances) Get(_ context.Context, eventID, memberID string) (*models.Attendance, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	att, ok := r.m.attendances[models.ParticipationKeyName(eventID, memberID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &att, nil
+}
+
+func (r memAttendances) list(match func(models.Attendance) bool) []models.Attendance {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	atts := []models.Attendance{}
+	for _, att := range r.m.attendances {
+		if match(att) {
+			atts = append(atts, att)
+		}
+	}
+	sort.Slice(atts, func(i, j int) bool {
+		return models.ParticipationKeyName(atts[i].EventID, atts[i].MemberID) < models.ParticipationKeyName(atts[j].EventID, atts[j].MemberID)
+	})
+	return atts
+}
+
+func (r memAttendances) ListByEvent(_ context.Context, eventID string) ([]models.Attendance, error) {
+	return r.list(func(att models.Attendance) bool { return att.EventID == eventID }), nil
+}
+
+func (r memAttendances) ListByEvents(_ context.Context, eventIDs []string) (map[string][]models.Attendance, error) {
+	byEvent := map[string][]models.Attendance{}
+	for _, att := range r.list(func(att models.Attendance) bool { return slices.Contains(eventIDs, att.EventID) }) {
+		byEvent[att.EventID] = append(byEvent[att.EventID], att)
+	}
+	return byEvent, nil
+}
+
+func (r memAttendances) ListByMember(_ context.Context, memberID string) ([]models.Attendance, error) {
+	return r.list(func(att models.Attendance) bool { return att.MemberID == memberID }), nil
+}
+
+func (r memAttendances) Put(_ context.Context, att *models.Attendance) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.attendances[models.ParticipationKeyName(att.EventID, att.MemberID)] = *att
+	return nil
+}
+
+func (r memAttendances) Delete(_ context.Context, eventID, memberID string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	delete(r.m.attendances, models.ParticipationKeyName(eventID, memberID))
+	return nil
+}
+
+func (r memAttendances) GetCode(_ context.Context, eventID string) (*models.CheckInCode, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	code, ok := r.m.checkInCodes[eventID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &code, nil
+}
+
+func (r memAttendances) PutCode(_ context.Context, code *models.CheckInCode) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.checkInCodes[code.EventID] = *code
+	return nil
+}
+
+func (r memAttendances) UpdateCode(_ context.Context, eventID string, fn func(*models.CheckInCode) error) (*models.CheckInCode, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	code, ok := r.m.checkInCodes[eventID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	code.FailedBy = slices.Clone(code.FailedBy)
+	if err := fn(&code); err != nil {
+		return nil, err
+	}
+	r.m.checkInCodes[eventID] = code
+	return &code, nil
+}
+
+// --- CalendarFeeds ---
+
+type memCalendarFeeds struct{ m *Memory }
+
+func (r memCalendarFeeds) Get(_ context.Context, token string) (*models.CalendarFeed, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	feed, ok := r.m.calendarFeeds[token]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &feed, nil
+}
+
+func (r memCalendarFeeds) ListByMember(_ context.Context, memberID string) ([]models.CalendarFeed, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	feeds := []models.CalendarFeed{}
+	for _, feed := range r.m.calendarFeeds {
+		if feed.MemberID == memberID {
+			feeds = append(feeds, feed)
+		}
+	}
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].CreatedAt.Before(feeds[j].CreatedAt) })
+	return feeds, nil
+}
+
+func (r memCalendarFeeds) Put(_ context.Context, feed *models.CalendarFeed) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.calendarFeeds[feed.Token] = *feed
+	return nil
+}
+
+func (r memCalendarFeeds) Delete(_ context.Context, token string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	delete(r.m.calendarFeeds, token)
+	return nil
+}
+
+// --- MemberStatusChanges ---
+
+type memMemberStatusChanges struct{ m *Memory }
+
+func (r memMemberStatusChanges) ListByMember(_ context.Context, memberID string) ([]models.MemberStatusChange, error) {
+	return r.list(func(c models.MemberStatusChange) bool { return c.MemberID == memberID }), nil
+}
+
+func (r memMemberStatusChanges) ListSince(_ context.Context, since time.Time) ([]models.MemberStatusChange, error) {
+	return r.list(func(c models.MemberStatusChange) bool { return !c.EffectiveAt.Before(since) }), nil
+}
+
+func (r memMemberStatusChanges) list(match func(models.MemberStatusChange) bool) []models.MemberStatusChange {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	changes := []models.MemberStatusChange{}
+	for _, change := range r.m.statusChanges {
+		if match(change) {
+			changes = append(changes, change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].EffectiveAt.Equal(changes[j].EffectiveAt) {
+			return changes[i].EffectiveAt.Before(changes[j].EffectiveAt)
+		}
+		return changes[i].KeyName() < changes[j].KeyName()
+	})
+	return changes
+}
+
+func (r memMemberStatusChanges) Add(_ context.Context, change *models.MemberStatusChange) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.statusChanges[change.KeyName()] = *change
+	return nil
+}
+
+// --- DormancyProposals ---
+
+type memDormancyProposals struct{ m *Memory }
+
+func (r memDormancyProposals) Get(_ context.Context, memberID string) (*models.DormancyProposal, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	proposal, ok := r.m.dormancy[memberID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &proposal, nil
+}
+
+func (r memDormancyProposals) Put(_ context.Context, proposal *models.DormancyProposal) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.dormancy[proposal.MemberID] = *proposal
+	return nil
+}
+
+func (r memDormancyProposals) Update(_ context.Context, memberID string, fn func(*models.DormancyProposal) error) (*models.DormancyProposal, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	proposal, ok := r.m.dormancy[memberID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := fn(&proposal); err != nil {
+		return nil, err
+	}
+	r.m.dormancy[memberID] = proposal
+	return &proposal, nil
+}
+
+func (r memDormancyProposals) Delete(_ context.Context, memberID string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	delete(r.m.dormancy, memberID)
+	return nil
+}
+
+// --- Audit ---
+
+type memAuditLog struct{ m *Memory }
+
+func (r memAuditLog) Record(_ context.Context, entry *models.AuditEntry) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.audit = append(r.m.audit, *entry)
+	return nil
+}
+
+func (r memAuditLog) Find(_ context.Context, q AuditQuery) ([]models.AuditEntry, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	entries := []models.AuditEntry{}
+	for _, e := range r.m.audit {
+		if q.Actor != "" && e.Actor != q.Actor {
+			continue
+		}
+		if q.Kind != "" && e.Kind != q.Kind {
+			continue
+		}
+		if !q.From.IsZero() && e.Timestamp.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !e.Timestamp.Before(q.To) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.After(entries[j].Timestamp) })
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries, nil
+}
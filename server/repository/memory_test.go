@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/triax/hub/server/models"
+)
+
+// TestMemoryNumbers_Assign は、背番号の割り当てで以前の保持者から剥奪されることを検証する。
+func TestMemoryNumbers_Assign(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+	for _, id := range []string{"U00000001", "U00000002"} {
+		if err := repo.Members().Put(ctx, &models.Member{Slack: models.SlackUser{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := repo.Numbers().Assign(ctx, 12, "U00000001"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Numbers().Assign(ctx, 12, "U00000002"); err != nil {
+		t.Fatal(err)
+	}
+
+	prev, _ := repo.Members().Get(ctx, "U00000001")
+	if prev.Number != nil {
+		t.Errorf("previous holder still has number %d", *prev.Number)
+	}
+	next, _ := repo.Members().Get(ctx, "U00000002")
+	if next.Number == nil || *next.Number != 12 {
+		t.Errorf("number = %v, want 12", next.Number)
+	}
+	found, err := repo.Members().FindByNumber(ctx, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Slack.ID != "U00000002" {
+		t.Errorf("FindByNumber = %+v", found)
+	}
+}
+
+// TestMemoryMembers_Get は、未存在のメンバーで ErrNotFound を返すことを検証する。
+func TestMemoryMembers_Get(t *testing.T) {
+	if _, err := NewMemory().Members().Get(context.Background(), "U99999999"); err != ErrNotFound {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+// TestMemoryParticipations_ListByEvents は、複数の Event への回答が Event ごとにまとめて返ることを検証する。
+func TestMemoryParticipations_ListByEvents(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+	for _, k := range [][2]string{{"ev1", "U1"}, {"ev1", "U2"}, {"ev2", "U1"}, {"ev3", "U1"}} {
+		if _, err := repo.Participations().Update(ctx, k[0], k[1], func(p *models.Participation) error {
+			p.Type = models.PTJoin
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	byEvent, err := repo.Participations().ListByEvents(ctx, []string{"ev1", "ev2", "ev9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byEvent) != 2 || len(byEvent["ev1"]) != 2 || len(byEvent["ev2"]) != 1 {
+		t.Errorf("participations = %+v", byEvent)
+	}
+}
+
+// TestMemoryAttendances_ListByEvents は、複数の Event へのチェックインが Event ごとにまとめて返ることを検証する。
+func TestMemoryAttendances_ListByEvents(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+	for _, k := range [][2]string{{"ev1", "U1"}, {"ev1", "U2"}, {"ev2", "U1"}, {"ev3", "U1"}} {
+		if err := repo.Attendances().Put(ctx, &models.Attendance{EventID: k[0], MemberID: k[1]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	byEvent, err := repo.Attendances().ListByEvents(ctx, []string{"ev1", "ev2", "ev9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byEvent) != 2 || len(byEvent["ev1"]) != 2 || len(byEvent["ev2"]) != 1 {
+		t.Errorf("attendances = %+v", byEvent)
+	}
+}
@@ -0,0 +1,360 @@
+// Package repository は Datastore へのアクセスを Kind ごとのインターフェースにまとめる。
+//
+// ハンドラ（api / tasks / slackbot / controllers）は datastore.NewClient を直接呼ばず、
+// filters.GetRepositoryContext で注入された Repository を経由してデータを読み書きする。
+// 本番では NewDatastore、テストやローカル検証では NewMemory を使う。
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/triax/hub/server/models"
+)
+
+// ErrNotFound は指定 key のエンティティが存在しないことを表す。
+var ErrNotFound = errors.New("repository: entity not found")
+
+// ErrInvalidCursor はページングの cursor が解釈できないことを表す。
+var ErrInvalidCursor = errors.New("repository: invalid cursor")
+
+// Repository は Kind ごとのリポジトリを束ねる。
+type Repository interface {
+	Members() Members
+	Events() Events
+	Participations() Participations
+	Equips() Equips
+	Numbers() Numbers
+	Taping() Taping
+	Applications() Applications
+	HPProfiles() HPProfiles
+	CalendarSyncs() CalendarSyncs
+	EventTags() EventTags
+	Reminders() Reminders
+	Attendances() Attendances
+	CalendarFeeds() CalendarFeeds
+	MemberStatusChanges() MemberStatusChanges
+	DormancyProposals() DormancyProposals
+	Audit() AuditLog
+}
+
+// Members は Member（NameKey: Slack ID）を扱う。
+type Members interface {
+	Get(ctx context.Context, slackID string) (*models.Member, error)
+	// List は退部済み（Slack.Deleted）を除いたメンバーを返す。includeDeleted で全件。
+	List(ctx context.Context, includeDeleted bool) ([]models.Member, error)
+	FindByNumber(ctx context.Context, number int) ([]models.Member, error)
+	Put(ctx context.Context, member *models.Member) error
+	// Update は Member を読み込み fn を適用して書き戻す（read-modify-write をアトミックに行う）。
+	// 未存在の場合はゼロ値の Member が fn に渡される。
+	Update(ctx context.Context, slackID string, fn func(*models.Member) error) (*models.Member, error)
+}
+
+// EventQuery は Google.StartTime による Event の範囲検索条件。
+// From <= StartTime < To。ゼロ値の境界は無制限として扱う。
+type EventQuery struct {
+	From  time.Time
+	To    time.Time
+	Desc  bool // true なら StartTime の降順
+	Limit int  // 0 なら無制限（キャンセル済みを除いた後の件数）
+	// IncludeCancelled が false なら Google Calendar でキャンセルされた Event（Cancelled）を除く。
+	IncludeCancelled bool
+}
+
+// Events は Event（NameKey: Google Calendar ID）を扱う。
+type Events interface {
+	Get(ctx context.Context, id string) (*models.Event, error)
+	Find(ctx context.Context, q EventQuery) ([]models.Event, error)
+	// Page は q の条件で cursor の位置から読み、match を満たす Event を最大 q.Limit 件返す（match が nil なら全件）。
+	// q.Limit 件に達したら次のページの cursor を返す（続きが無い場合でも返すことがあり、そのときの次のページは空）。
+	// 空の cursor は先頭から読む。解釈できない cursor は ErrInvalidCursor。
+	Page(ctx context.Context, q EventQuery, cursor string, match func(models.Event) bool) ([]models.Event, string, error)
+	Put(ctx context.Context, event *models.Event) error
+	// Update は Event を読み込み fn を適用して書き戻す。未存在の場合はゼロ値が渡される。
+	Update(ctx context.Context, id string, fn func(*models.Event) error) (*models.Event, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Participations は出欠回答 Participation（NameKey: eventID_memberID）を扱う。
+type Participations interface {
+	// Get は回答を返す。未回答の場合は ErrNotFound。
+	Get(ctx context.Context, eventID, memberID string) (*models.Participation, error)
+	ListByEvent(ctx context.Context, eventID string) ([]models.Participation, error)
+	// ListByEvents は複数の Event への回答をまとめて読み、Event ID → 回答で返す（回答の無い Event は含まない）。
+	ListByEvents(ctx context.Context, eventIDs []string) (map[string][]models.Participation, error)
+	ListByMember(ctx context.Context, memberID string) ([]models.Participation, error)
+	// Update は回答を読み込み fn を適用して書き戻す（トランザクション内）。
+	// 未回答の場合は EventID / MemberID だけを埋めた Participation が fn に渡される。
+	Update(ctx context.Context, eventID, memberID string, fn func(*models.Participation) error) (*models.Participation, error)
+	// PutMulti は回答をまとめて上書きする（移行用）。
+	PutMulti(ctx context.Context, parts []*models.Participation) error
+	// DeleteByEvent は Event に対する回答をすべて削除する。
+	DeleteByEvent(ctx context.Context, eventID string) error
+}
+
+// Equips は Equip（IDKey）と、その子 Kind である Custody を扱う。
+type Equips interface {
+	// List は全 Equip を返す（ID / Key は埋めるが History は埋めない）。
+	List(ctx context.Context) ([]models.Equip, error)
+	Get(ctx context.Context, id int64) (*models.Equip, error)
+	// Create は auto-ID で Equip を作成し、equip.Key / equip.ID を埋める。
+	Create(ctx context.Context, equip *models.Equip) error
+	Put(ctx context.Context, id int64, equip *models.Equip) error
+	// Delete は Equip とその Custody をまとめて削除する。
+	Delete(ctx context.Context, id int64) error
+	// History は Custody を Timestamp の降順で返す。limit が 0 なら全件。
+	History(ctx context.Context, id int64, limit int) ([]models.Custody, error)
+	// AddCustody は各 Equip の子として Custody を追加し、Key を埋めたものを返す。
+	AddCustody(ctx context.Context, ids []int64, custody models.Custody) ([]*models.Custody, error)
+}
+
+// Numbers は背番号（NameKey: 背番号の文字列）を扱う。
+type Numbers interface {
+	List(ctx context.Context) ([]models.PlayerNumber, error)
+	Get(ctx context.Context, number int) (*models.PlayerNumber, error)
+	// Assign は背番号を playerID のメンバーへ割り当て、以前の保持者からは剥奪する。
+	Assign(ctx context.Context, number int, playerID string) (*models.PlayerNumber, error)
+	// Deprive は背番号を現在の保持者から剥奪する。
+	Deprive(ctx context.Context, number int) (*models.PlayerNumber, error)
+}
+
+// TapingQuery は Taping の検索条件。空の条件は絞り込まない。
+type TapingQuery struct {
+	MemberID      string
+	EventID       string
+	RequestedFrom int64 // ミリ秒, RequestedAt >= RequestedFrom
+	RequestedTo   int64 // ミリ秒, RequestedAt < RequestedTo
+}
+
+// Taping は TapeItem / TapingMenuItem のマスタと、Taping リクエストを扱う。
+type Taping interface {
+	ListTapeItems(ctx context.Context) ([]models.TapeItem, error)
+	// PutTapeItem は item.ID が 0 なら新規作成し、item.ID を埋める。
+	PutTapeItem(ctx context.Context, item *models.TapeItem) error
+	DeleteTapeItem(ctx context.Context, id int64) error
+
+	ListMenuItems(ctx context.Context) ([]models.TapingMenuItem, error)
+	// GetMenuItems は ids と同じ順序でメニューを返す。未存在のものはゼロ値。
+	GetMenuItems(ctx context.Context, ids []int64) ([]models.TapingMenuItem, error)
+	// PutMenuItem は item.ID が 0 なら新規作成し、item.ID を埋める。
+	PutMenuItem(ctx context.Context, item *models.TapingMenuItem) error
+	DeleteMenuItem(ctx context.Context, id int64) error
+
+	ListRequests(ctx context.Context, q TapingQuery) ([]models.Taping, error)
+	// ReplaceRequests は memberID + eventID のリクエストを tapings で置き換える。
+	// tapings に含まれないメニューの既存リクエストは削除される。
+	ReplaceRequests(ctx context.Context, memberID, eventID string, tapings []*models.Taping) error
+}
+
+// Applications は入部・退部などの申請（NameKey: 申請 ID）を扱う。
+type Applications interface {
+	// Get は申請を返す。未存在の場合は ErrNotFound。
+	Get(ctx context.Context, id string) (*models.Application, error)
+	Put(ctx context.Context, id string, app *models.Application) error
+	// List は CreatedAt の降順で申請と ID を返す。appType が空なら全種別。
+	List(ctx context.Context, appType string) ([]*models.Application, []string, error)
+}
+
+// HPProfiles は MemberHPProfile（NameKey: Slack ID）を扱う。
+type HPProfiles interface {
+	// Get はプロフィールを返す。未存在の場合は空のプロフィールを返す。
+	Get(ctx context.Context, slackID string) (*models.MemberHPProfile, error)
+	// GetMulti は members と同じ順序でプロフィールを返す。未存在は空、取得失敗は nil。
+	GetMulti(ctx context.Context, members []models.Member) ([]*models.MemberHPProfile, error)
+	Put(ctx context.Context, slackID string, profile *models.MemberHPProfile) error
+}
+
+// CalendarSyncs は Google Calendar の同期状態 CalendarSync（NameKey: Calendar ID）を扱う。
+type CalendarSyncs interface {
+	// Get は同期状態を返す。一度も同期していない場合は ErrNotFound。
+	Get(ctx context.Context, calendarID string) (*models.CalendarSync, error)
+	Put(ctx context.Context, state *models.CalendarSync) error
+}
+
+// EventTags はタグの定義 EventTagRule（NameKey: タグ名）を扱う。
+type EventTags interface {
+	// List は保存されている定義を Order の昇順で返す。1 件も無ければ空（既定値は LoadEventTagRules）。
+	List(ctx context.Context) ([]models.EventTagRule, error)
+	// Get は定義を返す。未存在の場合は ErrNotFound。
+	Get(ctx context.Context, tag models.EventTag) (*models.EventTagRule, error)
+	Put(ctx context.Context, rule *models.EventTagRule) error
+	Delete(ctx context.Context, tag models.EventTag) error
+}
+
+// Reminders はリマインダの計画と送信状態 Reminder（NameKey: models.ReminderKeyName）を扱う。
+type Reminders interface {
+	ListByEvent(ctx context.Context, eventID string) ([]models.Reminder, error)
+	// ListDue は送信待ち（RSPlanned）で FireAt <= now のものを FireAt の昇順で返す。
+	ListDue(ctx context.Context, now time.Time) ([]models.Reminder, error)
+	Put(ctx context.Context, reminder *models.Reminder) error
+	// Update は Reminder を読み込み fn を適用して書き戻す（トランザクション内）。
+	// 未存在の場合は ErrNotFound。fn がエラーを返した場合は書き戻さずにそのエラーを返す。
+	Update(ctx context.Context, keyName string, fn func(*models.Reminder) error) (*models.Reminder, error)
+}
+
+// Attendances は Attendance（NameKey: eventID + "_" + memberID）と、
+// セルフチェックイン用の CheckInCode（NameKey: eventID）を扱う。
+type Attendances interface {
+	// Get はチェックインを返す。未チェックインの場合は ErrNotFound。
+	Get(ctx context.Context, eventID, memberID string) (*models.Attendance, error)
+	ListByEvent(ctx context.Context, eventID string) ([]models.Attendance, error)
+	// ListByEvents は複数の Event へのチェックインをまとめて読み、Event ID → チェックインで返す（チェックインの無い Event は含まない）。
+	ListByEvents(ctx context.Context, eventIDs []string) (map[string][]models.Attendance, error)
+	ListByMember(ctx context.Context, memberID string) ([]models.Attendance, error)
+	Put(ctx context.Context, att *models.Attendance) error
+	Delete(ctx context.Context, eventID, memberID string) error
+	// GetCode はイベントのチェックインコードを返す。未発行の場合は ErrNotFound。
+	GetCode(ctx context.Context, eventID string) (*models.CheckInCode, error)
+	PutCode(ctx context.Context, code *models.CheckInCode) error
+	// UpdateCode はチェックインコードを読み込み fn を適用して書き戻す（トランザクション内）。
+	// 未発行の場合は ErrNotFound。fn がエラーを返した場合は書き戻さずにそのエラーを返す。
+	UpdateCode(ctx context.Context, eventID string, fn func(*models.CheckInCode) error) (*models.CheckInCode, error)
+}
+
+// CalendarFeeds は個人用の iCalendar フィードのトークン CalendarFeed（NameKey: Token）を扱う。
+type CalendarFeeds interface {
+	// Get は未登録のトークンなら ErrNotFound。
+	Get(ctx context.Context, token string) (*models.CalendarFeed, error)
+	ListByMember(ctx context.Context, memberID string) ([]models.CalendarFeed, error)
+	Put(ctx context.Context, feed *models.CalendarFeed) error
+	Delete(ctx context.Context, token string) error
+}
+
+// MemberStatusChanges は参加状態の変更履歴 MemberStatusChange（NameKey: models.MemberStatusChange.KeyName）を扱う。
+type MemberStatusChanges interface {
+	// ListByMember は EffectiveAt の昇順で返す。
+	ListByMember(ctx context.Context, memberID string) ([]models.MemberStatusChange, error)
+	// ListSince は全メンバーの EffectiveAt が since 以降の変更を EffectiveAt の昇順で返す。
+	ListSince(ctx context.Context, since time.Time) ([]models.MemberStatusChange, error)
+	Add(ctx context.Context, change *models.MemberStatusChange) error
+}
+
+// DormancyProposals は休眠の提案 DormancyProposal（NameKey: MemberID）を扱う。
+type DormancyProposals interface {
+	// Get は提案が無ければ ErrNotFound。
+	Get(ctx context.Context, memberID string) (*models.DormancyProposal, error)
+	Put(ctx context.Context, proposal *models.DormancyProposal) error
+	// Update は提案を読み込み fn を適用して書き戻す（トランザクション内）。
+	// 未存在の場合は ErrNotFound。fn がエラーを返した場合は書き戻さずにそのエラーを返す。
+	Update(ctx context.Context, memberID string, fn func(*models.DormancyProposal) error) (*models.DormancyProposal, error)
+	Delete(ctx context.Context, memberID string) error
+}
+
+// AuditQuery は AuditEntry の検索条件。空の条件は絞り込まない。
+// From <= Timestamp < To。
+type AuditQuery struct {
+	Actor string
+	Kind  string
+	From  time.Time
+	To    time.Time
+	Limit int // 0 なら無制限
+}
+
+// AuditLog は書き込みの監査記録 AuditEntry（IDKey）を扱う。
+type AuditLog interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+	// Find は Timestamp の降順で返す。
+	Find(ctx context.Context, q AuditQuery) ([]models.AuditEntry, error)
+}
+
+// FindEventsBetween は指定範囲に開始する Event を StartTime の降順で最大 10 件返す。
+//
+// timebound[0] == いつから
+// timebound[1] == いつまで
+// 省略時は「今から 24 時間以内」。
+func FindEventsBetween(ctx context.Context, events Events, timebound ...time.Time) ([]models.Event, error) {
+	if len(timebound) == 0 {
+		timebound = []time.Time{time.Now()}
+	}
+	if len(timebound) == 1 {
+		timebound = append(timebound, timebound[0].Add(24*time.Hour))
+	}
+	from := timebound[0]
+	to := timebound[1]
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid time-bound")
+	}
+	return events.Find(ctx, EventQuery{From: from, To: to, Desc: true, Limit: 10})
+}
+
+// LoadEventTagRules は保存されているタグの定義を返す。1 件も無ければ models.DefaultEventTagRules。
+func LoadEventTagRules(ctx context.Context, tags EventTags) ([]models.EventTagRule, error) {
+	rules, err := tags.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return models.DefaultEventTagRules(), nil
+	}
+	return rules, nil
+}
+
+// GetAllMembersAsDict は退部済みを除いた全メンバーを Slack ID の辞書で返す。
+func GetAllMembersAsDict(ctx context.Context, members Members) (map[string]models.Member, error) {
+	all, err := members.List(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %v", err)
+	}
+	return models.MembersToDict(all), nil
+}
+
+// ErrStatusUnchanged は SetMemberStatus で参加状態が変わらなかったことを表す。
+var ErrStatusUnchanged = errors.New("repository: member status is unchanged")
+
+// SetMemberStatus は Members().Update の fn の中で m の参加状態を to にし、変更前の状態を返す（空は MSActive とみなす）。
+// 状態が変わらなければ m を変えずに ErrStatusUnchanged を返す。
+func SetMemberStatus(m *models.Member, to models.MemberStatus) (models.MemberStatus, error) {
+	from := m.Status
+	if from == "" {
+		from = models.MSActive
+	}
+	if from == to {
+		return from, ErrStatusUnchanged
+	}
+	m.Status = to
+	return from, nil
+}
+
+// RecordMemberStatusChange は from から to への参加状態の変更履歴 MemberStatusChange を記録する。
+// 変更した人は ctx の操作者（WithActor）。effectiveAt がゼロなら now を有効日とする。
+func RecordMemberStatusChange(ctx context.Context, repo Repository, memberID string, from, to models.MemberStatus, effectiveAt time.Time, reason string, now time.Time) error {
+	if effectiveAt.IsZero() {
+		effectiveAt = now
+	}
+	change := &models.MemberStatusChange{
+		MemberID:    memberID,
+		From:        from,
+		To:          to,
+		EffectiveAt: effectiveAt,
+		Reason:      reason,
+		ChangedBy:   ActorFrom(ctx),
+		CreatedAt:   now,
+	}
+	if err := repo.MemberStatusChanges().Add(ctx, change); err != nil {
+		return fmt.Errorf("failed to record status change: %v", err)
+	}
+	return nil
+}
+
+// ChangeMemberStatus はメンバーの参加状態を to に変更し、変更履歴を記録する（RecordMemberStatusChange）。
+// 状態が変わらない場合は何も書き込まない。メンバーが存在しなければ ErrNotFound。
+func ChangeMemberStatus(ctx context.Context, repo Repository, memberID string, to models.MemberStatus, effectiveAt time.Time, reason string, now time.Time) (*models.Member, error) {
+	var from models.MemberStatus
+	member, err := repo.Members().Update(ctx, memberID, func(m *models.Member) error {
+		if m.Slack.ID == "" {
+			return ErrNotFound
+		}
+		var err error
+		from, err = SetMemberStatus(m, to)
+		return err
+	})
+	if errors.Is(err, ErrStatusUnchanged) {
+		return repo.Members().Get(ctx, memberID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return member, RecordMemberStatusChange(ctx, repo, memberID, from, to, effectiveAt, reason, now)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/triax/hub/server/models"
+)
+
+// TestChangeMemberStatus は、参加状態が変わったときだけメンバーと変更履歴を書き込み、
+// 存在しないメンバーを作らないことを検証する。
+func TestChangeMemberStatus(t *testing.T) {
+	ctx := WithActor(context.Background(), "UADMIN")
+	repo := WithAudit(NewMemory())
+	if err := repo.Members().Put(ctx, &models.Member{Slack: models.SlackUser{ID: "U00000001"}}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	written := func() int {
+		entries, err := repo.Audit().Find(ctx, AuditQuery{Kind: models.KindMember})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+	before := written()
+
+	// 空の状態は MSActive とみなすので、MSActive への変更は何も書き込まない
+	if m, err := ChangeMemberStatus(ctx, repo, "U00000001", models.MSActive, time.Time{}, "", now); err != nil || m.Slack.ID != "U00000001" {
+		t.Fatalf("unchanged: %+v, %v", m, err)
+	}
+	if n := written(); n != before {
+		t.Errorf("audit entries = %d, want %d", n, before)
+	}
+
+	m, err := ChangeMemberStatus(ctx, repo, "U00000001", models.MSInactive, time.Time{}, "休部", now)
+	if err != nil || m.Status != models.MSInactive {
+		t.Fatalf("changed: %+v, %v", m, err)
+	}
+	if n := written(); n != before+1 {
+		t.Errorf("audit entries = %d, want %d", n, before+1)
+	}
+	changes, _ := repo.MemberStatusChanges().ListByMember(ctx, "U00000001")
+	if len(changes) != 1 || changes[0].From != models.MSActive || changes[0].ChangedBy != "UADMIN" || !changes[0].EffectiveAt.Equal(now) {
+		t.Errorf("changes = %+v", changes)
+	}
+
+	if _, err := ChangeMemberStatus(ctx, repo, "U99999999", models.MSInactive, time.Time{}, "", now); err != ErrNotFound {
+		t.Errorf("unknown member: %v", err)
+	}
+	if _, err := repo.Members().Get(ctx, "U99999999"); err != ErrNotFound {
+		t.Errorf("unknown member was created: %v", err)
+	}
+}
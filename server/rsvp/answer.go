@@ -0,0 +1,161 @@
+// Package rsvp は出欠回答の書き込みと、出欠確認のメッセージを Hub の API・Slack のボタン・cron タスクで共有する。
+package rsvp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// ErrReasonRequired は出欠回答の締め切り後の回答に理由が無いことを表す。
+var ErrReasonRequired = errors.New("reason is required after the RSVP deadline")
+
+// ErrInvalidType は回答として受け付けない種類（models.ParticipationType.Answerable でないもの）を表す。
+var ErrInvalidType = errors.New("invalid participation type")
+
+// ErrEventUnavailable はキャンセル済みか、メンバーが閲覧できない（models.Event.VisibleTo）イベントへの回答を表す。
+var ErrEventUnavailable = errors.New("event is cancelled or not visible to the member")
+
+// Answer は member の ev への出欠を回答し、回答前の Participation を返す。
+//
+// キャンセル済みのイベントと member が閲覧できないイベントには回答できず（ErrEventUnavailable）、
+// 種類は参加・遅参・早退・欠席のいずれか（ErrInvalidType）。
+// 出欠回答の締め切り（Event.RSVPDeadlineAt）を過ぎた回答には reason が必要で（無ければ ErrReasonRequired）、
+// 遅い回答として記録し、回答が変わった場合はポジションのコーチに連絡する。
+func Answer(ctx context.Context, repo repository.Repository, member models.Member, ev models.Event, typ models.ParticipationType, params map[string]interface{}, reason string, now time.Time) (models.Participation, error) {
+	return answer(ctx, repo, member, ev, typ, params, reason, "", now)
+}
+
+// AnswerFor はスタッフ（proxy）が member の代わりに出欠を回答する。
+// 誰が回答したかを Participation.AnsweredBy に残し、記録した内容を member に DM する。
+// 締め切りの扱いは Answer と同じ。
+func AnswerFor(ctx context.Context, repo repository.Repository, proxy, member models.Member, ev models.Event, typ models.ParticipationType, params map[string]interface{}, reason string, now time.Time) (models.Participation, error) {
+	prev, err := answer(ctx, repo, member, ev, typ, params, reason, proxy.Slack.ID, now)
+	if err != nil {
+		return prev, err
+	}
+	if err := notifyProxyAnswer(ctx, proxy, member, ev, typ, params); err != nil {
+		log.Println("[ERROR]", 4010, err.Error())
+	}
+	return prev, nil
+}
+
+func answer(ctx context.Context, repo repository.Repository, member models.Member, ev models.Event, typ models.ParticipationType, params map[string]interface{}, reason, answeredBy string, now time.Time) (models.Participation, error) {
+	if ev.Cancelled || !ev.VisibleTo(member) {
+		return models.Participation{}, ErrEventUnavailable
+	}
+	if !typ.Answerable() {
+		return models.Participation{}, ErrInvalidType
+	}
+	late := ev.IsRSVPClosed(now)
+	reason = strings.TrimSpace(reason)
+	if late && reason == "" {
+		return models.Participation{}, ErrReasonRequired
+	}
+
+	// 回答は event+member ごとのエンティティをトランザクション内で更新するので、
+	// 同時に回答しても他メンバーの回答を上書きしない。
+	var prev models.Participation
+	if _, err := repo.Participations().Update(ctx, ev.Google.ID, member.Slack.ID, func(p *models.Participation) error {
+		prev = *p
+		var err error
+		if late {
+			err = p.AnswerLate(typ, params, now, reason)
+		} else {
+			err = p.Answer(typ, params, now)
+		}
+		p.AnsweredBy = answeredBy
+		return err
+	}); err != nil {
+		return prev, err
+	}
+
+	if late && prev.Type != typ {
+		if err := notifyLateChange(ctx, repo, member, ev, prev.Type, typ, reason); err != nil {
+			log.Println("[ERROR]", 4009, err.Error())
+		}
+	}
+	return prev, nil
+}
+
+// notifyProxyAnswer は代理で記録された回答を本人に DM する。
+func notifyProxyAnswer(ctx context.Context, proxy, member models.Member, e models.Event, typ models.ParticipationType, params map[string]interface{}) error {
+	api := server.NewSlackClient()
+	ch, _, _, err := api.OpenConversationContext(ctx, &slack.OpenConversationParameters{Users: []string{member.Slack.ID}})
+	if err != nil {
+		return fmt.Errorf("OpenConversation %s: %w", member.Slack.ID, err)
+	}
+	answer := typ.String()
+	if t, ok := params["time"].(string); ok && t != "" {
+		answer += " " + t
+	}
+	start := e.Google.Start().In(server.ServiceLocation)
+	evURL := fmt.Sprintf("%s/events/%s", server.HubBaseURL(), e.Google.ID)
+	text := fmt.Sprintf("%s さんが <%s|%s %s> の出欠を *%s* で代理回答しました。違っている場合は Hub から回答し直してください。",
+		proxy.Name(), evURL, start.Format("2006/01/02"), e.Google.Title, answer)
+	_, _, err = api.PostMessageContext(ctx, ch.ID, slack.MsgOptionText(text, false))
+	return err
+}
+
+// notifyLateChange は締め切り後の出欠変更を、回答したメンバーと同じポジションのコーチへ DM する。
+// コーチが見つからなければポジションのチャンネルへ、ポジションが分からなければ #practice へ投稿する。
+func notifyLateChange(ctx context.Context, repo repository.Repository, m models.Member, e models.Event, prev, next models.ParticipationType, reason string) error {
+	msg := buildLateChangeMessage(m, e, prev, next, reason)
+	api := server.NewSlackClient()
+
+	group, ok := m.PositionGroup()
+	if !ok {
+		_, _, err := api.PostMessageContext(ctx, "#practice", msg)
+		return err
+	}
+
+	members, err := repo.Members().List(ctx, false)
+	if err != nil {
+		return err
+	}
+	coaches := []string{}
+	for _, c := range members {
+		if c.Slack.ID == m.Slack.ID || !c.IsCoach() {
+			continue
+		}
+		if g, ok := c.PositionGroup(); ok && g.Name == group.Name {
+			coaches = append(coaches, c.Slack.ID)
+		}
+	}
+	if len(coaches) == 0 {
+		_, _, err := api.PostMessageContext(ctx, "#"+group.Channel, msg)
+		return err
+	}
+
+	errs := []error{}
+	for _, uid := range coaches {
+		ch, _, _, err := api.OpenConversationContext(ctx, &slack.OpenConversationParameters{Users: []string{uid}})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("OpenConversation %s: %w", uid, err))
+			continue
+		}
+		if _, _, err := api.PostMessageContext(ctx, ch.ID, msg); err != nil {
+			errs = append(errs, fmt.Errorf("PostMessage %s: %w", uid, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func buildLateChangeMessage(m models.Member, e models.Event, p, n models.ParticipationType, reason string) slack.MsgOption {
+	start := e.Google.Start().In(server.ServiceLocation)
+	if p.Unanswered() {
+		p = models.PTUnanswered
+	}
+	return slack.MsgOptionBlocks(
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, start.Format("2006/01/02 ")+e.Google.Title+"（締め切り後の変更）", false, false)),
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s ⇒ %s* %s\n理由: %s", p, n, m.Name(), reason), false, false), nil, nil),
+	)
+}
@@ -0,0 +1,130 @@
+package rsvp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+var summaryTemplate = template.Must(template.New("x").Parse(
+	`参加:		{{len (index . "join")}}
+不参加:	{{len (index . "absent")}}
+未回答:	{{len (index . "unanswered")}}`))
+
+// Summary は回答が期待されるメンバーの出欠の内訳（"join" / "absent" / "unanswered"）。
+// 遅参・早退は "join" に含める。
+type Summary map[string][]models.Member
+
+// Summarize は ev への出欠を集計する。
+func Summarize(ctx context.Context, repo repository.Repository, ev models.Event) (Summary, error) {
+	parts, err := repo.Participations().ListByEvent(ctx, ev.Google.ID)
+	if err != nil {
+		log.Println("[ERROR]", 4004, err.Error())
+		return nil, err
+	}
+	participations := models.ParticipationsOf(parts)
+
+	members, err := repo.Members().List(ctx, false)
+	if err != nil {
+		log.Println("[ERROR]", 4005, err.Error())
+		return nil, err
+	}
+
+	x := Summary{
+		"join":       {},
+		"absent":     {},
+		"unanswered": {},
+	}
+
+	for _, member := range members {
+		if !member.IsExpectedToRSVP() {
+			continue
+		}
+		if p, ok := participations[member.Slack.ID]; ok {
+			switch p.Type {
+			case models.PTJoin, models.PTJoinLate, models.PTLeaveEarly:
+				x["join"] = append(x["join"], member)
+			case models.PTAbsent:
+				x["absent"] = append(x["absent"], member)
+			default:
+				x["join"] = append(x["join"], member)
+			}
+		} else {
+			x["unanswered"] = append(x["unanswered"], member)
+		}
+	}
+	return x, nil
+}
+
+// ActionPrefix は出欠回答ボタンの action_id の接頭辞（Bot.Shortcuts の振り分けに使う）。
+const ActionPrefix = "rsvp_answer/"
+
+// answerButtons は出欠確認のメッセージに並べるボタン。
+var answerButtons = []struct {
+	Type  models.ParticipationType
+	Style slack.Style
+}{
+	{models.PTJoin, slack.StylePrimary},
+	{models.PTJoinLate, slack.StyleDefault},
+	{models.PTLeaveEarly, slack.StyleDefault},
+	{models.PTAbsent, slack.StyleDanger},
+}
+
+// ActionID は ev に typ で回答するボタンの action_id を返す。
+func ActionID(eventID string, typ models.ParticipationType) string {
+	return ActionPrefix + "?" + url.Values{"event": {eventID}, "type": {string(typ)}}.Encode()
+}
+
+// ParseActionID は ActionID の逆。出欠回答のボタンでなければ false。
+func ParseActionID(actionID string) (string, models.ParticipationType, bool) {
+	if !strings.HasPrefix(actionID, ActionPrefix) {
+		return "", "", false
+	}
+	u, err := url.Parse(actionID)
+	if err != nil {
+		return "", "", false
+	}
+	q := u.Query()
+	typ := models.ParticipationType(q.Get("type"))
+	switch typ {
+	case models.PTJoin, models.PTJoinLate, models.PTLeaveEarly, models.PTAbsent:
+	default:
+		return "", "", false
+	}
+	return q.Get("event"), typ, q.Get("event") != ""
+}
+
+// SummaryBlocks は出欠状況のメッセージを返す。ボタンから直接回答でき、回答のたびに書き換える。
+func SummaryBlocks(ev models.Event, x Summary) ([]slack.Block, error) {
+	evURL := fmt.Sprintf("%s/events/%s", server.HubBaseURL(), ev.Google.ID)
+	link := fmt.Sprintf("<%s|:triax::football::spiral_calendar_pad: %s>", evURL, ev.Google.Title)
+	text := bytes.NewBuffer(nil)
+	if err := summaryTemplate.Execute(text, x); err != nil {
+		log.Println("[ERROR]", 4006, err.Error())
+		return nil, err
+	}
+
+	buttons := []slack.BlockElement{}
+	for _, b := range answerButtons {
+		button := slack.NewButtonBlockElement(ActionID(ev.Google.ID, b.Type), string(b.Type),
+			slack.NewTextBlockObject(slack.PlainTextType, b.Type.String(), false, false))
+		button.Style = b.Style
+		buttons = append(buttons, button)
+	}
+
+	return []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, ev.Google.Title, false, false)),
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, text.String(), false, false), nil, nil),
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, link, false, false), nil, nil),
+		slack.NewActionBlock("rsvp_buttons", buttons...),
+	}, nil
+}
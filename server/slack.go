@@ -0,0 +1,28 @@
+package server
+
+import (
+	"os"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// SlackAPIURL は Slack Web API のベース URL を返す（末尾は "/"）。
+// SLACK_API_URL が未設定なら本物の Slack（slack.APIURL）。
+// ローカル開発・テストでは cmd/slackfake を起動し、その URL を設定する。
+func SlackAPIURL() string {
+	u := os.Getenv("SLACK_API_URL")
+	if u == "" {
+		return slack.APIURL
+	}
+	if !strings.HasSuffix(u, "/") {
+		u += "/"
+	}
+	return u
+}
+
+// NewSlackClient は bot token（SLACK_BOT_USER_OAUTH_TOKEN）の Slack client を作る。
+// hub から Slack Web API を呼ぶときは slack.New ではなく必ずこれを使う（SLACK_API_URL に従うため）。
+func NewSlackClient() *slack.Client {
+	return slack.New(os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN"), slack.OptionAPIURL(SlackAPIURL()))
+}
@@ -0,0 +1,56 @@
+package slackbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server/dormancy"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// decideDormancy は休眠の提案のボタンから、提案を承認・却下する。PermManageMembers を持つ人だけが決められる。
+// 決めたら提案のメッセージを結果に書き換える。
+func (bot Bot) decideDormancy(ctx context.Context, payload slack.InteractionCallback, action slack.BlockAction) error {
+	memberID, approve, ok := dormancy.ParseActionID(action.ActionID)
+	if !ok {
+		return fmt.Errorf("invalid dormancy action: %s", action.ActionID)
+	}
+	caller, err := bot.Repository.Members().Get(ctx, payload.User.ID)
+	if err != nil || !caller.Can(models.PermManageMembers) {
+		postSlackJSON(payload.ResponseURL, "メンバーの参加状態を変更する権限がありません")
+		return nil
+	}
+
+	proposal, err := dormancy.Decide(ctx, bot.Repository, memberID, approve, time.Now())
+	switch {
+	case errors.Is(err, dormancy.ErrAlreadyDecided):
+		postSlackJSON(payload.ResponseURL, "この提案はすでに決まっています")
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		postSlackJSON(payload.ResponseURL, "この提案は見つかりません")
+		return nil
+	case err != nil:
+		return fmt.Errorf("dormancy %s: %v", memberID, err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"replace_original": true,
+		"text":             payload.Message.Text,
+		"blocks":           dormancy.DecidedBlocks(*proposal),
+	})
+	if err != nil {
+		return err
+	}
+	res, err := http.Post(payload.ResponseURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
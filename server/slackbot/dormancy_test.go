@@ -0,0 +1,86 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/triax/hub/server/dormancy"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+func TestShortcuts_DormancyButtons(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("SLACK_BOT_EVENTS_VERIFICATION_TOKEN", "verification-token")
+	replies := []map[string]interface{}{}
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body := map[string]interface{}{}
+		json.NewDecoder(req.Body).Decode(&body)
+		replies = append(replies, body)
+	}))
+	t.Cleanup(responder.Close)
+
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UADMIN", IsAdmin: true}},
+		{Slack: models.SlackUser{ID: "UPLAYER"}},
+		{Slack: models.SlackUser{ID: "UDORMANT"}, Status: models.MSActive},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.DormancyProposals().Put(ctx, &models.DormancyProposal{
+		MemberID: "UDORMANT", EventIDs: []string{"p1", "p2", "p3", "p4"}, Status: models.DPPending, ProposedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	bot := Bot{Repository: repo}
+	click := func(user string, approve bool) map[string]interface{} {
+		replies = replies[:0]
+		postShortcut(t, bot, map[string]interface{}{
+			"type":         "block_actions",
+			"user":         map[string]string{"id": user},
+			"response_url": responder.URL,
+			"actions":      []map[string]string{{"type": "button", "block_id": "dormancy_buttons", "action_id": dormancy.ActionID("UDORMANT", approve)}},
+		})
+		if len(replies) != 1 {
+			t.Fatalf("replies = %v", replies)
+		}
+		return replies[0]
+	}
+
+	// 権限が無ければ決められない
+	if reply := click("UPLAYER", true); !strings.Contains(reply["text"].(string), "権限") {
+		t.Errorf("reply = %v", reply)
+	}
+	if p, _ := repo.DormancyProposals().Get(ctx, "UDORMANT"); p.Status != models.DPPending {
+		t.Errorf("proposal = %+v", p)
+	}
+
+	// 承認すると休眠にして履歴を残し、メッセージを書き換える
+	if reply := click("UADMIN", true); reply["replace_original"] != true {
+		t.Errorf("reply = %v", reply)
+	}
+	p, _ := repo.DormancyProposals().Get(ctx, "UDORMANT")
+	if p.Status != models.DPApproved || p.DecidedBy != "UADMIN" {
+		t.Errorf("proposal = %+v", p)
+	}
+	if m, _ := repo.Members().Get(ctx, "UDORMANT"); m.Status != models.MSInactive {
+		t.Errorf("status = %s", m.Status)
+	}
+	changes, _ := repo.MemberStatusChanges().ListByMember(ctx, "UDORMANT")
+	if len(changes) != 1 || changes[0].From != models.MSActive || changes[0].To != models.MSInactive || changes[0].ChangedBy != "UADMIN" {
+		t.Errorf("changes = %+v", changes)
+	}
+
+	// 決まった提案は変えられない
+	if reply := click("UADMIN", false); !strings.Contains(reply["text"].(string), "すでに") {
+		t.Errorf("reply = %v", reply)
+	}
+}
@@ -0,0 +1,141 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/rsvp"
+)
+
+// rsvpModalCallbackID は遅参・早退の時刻や、締め切り後の理由を入力するモーダルの callback_id。
+const rsvpModalCallbackID = "rsvp_answer"
+
+// rsvpModalMetadata はモーダルの private_metadata。回答後に書き換える出欠状況のメッセージも持ち回る。
+type rsvpModalMetadata struct {
+	EventID string                   `json:"event_id"`
+	Type    models.ParticipationType `json:"type"`
+	Channel string                   `json:"channel"`
+	TS      string                   `json:"ts"`
+}
+
+// answerRSVP は出欠確認のメッセージのボタンから、押したユーザの出欠を回答する。
+// 遅参・早退（時刻が必要）と締め切り後（理由が必要）はモーダルを開き、それ以外はそのまま回答する。
+func (bot Bot) answerRSVP(ctx context.Context, payload slack.InteractionCallback, action slack.BlockAction) error {
+	eventID, typ, ok := rsvp.ParseActionID(action.ActionID)
+	if !ok {
+		return fmt.Errorf("invalid rsvp action: %s", action.ActionID)
+	}
+	ev, err := bot.Repository.Events().Get(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("rsvp event %s: %v", eventID, err)
+	}
+	if !ev.Google.Start().After(time.Now()) || ev.Cancelled {
+		postSlackJSON(payload.ResponseURL, fmt.Sprintf("「%s」は回答を受け付けていません", ev.Google.Title))
+		return nil
+	}
+
+	meta := rsvpModalMetadata{EventID: eventID, Type: typ, Channel: payload.Container.ChannelID, TS: payload.Container.MessageTs}
+	closed := ev.IsRSVPClosed(time.Now())
+	if typ == models.PTJoinLate || typ == models.PTLeaveEarly || closed {
+		_, err := bot.SlackAPI.OpenView(payload.TriggerID, buildRSVPModal(*ev, meta, closed))
+		return err
+	}
+	return bot.writeRSVP(ctx, payload.User.ID, *ev, meta, nil, "", payload.ResponseURL)
+}
+
+// submitRSVPModal はモーダルに入力された時刻・理由で出欠を回答する。
+func (bot Bot) submitRSVPModal(ctx context.Context, payload slack.InteractionCallback) error {
+	meta := rsvpModalMetadata{}
+	if err := json.Unmarshal([]byte(payload.View.PrivateMetadata), &meta); err != nil {
+		return fmt.Errorf("rsvp modal metadata: %v", err)
+	}
+	ev, err := bot.Repository.Events().Get(ctx, meta.EventID)
+	if err != nil {
+		return fmt.Errorf("rsvp event %s: %v", meta.EventID, err)
+	}
+	values := payload.View.State.Values
+	var params map[string]interface{}
+	if v, ok := values["rsvp_time"]["time"]; ok {
+		params = map[string]interface{}{"time": v.SelectedTime}
+	}
+	return bot.writeRSVP(ctx, payload.User.ID, *ev, meta, params, values["rsvp_reason"]["reason"].Value, "")
+}
+
+// writeRSVP は回答を書き込み、出欠状況のメッセージの集計を更新する。responseURL があれば本人にだけ結果を返す。
+func (bot Bot) writeRSVP(ctx context.Context, slackID string, ev models.Event, meta rsvpModalMetadata, params map[string]interface{}, reason, responseURL string) error {
+	member, err := bot.Repository.Members().Get(ctx, slackID)
+	if err != nil {
+		if responseURL != "" {
+			postSlackJSON(responseURL, "Hub のメンバーとして登録されていないため回答できません")
+		}
+		return fmt.Errorf("rsvp member %s: %v", slackID, err)
+	}
+	if _, err := rsvp.Answer(ctx, bot.Repository, *member, ev, meta.Type, params, reason, time.Now()); err != nil {
+		if responseURL != "" && errors.Is(err, rsvp.ErrReasonRequired) {
+			postSlackJSON(responseURL, "回答の締め切りを過ぎているため、理由の入力が必要です")
+		}
+		if errors.Is(err, rsvp.ErrEventUnavailable) {
+			if responseURL != "" {
+				postSlackJSON(responseURL, fmt.Sprintf("「%s」は回答を受け付けていません", ev.Google.Title))
+			}
+			return nil
+		}
+		return err
+	}
+	if responseURL != "" {
+		postSlackJSON(responseURL, fmt.Sprintf("「%s」に *%s* で回答しました", ev.Google.Title, meta.Type))
+	}
+	return bot.updateRSVPSummary(ctx, ev, meta.Channel, meta.TS)
+}
+
+// updateRSVPSummary は出欠状況のメッセージの集計を最新にする。
+func (bot Bot) updateRSVPSummary(ctx context.Context, ev models.Event, channel, ts string) error {
+	if channel == "" || ts == "" {
+		return nil
+	}
+	x, err := rsvp.Summarize(ctx, bot.Repository, ev)
+	if err != nil {
+		return err
+	}
+	blocks, err := rsvp.SummaryBlocks(ev, x)
+	if err != nil {
+		return err
+	}
+	_, _, _, err = bot.SlackAPI.UpdateMessage(channel, ts, slack.MsgOptionText(ev.Google.Title, false), slack.MsgOptionBlocks(blocks...))
+	return err
+}
+
+func buildRSVPModal(ev models.Event, meta rsvpModalMetadata, closed bool) slack.ModalViewRequest {
+	metadata, _ := json.Marshal(meta)
+	start := ev.Google.Start().In(server.ServiceLocation)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("*%s*\n%s", ev.Google.Title, start.Format("2006/01/02 15:04")), false, false), nil, nil),
+	}
+	if meta.Type == models.PTJoinLate || meta.Type == models.PTLeaveEarly {
+		picker := slack.NewTimePickerBlockElement("time")
+		picker.InitialTime = start.Format("15:04")
+		blocks = append(blocks, slack.NewInputBlock("rsvp_time",
+			slack.NewTextBlockObject(slack.PlainTextType, meta.Type.String()+"の時刻（頃）", false, false), nil, picker))
+	}
+	if closed {
+		input := slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject(slack.PlainTextType, "体調不良のため、など", false, false), "reason")
+		blocks = append(blocks, slack.NewInputBlock("rsvp_reason",
+			slack.NewTextBlockObject(slack.PlainTextType, "締め切り後の変更の理由（コーチに連絡されます）", false, false), nil, input))
+	}
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      rsvpModalCallbackID,
+		PrivateMetadata: string(metadata),
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, "出欠回答: "+meta.Type.String(), false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, "回答する", false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "キャンセル", false, false),
+		Blocks:          slack.Blocks{BlockSet: blocks},
+	}
+}
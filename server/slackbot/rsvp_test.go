@@ -0,0 +1,124 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+	"github.com/triax/hub/server/rsvp"
+	"github.com/triax/hub/slackfake"
+)
+
+func postShortcut(t *testing.T, bot Bot, payload map[string]interface{}) {
+	t.Helper()
+	payload["token"] = "verification-token"
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/slack/shortcuts", strings.NewReader(url.Values{"payload": {string(b)}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	bot.Shortcuts(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
+
+func TestShortcuts_RSVPButtons(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("SLACK_BOT_EVENTS_VERIFICATION_TOKEN", "verification-token")
+	fake := slackfake.New(slackfake.DefaultTeam, nil)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+	replies := []string{}
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body := map[string]string{}
+		json.NewDecoder(req.Body).Decode(&body)
+		replies = append(replies, body["text"])
+	}))
+	t.Cleanup(responder.Close)
+
+	repo := repository.NewMemory()
+	for _, id := range []string{"UPLAYER", "UOTHER"} {
+		if err := repo.Members().Put(ctx, &models.Member{Slack: models.SlackUser{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ev := models.Event{Google: models.GoogleEvent{ID: "ev001", Title: "#練習", StartTime: time.Now().Add(5 * 24 * time.Hour).UnixMilli()}}
+	if err := repo.Events().Put(ctx, &ev); err != nil {
+		t.Fatal(err)
+	}
+
+	api := slack.New("xoxb-test", slack.OptionAPIURL(ts.URL+"/api/"))
+	bot := Bot{SlackAPI: api, Repository: repo}
+	x, err := rsvp.Summarize(ctx, repo, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := rsvp.SummaryBlocks(ev, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel, msgTS, err := api.PostMessage("#general", slack.MsgOptionBlocks(blocks...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	click := func(typ models.ParticipationType) {
+		postShortcut(t, bot, map[string]interface{}{
+			"type":         "block_actions",
+			"user":         map[string]string{"id": "UPLAYER"},
+			"trigger_id":   "trigger-" + string(typ),
+			"response_url": responder.URL,
+			"container":    map[string]string{"type": "message", "channel_id": channel, "message_ts": msgTS},
+			"actions":      []map[string]string{{"type": "button", "block_id": "rsvp_buttons", "action_id": rsvp.ActionID("ev001", typ), "value": string(typ)}},
+		})
+	}
+
+	// 出席はそのまま回答し、集計を書き換える
+	click(models.PTJoin)
+	if p, err := repo.Participations().Get(ctx, "ev001", "UPLAYER"); err != nil || p.Type != models.PTJoin {
+		t.Fatalf("participation = %+v, %v", p, err)
+	}
+	msgs := fake.Messages("general")
+	if len(msgs) != 1 || !msgs[0].Updated || !strings.Contains(string(msgs[0].Blocks), `参加:\t\t1`) || !strings.Contains(string(msgs[0].Blocks), `未回答:\t1`) {
+		t.Errorf("summary = %s", msgs[0].Blocks)
+	}
+	if len(replies) != 1 || !strings.Contains(replies[0], "出席") {
+		t.Errorf("replies = %v", replies)
+	}
+
+	// 遅参は時刻を入力するモーダルを開き、送信されたら回答する
+	click(models.PTJoinLate)
+	views := fake.Views()
+	if len(views) != 1 || views[0].TriggerID != "trigger-join_late" || views[0].View.CallbackID != rsvpModalCallbackID {
+		t.Fatalf("views = %+v", views)
+	}
+	if p, _ := repo.Participations().Get(ctx, "ev001", "UPLAYER"); p.Type != models.PTJoin {
+		t.Errorf("join_late must not be written before the modal is submitted: %+v", p)
+	}
+	postShortcut(t, bot, map[string]interface{}{
+		"type": "view_submission",
+		"user": map[string]string{"id": "UPLAYER"},
+		"view": map[string]interface{}{
+			"callback_id":      rsvpModalCallbackID,
+			"private_metadata": views[0].View.PrivateMetadata,
+			"state": map[string]interface{}{"values": map[string]interface{}{
+				"rsvp_time": map[string]interface{}{"time": map[string]string{"type": "timepicker", "selected_time": "10:30"}},
+			}},
+		},
+	})
+	p, _ := repo.Participations().Get(ctx, "ev001", "UPLAYER")
+	if p.Type != models.PTJoinLate || p.Params["time"] != "10:30" {
+		t.Errorf("participation after modal = %+v", p)
+	}
+}
@@ -10,12 +10,15 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/otiai10/openaigo"
 	"github.com/slack-go/slack"
+	"github.com/triax/hub/server/dormancy"
 	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+	"github.com/triax/hub/server/rsvp"
 )
 
 // postSlackJSON はSlackのresponse_urlに安全にJSONを送信する
@@ -45,7 +48,8 @@ func (bot Bot) Shortcuts(w http.ResponseWriter, req *http.Request) {
 
 	w.WriteHeader(200)
 
-	ctx := context.Background()
+	// ボタン等を押した Slack ユーザを書き込みの操作者として記録する
+	ctx := repository.WithActor(context.Background(), payload.User.ID)
 
 	var err error
 	switch { // TODO: 言語コードは2文字に統一したい
@@ -55,11 +59,21 @@ func (bot Bot) Shortcuts(w http.ResponseWriter, req *http.Request) {
 		err = bot.Translate(ctx, payload, "JA")
 	case payload.CallbackID == "translate_to_fra" || payload.CallbackID == "translate_to_fr":
 		err = bot.Translate(ctx, payload, "FR")
+	case payload.Type == slack.InteractionTypeViewSubmission && payload.View.CallbackID == rsvpModalCallbackID:
+		err = bot.submitRSVPModal(ctx, payload)
 	case payload.Type == "block_actions":
 		if len(payload.ActionCallback.BlockActions) == 0 {
 			return
 		}
 		action := payload.ActionCallback.BlockActions[0]
+		if strings.HasPrefix(action.ActionID, rsvp.ActionPrefix) {
+			err = bot.answerRSVP(ctx, payload, *action)
+			break
+		}
+		if strings.HasPrefix(action.ActionID, dormancy.ActionPrefix) {
+			err = bot.decideDormancy(ctx, payload, *action)
+			break
+		}
 		u, err := url.Parse(action.ActionID)
 		if err != nil {
 			fmt.Println(err)
@@ -69,29 +83,17 @@ func (bot Bot) Shortcuts(w http.ResponseWriter, req *http.Request) {
 		mid := action.SelectedUser
 		// ev := u.Query().Get("ev")
 
-		ctx := context.Background()
-		member, err := models.GetMemberInfoByCache(ctx, mid)
+		member, err := bot.Repository.Members().Get(ctx, mid)
 		if err != nil {
 			fmt.Println(err) // TODO: Error log
 			return
 		}
 
-		client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-		if err != nil {
-			fmt.Println(err) // TODO: Error log
-			return
-		}
-		defer client.Close()
-		custody := &models.Custody{
+		eidnumeric, _ := strconv.Atoi(eid)
+		if _, err = bot.Repository.Equips().AddCustody(ctx, []int64{int64(eidnumeric)}, models.Custody{
 			MemberID:  mid,
 			Timestamp: time.Now().Unix() * 1000,
-		}
-		eidnumeric, _ := strconv.Atoi(eid)
-		if _, err = client.Put(ctx, datastore.IncompleteKey(
-			models.KindCustody,
-			datastore.IDKey(models.KindEquip, int64(eidnumeric), nil)),
-			custody,
-		); err != nil {
+		}); err != nil {
 			fmt.Println(err) // TODO: Error log
 			return
 		}
@@ -13,11 +13,11 @@ import (
 	"strings"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/triax/hub/server"
 	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
 
 	"github.com/otiai10/largo"
 	"github.com/otiai10/openaigo"
@@ -42,6 +42,8 @@ type SlackAPI interface {
 	GetConversationInfo(input *slack.GetConversationInfoInput) (*slack.Channel, error)
 	GetConversationReplies(params *slack.GetConversationRepliesParameters) (msgs []slack.Message, hasMore bool, nextCursor string, err error)
 	OpenConversation(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error)
+	UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+	OpenView(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error)
 }
 
 // This interface represents *openaigo.Client.
@@ -53,6 +55,7 @@ type Bot struct {
 	VerificationToken string
 	SlackAPI          SlackAPI
 	ChatGPT           ChatGPT
+	Repository        repository.Repository
 }
 
 type (
@@ -191,7 +194,6 @@ func (bot Bot) onMessage(_ *http.Request, _ http.ResponseWriter, payload Payload
 
 	// {{{
 	user, err := bot.SlackAPI.GetUserInfo(event.User)
-	// member, err := models.GetMemberInfoByCache(ctx, event.User)
 	if err == nil {
 		opts = append(opts, slack.MsgOptionUsername(user.Profile.DisplayName), slack.MsgOptionIconURL(user.Profile.Image192))
 	} else {
@@ -305,17 +307,10 @@ func (bot Bot) onMentionReadCheck(_ *http.Request, _ http.ResponseWriter, event
 
 func (bot Bot) onMentionEquipCheck(req *http.Request, _ http.ResponseWriter, event slackevents.AppMentionEvent) {
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	equips, err := bot.Repository.Equips().List(ctx)
 	if err != nil {
 		return
 	}
-	defer client.Close()
-
-	equips := []models.Equip{}
-	query := datastore.NewQuery(models.KindEquip)
-	if _, err := client.GetAll(ctx, query, &equips); err != nil && !models.IsFiledMismatch(err) {
-		return
-	}
 
 	summary := struct {
 		Unmanaged  []models.Equip
@@ -326,10 +321,8 @@ func (bot Bot) onMentionEquipCheck(req *http.Request, _ http.ResponseWriter, eve
 	}
 
 	for i, e := range equips {
-		equips[i].ID = e.Key.ID
 		// 最新のHistoryだけ収集する
-		query := datastore.NewQuery(models.KindCustody).Ancestor(e.Key).Order("-Timestamp").Limit(1)
-		client.GetAll(ctx, query, &equips[i].History) // エラーは無視してよい
+		equips[i].History, _ = bot.Repository.Equips().History(ctx, e.ID, 1) // エラーは無視してよい
 		// Summarizeする
 		if len(equips[i].History) == 0 {
 			summary.Unmanaged = append(summary.Unmanaged, equips[i])
@@ -1,17 +1,19 @@
 package tasks
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"time"
 
 	"github.com/otiai10/marmoset"
 	"github.com/slack-go/slack"
 	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/filters"
 	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
 )
 
 func ConditionFrom(w http.ResponseWriter, req *http.Request) {
@@ -37,7 +39,7 @@ func ConditionFrom(w http.ResponseWriter, req *http.Request) {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err.Error()})
 		return
 	}
-	events, err := models.FindEventsBetween(ctx, f, t)
+	events, err := repository.FindEventsBetween(ctx, filters.GetRepositoryContext(req).Events(), f, t)
 	if err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"events": events, "error": err})
 		return
@@ -53,16 +55,26 @@ func ConditionFrom(w http.ResponseWriter, req *http.Request) {
 	}
 	title := ev.Google.Title
 
+	blocks, err := postConditionForm(ctx, ev, label, position, channel)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"success": false, "error": err.Error(), "blocks": blocks})
+		return
+	}
+	render.JSON(http.StatusOK, marmoset.P{"success": true, "blocks": blocks, "channel": channel, "title": title})
+}
+
+// postConditionForm は ev のコンディショニングチェックシートへのボタンを channel に投稿する。
+// 投稿後、回答の返信先としてそのメッセージを埋め込んだボタンに差し替える。
+func postConditionForm(ctx context.Context, ev models.Event, label, position, channel string) ([]slack.Block, error) {
 	blocks := createConditioningMessageBlocks(ev, label, position, "", "")
-	api := slack.New(os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN"))
-	if ch, ts, err := api.PostMessageContext(ctx, channel, slack.MsgOptionBlocks(blocks...)); err != nil {
+	api := server.NewSlackClient()
+	ch, ts, err := api.PostMessageContext(ctx, channel, slack.MsgOptionBlocks(blocks...))
+	if err != nil {
 		log.Println("[ERROR]", 8005, err.Error())
-		render.JSON(http.StatusInternalServerError, marmoset.P{"success": false, "error": err.Error(), "blocks": blocks})
-	} else {
-		render.JSON(http.StatusOK, marmoset.P{"success": true, "blocks": blocks, "channel": channel, "title": title})
-		blocks := createConditioningMessageBlocks(ev, label, position, ch, ts)
-		api.UpdateMessage(ch, ts, slack.MsgOptionBlocks(blocks...))
+		return blocks, err
 	}
+	api.UpdateMessage(ch, ts, slack.MsgOptionBlocks(createConditioningMessageBlocks(ev, label, position, ch, ts)...))
+	return blocks, nil
 }
 
 func createConditioningMessageBlocks(ev models.Event, label, position, ch, timestamp string) (blocks []slack.Block) {
@@ -0,0 +1,77 @@
+package tasks
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/otiai10/marmoset"
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/dormancy"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+)
+
+// CronScanDormantMembers は出欠の未回答が直近 ?events= 回（既定 dormancy.DefaultEvents）続いている
+// MSActive のメンバーを探し、休眠（MSInactive）にするかを ?channel=（既定 #admin）に承認・却下のボタン付きで提案する。
+// ?dry=1 なら提案せずに対象のメンバーだけを返す。
+func CronScanDormantMembers(w http.ResponseWriter, req *http.Request) {
+	render := marmoset.Render(w, true)
+	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
+	now := time.Now()
+
+	n := dormancy.DefaultEvents
+	if v := req.URL.Query().Get("events"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n <= 0 {
+			render.JSON(http.StatusBadRequest, marmoset.P{"error": "invalid events"})
+			return
+		}
+	}
+	channel := req.URL.Query().Get("channel")
+	if channel == "" {
+		channel = "admin"
+	}
+
+	candidates, err := dormancy.Scan(ctx, repo, n, now)
+	if err != nil {
+		log.Println("[ERROR]", 15001, err.Error())
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	members := []models.Member{}
+	for _, c := range candidates {
+		members = append(members, c.Member)
+	}
+	if req.URL.Query().Get("dry") != "" {
+		render.JSON(http.StatusOK, marmoset.P{"events": n, "channel": channel, "members": members})
+		return
+	}
+
+	api := server.NewSlackClient()
+	proposed := []string{}
+	for _, c := range candidates {
+		// 先に提案を記録してから投稿する（ボタンが押されたときに提案が見つかるように）
+		_, prev, err := dormancy.Propose(ctx, repo, c, now)
+		if errors.Is(err, dormancy.ErrAlreadyProposed) {
+			continue
+		}
+		if err != nil {
+			log.Println("[ERROR]", 15003, c.Member.Slack.ID, err.Error())
+			continue
+		}
+		if _, _, err := api.PostMessageContext(ctx, "#"+channel, slack.MsgOptionBlocks(dormancy.ProposalBlocks(c)...)); err != nil {
+			log.Println("[ERROR]", 15002, c.Member.Slack.ID, err.Error())
+			if err := dormancy.Withdraw(ctx, repo, c.Member.Slack.ID, prev); err != nil {
+				log.Println("[ERROR]", 15004, c.Member.Slack.ID, err.Error())
+			}
+			continue
+		}
+		proposed = append(proposed, c.Member.Slack.ID)
+	}
+	render.JSON(http.StatusOK, marmoset.P{"events": n, "channel": channel, "proposed": proposed})
+}
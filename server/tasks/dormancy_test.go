@@ -0,0 +1,160 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+	"github.com/triax/hub/slackfake"
+)
+
+func runScanDormant(t *testing.T, repo repository.Repository, query string) []string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/members/scan-dormant?"+query, nil)
+	req = filters.SetRepositoryContext(req, repo)
+	rec := httptest.NewRecorder()
+	CronScanDormantMembers(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	res := struct {
+		Proposed []string `json:"proposed"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Proposed
+}
+
+func TestCronScanDormantMembers(t *testing.T) {
+	ctx := context.Background()
+	fake := slackfake.New(slackfake.DefaultTeam, nil)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+	repo := repository.NewMemory()
+	now := time.Now()
+
+	// 1 週間おきの練習（p1 が最新）と、数えないスタッフ限定のミーティング
+	days := map[string]int{"p1": 1, "p2": 8, "p3": 15, "p4": 22, "p5": 29}
+	for id, d := range days {
+		start := now.AddDate(0, 0, -d)
+		ev := &models.Event{Google: models.GoogleEvent{ID: id, Title: "#練習", StartTime: start.UnixMilli(), EndTime: start.Add(3 * time.Hour).UnixMilli()}}
+		if err := repo.Events().Put(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	staff := now.AddDate(0, 0, -3)
+	if err := repo.Events().Put(ctx, &models.Event{Google: models.GoogleEvent{ID: "staff", Title: "#練習 スタッフMTG", Visibility: models.EVStaff, StartTime: staff.UnixMilli(), EndTime: staff.Add(time.Hour).UnixMilli()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	members := []models.Member{
+		{Slack: models.SlackUser{ID: "UACTIVE"}, Status: models.MSActive},
+		{Slack: models.SlackUser{ID: "UANSWER"}, Status: models.MSActive},
+		{Slack: models.SlackUser{ID: "UINACTIVE"}, Status: models.MSInactive},
+		{Slack: models.SlackUser{ID: "UNEW"}, Status: models.MSActive, JoinedAt: now.AddDate(0, 0, -10)},
+		{Slack: models.SlackUser{ID: "UREJECTED"}, Status: models.MSActive},
+		{Slack: models.SlackUser{ID: "UPENDING"}},
+		{Slack: models.SlackUser{ID: "URETURNED"}, Status: models.MSActive},
+	}
+	for i := range members {
+		if err := repo.Members().Put(ctx, &members[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.Participations().Update(ctx, "p2", "UANSWER", func(p *models.Participation) error {
+		p.Type = models.PTAbsent
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 5 日前に休眠から戻ったメンバーは、それより前のイベントを数えない
+	if err := repo.MemberStatusChanges().Add(ctx, &models.MemberStatusChange{
+		MemberID: "URETURNED", From: models.MSInactive, To: models.MSActive, EffectiveAt: now.AddDate(0, 0, -5), CreatedAt: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 却下した後に 4 回の未回答が揃っていない / まだ決まっていない
+	for _, p := range []models.DormancyProposal{
+		{MemberID: "UREJECTED", Status: models.DPRejected, DecidedAt: now.AddDate(0, 0, -20)},
+		{MemberID: "UPENDING", Status: models.DPPending, ProposedAt: now.AddDate(0, 0, -7)},
+	} {
+		if err := repo.DormancyProposals().Put(ctx, &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if proposed := runScanDormant(t, repo, "events=4&channel=admin"); !slices.Equal(proposed, []string{"UACTIVE"}) {
+		t.Fatalf("proposed = %v", proposed)
+	}
+	proposal, err := repo.DormancyProposals().Get(ctx, "UACTIVE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Status != models.DPPending || !slices.Equal(proposal.EventIDs, []string{"p1", "p2", "p3", "p4"}) {
+		t.Errorf("proposal = %+v", proposal)
+	}
+	msgs := fake.Messages("admin")
+	if len(msgs) != 1 || !strings.Contains(string(msgs[0].Blocks), "UACTIVE") || !strings.Contains(string(msgs[0].Blocks), "dormancy/") {
+		t.Fatalf("messages = %+v", msgs)
+	}
+
+	// 保留中の提案は繰り返さない
+	if proposed := runScanDormant(t, repo, "events=4&channel=admin"); len(proposed) != 0 {
+		t.Errorf("proposed again: %v", proposed)
+	}
+	// 回数を減らすと、却下後の未回答が揃ったメンバーも対象になる
+	if proposed := runScanDormant(t, repo, "events=2&channel=admin&dry=1"); proposed != nil {
+		t.Errorf("dry run must not propose: %v", proposed)
+	}
+	if proposed := runScanDormant(t, repo, "events=2&channel=admin"); !slices.Equal(proposed, []string{"UNEW", "UREJECTED"}) && !slices.Equal(proposed, []string{"UREJECTED", "UNEW"}) {
+		t.Errorf("proposed = %v", proposed)
+	}
+}
+
+// TestCronScanDormantMembers_PostFailure は、提案を Slack に投稿できなければ記録した提案を取り消し、
+// 以前の提案に戻すことを検証する。
+func TestCronScanDormantMembers_PostFailure(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(slackfake.New(slackfake.DefaultTeam, nil))
+	ts.Close() // 投稿は接続できずに失敗する
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+	repo := repository.NewMemory()
+	now := time.Now()
+
+	for i := 1; i <= 2; i++ {
+		start := now.AddDate(0, 0, -7*i)
+		if err := repo.Events().Put(ctx, &models.Event{Google: models.GoogleEvent{ID: fmt.Sprintf("p%d", i), Title: "#練習", StartTime: start.UnixMilli(), EndTime: start.Add(3 * time.Hour).UnixMilli()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"UNEW", "UREJECTED"} {
+		if err := repo.Members().Put(ctx, &models.Member{Slack: models.SlackUser{ID: id}, Status: models.MSActive}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rejected := models.DormancyProposal{MemberID: "UREJECTED", Status: models.DPRejected, DecidedAt: now.AddDate(0, 0, -30)}
+	if err := repo.DormancyProposals().Put(ctx, &rejected); err != nil {
+		t.Fatal(err)
+	}
+
+	if proposed := runScanDormant(t, repo, "events=2"); len(proposed) != 0 {
+		t.Errorf("proposed = %v", proposed)
+	}
+	if p, err := repo.DormancyProposals().Get(ctx, "UNEW"); err != repository.ErrNotFound {
+		t.Errorf("proposal left = %+v, %v", p, err)
+	}
+	if p, _ := repo.DormancyProposals().Get(ctx, "UREJECTED"); p.Status != models.DPRejected {
+		t.Errorf("proposal not restored = %+v", p)
+	}
+}
@@ -1,18 +1,20 @@
 package tasks
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/otiai10/marmoset"
 	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/filters"
 	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
 )
 
 func EquipsRemindBring(w http.ResponseWriter, req *http.Request) {
@@ -20,22 +22,15 @@ func EquipsRemindBring(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	render := marmoset.Render(w, true)
 
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		log.Println("[ERROR]", 8001, err.Error())
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
 	// 1) 直近24時間以内のイベントを取得
-	events := []models.Event{}
-	query := datastore.NewQuery(models.KindEvent).
-		Filter("Google.StartTime >", time.Now().Unix()*1000).
-		Filter("Google.StartTime <=", time.Now().Add(24*time.Hour).Unix()*1000).
-		Order("Google.StartTime").
-		Limit(1)
-	if _, err := client.GetAll(ctx, query, &events); err != nil {
+	events, err := repo.Events().Find(ctx, repository.EventQuery{
+		From:  time.Now(),
+		To:    time.Now().Add(24 * time.Hour),
+		Limit: 1,
+	})
+	if err != nil {
 		log.Println("[ERROR]", 8002, err.Error())
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
@@ -52,21 +47,29 @@ func EquipsRemindBring(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// 2) 全Equipsを取得する
-	equips := []models.Equip{}
-	query = datastore.NewQuery(models.KindEquip)
-	if ev.IsGame() {
-		query.Filter("ForGame =", true)
-	} else if ev.IsPractice() {
-		query.Filter("ForPractice =", true)
-	}
-	if _, err := client.GetAll(ctx, query, &equips); err != nil && !models.IsFiledMismatch(err) {
-		log.Println("[ERROR]", 8003, err.Error())
+	dmed, err := remindEquipsToBring(ctx, repo, ev)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
+	if len(dmed) == 0 {
+		render.JSON(http.StatusOK, marmoset.P{"message": "no targets"})
+		return
+	}
+
+	render.JSON(http.StatusOK, marmoset.P{"event": ev.Google.Title, "dmed": dmed})
+}
 
-	// 3) 持ち帰り管理かつ対象イベント向け備品のホルダーをグルーピング
+// remindEquipsToBring は ev に持って来てほしい備品（持ち帰り管理）のホルダーに個別DMを送り、送れた Slack ID を返す。
+func remindEquipsToBring(ctx context.Context, repo repository.Repository, ev models.Event) ([]string, error) {
+	// 1) 全Equipsを取得する（対象イベント向けかどうかは ShouldBringFor で判定する）
+	equips, err := repo.Equips().List(ctx)
+	if err != nil {
+		log.Println("[ERROR]", 8003, err.Error())
+		return nil, err
+	}
+
+	// 2) 持ち帰り管理かつ対象イベント向け備品のホルダーをグルーピング
 	byHolder := map[string][]models.Equip{}
 	for i, eq := range equips {
 		if eq.StorageType == models.StorageTypeWarehouse {
@@ -75,9 +78,7 @@ func EquipsRemindBring(w http.ResponseWriter, req *http.Request) {
 		if !eq.ShouldBringFor(ev) {
 			continue
 		}
-		equips[i].ID = eq.Key.ID
-		query := datastore.NewQuery(models.KindCustody).Ancestor(eq.Key).Order("-Timestamp").Limit(1)
-		client.GetAll(ctx, query, &equips[i].History) // エラーは無視してよい
+		equips[i].History, _ = repo.Equips().History(ctx, eq.ID, 1) // エラーは無視してよい
 		if len(equips[i].History) == 0 {
 			log.Printf("[WARN] 誰も管理していない: %s", eq.Name)
 			continue
@@ -86,13 +87,8 @@ func EquipsRemindBring(w http.ResponseWriter, req *http.Request) {
 		byHolder[uid] = append(byHolder[uid], equips[i])
 	}
 
-	if len(byHolder) == 0 {
-		render.JSON(http.StatusOK, marmoset.P{"message": "no targets"})
-		return
-	}
-
-	// 4) ホルダーごとに個別DM送信
-	api := slack.New(os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN"))
+	// 3) ホルダーごとに個別DM送信
+	api := server.NewSlackClient()
 	dmed := []string{}
 	for uid, eqs := range byHolder {
 		ch, _, _, err := api.OpenConversation(&slack.OpenConversationParameters{
@@ -111,7 +107,8 @@ func EquipsRemindBring(w http.ResponseWriter, req *http.Request) {
 			}
 		}
 		msg := fmt.Sprintf(
-			"明日の *%s* にて以下の備品をお持ちください :bow:\n%s\n※ご欠席の場合は参加者への引き渡しをお願いします。",
+			"%s の *%s* にて以下の備品をお持ちください :bow:\n%s\n※ご欠席の場合は参加者への引き渡しをお願いします。",
+			formatEventSpan(ev.Google),
 			ev.Google.Title,
 			strings.Join(names, "\n"),
 		)
@@ -121,8 +118,7 @@ func EquipsRemindBring(w http.ResponseWriter, req *http.Request) {
 		}
 		dmed = append(dmed, uid)
 	}
-
-	render.JSON(http.StatusOK, marmoset.P{"event": ev.Google.Title, "dmed": dmed})
+	return dmed, nil
 }
 
 func EquipsRemindReportAfterEvent(w http.ResponseWriter, req *http.Request) {
@@ -137,7 +133,8 @@ func EquipsRemindReportAfterEvent(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	events, err := models.FindEventsBetween(ctx, from, to)
+	repo := filters.GetRepositoryContext(req)
+	events, err := repository.FindEventsBetween(ctx, repo.Events(), from, to)
 	if err != nil {
 		log.Println("[ERROR]", 9002, err.Error())
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
@@ -156,18 +153,11 @@ func EquipsRemindReportAfterEvent(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	all, err := repo.Equips().List(ctx)
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, map[string]any{"error": err})
 		return
 	}
-	defer client.Close()
-
-	all := []models.Equip{}
-	if _, err = client.GetAll(ctx, datastore.NewQuery(models.KindEquip), &all); err != nil {
-		render.JSON(http.StatusInternalServerError, map[string]any{"error": err})
-		return
-	}
 
 	targets := []models.Equip{}
 	for _, equip := range all {
@@ -201,12 +191,12 @@ func EquipsRemindReportAfterEvent(w http.ResponseWriter, req *http.Request) {
 			nil,
 			slack.NewAccessory(slack.NewOptionsSelectBlockElement(
 				"users_select", nil,
-				fmt.Sprintf("equip_unreported/?eid=%d&ev=%s", equip.Key.ID, ev.Google.Title),
+				fmt.Sprintf("equip_unreported/?eid=%d&ev=%s", equip.ID, ev.Google.Title),
 			)),
 		))
 	}
 
-	api := slack.New(os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN"))
+	api := server.NewSlackClient()
 	channel := req.URL.Query().Get("channel")
 	if channel == "" {
 		channel = "general"
@@ -238,8 +228,9 @@ func EquipsScanUnreported(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	ctx := req.Context()
+	repo := filters.GetRepositoryContext(req)
 
-	events, err := models.FindEventsBetween(ctx, time.Time{}, time.Now())
+	events, err := repository.FindEventsBetween(ctx, repo.Events(), time.Time{}, time.Now())
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, map[string]any{"error": err})
 		return
@@ -260,18 +251,11 @@ func EquipsScanUnreported(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	all, err := repo.Equips().List(ctx)
 	if err != nil {
 		render.JSON(http.StatusInternalServerError, map[string]any{"error": err})
 		return
 	}
-	defer client.Close()
-
-	all := []models.Equip{}
-	if _, err = client.GetAll(ctx, datastore.NewQuery(models.KindEquip), &all); err != nil {
-		render.JSON(http.StatusInternalServerError, map[string]any{"error": err})
-		return
-	}
 
 	// 未報告をスキャン（倉庫管理はスキップ）
 	unreported := []models.Equip{}
@@ -282,8 +266,7 @@ func EquipsScanUnreported(w http.ResponseWriter, req *http.Request) {
 		if !equip.ShouldBringFor(latest) {
 			continue
 		}
-		query := datastore.NewQuery(models.KindCustody).Ancestor(equip.Key).Order("-Timestamp").Limit(1)
-		if _, err = client.GetAll(ctx, query, &equip.History); err != nil {
+		if equip.History, err = repo.Equips().History(ctx, equip.ID, 1); err != nil {
 			render.JSON(http.StatusInternalServerError, map[string]any{"error": err})
 			return
 		}
@@ -303,7 +286,7 @@ func EquipsScanUnreported(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	api := slack.New(os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN"))
+	api := server.NewSlackClient()
 	channel := req.URL.Query().Get("channel")
 	if channel == "" {
 		channel = "general"
@@ -345,7 +328,7 @@ func EquipsScanUnreported(w http.ResponseWriter, req *http.Request) {
 			nil,
 			slack.NewAccessory(slack.NewOptionsSelectBlockElement(
 				"users_select", nil,
-				fmt.Sprintf("equip_unreported/?eid=%d&ev=%s", equip.Key.ID, latest.Google.Title),
+				fmt.Sprintf("equip_unreported/?eid=%d&ev=%s", equip.ID, latest.Google.Title),
 			)),
 		))
 	}
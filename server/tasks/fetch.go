@@ -1,124 +1,373 @@
 package tasks
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"reflect"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/otiai10/marmoset"
 	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/gcal"
 	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
 	"google.golang.org/api/calendar/v3"
-	"google.golang.org/api/option"
 )
 
-const (
-	eventFetchDurationMonths = 6 // 半年までのイベントを取得する
-)
+// errSkipEvent は Events().Update の fn から返して、書き込まずに抜けるためのエラー。
+var errSkipEvent = errors.New("skip event")
+
+// calendarSyncResult は CronFetchGoogleEvents の集計。
+// Total（カレンダーから受け取った件数）は Created から Skipped までの合計と一致する。
+type calendarSyncResult struct {
+	Total     int
+	Created   int
+	Updated   int
+	Unchanged int
+	Cancelled int // キャンセル・#ignore になり、Hub の Event をキャンセル扱いにした
+	Ignored   int // #ignore（Hub に無い）
+	Skipped   int // Hub に無いキャンセル・過去のイベント、解釈できないイベント、他のカレンダーの Event
+	// Removed は全件同期でカレンダーに見つからず、キャンセル扱いにした今後の Event。
+	Removed int
+	// Rescheduled は日時が変わった今後の Event。
+	Rescheduled []rescheduledEvent
+	Notified    int
+}
+
+// rescheduledEvent は日時が変わった Event と、変更前の日時。
+type rescheduledEvent struct {
+	Event  models.Event
+	Before models.GoogleEvent
+}
+
+func (r *calendarSyncResult) add(o *calendarSyncResult) {
+	r.Total += o.Total
+	r.Created += o.Created
+	r.Updated += o.Updated
+	r.Unchanged += o.Unchanged
+	r.Cancelled += o.Cancelled
+	r.Ignored += o.Ignored
+	r.Skipped += o.Skipped
+	r.Removed += o.Removed
+	r.Rescheduled = append(r.Rescheduled, o.Rescheduled...)
+	r.Notified += o.Notified
+}
 
+func (r *calendarSyncResult) counts() map[string]any {
+	return map[string]any{
+		"total":       r.Total,
+		"ignored":     r.Ignored,
+		"created":     r.Created,
+		"updated":     r.Updated,
+		"unchanged":   r.Unchanged,
+		"cancelled":   r.Cancelled,
+		"removed":     r.Removed,
+		"skipped":     r.Skipped,
+		"rescheduled": len(r.Rescheduled),
+		"notified":    r.Notified,
+		"validation":  r.Total == r.Created+r.Updated+r.Unchanged+r.Cancelled+r.Ignored+r.Skipped,
+	}
+}
+
+// CronFetchGoogleEvents は Google Calendar のイベントを Hub の Event に同期する。
+//
+// 取り込むカレンダーは gcal.LoadSources の設定に従い、イベントには取り込み元（Google.Source）と、
+// カレンダーごとの既定タグ・閲覧範囲を記録する。
+// 前回保存した SyncToken があれば、それ以降に変更されたイベントだけを受け取る（増分同期）。
+// SyncToken が無い・失効した・?full=1 の場合は全件同期し、カレンダーから消えた今後の Event もキャンセル扱いにする。
+// キャンセルされた Event は消さずに Cancelled を立てる（回答の履歴を残し、一覧・リマインダからは外れる）。
+// 今後の Event の日時が変わった場合は、すでに回答したメンバーへ DM で知らせる。
 func CronFetchGoogleEvents(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
-	jsonstr := os.Getenv("GOOGLE_SERVICE_ACCOUNT_JSON")
-	opt := option.WithCredentialsJSON([]byte(jsonstr))
-	service, err := calendar.NewService(ctx, opt)
+	cal := filters.GetCalendarContext(req)
+	repo := filters.GetRepositoryContext(req)
+
+	sources, err := gcal.LoadSources()
 	if err != nil {
-		fmt.Println("[ERROR]", 7001, err)
+		fmt.Println("[ERROR]", 7010, err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprint(w, err.Error())
 		return
 	}
-	id := os.Getenv("GOOGLE_CALENDAR_ID")
 
-	now := time.Now()
-	all, err := service.Events.List(id).
-		ShowDeleted(false).
-		SingleEvents(true).
-		TimeMin(now.Format(time.RFC3339)).
-		TimeMax(now.AddDate(0, eventFetchDurationMonths, 0).Format(time.RFC3339)).
-		OrderBy("startTime").
-		Do()
-	if err != nil {
-		fmt.Println("[ERROR]", 7002, err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprint(w, err.Error())
+	dry := req.URL.Query().Get("dry") != ""
+	full := req.URL.Query().Get("full") != ""
+	total := &calendarSyncResult{}
+	calendars := []map[string]any{}
+	for i, src := range sources {
+		changes, err := fetchCalendarChanges(ctx, cal, repo, src, full)
+		if err != nil {
+			fmt.Println("[ERROR]", 7002, src.Name, err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		mode := "incremental"
+		if changes.Full {
+			mode = "full"
+		}
+
+		if dry {
+			targets, ignored, cancelled := []*calendar.Event{}, []*calendar.Event{}, []*calendar.Event{}
+			for _, item := range changes.Items {
+				switch {
+				case item.Status == "cancelled":
+					cancelled = append(cancelled, item)
+				case models.TitleHasTag(item.Summary, models.ETIgnore):
+					ignored = append(ignored, item)
+				default:
+					targets = append(targets, item)
+				}
+			}
+			calendars = append(calendars, map[string]any{
+				"source":    src,
+				"mode":      mode,
+				"targets":   targets,
+				"ignored":   ignored,
+				"cancelled": cancelled,
+			})
+			continue
+		}
+
+		result, err := syncCalendarEvents(ctx, repo, src, i == 0, changes, time.Now())
+		if err != nil {
+			fmt.Println("[ERROR]", 7005, src.Name, err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+
+		if err := repo.CalendarSyncs().Put(ctx, &models.CalendarSync{
+			CalendarID: src.CalendarID,
+			SyncToken:  changes.NextSyncToken,
+			SyncedAt:   time.Now(),
+		}); err != nil {
+			// 次回は同じ範囲をもう一度同期するだけなので、処理自体は成功として扱う
+			fmt.Println("[ERROR]", 7006, src.Name, err.Error())
+		}
+
+		result.Notified = notifyRescheduled(ctx, repo, result.Rescheduled)
+		total.add(result)
+
+		counts := result.counts()
+		counts["name"] = src.Name
+		counts["mode"] = mode
+		calendars = append(calendars, counts)
+	}
+
+	if dry {
+		marmoset.RenderJSON(w, http.StatusOK, marmoset.P{"calendars": calendars})
 		return
 	}
 
-	// #ignore が含まれるイベントは、そもそもHubに入れない
-	targets := []calendar.Event{}
-	ignored := []calendar.Event{}
-	for _, item := range all.Items {
-		if models.EventExpressionIgnore.MatchString(item.Summary) {
-			ignored = append(ignored, *item)
-		} else {
-			targets = append(targets, *item)
+	marmoset.Render(w).JSON(http.StatusOK, marmoset.P{
+		"message":   "ok",
+		"events":    total.counts(),
+		"calendars": calendars,
+	})
+}
+
+// fetchCalendarChanges は src の前回の同期以降の変更を返す。
+// full が true、または保存した SyncToken が使えない場合は全件同期する。
+func fetchCalendarChanges(ctx context.Context, cal gcal.Calendar, repo repository.Repository, src gcal.Source, full bool) (*gcal.Changes, error) {
+	token := ""
+	if !full {
+		state, err := repo.CalendarSyncs().Get(ctx, src.CalendarID)
+		if err != nil && err != repository.ErrNotFound {
+			return nil, err
+		}
+		if state != nil {
+			token = state.SyncToken
 		}
 	}
+	changes, err := cal.Sync(ctx, gcal.SyncQuery{CalendarID: src.CalendarID, SyncToken: token})
+	if err == gcal.ErrSyncTokenExpired {
+		fmt.Println("[WARN]", 7003, src.Name, "sync token expired, fallback to full sync")
+		return cal.Sync(ctx, gcal.SyncQuery{CalendarID: src.CalendarID})
+	}
+	return changes, err
+}
 
-	if req.URL.Query().Get("dry") != "" {
-		marmoset.RenderJSON(w, 200, marmoset.P{
-			"targets": targets,
-			"ignored": ignored,
+// syncCalendarEvents は src の changes を Event に反映する。
+//
+// 同じイベントが複数のカレンダーに入っている場合（ID が同じ）は、先に取り込んだカレンダーのものとして扱い、
+// 他のカレンダーからは更新・キャンセルしない。primary は Google.Source の無い（複数カレンダー対応以前の）
+// Event をこのカレンダーのものとして扱うかどうか。
+func syncCalendarEvents(ctx context.Context, repo repository.Repository, src gcal.Source, primary bool, changes *gcal.Changes, now time.Time) (*calendarSyncResult, error) {
+	result := &calendarSyncResult{Total: len(changes.Items)}
+	present := map[string]bool{} // カレンダー上に存在し Hub に取り込む対象のイベント
+	owns := func(ev *models.Event) bool {
+		return ev.Google.Source == src.Name || (ev.Google.Source == "" && primary)
+	}
+
+	for _, item := range changes.Items {
+		// キャンセルされた、または #ignore が付いたイベントは Hub に入れない（既にあればキャンセル扱い）
+		if item.Status == "cancelled" || models.TitleHasTag(item.Summary, models.ETIgnore) {
+			ok, err := cancelEvent(ctx, repo, item.Id, owns)
+			switch {
+			case err != nil:
+				return nil, err
+			case ok:
+				result.Cancelled++
+			case item.Status == "cancelled":
+				result.Skipped++
+			default:
+				result.Ignored++
+			}
+			continue
+		}
+
+		google, err := models.CreateEventFromCalendarAPI(item)
+		if err != nil {
+			fmt.Printf("[WARN] skipping event %q (%s): %v\n", item.Summary, item.Id, err)
+			result.Skipped++
+			continue
+		}
+		src.Apply(&google)
+		present[item.Id] = true
+
+		var before models.Event
+		ev, err := repo.Events().Update(ctx, item.Id, func(ev *models.Event) error {
+			before = *ev
+			if ev.Google.ID == "" && google.EndTime < now.UnixMilli() {
+				return errSkipEvent // 全件同期で受け取る過去のイベントは取り込まない
+			}
+			if ev.Google.ID != "" && !owns(ev) && !ev.Cancelled {
+				fmt.Printf("[WARN] event %s is already imported from %q, skip %q\n", item.Id, ev.Google.Source, src.Name)
+				return errSkipEvent
+			}
+			if reflect.DeepEqual(ev.Google, google) && !ev.Cancelled {
+				return errSkipEvent
+			}
+			ev.Google = google
+			ev.Cancelled = false
+			return nil
 		})
-		return
+		if err == errSkipEvent {
+			if before.Google.ID != "" && owns(&before) {
+				result.Unchanged++
+			} else {
+				result.Skipped++
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if before.Google.ID == "" {
+			fmt.Printf("[DEBUG] NEW EVENT: %+v\n", google)
+			result.Created++
+			continue
+		}
+		result.Updated++
+		moved := before.Google.StartTime != google.StartTime || before.Google.EndTime != google.EndTime
+		if moved && !before.Cancelled && google.EndTime >= now.UnixMilli() {
+			result.Rescheduled = append(result.Rescheduled, rescheduledEvent{Event: *ev, Before: before.Google})
+		}
 	}
 
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	if !changes.Full {
+		return result, nil
+	}
+	// 全件同期の結果に無い今後の Event は、カレンダーから削除されたとみなす
+	upcoming, err := repo.Events().Find(ctx, repository.EventQuery{From: now})
 	if err != nil {
-		fmt.Println("[ERROR]", 7003, err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprint(w, err.Error())
-		return
+		return nil, err
 	}
-	defer client.Close()
+	for _, ev := range upcoming {
+		if present[ev.Google.ID] || !owns(&ev) {
+			continue
+		}
+		ok, err := cancelEvent(ctx, repo, ev.Google.ID, owns)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result.Removed++
+		}
+	}
+	return result, nil
+}
 
-	var created, updated int
-	if _, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
-		for _, item := range targets {
-			ev := models.Event{}
-			key := datastore.NameKey(models.KindEvent, item.Id, nil)
-			if err := tx.Get(key, &ev); err != nil {
-				fmt.Printf("[DEBUG] NEW EVENT: %+v\n", item)
-				created += 1
-			} else {
-				updated += 1
+// cancelEvent は Hub にある Event をキャンセル扱いにする。
+// Hub に無い・既にキャンセル済み・owns が false（他のカレンダーの Event）なら false。
+func cancelEvent(ctx context.Context, repo repository.Repository, id string, owns func(*models.Event) bool) (bool, error) {
+	_, err := repo.Events().Update(ctx, id, func(ev *models.Event) error {
+		if ev.Google.ID == "" || ev.Cancelled || !owns(ev) {
+			return errSkipEvent
+		}
+		ev.Cancelled = true
+		return nil
+	})
+	if err == errSkipEvent {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// notifyRescheduled は日時が変わった Event に回答済みのメンバーへ DM し、送った件数を返す。
+// 送信の失敗はログに残して続ける（同期の結果は保存済みのため）。
+func notifyRescheduled(ctx context.Context, repo repository.Repository, events []rescheduledEvent) int {
+	if len(events) == 0 {
+		return 0
+	}
+	api := server.NewSlackClient()
+	notified := 0
+	for _, r := range events {
+		parts, err := repo.Participations().ListByEvent(ctx, r.Event.Google.ID)
+		if err != nil {
+			fmt.Println("[ERROR]", 7007, err.Error())
+			continue
+		}
+		msg := fmt.Sprintf(
+			"回答済みのイベントの日時が変更されました。\n<%s/events/%s|%s>\n変更前: %s\n変更後: %s\n出欠が変わる場合は回答し直してください。",
+			server.HubBaseURL(), r.Event.Google.ID, r.Event.Google.Title,
+			formatEventSpan(r.Before), formatEventSpan(r.Event.Google),
+		)
+		for _, p := range parts {
+			if p.Type == "" || p.Type.Unanswered() {
+				continue
 			}
-			google, err := models.CreateEventFromCalendarAPI(&item)
+			ch, _, _, err := api.OpenConversationContext(ctx, &slack.OpenConversationParameters{Users: []string{p.MemberID}})
 			if err != nil {
-				fmt.Printf("[WARN] skipping event %q (%s): %v\n", item.Summary, item.Id, err)
+				fmt.Printf("[ERROR] 7008 OpenConversation %s: %v\n", p.MemberID, err)
 				continue
 			}
-			ev.Google = google
-			if _, err := tx.Put(key, &ev); err != nil {
-				return err
+			if _, _, err := api.PostMessageContext(ctx, ch.ID, slack.MsgOptionText(msg, false)); err != nil {
+				fmt.Printf("[ERROR] 7009 PostMessage DM to %s: %v\n", p.MemberID, err)
+				continue
 			}
+			notified++
 		}
-		return nil
-	}); err != nil {
-		fmt.Println("[ERROR]", 7005, err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprint(w, err.Error())
-		return
 	}
+	return notified
+}
 
-	marmoset.Render(w).JSON(http.StatusOK, marmoset.P{
-		"message": "ok",
-		"events": map[string]any{
-			"total":      len(all.Items),
-			"ignored":    len(ignored),
-			"created":    created,
-			"updated":    updated,
-			"validation": len(all.Items) == len(ignored)+created+updated,
-		},
-	})
+var weekdaysJa = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+// formatEventSpan は "6/7(日) 09:00〜12:00" の形式で日時を返す（日をまたぐ場合は終了にも日付を付ける）。
+func formatEventSpan(g models.GoogleEvent) string {
+	start := time.UnixMilli(g.StartTime).In(server.ServiceLocation)
+	end := time.UnixMilli(g.EndTime).In(server.ServiceLocation)
+	day := func(t time.Time) string {
+		return fmt.Sprintf("%d/%d(%s)", t.Month(), t.Day(), weekdaysJa[t.Weekday()])
+	}
+	if start.YearDay() == end.YearDay() && start.Year() == end.Year() {
+		return fmt.Sprintf("%s %s〜%s", day(start), start.Format("15:04"), end.Format("15:04"))
+	}
+	return fmt.Sprintf("%s %s〜%s %s", day(start), start.Format("15:04"), day(end), end.Format("15:04"))
 }
 
+// CronFetchSlackMembers は Slack のユーザを Member に同期する（Slack / Team だけを上書きする）。
+// 初めて取り込んだユーザは JoinedAt を、アカウントが削除されたユーザは LeftAt を記録し、参加状態の変更履歴にも残す。
 func CronFetchSlackMembers(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
-	token := os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN")
-	api := slack.New(token)
+	api := server.NewSlackClient()
 
 	team, err := api.GetTeamInfoContext(ctx)
 	if err != nil {
@@ -139,52 +388,69 @@ func CronFetchSlackMembers(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		fmt.Println("[ERROR]", 6003, err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
+	now := time.Now()
 	count := 0
 	newjoiner := []models.Member{}
+	left := []models.Member{}
 	for _, u := range users {
 
 		if u.IsBot || u.IsAppUser {
 			continue
 		}
 
-		key := datastore.NameKey(models.KindMember, u.ID, nil)
-		member := models.Member{}
-
-		if _, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
-			if err := tx.Get(key, &member); err != nil {
-				if _, ok := err.(*datastore.ErrFieldMismatch); !ok {
-					fmt.Printf("[DEBUG] NEW MEMBER: %+v\n", member)
-					newjoiner = append(newjoiner, member)
-				}
+		// 入部・退部（Slack のアカウント削除）・復帰を検知したら、参加状態の変更履歴に残す
+		var change *models.MemberStatusChange
+		member, err := repo.Members().Update(ctx, u.ID, func(member *models.Member) error {
+			change = nil
+			status := member.Status
+			if status == "" {
+				status = models.MSActive
+			}
+			switch {
+			case member.Slack.ID == "":
+				fmt.Printf("[DEBUG] NEW MEMBER: %+v\n", member)
+				member.JoinedAt = now
+				change = &models.MemberStatusChange{To: status, Reason: "Slack に参加"}
+			case !member.Slack.Deleted && u.Deleted:
+				member.LeftAt = now
+				change = &models.MemberStatusChange{From: status, To: models.MSDeleted, Reason: "Slack のアカウントが削除された"}
+			case member.Slack.Deleted && !u.Deleted:
+				member.LeftAt = time.Time{}
+				change = &models.MemberStatusChange{From: models.MSDeleted, To: status, Reason: "Slack のアカウントが復帰した"}
 			}
 
 			// いずれにしても、存在しているSlack上の情報で上書き
 			member.Slack = models.ConvertSlackAPIUserToInternalUser(u)
 			member.Team = models.ConvertSlackAPITeamToInternalTeam(*team)
-
-			if _, err := tx.Put(key, &member); err != nil {
-				return err
-			}
-			count++
 			return nil
-		}); err != nil {
+		})
+		if err != nil {
 			fmt.Println("[ERROR]", 6004, err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		count++
+		if change == nil {
+			continue
+		}
+		switch {
+		case change.From == "":
+			newjoiner = append(newjoiner, *member)
+		case change.To == models.MSDeleted:
+			left = append(left, *member)
+		}
+		change.MemberID, change.EffectiveAt, change.CreatedAt = u.ID, now, now
+		if err := repo.MemberStatusChanges().Add(ctx, change); err != nil {
+			fmt.Println("[ERROR]", 6005, err)
+		}
 	}
 
 	marmoset.RenderJSON(w, http.StatusOK, marmoset.P{
 		"message": "ok",
 		"new":     newjoiner,
+		"left":    left,
 		"count":   count,
 	})
 }
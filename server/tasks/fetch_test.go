@@ -0,0 +1,428 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/gcal"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+	"github.com/triax/hub/slackfake"
+	"google.golang.org/api/calendar/v3"
+)
+
+const fetchTestCalendarYAML = `
+team@example.com:
+  - id: practice
+    summary: "#練習 定期練習"
+    start: "+2d 09:00"
+    end: "+2d 12:00"
+    recurrence: ["RRULE:FREQ=WEEKLY;COUNT=3"]
+  - id: practice-cancel
+    recurring_event_id: practice
+    original_start: "+9d 09:00"
+    status: cancelled
+  - id: camp
+    summary: 夏合宿
+    date: "+20d"
+    end_date: "+22d"
+  - id: ignored
+    summary: "#ignore 幹部会"
+    start: "+3d 19:00"
+    end: "+3d 21:00"
+  - id: cancelled
+    summary: "#試合 中止"
+    start: "+4d 10:00"
+    end: "+4d 13:00"
+    status: cancelled
+  - id: past
+    summary: "#練習 先月"
+    start: "-30d 09:00"
+    end: "-30d 12:00"
+`
+
+func runFetchGoogleEvents(t *testing.T, repo repository.Repository, cal gcal.Calendar, query string) (map[string]int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/fetch-calendar-events"+query, nil)
+	req = filters.SetRepositoryContext(req, repo)
+	req = filters.SetCalendarContext(req, cal)
+	rec := httptest.NewRecorder()
+	CronFetchGoogleEvents(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	res := struct {
+		Events    map[string]interface{}   `json:"events"`
+		Calendars []map[string]interface{} `json:"calendars"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for k, v := range res.Events {
+		if n, ok := v.(float64); ok {
+			counts[k] = int(n)
+		}
+	}
+	if res.Events["validation"] != true {
+		t.Errorf("validation failed: %v", res.Events)
+	}
+	mode, _ := res.Calendars[0]["mode"].(string)
+	return counts, mode
+}
+
+// newFetchTest は YAML カレンダー・メモリのリポジトリ・Slack の fake を用意する。
+func newFetchTest(t *testing.T) (*gcal.Memory, *repository.Memory, *slackfake.Server) {
+	t.Helper()
+	t.Setenv("GOOGLE_CALENDAR_ID", "team@example.com")
+	cal, err := gcal.ParseYAML([]byte(fetchTestCalendarYAML), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := slackfake.New(slackfake.DefaultTeam, nil)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+	return cal, repository.NewMemory(), fake
+}
+
+func TestCronFetchGoogleEvents(t *testing.T) {
+	ctx := context.Background()
+	cal, repo, _ := newFetchTest(t)
+
+	counts, mode := runFetchGoogleEvents(t, repo, cal, "")
+	// 初回は全件同期: practice x2（1 回キャンセル）+ camp + ignored（取り込まない）+ past（過去は取り込まない）
+	if mode != "full" || counts["total"] != 5 || counts["ignored"] != 1 || counts["created"] != 3 || counts["skipped"] != 1 {
+		t.Errorf("first run = %s %v", mode, counts)
+	}
+
+	events, err := repo.Events().Find(ctx, repository.EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]models.Event{}
+	for _, ev := range events {
+		ids[ev.Google.ID] = ev
+	}
+	if len(ids) != 3 {
+		t.Fatalf("events = %v", ids)
+	}
+	camp, ok := ids["camp"]
+	if !ok {
+		t.Fatal("all-day event is not stored")
+	}
+	if d := time.Duration(camp.Google.EndTime-camp.Google.StartTime) * time.Millisecond; d != 48*time.Hour {
+		t.Errorf("all-day event duration = %v", d)
+	}
+	for id := range ids {
+		if id == "ignored" || id == "cancelled" || id == "past" {
+			t.Errorf("%s should not be stored", id)
+		}
+	}
+
+	// 2 回目は増分同期: 変更されたイベントだけを受け取る
+	counts, mode = runFetchGoogleEvents(t, repo, cal, "")
+	if mode != "incremental" || counts["total"] != 0 {
+		t.Errorf("no-change run = %s %v", mode, counts)
+	}
+	cal.Put("team@example.com", mustEvent(t, cal, "camp", "夏合宿（変更）"))
+	counts, _ = runFetchGoogleEvents(t, repo, cal, "")
+	if counts["total"] != 1 || counts["created"] != 0 || counts["updated"] != 1 || counts["rescheduled"] != 0 {
+		t.Errorf("update run = %v", counts)
+	}
+	ev, err := repo.Events().Get(ctx, "camp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Google.Title != "夏合宿（変更）" {
+		t.Errorf("title = %q", ev.Google.Title)
+	}
+}
+
+func TestCronFetchGoogleEvents_Cancel(t *testing.T) {
+	ctx := context.Background()
+	cal, repo, _ := newFetchTest(t)
+	runFetchGoogleEvents(t, repo, cal, "")
+
+	// 繰り返しイベントをキャンセルすると、Hub にある instance がすべてキャンセル扱いになる
+	if err := cal.Cancel("team@example.com", "practice"); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ := runFetchGoogleEvents(t, repo, cal, "")
+	// 3 instance のうち 1 つは元々キャンセル済みで Hub に無い
+	if counts["total"] != 3 || counts["cancelled"] != 2 || counts["skipped"] != 1 {
+		t.Errorf("cancel run = %v", counts)
+	}
+	events, err := repo.Events().Find(ctx, repository.EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Google.ID != "camp" {
+		t.Errorf("events = %+v", events)
+	}
+	all, err := repo.Events().Find(ctx, repository.EventQuery{IncludeCancelled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("cancelled events should be kept: %+v", all)
+	}
+	for _, ev := range all {
+		if ev.Google.ID != "camp" && (!ev.Cancelled || !ev.ShouldSkipReminders(models.RTFinalCall)) {
+			t.Errorf("%s should be cancelled", ev.Google.ID)
+		}
+	}
+
+	// #ignore を付けられたイベントもキャンセル扱いにする
+	cal.Put("team@example.com", mustEvent(t, cal, "camp", "#ignore 夏合宿"))
+	counts, _ = runFetchGoogleEvents(t, repo, cal, "")
+	if counts["cancelled"] != 1 {
+		t.Errorf("ignore run = %v", counts)
+	}
+
+	// 全件同期では、カレンダーから見つからなくなった今後の Event をキャンセル扱いにする
+	repo.Events().Put(ctx, &models.Event{Google: models.GoogleEvent{
+		ID: "deleted", Title: "#練習 削除済み", StartTime: time.Now().Add(48 * time.Hour).UnixMilli(), EndTime: time.Now().Add(50 * time.Hour).UnixMilli(),
+	}})
+	counts, mode := runFetchGoogleEvents(t, repo, cal, "?full=1")
+	if mode != "full" || counts["removed"] != 1 {
+		t.Errorf("full run = %s %v", mode, counts)
+	}
+	if ev, _ := repo.Events().Get(ctx, "deleted"); !ev.Cancelled {
+		t.Error("event missing in calendar should be cancelled")
+	}
+}
+
+func TestCronFetchGoogleEvents_Reschedule(t *testing.T) {
+	ctx := context.Background()
+	cal, repo, fake := newFetchTest(t)
+	runFetchGoogleEvents(t, repo, cal, "")
+
+	events, err := repo.Events().Find(ctx, repository.EventQuery{})
+	if err != nil || len(events) == 0 {
+		t.Fatalf("events = %v, %v", events, err)
+	}
+	first := events[0] // practice の初回
+	for member, typ := range map[string]models.ParticipationType{"UJOIN": models.PTJoin, "UABSENT": models.PTAbsent} {
+		if _, err := repo.Participations().Update(ctx, first.Google.ID, member, func(p *models.Participation) error {
+			return p.Answer(typ, nil, time.Now())
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 開始を 4 時間遅らせる（繰り返しの 1 回だけを変更）
+	instance := mustEvent(t, cal, first.Google.ID, "#練習 定期練習（午後）")
+	start, _ := time.Parse(time.RFC3339, instance.Start.DateTime)
+	end, _ := time.Parse(time.RFC3339, instance.End.DateTime)
+	instance.Id = "practice-moved"
+	instance.Start = &calendar.EventDateTime{DateTime: start.Add(4 * time.Hour).Format(time.RFC3339)}
+	instance.End = &calendar.EventDateTime{DateTime: end.Add(4 * time.Hour).Format(time.RFC3339)}
+	cal.Put("team@example.com", instance)
+
+	counts, _ := runFetchGoogleEvents(t, repo, cal, "")
+	if counts["updated"] != 1 || counts["rescheduled"] != 1 || counts["notified"] != 2 {
+		t.Errorf("reschedule run = %v", counts)
+	}
+	ev, err := repo.Events().Get(ctx, first.Google.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Google.StartTime != start.Add(4*time.Hour).UnixMilli() {
+		t.Errorf("start = %v", ev.Google.Start())
+	}
+	msgs := fake.Messages("DUJOIN")
+	if len(msgs) != 1 || !strings.Contains(msgs[0].Text, "変更前: "+formatEventSpan(first.Google)) || !strings.Contains(msgs[0].Text, "変更後: "+formatEventSpan(ev.Google)) {
+		t.Errorf("DM = %+v", msgs)
+	}
+
+	// タイトルだけの変更では通知しない
+	cal.Put("team@example.com", mustEvent(t, cal, first.Google.ID, "#練習 定期練習（午後・雨天決行）"))
+	counts, _ = runFetchGoogleEvents(t, repo, cal, "")
+	if counts["updated"] != 1 || counts["rescheduled"] != 0 || counts["notified"] != 0 {
+		t.Errorf("title-only run = %v", counts)
+	}
+}
+
+const fetchTestLeagueYAML = `
+league@example.com:
+  - id: league01
+    summary: 秋季リーグ 第1節
+    start: "+6d 13:00"
+    end: "+6d 16:00"
+  - id: practice-shared
+    summary: "#練習 合同練習（チームカレンダーにも入っている）"
+    start: "+7d 09:00"
+    end: "+7d 12:00"
+staff@example.com:
+  - id: staff01
+    summary: "#meeting スタッフ会議"
+    start: "+3d 21:00"
+    end: "+3d 22:00"
+team@example.com:
+  - id: practice-shared
+    summary: "#練習 合同練習"
+    start: "+7d 09:00"
+    end: "+7d 12:00"
+`
+
+func TestCronFetchGoogleEvents_MultipleCalendars(t *testing.T) {
+	ctx := context.Background()
+	_, repo, _ := newFetchTest(t)
+	t.Setenv("GOOGLE_CALENDARS", `
+- name: team
+  calendar_id: team@example.com
+- name: league
+  calendar_id: league@example.com
+  tags: ["試合"]
+- name: staff
+  calendar_id: staff@example.com
+  visibility: staff
+`)
+	cal, err := gcal.ParseYAML([]byte(fetchTestLeagueYAML), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 複数カレンダー対応以前に取り込んだ Event は主カレンダー（team）のもの
+	legacy := models.Event{Google: models.GoogleEvent{ID: "legacy", Title: "#練習 削除済み", StartTime: time.Now().Add(48 * time.Hour).UnixMilli()}}
+	if err := repo.Events().Put(ctx, &legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, _ := runFetchGoogleEvents(t, repo, cal, "")
+	// team: practice-shared, league: league01 + practice-shared（team のものなので skip）, staff: staff01
+	if counts["total"] != 4 || counts["created"] != 3 || counts["skipped"] != 1 || counts["removed"] != 1 {
+		t.Errorf("counts = %v", counts)
+	}
+
+	league, err := repo.Events().Get(ctx, "league01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if league.Google.Source != "league" || !league.IsGame() || league.HasTag(models.ETUnkonwn) {
+		t.Errorf("league event = %+v, tags = %v", league.Google, league.Tags())
+	}
+	shared, _ := repo.Events().Get(ctx, "practice-shared")
+	if shared.Google.Source != "team" || shared.IsGame() {
+		t.Errorf("shared event = %+v", shared.Google)
+	}
+	staff, _ := repo.Events().Get(ctx, "staff01")
+	if staff.Google.Source != "staff" || staff.Google.Visibility != models.EVStaff || !staff.ShouldSkipReminders(models.RTRSVP) {
+		t.Errorf("staff event = %+v", staff.Google)
+	}
+	if staff.VisibleTo(models.Member{}) || !staff.VisibleTo(models.Member{Slack: models.SlackUser{IsAdmin: true}}) {
+		t.Error("staff event should be visible only to staff")
+	}
+	if ev, _ := repo.Events().Get(ctx, "legacy"); !ev.Cancelled {
+		t.Error("legacy event missing in the primary calendar should be cancelled")
+	}
+
+	// league 側でキャンセルされても、team のイベントはキャンセルしない
+	if err := cal.Cancel("league@example.com", "practice-shared"); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ = runFetchGoogleEvents(t, repo, cal, "")
+	if counts["cancelled"] != 0 || counts["skipped"] != 1 {
+		t.Errorf("cancel in other calendar = %v", counts)
+	}
+	if ev, _ := repo.Events().Get(ctx, "practice-shared"); ev.Cancelled {
+		t.Error("event owned by team should not be cancelled by league")
+	}
+}
+
+// TestCronFetchSlackMembers_Lifecycle は、入部・退部・復帰を検知して入退部日と参加状態の変更履歴を残すことを検証する。
+func TestCronFetchSlackMembers_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	users := []slack.User{{ID: "UNEW", Name: "new"}, {ID: "UGONE", Name: "gone", Deleted: true}, {ID: "UBACK", Name: "back"}}
+	fake := slackfake.New(slackfake.DefaultTeam, users)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+	repo := repository.NewMemory()
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UGONE"}, Status: models.MSLimited},
+		{Slack: models.SlackUser{ID: "UBACK", Deleted: true}, LeftAt: time.Now().AddDate(0, -1, 0)},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := filters.SetRepositoryContext(httptest.NewRequest(http.MethodGet, "/tasks/fetch-slack-members", nil), repo)
+	rec := httptest.NewRecorder()
+	CronFetchSlackMembers(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+
+	want := map[string]struct{ From, To models.MemberStatus }{
+		"UNEW":  {"", models.MSActive},
+		"UGONE": {models.MSLimited, models.MSDeleted},
+		"UBACK": {models.MSDeleted, models.MSActive},
+	}
+	for id, w := range want {
+		changes, err := repo.MemberStatusChanges().ListByMember(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 1 || changes[0].From != w.From || changes[0].To != w.To {
+			t.Errorf("%s: changes = %+v", id, changes)
+		}
+	}
+	if m, _ := repo.Members().Get(ctx, "UNEW"); m.JoinedAt.IsZero() {
+		t.Errorf("UNEW: joined_at is not set")
+	}
+	if m, _ := repo.Members().Get(ctx, "UGONE"); m.LeftAt.IsZero() || m.Status != models.MSLimited {
+		t.Errorf("UGONE: %+v", m)
+	}
+	if m, _ := repo.Members().Get(ctx, "UBACK"); !m.LeftAt.IsZero() {
+		t.Errorf("UBACK: left_at = %v", m.LeftAt)
+	}
+
+	// 変化が無ければ履歴を増やさない
+	CronFetchSlackMembers(httptest.NewRecorder(), req)
+	if changes, _ := repo.MemberStatusChanges().ListByMember(ctx, "UGONE"); len(changes) != 1 {
+		t.Errorf("UGONE: changes = %+v", changes)
+	}
+}
+
+func TestFormatEventSpan(t *testing.T) {
+	start := time.Date(2026, 6, 7, 9, 0, 0, 0, server.ServiceLocation)
+	for want, g := range map[string]models.GoogleEvent{
+		"6/7(日) 09:00〜12:00":        {StartTime: start.UnixMilli(), EndTime: start.Add(3 * time.Hour).UnixMilli()},
+		"6/7(日) 09:00〜6/8(月) 12:00": {StartTime: start.UnixMilli(), EndTime: start.Add(27 * time.Hour).UnixMilli()},
+	} {
+		if got := formatEventSpan(g); got != want {
+			t.Errorf("formatEventSpan = %q, want %q", got, want)
+		}
+	}
+}
+
+func mustEvent(t *testing.T, cal *gcal.Memory, id, summary string) *calendar.Event {
+	t.Helper()
+	items, err := cal.List(context.Background(), gcal.Query{
+		CalendarID: "team@example.com",
+		From:       time.Now(),
+		To:         time.Now().AddDate(1, 0, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if item.Id == id {
+			copied := *item
+			copied.Summary = summary
+			return &copied
+		}
+	}
+	t.Fatalf("event %s not found", id)
+	return nil
+}
@@ -0,0 +1,65 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+)
+
+// postHeadcountWarnings は ev の参加予定が最低人数に満たないポジションを、ポジションのグループのチャンネルに警告する。
+// グループに属さないポジション（K など）は #practice に送る。不足が無ければ何も送らない。
+// 送ったチャンネル → 警告したポジションの人数を返す。
+func postHeadcountWarnings(ctx context.Context, repo repository.Repository, ev models.Event) (map[string][]models.PositionHeadcount, error) {
+	members, err := repo.Members().List(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := repo.Participations().ListByEvent(ctx, ev.Google.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	byChannel := map[string][]models.PositionHeadcount{}
+	channels := []string{}
+	for _, c := range models.Shortages(models.NewHeadcounts(ev, parts, members)) {
+		channel := "practice"
+		if g, ok := models.PositionGroupOf(c.Position); ok {
+			channel = g.Channel
+		}
+		if _, ok := byChannel[channel]; !ok {
+			channels = append(channels, channel)
+		}
+		byChannel[channel] = append(byChannel[channel], c)
+	}
+
+	api := server.NewSlackClient()
+	errs := []error{}
+	for _, channel := range channels {
+		if _, _, err := api.PostMessageContext(ctx, "#"+channel, buildHeadcountWarningMessage(ev, byChannel[channel])); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+		}
+	}
+	return byChannel, errors.Join(errs...)
+}
+
+func buildHeadcountWarningMessage(ev models.Event, shortages []models.PositionHeadcount) slack.MsgOption {
+	lines := []string{}
+	for _, c := range shortages {
+		lines = append(lines, "• "+c.Warning())
+	}
+	return slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(
+				":warning: 人数不足 <%s/events/%s|%s>\n%s\n未回答の人は回答を、参加できる人は予定の調整をお願いします。",
+				server.HubBaseURL(), ev.Google.ID, ev.Google.Title, strings.Join(lines, "\n"),
+			), false, false),
+			nil, nil,
+		),
+	)
+}
@@ -0,0 +1,222 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/otiai10/marmoset"
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+	"github.com/triax/hub/server/rsvp"
+)
+
+// reminderGracePeriod を過ぎても送れていないリマインダは送らない（古い案内を今さら流さないため）。
+// cron の tick の間隔より十分長くしておくこと。
+const reminderGracePeriod = 2 * time.Hour
+
+// errReminderNotPlanned は他の tick が先に送信（またはスキップ）したことを表す。
+var errReminderNotPlanned = errors.New("reminder is not planned")
+
+// CronReminderTick は直近のイベントのリマインダを計画し、送信時刻を過ぎたものを送る。
+//
+// 送るタイミングはイベントのタグごとに EventTagRule.Schedule（無ければ models.DefaultReminderSchedule）で決まり、
+// 計画と送信状態は Reminder として保存するので、何度呼ばれても同じリマインダを二度は送らない。
+// cron から 10 分おきに呼ぶ。?dry=1 なら計画だけして送らない。
+func CronReminderTick(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	render := marmoset.Render(w, true)
+	repo := filters.GetRepositoryContext(req)
+	now := time.Now()
+
+	// 1) 計画: リマインダの範囲に入っているイベントについて、予定を作る・直す
+	events, err := repo.Events().Find(ctx, repository.EventQuery{
+		From:             now,
+		To:               now.Add(models.MaxReminderHours * time.Hour),
+		IncludeCancelled: true,
+	})
+	if err != nil {
+		fmt.Println("[ERROR]", 12001, err.Error())
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	planned := 0
+	for _, ev := range events {
+		n, err := planReminders(ctx, repo, ev, now)
+		if err != nil {
+			fmt.Println("[ERROR]", 12002, ev.Google.ID, err.Error())
+			continue
+		}
+		planned += n
+	}
+
+	if req.URL.Query().Get("dry") != "" {
+		due, err := repo.Reminders().ListDue(ctx, now)
+		if err != nil {
+			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+			return
+		}
+		render.JSON(http.StatusOK, marmoset.P{"events": len(events), "planned": planned, "due": due})
+		return
+	}
+
+	// 2) 送信: 送信時刻を過ぎたものを送る
+	due, err := repo.Reminders().ListDue(ctx, now)
+	if err != nil {
+		fmt.Println("[ERROR]", 12003, err.Error())
+		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+		return
+	}
+	result := map[models.ReminderStatus][]string{models.RSSent: {}, models.RSSkipped: {}, models.RSFailed: {}}
+	for _, r := range due {
+		status, err := dispatchReminder(ctx, repo, r, now)
+		if errors.Is(err, errReminderNotPlanned) {
+			continue
+		}
+		if err != nil {
+			fmt.Println("[ERROR]", 12004, r.KeyName(), err.Error())
+		}
+		result[status] = append(result[status], r.KeyName())
+	}
+
+	render.JSON(http.StatusOK, marmoset.P{"events": len(events), "planned": planned, "reminders": result})
+}
+
+// planReminders は ev の ReminderSchedule に合わせて Reminder を作る・直し、書き込んだ件数を返す。
+//
+//   - 予定に無い送信待ちのものはスキップにする（タグが変わった・キャンセルされたなど）。
+//   - 送信待ちのものは、イベントの日時が変わっていれば送信時刻を付け直す。
+//   - 送信済み・失敗したものには触らない。
+//   - 送信時刻から reminderGracePeriod 以上過ぎたものは、送らずにスキップとして記録する。
+func planReminders(ctx context.Context, repo repository.Repository, ev models.Event, now time.Time) (int, error) {
+	existing, err := repo.Reminders().ListByEvent(ctx, ev.Google.ID)
+	if err != nil {
+		return 0, err
+	}
+	byKey := map[string]models.Reminder{}
+	for _, r := range existing {
+		byKey[r.KeyName()] = r
+	}
+
+	changes := []models.Reminder{}
+	wanted := map[string]bool{}
+	for _, o := range ev.ReminderSchedule() {
+		r := models.Reminder{
+			EventID: ev.Google.ID,
+			Type:    o.Type,
+			Hours:   o.Hours,
+			FireAt:  ev.Google.Start().Add(-time.Duration(o.Hours) * time.Hour),
+			Status:  models.RSPlanned,
+		}
+		if now.Sub(r.FireAt) > reminderGracePeriod {
+			r.Status, r.Reason = models.RSSkipped, "too late to send"
+		}
+		wanted[r.KeyName()] = true
+		prev, ok := byKey[r.KeyName()]
+		if ok && (prev.Status == models.RSSent || prev.Status == models.RSFailed) {
+			continue
+		}
+		if ok && prev.FireAt.Equal(r.FireAt) && prev.Status == r.Status && prev.Reason == r.Reason {
+			continue
+		}
+		changes = append(changes, r)
+	}
+	for _, prev := range existing {
+		if prev.Status == models.RSPlanned && !wanted[prev.KeyName()] {
+			prev.Status, prev.Reason = models.RSSkipped, "not scheduled"
+			changes = append(changes, prev)
+		}
+	}
+
+	for i := range changes {
+		if err := repo.Reminders().Put(ctx, &changes[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(changes), nil
+}
+
+// dispatchReminder は送信待ちの r を送り、記録した状態を返す。
+//
+// 送信の前に RSSent を書き込んでから送るので、同時に動いた tick と重複して送ることはない
+// （代わりに送信に失敗したものは RSFailed として残し、再送はしない）。
+func dispatchReminder(ctx context.Context, repo repository.Repository, r models.Reminder, now time.Time) (models.ReminderStatus, error) {
+	ev, err := repo.Events().Get(ctx, r.EventID)
+	reason := ""
+	switch {
+	case err != nil:
+		reason = fmt.Sprintf("event not found: %v", err)
+	case !slices.Contains(ev.ReminderSchedule(), models.ReminderOffset{Type: r.Type, Hours: r.Hours}):
+		reason = "not scheduled"
+	case !ev.Google.Start().Add(-time.Duration(r.Hours) * time.Hour).Equal(r.FireAt):
+		reason = "event has been rescheduled" // 次の tick で計画し直す
+	case !ev.Google.Start().After(now):
+		reason = "event has already started"
+	case now.Sub(r.FireAt) > reminderGracePeriod:
+		reason = "too late to send"
+	}
+
+	if _, err := repo.Reminders().Update(ctx, r.KeyName(), func(x *models.Reminder) error {
+		if x.Status != models.RSPlanned {
+			return errReminderNotPlanned
+		}
+		if reason != "" {
+			x.Status, x.Reason = models.RSSkipped, reason
+			return nil
+		}
+		x.Status, x.SentAt = models.RSSent, now
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	if reason != "" {
+		return models.RSSkipped, nil
+	}
+
+	if err := sendReminder(ctx, repo, r.Type, *ev); err != nil {
+		if _, uerr := repo.Reminders().Update(ctx, r.KeyName(), func(x *models.Reminder) error {
+			x.Status, x.Reason = models.RSFailed, err.Error()
+			return nil
+		}); uerr != nil {
+			fmt.Println("[ERROR]", 12005, r.KeyName(), uerr.Error())
+		}
+		return models.RSFailed, err
+	}
+	return models.RSSent, nil
+}
+
+// sendReminder は種別ごとのリマインダを送る。
+func sendReminder(ctx context.Context, repo repository.Repository, rt models.ReminderType, ev models.Event) error {
+	switch rt {
+	case models.RTRSVP:
+		x, err := rsvp.Summarize(ctx, repo, ev)
+		if err != nil {
+			return err
+		}
+		_, err = postRSVPReminder(ev, x, "general")
+		return err
+	case models.RTFinalCall:
+		errs := []error{}
+		for _, g := range models.PositionGroups {
+			if _, _, err := postFinalCall(ctx, repo, ev, g.Roles, g.Channel); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", g.Channel, err))
+			}
+		}
+		return errors.Join(errs...)
+	case models.RTEquipment:
+		_, err := remindEquipsToBring(ctx, repo, ev)
+		return err
+	case models.RTCondition:
+		_, err := postConditionForm(ctx, ev, "before", "", "condi-check")
+		return err
+	case models.RTHeadcount:
+		_, err := postHeadcountWarnings(ctx, repo, ev)
+		return err
+	default:
+		return fmt.Errorf("unknown reminder type %q", rt)
+	}
+}
@@ -0,0 +1,189 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/triax/hub/server/filters"
+	"github.com/triax/hub/server/models"
+	"github.com/triax/hub/server/repository"
+	"github.com/triax/hub/slackfake"
+)
+
+func runReminderTick(t *testing.T, repo repository.Repository) map[models.ReminderStatus][]string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/reminders/tick", nil)
+	req = filters.SetRepositoryContext(req, repo)
+	rec := httptest.NewRecorder()
+	CronReminderTick(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (body=%s)", rec.Code, rec.Body.String())
+	}
+	res := struct {
+		Reminders map[models.ReminderStatus][]string `json:"reminders"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Reminders
+}
+
+func reminderStatuses(t *testing.T, repo repository.Repository, eventID string) map[string]models.Reminder {
+	t.Helper()
+	reminders, err := repo.Reminders().ListByEvent(context.Background(), eventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byKey := map[string]models.Reminder{}
+	for _, r := range reminders {
+		byKey[r.KeyName()] = r
+	}
+	return byKey
+}
+
+func TestCronReminderTick(t *testing.T) {
+	ctx := context.Background()
+	fake := slackfake.New(slackfake.DefaultTeam, nil)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+	repo := repository.NewMemory()
+
+	// 72 時間前のリマインダの送信時刻を 30 分過ぎた練習（出欠確認は締め切り 67 時間前より前の 72 時間前だけ）
+	start := time.Now().Add(72*time.Hour - 30*time.Minute).Truncate(time.Minute)
+	ev := &models.Event{Google: models.GoogleEvent{ID: "practice", Title: "#練習 グラウンド", StartTime: start.UnixMilli(), EndTime: start.Add(3 * time.Hour).UnixMilli()}}
+	if err := repo.Events().Put(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+
+	res := runReminderTick(t, repo)
+	if len(res[models.RSSent]) != 1 || len(res[models.RSFailed]) != 0 {
+		t.Errorf("first tick = %v", res)
+	}
+	got := reminderStatuses(t, repo, "practice")
+	want := map[string]models.ReminderStatus{
+		"practice_rsvp_72h":       models.RSSent,
+		"practice_headcount_48h":  models.RSPlanned,
+		"practice_equipment_24h":  models.RSPlanned,
+		"practice_final_call_18h": models.RSPlanned,
+	}
+	for key, status := range want {
+		if got[key].Status != status {
+			t.Errorf("%s = %q, want %q", key, got[key].Status, status)
+		}
+	}
+	if n := len(fake.Messages("general")); n != 2 { // 出欠状況 + 未回答者へのメンション
+		t.Errorf("messages in #general = %d, want 2", n)
+	}
+
+	// 何度 tick しても同じリマインダは送らない
+	res = runReminderTick(t, repo)
+	if len(res[models.RSSent]) != 0 {
+		t.Errorf("second tick = %v", res)
+	}
+	if n := len(fake.Messages("general")); n != 2 {
+		t.Errorf("messages in #general = %d after second tick, want 2", n)
+	}
+
+	// 日時が変われば送信待ちのものは送信時刻を付け直す
+	moved := start.Add(2 * time.Hour)
+	if _, err := repo.Events().Update(ctx, "practice", func(e *models.Event) error {
+		e.Google.StartTime = moved.UnixMilli()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	runReminderTick(t, repo)
+	got = reminderStatuses(t, repo, "practice")
+	if fc := got["practice_final_call_18h"]; fc.Status != models.RSPlanned || !fc.FireAt.Equal(moved.Add(-18*time.Hour)) {
+		t.Errorf("final call after reschedule = %+v", fc)
+	}
+
+	// キャンセルされたら送信待ちのものはスキップする
+	if _, err := repo.Events().Update(ctx, "practice", func(e *models.Event) error {
+		e.Cancelled = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	runReminderTick(t, repo)
+	got = reminderStatuses(t, repo, "practice")
+	if fc := got["practice_final_call_18h"]; fc.Status != models.RSSkipped {
+		t.Errorf("final call after cancel = %+v", fc)
+	}
+	if got["practice_rsvp_72h"].Status != models.RSSent {
+		t.Errorf("sent reminder must stay sent: %+v", got["practice_rsvp_72h"])
+	}
+}
+
+func TestCronReminderTick_FinalCall(t *testing.T) {
+	ctx := context.Background()
+	fake := slackfake.New(slackfake.DefaultTeam, nil)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+	repo := repository.NewMemory()
+
+	// 直前確認（18 時間前）だけが送信時刻を過ぎた試合
+	start := time.Now().Add(18*time.Hour - 5*time.Minute)
+	ev := &models.Event{Google: models.GoogleEvent{ID: "game", Title: "#試合 vs X", StartTime: start.UnixMilli(), EndTime: start.Add(3 * time.Hour).UnixMilli()}}
+	if err := repo.Events().Put(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+
+	res := runReminderTick(t, repo)
+	if len(res[models.RSSent]) != 1 || res[models.RSSent][0] != "game_final_call_18h" {
+		t.Errorf("tick = %v", res)
+	}
+	for _, g := range models.PositionGroups {
+		if n := len(fake.Messages(g.Channel)); n != 1 {
+			t.Errorf("messages in #%s = %d, want 1", g.Channel, n)
+		}
+	}
+}
+
+func TestCronReminderTick_Headcount(t *testing.T) {
+	ctx := context.Background()
+	fake := slackfake.New(slackfake.DefaultTeam, nil)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+	repo := repository.NewMemory()
+
+	// QB は 1 人いるが OL が足りない試合（人数不足の警告は 48 時間前）
+	for _, m := range []models.Member{
+		{Slack: models.SlackUser{ID: "UQB"}, Positions: models.MemberPositions{Primary: "QB", Unit: "offence"}},
+		{Slack: models.SlackUser{ID: "UOL"}, Positions: models.MemberPositions{Primary: "OL", Unit: "offence"}},
+	} {
+		if err := repo.Members().Put(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(48*time.Hour - 5*time.Minute)
+	ev := &models.Event{Google: models.GoogleEvent{ID: "game", Title: "#試合 vs X", StartTime: start.UnixMilli(), EndTime: start.Add(3 * time.Hour).UnixMilli()}}
+	if err := repo.Events().Put(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Participations().Update(ctx, "game", "UQB", func(p *models.Participation) error {
+		return p.Answer(models.PTJoin, nil, time.Now())
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	res := runReminderTick(t, repo)
+	if got := res[models.RSSent]; len(got) != 1 || got[0] != "game_headcount_48h" {
+		t.Errorf("tick = %v", res)
+	}
+	if n := len(fake.Messages("offence")); n != 1 {
+		t.Fatalf("messages in #offence = %d, want 1", n)
+	}
+	for _, ch := range []string{"defence", "staff"} {
+		if n := len(fake.Messages(ch)); n != 0 {
+			t.Errorf("messages in #%s = %d, want 0", ch, n)
+		}
+	}
+}
@@ -1,28 +1,21 @@
 package tasks
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/datastore"
 	"github.com/otiai10/marmoset"
 	"github.com/slack-go/slack"
 	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/filters"
 	"github.com/triax/hub/server/models"
-)
-
-var (
-	rsvp = template.Must(template.New("x").Parse(
-		`参加:		{{len (index . "join")}}
-不参加:	{{len (index . "absent")}}
-未回答:	{{len (index . "unanswered")}}`))
+	"github.com/triax/hub/server/repository"
+	"github.com/triax/hub/server/rsvp"
 )
 
 func FinalCall(w http.ResponseWriter, req *http.Request) {
@@ -31,13 +24,9 @@ func FinalCall(w http.ResponseWriter, req *http.Request) {
 	roles := strings.Split(req.URL.Query().Get("role"), ",")
 	channel := req.URL.Query().Get("channel")
 
-	members, err := models.GetAllMembersAsDict(ctx)
-	if err != nil {
-		render.JSON(http.StatusBadRequest, marmoset.P{"error": err})
-		return
-	}
+	repo := filters.GetRepositoryContext(req)
 
-	events, err := models.FindEventsBetween(ctx)
+	events, err := repository.FindEventsBetween(ctx, repo.Events())
 	if err != nil {
 		render.JSON(http.StatusBadRequest, marmoset.P{"error": err})
 		return
@@ -52,12 +41,28 @@ func FinalCall(w http.ResponseWriter, req *http.Request) {
 		render.JSON(http.StatusOK, marmoset.P{"events": events, "error": fmt.Errorf("should ignore: %s", ev.Google.Title)})
 		return
 	}
-	pats, err := ev.Participations()
+
+	joins, unans, err := postFinalCall(ctx, repo, ev, roles, channel)
 	if err != nil {
-		render.JSON(http.StatusBadRequest, marmoset.P{"error": fmt.Errorf("JSON decode error: %v", err)})
+		render.JSON(http.StatusBadRequest, marmoset.P{"error": err})
 		return
 	}
 
+	render.JSON(http.StatusOK, marmoset.P{"roles": roles, "channel": channel, "joins": joins, "unans": unans})
+}
+
+// postFinalCall は roles のメンバーのうち ev に参加する人と未回答の人を channel に投稿する。
+func postFinalCall(ctx context.Context, repo repository.Repository, ev models.Event, roles []string, channel string) (map[string][]models.Member, []models.Member, error) {
+	members, err := repository.GetAllMembersAsDict(ctx, repo.Members())
+	if err != nil {
+		return nil, nil, err
+	}
+	parts, err := repo.Participations().ListByEvent(ctx, ev.Google.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	pats := models.ParticipationsOf(parts)
+
 	joins := map[string][]models.Member{}
 	unans := []models.Member{}
 
@@ -65,11 +70,7 @@ func FinalCall(w http.ResponseWriter, req *http.Request) {
 		if !member.IsExpectedToRSVP() {
 			continue
 		}
-		yes, role, err := member.IsMemberOf(roles...)
-		if err != nil {
-			render.JSON(http.StatusBadRequest, marmoset.P{"error": fmt.Errorf("title regexp compile error: %v", err)})
-			return
-		}
+		role, yes := member.Plays(roles...)
 		if !yes {
 			continue
 		}
@@ -81,37 +82,29 @@ func FinalCall(w http.ResponseWriter, req *http.Request) {
 	}
 
 	msg := buildFinalCallMessage(ev, roles, joins, unans)
-	token := os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN")
-	api := slack.New(token)
+	api := server.NewSlackClient()
 	if _, _, err = api.PostMessage("#"+channel, msg); err != nil {
-		render.JSON(http.StatusBadRequest, marmoset.P{"error": err})
-		return
+		return nil, nil, err
 	}
-
-	render.JSON(http.StatusOK, marmoset.P{"roles": roles, "channel": channel, "joins": joins, "unans": unans})
+	return joins, unans, nil
 }
 
 func CronCheckRSVP(w http.ResponseWriter, req *http.Request) {
 	render := marmoset.Render(w)
 	ctx := req.Context()
-	client, err := datastore.NewClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
-	if err != nil {
-		log.Println("[ERROR]", 4001, err.Error())
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
-	}
-	defer client.Close()
+	repo := filters.GetRepositoryContext(req)
 
-	events := []models.Event{}
 	os.Setenv("TZ", "Asia/Tokyo")
 	after := 3 * 24 * time.Hour // 3日後のイベントを対象
 	now := time.Now()
 	_00AMof3daysLater := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).Add(after)
 	_23PMof3daysLater := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, time.Local).Add(after)
-	if _, err := client.GetAll(ctx, datastore.NewQuery(models.KindEvent).
-		Filter("Google.StartTime >", _00AMof3daysLater.Unix()*1000).
-		Filter("Google.StartTime <", _23PMof3daysLater.Unix()*1000).
-		Order("Google.StartTime").Limit(1), &events); err != nil {
+	events, err := repo.Events().Find(ctx, repository.EventQuery{
+		From:  _00AMof3daysLater,
+		To:    _23PMof3daysLater,
+		Limit: 1,
+	})
+	if err != nil {
 		log.Println("[ERROR]", 4002, err.Error())
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
@@ -129,45 +122,12 @@ func CronCheckRSVP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	participations := map[string]models.Participation{}
-	if err := json.NewDecoder(strings.NewReader(recent.ParticipationsJSONString)).Decode(&participations); err != nil {
-		log.Println("[ERROR]", 4004, err.Error())
-		render.JSON(http.StatusBadRequest, marmoset.P{"events": events, "error": err.Error()})
-		return
-	}
-
-	members := []models.Member{}
-	if _, err := client.GetAll(ctx, datastore.NewQuery(models.KindMember).
-		Filter("Slack.Deleted =", false), &members); err != nil && !models.IsFiledMismatch(err) {
-		log.Println("[ERROR]", 4005, err.Error())
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
+	x, err := rsvp.Summarize(ctx, repo, recent)
+	if err != nil {
+		render.JSON(http.StatusInternalServerError, marmoset.P{"events": events, "error": err.Error()})
 		return
 	}
 
-	x := map[string][]models.Member{
-		"join":       {},
-		"absent":     {},
-		"unanswered": {},
-	}
-
-	for _, member := range members {
-		if !member.IsExpectedToRSVP() {
-			continue
-		}
-		if p, ok := participations[member.Slack.ID]; ok {
-			switch p.Type {
-			case models.PTJoin, models.PTJoinLate, models.PTLeaveEarly:
-				x["join"] = append(x["join"], member)
-			case models.PTAbsent:
-				x["absent"] = append(x["absent"], member)
-			default:
-				x["join"] = append(x["join"], member)
-			}
-		} else {
-			x["unanswered"] = append(x["unanswered"], member)
-		}
-	}
-
 	if req.URL.Query().Get("dry") != "" {
 		render.JSON(200, x)
 		return
@@ -177,42 +137,42 @@ func CronCheckRSVP(w http.ResponseWriter, req *http.Request) {
 	if channel == "" {
 		channel = "general"
 	}
-	evURL := fmt.Sprintf("%s/events/%s", server.HubBaseURL(), recent.Google.ID)
-	link := fmt.Sprintf("<%s|:triax::football::spiral_calendar_pad: %s>", evURL, recent.Google.Title)
-	text := bytes.NewBuffer(nil)
-	if err := rsvp.Execute(text, x); err != nil {
-		log.Println("[ERROR]", 4006, err.Error())
+	ts, err := postRSVPReminder(recent, x, channel)
+	if err != nil {
 		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
 		return
 	}
 
-	token := os.Getenv("SLACK_BOT_USER_OAUTH_TOKEN")
-	api := slack.New(token)
-	_, ts, err := api.PostMessage("#"+channel, slack.MsgOptionBlocks(
-		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, recent.Google.Title, false, false)),
-		slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, text.String(), false, false), nil, nil),
-		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, link, false, false), nil, nil),
-	))
+	render.JSON(http.StatusOK, marmoset.P{"event": recent, "summary": map[string]int{
+		string(models.PTJoin):       len(x[string(models.PTJoin)]),
+		string(models.PTAbsent):     len(x[string(models.PTAbsent)]),
+		string(models.PTUnanswered): len(x[string(models.PTUnanswered)]),
+	}, "timestamp": ts})
+}
+
+// postRSVPReminder は出欠状況 x を channel に投稿し、（#event / #sponsor 以外は）未回答者をスレッドでメンションする。
+// 出欠状況のメッセージには回答ボタンが付き、押されると slackbot が回答を書き込んでメッセージを更新する。
+func postRSVPReminder(ev models.Event, x rsvp.Summary, channel string) (string, error) {
+	blocks, err := rsvp.SummaryBlocks(ev, x)
+	if err != nil {
+		return "", err
+	}
+
+	api := server.NewSlackClient()
+	_, ts, err := api.PostMessage("#"+channel, slack.MsgOptionText(ev.Google.Title, false), slack.MsgOptionBlocks(blocks...))
 	if err != nil {
 		log.Println("[ERROR]", 4007, err.Error())
-		render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-		return
+		return "", err
 	}
 
-	if !recent.HasTag(models.ETEvent) && !recent.HasTag(models.ETSponsor) { // #event / #sponsor を含むイベントは未回答者メンションをスキップ
-		reminder := buildRSVPReminderMentionMessage(recent, x["unanswered"])
+	if !ev.HasTag(models.ETEvent) && !ev.HasTag(models.ETSponsor) { // #event / #sponsor を含むイベントは未回答者メンションをスキップ
+		reminder := buildRSVPReminderMentionMessage(ev, x["unanswered"])
 		if _, _, err := api.PostMessage("#"+channel, reminder, slack.MsgOptionTS(ts)); err != nil {
 			log.Println("[ERROR]", 4008, err.Error())
-			render.JSON(http.StatusInternalServerError, marmoset.P{"error": err.Error()})
-			return
+			return ts, err
 		}
 	}
-
-	render.JSON(http.StatusOK, marmoset.P{"event": recent, "summary": map[string]int{
-		string(models.PTJoin):       len(x[string(models.PTJoin)]),
-		string(models.PTAbsent):     len(x[string(models.PTAbsent)]),
-		string(models.PTUnanswered): len(x[string(models.PTUnanswered)]),
-	}, "timestamp": ts})
+	return ts, nil
 }
 
 // 回答催促のメンション
@@ -227,7 +187,7 @@ func buildRSVPReminderMentionMessage(ev models.Event, unanswers []models.Member)
 	return slack.MsgOptionBlocks(
 		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "出欠未回答の皆さまへ", false, false)),
 		slack.NewSectionBlock(
-			slack.NewTextBlockObject(slack.MarkdownType, "下記のリンクから練習や試合の出欠回答ができます。伝助より使いやすいと思うので、サクッと回答お願いします。\n*<"+evURL+"|【Triax Team Hub】>*", false, false),
+			slack.NewTextBlockObject(slack.MarkdownType, "上のメッセージのボタン、または下記のリンクから練習や試合の出欠回答ができます。伝助より使いやすいと思うので、サクッと回答お願いします。\n*<"+evURL+"|【Triax Team Hub】>*", false, false),
 			nil, slack.NewAccessory(slack.NewImageBlockElement("https://avatars.slack-edge.com/2021-08-16/2369588425687_e490e60131c70bf52eee_192.png", "Triax Team Hub")),
 		),
 		slack.NewSectionBlock(
@@ -262,8 +222,8 @@ func buildFinalCallMessage(event models.Event, roles []string, joins map[string]
 		if len(joins[role]) == 0 {
 			blocks = append(blocks, slack.NewContextBlock("",
 				slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(
-					"Slackプロフィールで「役職（Title）」を「%s」や「%s」などに設定している人はいません.",
-					role, strings.ToUpper(role),
+					"hub のメンバーページでポジション（役割）を「%s」に設定している人はいません.",
+					strings.ToUpper(role),
 				), false, false),
 			))
 		} else {
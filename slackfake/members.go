@@ -0,0 +1,29 @@
+package slackfake
+
+import (
+	"encoding/json"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server/models"
+)
+
+// DefaultTeam は team.info が返す workspace（fixtures の Member と同じ TeamID）。
+var DefaultTeam = slack.TeamInfo{ID: "T9LHPRHA6", Name: "Triax (fake)", Domain: "triax-fake"}
+
+// UsersFromMembers は hub の Member（fixtures など）を users.list が返す slack.User に変換する。
+// models.SlackUser は Slack の JSON 表現をそのまま写したものなので JSON を経由して詰め替える。
+func UsersFromMembers(members []models.Member) ([]slack.User, error) {
+	users := make([]slack.User, 0, len(members))
+	for _, m := range members {
+		b, err := json.Marshal(m.Slack)
+		if err != nil {
+			return nil, err
+		}
+		u := slack.User{}
+		if err := json.Unmarshal(b, &u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
@@ -0,0 +1,468 @@
+// Package slackfake は、ローカル開発・テスト用の Slack Web API の代役サーバ。
+//
+// hub が使う API（chat.postMessage / chat.update / conversations.open / conversations.history /
+// conversations.replies / conversations.info / conversations.list / users.list / users.info /
+// team.info / reactions.get）だけを実装し、投稿されたメッセージはメモリに記録する。
+// 本物の workspace へ投稿せずに cron やボットの動作を確認するために使う。
+//
+// hub 側は SLACK_API_URL にこのサーバの "/api/" を設定すると、server.NewSlackClient が
+// 作るすべての client がこちらへ向く。
+package slackfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// channelID は Slack のチャンネル ID（"C06SZGR7L1W" など）の形。名前指定と区別するために使う。
+var channelID = regexp.MustCompile(`^[CDG][A-Z0-9]{6,}$`)
+
+// View は views.open で開かれたモーダル。
+type View struct {
+	TriggerID string                 `json:"trigger_id"`
+	View      slack.ModalViewRequest `json:"view"`
+}
+
+// Message は記録されたメッセージ。
+type Message struct {
+	Channel  string          `json:"channel"`
+	TS       string          `json:"ts"`
+	ThreadTS string          `json:"thread_ts,omitempty"`
+	User     string          `json:"user,omitempty"`
+	Text     string          `json:"text"`
+	Blocks   json.RawMessage `json:"blocks,omitempty"`
+	Updated  bool            `json:"updated,omitempty"`
+
+	reactions []slack.ItemReaction
+}
+
+// Server は Slack Web API の代役。ゼロ値ではなく New で作る。
+type Server struct {
+	// BotUserID は投稿者として記録する bot の user ID。
+	BotUserID string
+
+	mu       sync.Mutex
+	team     slack.TeamInfo
+	users    []slack.User
+	channels []slack.Channel
+	messages []*Message
+	views    []View
+	seq      int64
+	now      func() time.Time
+}
+
+// New は users を workspace のメンバーとして持つ Server を作る。
+// "general" チャンネルは最初から存在する。
+func New(team slack.TeamInfo, users []slack.User) *Server {
+	s := &Server{
+		BotUserID: "UFAKEBOT",
+		team:      team,
+		users:     users,
+		now:       time.Now,
+	}
+	s.channelByName("general")
+	return s
+}
+
+// Messages は記録されたメッセージを投稿順に返す。channel が空でなければそのチャンネルに絞る。
+// channel はチャンネル ID・"#名前"・名前のいずれでもよい。
+func (s *Server) Messages(channel string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := ""
+	if channel != "" {
+		ch, ok := s.findChannel(channel)
+		if !ok {
+			return []Message{}
+		}
+		id = ch.ID
+	}
+	msgs := []Message{}
+	for _, m := range s.messages {
+		if id == "" || m.Channel == id {
+			msgs = append(msgs, *m)
+		}
+	}
+	return msgs
+}
+
+// Views は views.open で開かれたモーダルを開いた順に返す。
+func (s *Server) Views() []View {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]View{}, s.views...)
+}
+
+// Reset は記録されたメッセージを消す（users / channels は残す）。
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// AddReaction はメッセージにリアクションを付ける（reactions.get の結果に反映される）。
+func (s *Server) AddReaction(channel, ts, name string, users ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, _ := s.findChannel(channel)
+	m := s.findMessage(ch.ID, ts)
+	if m == nil {
+		return fmt.Errorf("message not found: %s %s", channel, ts)
+	}
+	for i, r := range m.reactions {
+		if r.Name == name {
+			m.reactions[i].Users = append(m.reactions[i].Users, users...)
+			m.reactions[i].Count = len(m.reactions[i].Users)
+			return nil
+		}
+	}
+	m.reactions = append(m.reactions, slack.ItemReaction{Name: name, Count: len(users), Users: users})
+	return nil
+}
+
+// ServeHTTP は "/api/{method}" を処理する。"/messages" は記録されたメッセージを JSON で返す（確認用）。
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/messages" {
+		writeJSON(w, s.Messages(req.URL.Query().Get("channel")))
+		return
+	}
+	method := strings.TrimPrefix(req.URL.Path, "/api/")
+	if method == req.URL.Path {
+		http.NotFound(w, req)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		writeJSON(w, failure("invalid_form_data"))
+		return
+	}
+	handler, ok := map[string]func(*http.Request) interface{}{
+		"chat.postMessage":      s.postMessage,
+		"chat.update":           s.update,
+		"conversations.open":    s.openConversation,
+		"conversations.history": s.history,
+		"conversations.replies": s.replies,
+		"conversations.info":    s.conversationInfo,
+		"conversations.list":    s.conversationList,
+		"users.list":            s.userList,
+		"users.info":            s.userInfo,
+		"team.info":             s.teamInfo,
+		"reactions.get":         s.reactions,
+		"views.open":            s.openView,
+	}[method]
+	if !ok {
+		writeJSON(w, failure("unknown_method"))
+		return
+	}
+	writeJSON(w, handler(req))
+}
+
+type response map[string]interface{}
+
+func success(r response) response {
+	r["ok"] = true
+	return r
+}
+
+func failure(code string) response {
+	return response{"ok": false, "error": code}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) postMessage(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channel := req.FormValue("channel")
+	if channel == "" {
+		return failure("channel_not_found")
+	}
+	ch := s.resolveChannel(channel)
+	s.seq++
+	m := &Message{
+		Channel:  ch.ID,
+		TS:       fmt.Sprintf("%d.%06d", s.now().Unix(), s.seq),
+		ThreadTS: req.FormValue("thread_ts"),
+		User:     s.BotUserID,
+		Text:     req.FormValue("text"),
+		Blocks:   rawBlocks(req.FormValue("blocks")),
+	}
+	s.messages = append(s.messages, m)
+	return success(response{"channel": m.Channel, "ts": m.TS, "message": s.toSlack(m)})
+}
+
+func (s *Server) update(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := s.resolveChannel(req.FormValue("channel"))
+	m := s.findMessage(ch.ID, req.FormValue("ts"))
+	if m == nil {
+		return failure("message_not_found")
+	}
+	if text := req.FormValue("text"); text != "" {
+		m.Text = text
+	}
+	if blocks := rawBlocks(req.FormValue("blocks")); blocks != nil {
+		m.Blocks = blocks
+	}
+	m.Updated = true
+	return success(response{"channel": m.Channel, "ts": m.TS, "text": m.Text})
+}
+
+// openView は JSON で送られるモーダルを記録する。
+func (s *Server) openView(req *http.Request) interface{} {
+	v := View{}
+	if err := json.NewDecoder(req.Body).Decode(&v); err != nil {
+		return failure("invalid_json")
+	}
+	if v.TriggerID == "" {
+		return failure("invalid_trigger_id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.views = append(s.views, v)
+	return success(response{"view": response{"id": fmt.Sprintf("VFAKE%04d", len(s.views)), "type": v.View.Type}})
+}
+
+// openConversation は DM チャンネル（"D" + user ID）を返す。
+func (s *Server) openConversation(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := req.FormValue("users")
+	if users == "" {
+		return failure("users_list_not_supplied")
+	}
+	id := "D" + strings.ReplaceAll(users, ",", "")
+	for _, ch := range s.channels {
+		if ch.ID == id {
+			return success(response{"channel": ch, "already_open": true})
+		}
+	}
+	ch := slack.Channel{}
+	ch.ID = id
+	ch.IsIM = true
+	ch.User = strings.Split(users, ",")[0]
+	s.channels = append(s.channels, ch)
+	return success(response{"channel": ch})
+}
+
+// history はスレッドの親（とスレッド外の投稿）を新しい順に返す。
+func (s *Server) history(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.findChannel(req.FormValue("channel"))
+	if !ok {
+		return failure("channel_not_found")
+	}
+	msgs := []slack.Message{}
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		m := s.messages[i]
+		if m.Channel == ch.ID && (m.ThreadTS == "" || m.ThreadTS == m.TS) && inRange(m.TS, req) {
+			msgs = append(msgs, s.toSlack(m))
+		}
+	}
+	if limit := atoi(req.FormValue("limit")); limit > 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return success(response{"messages": msgs, "has_more": false, "response_metadata": response{"next_cursor": ""}})
+}
+
+// replies はスレッドの親と返信を古い順に返す。
+func (s *Server) replies(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.findChannel(req.FormValue("channel"))
+	if !ok {
+		return failure("channel_not_found")
+	}
+	ts := req.FormValue("ts")
+	msgs := []slack.Message{}
+	for _, m := range s.messages {
+		if m.Channel == ch.ID && (m.TS == ts || m.ThreadTS == ts) {
+			msgs = append(msgs, s.toSlack(m))
+		}
+	}
+	if len(msgs) == 0 {
+		return failure("thread_not_found")
+	}
+	return success(response{"messages": msgs, "has_more": false, "response_metadata": response{"next_cursor": ""}})
+}
+
+func (s *Server) conversationInfo(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.findChannel(req.FormValue("channel"))
+	if !ok {
+		return failure("channel_not_found")
+	}
+	return success(response{"channel": ch})
+}
+
+func (s *Server) conversationList(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chans := []slack.Channel{}
+	for _, ch := range s.channels {
+		if !ch.IsIM {
+			chans = append(chans, ch)
+		}
+	}
+	return success(response{"channels": chans, "response_metadata": response{"next_cursor": ""}})
+}
+
+func (s *Server) userList(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return success(response{"members": s.users, "response_metadata": response{"next_cursor": ""}})
+}
+
+func (s *Server) userInfo(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := req.FormValue("user")
+	for _, u := range s.users {
+		if u.ID == id {
+			return success(response{"user": u})
+		}
+	}
+	return failure("user_not_found")
+}
+
+func (s *Server) teamInfo(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return success(response{"team": s.team})
+}
+
+func (s *Server) reactions(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.findChannel(req.FormValue("channel"))
+	if !ok {
+		return failure("channel_not_found")
+	}
+	m := s.findMessage(ch.ID, req.FormValue("timestamp"))
+	if m == nil {
+		return failure("message_not_found")
+	}
+	return success(response{"type": "message", "channel": ch.ID, "message": s.toSlack(m)})
+}
+
+// resolveChannel は ID・"#名前"・名前からチャンネルを探し、無ければ作る。
+// 本物の Slack と違い、存在しないチャンネルへの投稿もエラーにしない（cron の投稿先を問わないため）。
+// 呼び出し側で s.mu を取っていること。
+func (s *Server) resolveChannel(channel string) slack.Channel {
+	if ch, ok := s.findChannel(channel); ok {
+		return ch
+	}
+	if channelID.MatchString(channel) {
+		ch := slack.Channel{}
+		ch.ID = channel
+		ch.Name = strings.ToLower(channel)
+		ch.IsIM = strings.HasPrefix(channel, "D")
+		ch.IsChannel = !ch.IsIM
+		s.channels = append(s.channels, ch)
+		return ch
+	}
+	return s.channelByName(strings.TrimPrefix(channel, "#"))
+}
+
+func (s *Server) findChannel(channel string) (slack.Channel, bool) {
+	name := strings.TrimPrefix(channel, "#")
+	for _, ch := range s.channels {
+		if ch.ID == channel || (ch.Name == name && !ch.IsIM) {
+			return ch, true
+		}
+	}
+	return slack.Channel{}, false
+}
+
+func (s *Server) channelByName(name string) slack.Channel {
+	ch := slack.Channel{}
+	ch.ID = fmt.Sprintf("CFAKE%04d", len(s.channels)+1)
+	ch.Name = name
+	ch.IsChannel = true
+	ch.IsMember = true
+	s.channels = append(s.channels, ch)
+	return ch
+}
+
+func (s *Server) findMessage(channelID, ts string) *Message {
+	for _, m := range s.messages {
+		if m.Channel == channelID && m.TS == ts {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *Server) toSlack(m *Message) slack.Message {
+	msg := slack.Message{}
+	msg.Type = slack.TYPE_MESSAGE
+	msg.Channel = m.Channel
+	msg.Timestamp = m.TS
+	msg.ThreadTimestamp = m.ThreadTS
+	msg.User = m.User
+	msg.Text = m.Text
+	msg.Reactions = m.reactions
+	if m.Blocks != nil {
+		json.Unmarshal(m.Blocks, &msg.Blocks)
+	}
+	replies := 0
+	for _, r := range s.messages {
+		if r.ThreadTS == m.TS && r.TS != m.TS {
+			replies++
+		}
+	}
+	msg.ReplyCount = replies
+	return msg
+}
+
+func rawBlocks(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	if !json.Valid([]byte(s)) {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+// inRange は conversations.history の oldest / latest を ts 文字列の比較で判定する。
+func inRange(ts string, req *http.Request) bool {
+	oldest, latest := req.FormValue("oldest"), req.FormValue("latest")
+	if oldest != "" && compareTS(ts, oldest) < 0 {
+		return false
+	}
+	if latest != "" && compareTS(ts, latest) > 0 {
+		return false
+	}
+	return true
+}
+
+func compareTS(a, b string) int {
+	x, _ := strconv.ParseFloat(a, 64)
+	y, _ := strconv.ParseFloat(b, 64)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,116 @@
+package slackfake
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/triax/hub/server"
+	"github.com/triax/hub/server/models"
+)
+
+func newTestClient(t *testing.T) (*Server, *slack.Client) {
+	t.Helper()
+	m := models.Member{}
+	m.Slack.ID = "UFIXTUREQB"
+	m.Slack.Name = "qb"
+	m.Slack.Profile.Title = "QB"
+	users, err := UsersFromMembers([]models.Member{m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := New(DefaultTeam, users)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv("SLACK_API_URL", ts.URL+"/api")
+	return fake, server.NewSlackClient()
+}
+
+func TestServer_PostMessageAndHistory(t *testing.T) {
+	fake, api := newTestClient(t)
+
+	ch, ts, err := api.PostMessage("#general", slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "hello", false, false), nil, nil),
+	))
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	if _, _, err := api.PostMessage(ch, slack.MsgOptionText("reply", false), slack.MsgOptionTS(ts)); err != nil {
+		t.Fatalf("PostMessage(thread): %v", err)
+	}
+	if _, _, _, err := api.UpdateMessage(ch, ts, slack.MsgOptionText("edited", false)); err != nil {
+		t.Fatalf("UpdateMessage: %v", err)
+	}
+
+	msgs := fake.Messages("general")
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].Text != "edited" || !msgs[0].Updated || msgs[0].Blocks == nil {
+		t.Errorf("unexpected first message: %+v", msgs[0])
+	}
+	if msgs[1].ThreadTS != ts {
+		t.Errorf("reply is not in thread: %+v", msgs[1])
+	}
+
+	hist, err := api.GetConversationHistory(&slack.GetConversationHistoryParameters{ChannelID: ch, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetConversationHistory: %v", err)
+	}
+	if len(hist.Messages) != 1 || hist.Messages[0].ReplyCount != 1 {
+		t.Errorf("history should contain only the thread parent: %+v", hist.Messages)
+	}
+
+	if err := fake.AddReaction(ch, ts, "ok", "UFIXTUREQB"); err != nil {
+		t.Fatal(err)
+	}
+	item, err := api.GetReactions(slack.NewRefToMessage(ch, ts), slack.NewGetReactionsParameters())
+	if err != nil {
+		t.Fatalf("GetReactions: %v", err)
+	}
+	if len(item.Reactions) != 1 || item.Reactions[0].Users[0] != "UFIXTUREQB" {
+		t.Errorf("unexpected reactions: %+v", item.Reactions)
+	}
+}
+
+func TestServer_UsersAndConversations(t *testing.T) {
+	fake, api := newTestClient(t)
+
+	team, err := api.GetTeamInfo()
+	if err != nil || team.ID != DefaultTeam.ID {
+		t.Fatalf("GetTeamInfo: %v %+v", err, team)
+	}
+	users, err := api.GetUsers()
+	if err != nil {
+		t.Fatalf("GetUsers: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != "UFIXTUREQB" || users[0].Profile.Title != "QB" {
+		t.Errorf("unexpected users: %+v", users)
+	}
+	if _, err := api.GetUserInfo("UNKNOWN"); err == nil {
+		t.Error("expected user_not_found")
+	}
+
+	dm, _, _, err := api.OpenConversation(&slack.OpenConversationParameters{Users: []string{"UFIXTUREQB"}})
+	if err != nil {
+		t.Fatalf("OpenConversation: %v", err)
+	}
+	if _, _, err := api.PostMessage(dm.ID, slack.MsgOptionText("dm", false)); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Messages(dm.ID); len(got) != 1 || got[0].Text != "dm" {
+		t.Errorf("dm not recorded: %+v", got)
+	}
+
+	chans, _, err := api.GetConversations(&slack.GetConversationsParameters{})
+	if err != nil {
+		t.Fatalf("GetConversations: %v", err)
+	}
+	if len(chans) != 1 || chans[0].Name != "general" {
+		t.Errorf("DM should not be listed: %+v", chans)
+	}
+	info, err := api.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: chans[0].ID})
+	if err != nil || info.Name != "general" {
+		t.Errorf("GetConversationInfo: %v %+v", err, info)
+	}
+}